@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/url"
+	"os"
+	"syscall"
+
+	"github.com/netcracker/qubership-av-scan-service/pkg/clamav"
+	"github.com/netcracker/qubership-av-scan-service/pkg/handlers"
+	"github.com/netcracker/qubership-av-scan-service/pkg/router"
+	"github.com/oklog/run"
+	"github.com/spf13/cobra"
+)
+
+var gatewayCmd = &cobra.Command{
+	Use:   "gateway <upstream-url>",
+	Short: "Run scanning reverse-proxy gateway",
+	Long: "Forwards requests to upstream backend. Multipart and raw uploads of requests matching configured routes " +
+		"are scanned using clamd first, and requests are forwarded only if all files are clean, " +
+		"otherwise error with verdicts is returned. Gateway health and metrics are served at /_av/health and /_av/metrics",
+	Args: cobra.ExactArgs(1),
+	Run:  RunGateway,
+}
+
+func init() {
+	addScanFlags(gatewayCmd)
+	gatewayCmd.Flags().StringSlice("route", router.DefaultGatewayRoutes, "Routes which uploads are scanned, e.g. \"POST /upload\" or \"PUT /files/{name...}\"")
+	gatewayCmd.Flags().Bool("deep", false, "Expand archives and scan their members")
+	gatewayCmd.Flags().Int64("max-body-size", handlers.DefaultMaxGatewayBodySize, "Max size in bytes of request body scanned by the gateway, larger requests are rejected")
+	rootCmd.AddCommand(gatewayCmd)
+}
+
+func RunGateway(cmd *cobra.Command, args []string) {
+	level := &slog.LevelVar{}
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: level}))
+	slog.SetDefault(logger)
+	logger.Info("started antivirus scanning gateway")
+
+	certFile, keyFile := ParseCertsFromArgs(cmd, logger)
+
+	upstream, err := url.Parse(args[0])
+	if err != nil {
+		logger.Error("invalid upstream url", "error", err)
+		os.Exit(1)
+	}
+	routes, err := cmd.Flags().GetStringSlice("route")
+	if err != nil {
+		logger.Error("failed to get routes", "error", err)
+		os.Exit(1)
+	}
+	deep, err := cmd.Flags().GetBool("deep")
+	if err != nil {
+		logger.Error("failed to get deep", "error", err)
+		os.Exit(1)
+	}
+
+	maxBodySize, err := cmd.Flags().GetInt64("max-body-size")
+	if err != nil {
+		logger.Error("failed to get max body size", "error", err)
+		os.Exit(1)
+	}
+
+	opts := []router.Option{
+		router.WithScanOptions(ParseScanOptionsFromArgs(cmd, logger)),
+		router.WithDeepScan(deep),
+		router.WithMaxGatewayBodySize(maxBodySize),
+	}
+	r, err := router.NewGateway(clamav.NewClamD(), logger, upstream, routes, opts...)
+	if err != nil {
+		logger.Error("failed to create gateway", "error", err)
+		os.Exit(1)
+	}
+
+	var gr run.Group
+	gr.Add(run.SignalHandler(context.Background(), os.Interrupt, syscall.SIGINT, syscall.SIGTERM))
+	AddServer(&gr, r, certFile, keyFile, logger)
+
+	if err := gr.Run(); err != nil {
+		logger.Info("terminating...", "reason", err)
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/netcracker/qubership-av-scan-service/pkg/client"
+	"github.com/spf13/cobra"
+)
+
+var healthcheckCmd = &cobra.Command{
+	Use:   "healthcheck",
+	Short: "Check health of the local service",
+	Long: "Queries /health of the service running in the same container, so it can be used as Docker HEALTHCHECK " +
+		"without additional tools. The service is queried over https on 8443 port if --certfile or AV_CERTFILE " +
+		"environment variable is set, and over http on 8080 port otherwise, the same way as the service is started, " +
+		"so TLS should be configured with AV_CERTFILE and AV_KEYFILE to be seen by Docker HEALTHCHECK. " +
+		"Certificate of the service is verified to be the one from --certfile, as it is usually not issued for localhost. " +
+		"Exits with 0 if the service is healthy and with 1 otherwise",
+	Run: RunHealthcheck,
+}
+
+func init() {
+	healthcheckCmd.Flags().String("url", "", "URL of the service, derived from --certfile if empty")
+	healthcheckCmd.Flags().Duration("timeout", 5*time.Second, "Timeout of the health request")
+	healthcheckCmd.Flags().Duration("max-db-age", 0, "Report unhealthy if clamd DB is older, e.g. 48h. DB age is not checked if 0")
+	rootCmd.AddCommand(healthcheckCmd)
+}
+
+func RunHealthcheck(cmd *cobra.Command, _ []string) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+
+	certFile, _ := ParseCertsFromArgs(cmd, logger)
+	serviceURL, err := cmd.Flags().GetString("url")
+	if err != nil {
+		logger.Error("failed to get url", "error", err)
+		os.Exit(1)
+	}
+	timeout, err := cmd.Flags().GetDuration("timeout")
+	if err != nil {
+		logger.Error("failed to get timeout", "error", err)
+		os.Exit(1)
+	}
+	maxDBAge, err := cmd.Flags().GetDuration("max-db-age")
+	if err != nil {
+		logger.Error("failed to get max db age", "error", err)
+		os.Exit(1)
+	}
+
+	opts := []client.Option{
+		client.WithHTTPClient(&http.Client{Timeout: timeout}),
+		// docker retries failed health checks itself
+		client.WithRetries(0, 0),
+	}
+	if serviceURL == "" {
+		serviceURL = "http://localhost:8080"
+		if certFile != "" {
+			serviceURL = "https://localhost:8443"
+		}
+	}
+	if certFile != "" {
+		cfg, err := pinnedTLSConfig(certFile)
+		if err != nil {
+			logger.Error("failed to read cert file", "error", err)
+			os.Exit(1)
+		}
+		opts = append(opts, client.WithTLSConfig(cfg))
+	}
+	c, err := client.New(serviceURL, opts...)
+	if err != nil {
+		logger.Error("failed to create client", "error", err)
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if maxDBAge > 0 {
+		err = c.HealthDatabaseAge(ctx, maxDBAge)
+	} else {
+		err = c.Health(ctx)
+	}
+	if err != nil {
+		logger.Error("service is unhealthy", "error", err)
+		os.Exit(1)
+	}
+}
+
+// pinnedTLSConfig returns TLS config accepting only the server certificate from the given file.
+// Hostname is not verified, since the local service is queried by localhost.
+func pinnedTLSConfig(certFile string) (*tls.Config, error) {
+	data, err := os.ReadFile(certFile)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("no certificate found in %s", certFile)
+	}
+	if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		InsecureSkipVerify: true,
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 || !bytes.Equal(rawCerts[0], block.Bytes) {
+				return fmt.Errorf("server certificate does not match %s", certFile)
+			}
+			return nil
+		},
+	}, nil
+}
@@ -5,16 +5,30 @@ import (
 	"crypto/tls"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
+	"net/netip"
 	"os"
+	"slices"
 	"syscall"
 
+	"github.com/netcracker/qubership-av-scan-service/pkg/archive"
 	"github.com/netcracker/qubership-av-scan-service/pkg/clamav"
+	"github.com/netcracker/qubership-av-scan-service/pkg/fetch"
+	"github.com/netcracker/qubership-av-scan-service/pkg/handlers"
+	"github.com/netcracker/qubership-av-scan-service/pkg/icap"
+	"github.com/netcracker/qubership-av-scan-service/pkg/inspect"
+	"github.com/netcracker/qubership-av-scan-service/pkg/milter"
 	"github.com/netcracker/qubership-av-scan-service/pkg/router"
+	"github.com/netcracker/qubership-av-scan-service/pkg/rpc"
+	"github.com/netcracker/qubership-av-scan-service/pkg/s3"
 	certwatcher "github.com/netcracker/qubership-av-scan-service/pkg/tls"
+	"github.com/netcracker/qubership-av-scan-service/pkg/upload"
 
 	"github.com/oklog/run"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
 )
 
 var rootCmd = &cobra.Command{
@@ -25,8 +39,73 @@ var rootCmd = &cobra.Command{
 }
 
 func init() {
-	rootCmd.PersistentFlags().String("certfile", "", "SSL certificate file name")
-	rootCmd.PersistentFlags().String("keyfile", "", "SSL key file name")
+	rootCmd.PersistentFlags().String("certfile", "", "SSL certificate file name, "+certFileEnv+" environment variable is used if empty")
+	rootCmd.PersistentFlags().String("keyfile", "", "SSL key file name, "+keyFileEnv+" environment variable is used if empty")
+
+	addScanFlags(rootCmd)
+
+	fetchOpts := fetch.DefaultOptions()
+	rootCmd.Flags().Bool("url-scan", false, "Enable scanning of remote content by URL, which is fetched by the service")
+	rootCmd.Flags().Int64("url-max-size", fetchOpts.MaxSize, "Max size in bytes of remote content fetched for URL scan")
+	rootCmd.Flags().Duration("url-timeout", fetchOpts.Timeout, "Timeout of fetching remote content for URL scan")
+	rootCmd.Flags().Int("url-max-redirects", fetchOpts.MaxRedirects, "Max number of redirects followed when fetching remote content for URL scan")
+	rootCmd.Flags().StringSlice("url-allowed-hosts", nil, "Hosts which remote content may be fetched from, e.g. example.com,*.example.com. All hosts are allowed if empty")
+	rootCmd.Flags().StringSlice("url-allowed-networks", nil, "Private networks which remote content may be fetched from, e.g. 10.0.0.0/8. Only public addresses are allowed if empty")
+
+	rootCmd.Flags().Int64("ws-max-file-size", handlers.DefaultMaxWebSocketFileSize, "Max size in bytes of a file uploaded through WebSocket, capped by --max-file-size")
+
+	rootCmd.Flags().String("upload-dir", "", "Directory keeping content of tus resumable uploads. Resumable uploads are disabled if empty")
+	rootCmd.Flags().Int64("upload-max-size", handlers.DefaultMaxUploadSize, "Max size in bytes of a resumable upload, capped by --max-file-size")
+	rootCmd.Flags().Duration("upload-expiration", upload.DefaultExpiration, "Time after which resumable uploads are removed")
+
+	addS3Flags(rootCmd)
+	rootCmd.Flags().Int("s3-max-objects", handlers.DefaultMaxObjects, "Max number of objects scanned by a single prefix request")
+
+	rootCmd.Flags().String("icap-address", "", "Address of ICAP listener, e.g. 0.0.0.0:1344. ICAP is disabled if empty")
+	rootCmd.Flags().String("icap-oversize-policy", string(icap.OversizePolicyBlock), "How to handle ICAP bodies larger than --max-file-size, which are not scanned: block or pass")
+	rootCmd.Flags().String("grpc-address", "", "Address of gRPC listener, e.g. 0.0.0.0:9090. gRPC API is disabled if empty")
+
+	rootCmd.Flags().String("milter-address", "", "Address of milter listener, e.g. 0.0.0.0:7357 or unix:/run/av/milter.sock. Milter is disabled if empty")
+	rootCmd.Flags().String("milter-action", string(milter.ActionReject), "What is done with infected mail: reject, quarantine or accept")
+	rootCmd.Flags().String("milter-reply", milter.DefaultReply, "SMTP reply rejecting infected mail, virus name is appended to it")
+	rootCmd.Flags().Bool("milter-deep", false, "Expand archives attached to mail")
+}
+
+// addScanFlags adds flags configuring scanning of uploaded files
+func addScanFlags(cmd *cobra.Command) {
+	limits := archive.DefaultLimits()
+	cmd.Flags().Int("archive-max-depth", limits.MaxDepth, "Max nesting level of archives expanded in deep scan mode")
+	cmd.Flags().Int("archive-max-members", limits.MaxMembers, "Max number of archive members scanned in deep scan mode")
+	cmd.Flags().Int64("archive-max-size", limits.MaxSize, "Max total expanded size of archive in bytes in deep scan mode")
+
+	imageLimits := handlers.DefaultImageLimits()
+	cmd.Flags().Int("image-max-members", imageLimits.MaxMembers, "Max number of layer files scanned in container image")
+	cmd.Flags().Int64("image-max-size", imageLimits.MaxSize, "Max size in bytes of container image tarball and of total size of its layer files")
+
+	bombLimits := archive.DefaultBombLimits()
+	cmd.Flags().String("archive-bomb-policy", string(handlers.BombPolicyReject), "How to handle archive bombs in deep scan mode: reject or flag. Flagged archives have archive_bomb verdict and are not reported as infected")
+	cmd.Flags().Float64("archive-bomb-max-ratio", bombLimits.MaxRatio, "Max compression ratio of archive not considered as bomb")
+	cmd.Flags().Int("archive-bomb-max-entries", bombLimits.MaxEntries, "Max number of entries in archive not considered as bomb")
+	cmd.Flags().Int("archive-bomb-max-depth", bombLimits.MaxDepth, "Max nesting depth of archive not considered as bomb")
+	cmd.Flags().Int64("archive-bomb-max-size", bombLimits.MaxExpandedSize, "Max expanded size in bytes of archive not considered as bomb")
+
+	cmd.Flags().String("encrypted-policy", string(handlers.EncryptedPolicyFlag), "How to handle encrypted archives and documents: flag or infected")
+
+	cmd.Flags().StringSlice("allowed-types", nil, "Accepted file MIME types, e.g. image/*,application/pdf. All types are accepted if empty")
+	cmd.Flags().StringSlice("denied-types", nil, "Rejected file MIME types, e.g. application/x-elf. "+
+		"Files rejected by allowed or denied types have policy_blocked verdict and are not reported as infected")
+
+	cmd.Flags().StringSlice("blocked-findings", nil, "Types of findings which block the file with policy_blocked verdict, e.g. vba_project,xlm_macro,dde")
+
+	cmd.Flags().Int64("max-decoded-body-size", handlers.DefaultMaxDecodedBodySize, "Max size in bytes of compressed request body after decompression")
+	cmd.Flags().Int64("max-file-size", handlers.DefaultMaxFileSize, "Max size in bytes of a single uploaded file, should not exceed StreamMaxLength of clamd")
+}
+
+// addS3Flags adds flags configuring connection to S3-compatible storage,
+// credentials are taken from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment variables
+func addS3Flags(cmd *cobra.Command) {
+	cmd.Flags().String("s3-endpoint", "", "URL of S3-compatible storage, e.g. http://minio:9000. Object storage scanning is disabled if empty")
+	cmd.Flags().String("s3-region", s3.DefaultRegion, "Region of S3-compatible storage")
 }
 
 func main() {
@@ -51,6 +130,13 @@ func CheckFile(filePath string) error {
 	return nil
 }
 
+// Environment variables used if certfile and keyfile cli arguments are not set,
+// so healthcheck command run by Docker finds the same certificate as the service
+const (
+	certFileEnv = "AV_CERTFILE"
+	keyFileEnv  = "AV_KEYFILE"
+)
+
 // ParseCertsFromArgs parses keyfile and certfile cli arguments, verifies them and returns
 func ParseCertsFromArgs(cmd *cobra.Command, logger *slog.Logger) (string, string) {
 	certFile, err := cmd.Flags().GetString("certfile")
@@ -58,12 +144,18 @@ func ParseCertsFromArgs(cmd *cobra.Command, logger *slog.Logger) (string, string
 		logger.Error("failed to get cert file", "error", err)
 		os.Exit(1)
 	}
+	if certFile == "" {
+		certFile = os.Getenv(certFileEnv)
+	}
 
 	keyFile, err := cmd.Flags().GetString("keyfile")
 	if err != nil {
 		logger.Error("failed to get key file", "error", err)
 		os.Exit(1)
 	}
+	if keyFile == "" {
+		keyFile = os.Getenv(keyFileEnv)
+	}
 
 	if certFile != "" && keyFile != "" {
 		if err := CheckFile(certFile); err != nil {
@@ -81,6 +173,276 @@ func ParseCertsFromArgs(cmd *cobra.Command, logger *slog.Logger) (string, string
 	return certFile, keyFile
 }
 
+// ParseArchiveLimitsFromArgs parses archive expansion limits from cli arguments
+func ParseArchiveLimitsFromArgs(cmd *cobra.Command, logger *slog.Logger) archive.Limits {
+	var limits archive.Limits
+	var err error
+	if limits.MaxDepth, err = cmd.Flags().GetInt("archive-max-depth"); err != nil {
+		logger.Error("failed to get archive max depth", "error", err)
+		os.Exit(1)
+	}
+	if limits.MaxMembers, err = cmd.Flags().GetInt("archive-max-members"); err != nil {
+		logger.Error("failed to get archive max members", "error", err)
+		os.Exit(1)
+	}
+	if limits.MaxSize, err = cmd.Flags().GetInt64("archive-max-size"); err != nil {
+		logger.Error("failed to get archive max size", "error", err)
+		os.Exit(1)
+	}
+	return limits
+}
+
+// ParseImageLimitsFromArgs parses container image scan limits from cli arguments
+func ParseImageLimitsFromArgs(cmd *cobra.Command, logger *slog.Logger) archive.Limits {
+	var limits archive.Limits
+	var err error
+	if limits.MaxMembers, err = cmd.Flags().GetInt("image-max-members"); err != nil {
+		logger.Error("failed to get image max members", "error", err)
+		os.Exit(1)
+	}
+	if limits.MaxSize, err = cmd.Flags().GetInt64("image-max-size"); err != nil {
+		logger.Error("failed to get image max size", "error", err)
+		os.Exit(1)
+	}
+	return limits
+}
+
+// ParseBombProtectionFromArgs parses archive bomb detection limits and policy from cli arguments
+func ParseBombProtectionFromArgs(cmd *cobra.Command, logger *slog.Logger) (archive.BombLimits, handlers.BombPolicy) {
+	var limits archive.BombLimits
+	var err error
+	if limits.MaxRatio, err = cmd.Flags().GetFloat64("archive-bomb-max-ratio"); err != nil {
+		logger.Error("failed to get archive bomb max ratio", "error", err)
+		os.Exit(1)
+	}
+	if limits.MaxEntries, err = cmd.Flags().GetInt("archive-bomb-max-entries"); err != nil {
+		logger.Error("failed to get archive bomb max entries", "error", err)
+		os.Exit(1)
+	}
+	if limits.MaxDepth, err = cmd.Flags().GetInt("archive-bomb-max-depth"); err != nil {
+		logger.Error("failed to get archive bomb max depth", "error", err)
+		os.Exit(1)
+	}
+	if limits.MaxExpandedSize, err = cmd.Flags().GetInt64("archive-bomb-max-size"); err != nil {
+		logger.Error("failed to get archive bomb max size", "error", err)
+		os.Exit(1)
+	}
+
+	policy, err := cmd.Flags().GetString("archive-bomb-policy")
+	if err != nil {
+		logger.Error("failed to get archive bomb policy", "error", err)
+		os.Exit(1)
+	}
+	switch handlers.BombPolicy(policy) {
+	case handlers.BombPolicyReject, handlers.BombPolicyFlag:
+	default:
+		logger.Error("unsupported archive bomb policy", "policy", policy)
+		os.Exit(1)
+	}
+	return limits, handlers.BombPolicy(policy)
+}
+
+// ParseEncryptedPolicyFromArgs parses encrypted files handling policy from cli arguments
+func ParseEncryptedPolicyFromArgs(cmd *cobra.Command, logger *slog.Logger) handlers.EncryptedPolicy {
+	policy, err := cmd.Flags().GetString("encrypted-policy")
+	if err != nil {
+		logger.Error("failed to get encrypted policy", "error", err)
+		os.Exit(1)
+	}
+	switch handlers.EncryptedPolicy(policy) {
+	case handlers.EncryptedPolicyFlag, handlers.EncryptedPolicyInfected:
+	default:
+		logger.Error("unsupported encrypted policy", "policy", policy)
+		os.Exit(1)
+	}
+	return handlers.EncryptedPolicy(policy)
+}
+
+// ParseTypePolicyFromArgs parses allowed and denied file types from cli arguments
+func ParseTypePolicyFromArgs(cmd *cobra.Command, logger *slog.Logger) handlers.TypePolicy {
+	allowed, err := cmd.Flags().GetStringSlice("allowed-types")
+	if err != nil {
+		logger.Error("failed to get allowed types", "error", err)
+		os.Exit(1)
+	}
+	denied, err := cmd.Flags().GetStringSlice("denied-types")
+	if err != nil {
+		logger.Error("failed to get denied types", "error", err)
+		os.Exit(1)
+	}
+	return handlers.TypePolicy{Allowed: allowed, Denied: denied}
+}
+
+// ParseFindingPolicyFromArgs parses types of blocking findings from cli arguments
+func ParseFindingPolicyFromArgs(cmd *cobra.Command, logger *slog.Logger) handlers.FindingPolicy {
+	blocked, err := cmd.Flags().GetStringSlice("blocked-findings")
+	if err != nil {
+		logger.Error("failed to get blocked findings", "error", err)
+		os.Exit(1)
+	}
+	var policy handlers.FindingPolicy
+	for _, b := range blocked {
+		if !slices.Contains(inspect.FindingTypes, inspect.FindingType(b)) {
+			logger.Error("unsupported finding type", "type", b, "supported", inspect.FindingTypes)
+			os.Exit(1)
+		}
+		policy.Blocked = append(policy.Blocked, inspect.FindingType(b))
+	}
+	return policy
+}
+
+// ParseMaxDecodedBodySizeFromArgs parses limit of decompressed request body size from cli arguments
+func ParseMaxDecodedBodySizeFromArgs(cmd *cobra.Command, logger *slog.Logger) int64 {
+	size, err := cmd.Flags().GetInt64("max-decoded-body-size")
+	if err != nil {
+		logger.Error("failed to get max decoded body size", "error", err)
+		os.Exit(1)
+	}
+	return size
+}
+
+// ParseMaxFileSizeFromArgs parses limit of a single uploaded file size from cli arguments
+func ParseMaxFileSizeFromArgs(cmd *cobra.Command, logger *slog.Logger) int64 {
+	size, err := cmd.Flags().GetInt64("max-file-size")
+	if err != nil {
+		logger.Error("failed to get max file size", "error", err)
+		os.Exit(1)
+	}
+	return size
+}
+
+// ParseScanOptionsFromArgs parses all flags added by addScanFlags to scan options
+func ParseScanOptionsFromArgs(cmd *cobra.Command, logger *slog.Logger) handlers.ScanOptions {
+	opts := handlers.ScanOptions{
+		ArchiveLimits:      ParseArchiveLimitsFromArgs(cmd, logger),
+		ImageLimits:        ParseImageLimitsFromArgs(cmd, logger),
+		EncryptedPolicy:    ParseEncryptedPolicyFromArgs(cmd, logger),
+		TypePolicy:         ParseTypePolicyFromArgs(cmd, logger),
+		FindingPolicy:      ParseFindingPolicyFromArgs(cmd, logger),
+		MaxDecodedBodySize: ParseMaxDecodedBodySizeFromArgs(cmd, logger),
+		MaxFileSize:        ParseMaxFileSizeFromArgs(cmd, logger),
+	}
+	opts.BombLimits, opts.BombPolicy = ParseBombProtectionFromArgs(cmd, logger)
+	return opts
+}
+
+// ParseMilterOptionsFromArgs parses handling of infected mail from cli arguments
+func ParseMilterOptionsFromArgs(cmd *cobra.Command, logger *slog.Logger) milter.Options {
+	action, err := cmd.Flags().GetString("milter-action")
+	if err != nil {
+		logger.Error("failed to get milter action", "error", err)
+		os.Exit(1)
+	}
+	opts := milter.Options{}
+	if opts.Action, err = milter.ParseAction(action); err != nil {
+		logger.Error("failed to parse milter action", "error", err)
+		os.Exit(1)
+	}
+	if opts.Reply, err = cmd.Flags().GetString("milter-reply"); err != nil {
+		logger.Error("failed to get milter reply", "error", err)
+		os.Exit(1)
+	}
+	if opts.Deep, err = cmd.Flags().GetBool("milter-deep"); err != nil {
+		logger.Error("failed to get milter deep", "error", err)
+		os.Exit(1)
+	}
+	return opts
+}
+
+// ParseICAPOptionsFromArgs parses handling of ICAP bodies from cli arguments
+func ParseICAPOptionsFromArgs(cmd *cobra.Command, logger *slog.Logger) icap.Options {
+	policy, err := cmd.Flags().GetString("icap-oversize-policy")
+	if err != nil {
+		logger.Error("failed to get icap oversize policy", "error", err)
+		os.Exit(1)
+	}
+	opts := icap.Options{}
+	if opts.OversizePolicy, err = icap.ParseOversizePolicy(policy); err != nil {
+		logger.Error("failed to parse icap oversize policy", "error", err)
+		os.Exit(1)
+	}
+	return opts
+}
+
+// ParseFetchOptionsFromArgs parses limits of fetching remote content from cli arguments
+func ParseFetchOptionsFromArgs(cmd *cobra.Command, logger *slog.Logger) fetch.Options {
+	var opts fetch.Options
+	var err error
+	if opts.MaxSize, err = cmd.Flags().GetInt64("url-max-size"); err != nil {
+		logger.Error("failed to get url max size", "error", err)
+		os.Exit(1)
+	}
+	if opts.Timeout, err = cmd.Flags().GetDuration("url-timeout"); err != nil {
+		logger.Error("failed to get url timeout", "error", err)
+		os.Exit(1)
+	}
+	if opts.MaxRedirects, err = cmd.Flags().GetInt("url-max-redirects"); err != nil {
+		logger.Error("failed to get url max redirects", "error", err)
+		os.Exit(1)
+	}
+	if opts.AllowedHosts, err = cmd.Flags().GetStringSlice("url-allowed-hosts"); err != nil {
+		logger.Error("failed to get url allowed hosts", "error", err)
+		os.Exit(1)
+	}
+	networks, err := cmd.Flags().GetStringSlice("url-allowed-networks")
+	if err != nil {
+		logger.Error("failed to get url allowed networks", "error", err)
+		os.Exit(1)
+	}
+	for _, n := range networks {
+		prefix, err := netip.ParsePrefix(n)
+		if err != nil {
+			logger.Error("invalid url allowed network", "network", n, "error", err)
+			os.Exit(1)
+		}
+		opts.AllowedNetworks = append(opts.AllowedNetworks, prefix)
+	}
+	return opts
+}
+
+// ParseS3ConfigFromArgs parses S3-compatible storage connection settings from cli arguments and environment
+func ParseS3ConfigFromArgs(cmd *cobra.Command, logger *slog.Logger) s3.Config {
+	cfg := s3.Config{
+		AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	var err error
+	if cfg.Endpoint, err = cmd.Flags().GetString("s3-endpoint"); err != nil {
+		logger.Error("failed to get s3 endpoint", "error", err)
+		os.Exit(1)
+	}
+	if cfg.Region, err = cmd.Flags().GetString("s3-region"); err != nil {
+		logger.Error("failed to get s3 region", "error", err)
+		os.Exit(1)
+	}
+	return cfg
+}
+
+// ParseUploadStoreFromArgs creates store of resumable uploads configured by cli arguments,
+// nil is returned if resumable uploads are disabled
+func ParseUploadStoreFromArgs(cmd *cobra.Command, logger *slog.Logger) *upload.Store {
+	dir, err := cmd.Flags().GetString("upload-dir")
+	if err != nil {
+		logger.Error("failed to get upload dir", "error", err)
+		os.Exit(1)
+	}
+	if dir == "" {
+		return nil
+	}
+	expiration, err := cmd.Flags().GetDuration("upload-expiration")
+	if err != nil {
+		logger.Error("failed to get upload expiration", "error", err)
+		os.Exit(1)
+	}
+	store, err := upload.New(dir, expiration)
+	if err != nil {
+		logger.Error("failed to create upload store", "error", err)
+		os.Exit(1)
+	}
+	return store
+}
+
 // ShutdownServer i,plement graceful shutdown for http server
 func ShutdownServer(srv *http.Server, logger *slog.Logger, reason error) {
 	logger.Warn("stopping antivirus scanning service", "reason", reason)
@@ -92,26 +454,17 @@ func ShutdownServer(srv *http.Server, logger *slog.Logger, reason error) {
 	logger.Info("stopped antivirus scanning service")
 }
 
-func Run(cmd *cobra.Command, args []string) {
-	level := &slog.LevelVar{}
-	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: level}))
-	slog.SetDefault(logger)
-	logger.Info("started antivirus scanning service")
-
-	certFile, keyFile := ParseCertsFromArgs(cmd, logger)
+// AddServer adds http server serving given handler to the group,
+// server is started with TLS on 8443 port if certificate is provided, and without TLS on 8080 port otherwise
+func AddServer(gr *run.Group, handler http.Handler, certFile string, keyFile string, logger *slog.Logger) {
 	tlsEnabled := certFile != ""
-
-	// run http server
-	r := router.NewRouter(clamav.NewClamD(), logger)
-	var gr run.Group
-	gr.Add(run.SignalHandler(context.Background(), os.Interrupt, syscall.SIGINT, syscall.SIGTERM))
 	if tlsEnabled {
 		watcher, err := certwatcher.New(certFile, keyFile, logger)
 		if err != nil {
 			logger.Error("error creating certificate watcher", "error", err)
 			os.Exit(1)
 		}
-		srv := &http.Server{Handler: r, Addr: "0.0.0.0:8443"}
+		srv := &http.Server{Handler: handler, Addr: "0.0.0.0:8443"}
 		srv.TLSConfig = &tls.Config{
 			GetCertificate: watcher.GetCertificate,
 		}
@@ -134,7 +487,7 @@ func Run(cmd *cobra.Command, args []string) {
 			ShutdownServer(srv, logger, err)
 		})
 	} else {
-		srv := &http.Server{Handler: r, Addr: "0.0.0.0:8080"}
+		srv := &http.Server{Handler: handler, Addr: "0.0.0.0:8080"}
 		gr.Add(func() error {
 			err := srv.ListenAndServe()
 			if err == http.ErrServerClosed {
@@ -148,6 +501,154 @@ func Run(cmd *cobra.Command, args []string) {
 			ShutdownServer(srv, logger, err)
 		})
 	}
+}
+
+func AddICAPServer(gr *run.Group, srv *icap.Server, addr string, logger *slog.Logger) {
+	gr.Add(func() error {
+		logger.Info("serving icap", "address", addr)
+		err := srv.ListenAndServe(addr)
+		if err == icap.ErrServerClosed {
+			logger.Warn("icap server closed")
+		} else {
+			logger.Error("failed to serve icap", "error", err)
+			os.Exit(1)
+		}
+		return err
+	}, func(err error) {
+		if err := srv.Close(); err != nil {
+			logger.Error("failed to close icap server", "error", err)
+		}
+	})
+}
+
+func AddGRPCServer(gr *run.Group, srv *grpc.Server, addr string, logger *slog.Logger) {
+	gr.Add(func() error {
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			logger.Error("failed to listen grpc", "error", err)
+			os.Exit(1)
+		}
+		logger.Info("serving grpc", "address", addr)
+		err = srv.Serve(l)
+		if err == nil || err == grpc.ErrServerStopped {
+			logger.Warn("grpc server closed")
+		} else {
+			logger.Error("failed to serve grpc", "error", err)
+			os.Exit(1)
+		}
+		return err
+	}, func(err error) {
+		srv.GracefulStop()
+	})
+}
+
+func AddMilterServer(gr *run.Group, srv *milter.Server, addr string, logger *slog.Logger) {
+	gr.Add(func() error {
+		logger.Info("serving milter", "address", addr)
+		err := srv.ListenAndServe(addr)
+		if err == milter.ErrServerClosed {
+			logger.Warn("milter server closed")
+		} else {
+			logger.Error("failed to serve milter", "error", err)
+			os.Exit(1)
+		}
+		return err
+	}, func(err error) {
+		if err := srv.Close(); err != nil {
+			logger.Error("failed to close milter server", "error", err)
+		}
+	})
+}
+
+func Run(cmd *cobra.Command, args []string) {
+	level := &slog.LevelVar{}
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: level}))
+	slog.SetDefault(logger)
+	logger.Info("started antivirus scanning service")
+
+	certFile, keyFile := ParseCertsFromArgs(cmd, logger)
+
+	scanOpts := ParseScanOptionsFromArgs(cmd, logger)
+	maxWebSocketFileSize, err := cmd.Flags().GetInt64("ws-max-file-size")
+	if err != nil {
+		logger.Error("failed to get websocket max file size", "error", err)
+		os.Exit(1)
+	}
+	opts := []router.Option{
+		router.WithScanOptions(scanOpts),
+		router.WithMaxWebSocketFileSize(maxWebSocketFileSize),
+	}
+	urlScan, err := cmd.Flags().GetBool("url-scan")
+	if err != nil {
+		logger.Error("failed to get url scan", "error", err)
+		os.Exit(1)
+	}
+	if urlScan {
+		opts = append(opts, router.WithFetchOptions(ParseFetchOptionsFromArgs(cmd, logger)))
+	}
+	if s3Config := ParseS3ConfigFromArgs(cmd, logger); s3Config.Endpoint != "" {
+		client, err := s3.New(s3Config)
+		if err != nil {
+			logger.Error("failed to create s3 client", "error", err)
+			os.Exit(1)
+		}
+		maxObjects, err := cmd.Flags().GetInt("s3-max-objects")
+		if err != nil {
+			logger.Error("failed to get s3 max objects", "error", err)
+			os.Exit(1)
+		}
+		opts = append(opts, router.WithObjectStorage(client, maxObjects))
+	}
+
+	if store := ParseUploadStoreFromArgs(cmd, logger); store != nil {
+		maxUploadSize, err := cmd.Flags().GetInt64("upload-max-size")
+		if err != nil {
+			logger.Error("failed to get upload max size", "error", err)
+			os.Exit(1)
+		}
+		opts = append(opts, router.WithUploadStore(store, maxUploadSize))
+	}
+
+	icapAddress, err := cmd.Flags().GetString("icap-address")
+	if err != nil {
+		logger.Error("failed to get icap address", "error", err)
+		os.Exit(1)
+	}
+	grpcAddress, err := cmd.Flags().GetString("grpc-address")
+	if err != nil {
+		logger.Error("failed to get grpc address", "error", err)
+		os.Exit(1)
+	}
+	milterAddress, err := cmd.Flags().GetString("milter-address")
+	if err != nil {
+		logger.Error("failed to get milter address", "error", err)
+		os.Exit(1)
+	}
+
+	clamd := clamav.NewClamD()
+	// ICAP, gRPC and milter servers share metrics registry with http server, so all metrics are exposed at /metrics
+	registry := prometheus.NewRegistry()
+	opts = append(opts, router.WithRegistry(registry))
+
+	// run http server
+	r := router.NewRouter(clamd, logger, opts...)
+	var gr run.Group
+	gr.Add(run.SignalHandler(context.Background(), os.Interrupt, syscall.SIGINT, syscall.SIGTERM))
+	AddServer(&gr, r, certFile, keyFile, logger)
+	if icapAddress != "" {
+		AddICAPServer(&gr, icap.NewServer(clamd, registry, logger, scanOpts, ParseICAPOptionsFromArgs(cmd, logger)), icapAddress, logger)
+	}
+	if grpcAddress != "" {
+		AddGRPCServer(&gr, rpc.NewServer(clamd, registry, logger, scanOpts), grpcAddress, logger)
+	}
+	if milterAddress != "" {
+		srv, err := milter.NewServer(clamd, registry, logger, scanOpts, ParseMilterOptionsFromArgs(cmd, logger))
+		if err != nil {
+			logger.Error("failed to create milter server", "error", err)
+			os.Exit(1)
+		}
+		AddMilterServer(&gr, srv, milterAddress, logger)
+	}
 
 	if err := gr.Run(); err != nil {
 		logger.Info("terminating...", "reason", err)
@@ -0,0 +1,244 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/netcracker/qubership-av-scan-service/pkg/spool"
+)
+
+// Format is a type of archive detected by its magic bytes
+type Format string
+
+const (
+	// FormatNone means that content is not a supported archive
+	FormatNone Format = ""
+	FormatZip  Format = "zip"
+	FormatTar  Format = "tar"
+	FormatGzip Format = "gzip"
+)
+
+// HeaderSize is the number of leading bytes which is enough to detect any supported format
+const HeaderSize = 512
+
+// Limits restricts archive expansion to protect service from resource exhaustion
+type Limits struct {
+	// MaxDepth is the maximum nesting level of archives which are expanded,
+	// archives located deeper are scanned as a whole
+	MaxDepth int
+	// MaxMembers is the maximum total number of members in expanded archive tree
+	MaxMembers int
+	// MaxSize is the maximum total size in bytes of all expanded members,
+	// it also limits the size of a top-level archive
+	MaxSize int64
+}
+
+// DefaultLimits returns limits used when no explicit limits are configured
+func DefaultLimits() Limits {
+	return Limits{
+		MaxDepth:   3,
+		MaxMembers: 1000,
+		MaxSize:    100 << 20,
+	}
+}
+
+// LimitError is returned when archive expansion exceeds configured Limits
+type LimitError struct {
+	msg string
+}
+
+func (e *LimitError) Error() string {
+	return e.msg
+}
+
+// Detect returns archive format of the content starting with given header
+func Detect(header []byte) Format {
+	switch {
+	case bytes.HasPrefix(header, []byte("PK\x03\x04")), bytes.HasPrefix(header, []byte("PK\x05\x06")):
+		return FormatZip
+	case bytes.HasPrefix(header, []byte{0x1f, 0x8b}):
+		return FormatGzip
+	case len(header) >= 262 && bytes.Equal(header[257:262], []byte("ustar")):
+		return FormatTar
+	}
+	return FormatNone
+}
+
+// MemberFunc is called for each regular file found in archive
+type MemberFunc func(name string, r io.Reader) error
+
+// Walk calls fn for each regular file member of given archive content.
+// Gzip-compressed tar is walked as a single tar archive.
+func Walk(format Format, name string, r io.ReaderAt, size int64, fn MemberFunc) error {
+	switch format {
+	case FormatZip:
+		return walkZip(r, size, fn)
+	case FormatTar:
+		return walkTar(io.NewSectionReader(r, 0, size), fn)
+	case FormatGzip:
+		return walkGzip(name, io.NewSectionReader(r, 0, size), fn)
+	}
+	return fmt.Errorf("unsupported archive format: %q", format)
+}
+
+func walkZip(r io.ReaderAt, size int64, fn MemberFunc) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return fmt.Errorf("failed to read zip: %w", err)
+	}
+	for _, f := range zr.File {
+		// encrypted members can not be decompressed
+		if f.FileInfo().IsDir() || isEncrypted(f) {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("failed to open zip member %s: %w", f.Name, err)
+		}
+		err = fn(f.Name, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isEncrypted checks encryption bit of zip member general purpose flags
+func isEncrypted(f *zip.File) bool {
+	return f.Flags&0x1 != 0
+}
+
+func walkTar(r io.Reader, fn MemberFunc) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read tar: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := fn(hdr.Name, tr); err != nil {
+			return err
+		}
+	}
+}
+
+func walkGzip(name string, r io.Reader, fn MemberFunc) error {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("failed to read gzip: %w", err)
+	}
+	defer gr.Close()
+
+	// peek decompressed header to walk tar.gz as a plain tar
+	br := bufio.NewReaderSize(gr, HeaderSize)
+	header, err := br.Peek(HeaderSize)
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("failed to read gzip: %w", err)
+	}
+	if Detect(header) == FormatTar {
+		return walkTar(br, fn)
+	}
+
+	memberName := gr.Name
+	if memberName == "" {
+		memberName = gunzipName(name)
+	}
+	return fn(memberName, br)
+}
+
+// gunzipName guesses the name of decompressed file from the gzip file name
+func gunzipName(name string) string {
+	base := path.Base(name)
+	switch {
+	case strings.HasSuffix(base, ".tgz"):
+		return strings.TrimSuffix(base, ".tgz") + ".tar"
+	case strings.HasSuffix(base, ".gz"):
+		return strings.TrimSuffix(base, ".gz")
+	}
+	return base
+}
+
+// ReadAll reads r fully, failing with LimitError if more than limit bytes are read
+func ReadAll(r io.Reader, limit int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, &LimitError{fmt.Sprintf("content size exceeds %d bytes", limit)}
+	}
+	return data, nil
+}
+
+// Spool spools r fully like spool.New, failing with LimitError if more than limit bytes are read
+func Spool(r io.Reader, limit int64, memLimit int64) (*spool.File, error) {
+	f, err := spool.New(io.LimitReader(r, limit+1), memLimit)
+	if err != nil {
+		return nil, err
+	}
+	if f.Size() > limit {
+		f.Close()
+		return nil, &LimitError{fmt.Sprintf("content size exceeds %d bytes", limit)}
+	}
+	return f, nil
+}
+
+// Budget tracks members count and size consumed during expansion of a single archive tree
+type Budget struct {
+	limits  Limits
+	members int
+	size    int64
+}
+
+func NewBudget(limits Limits) *Budget {
+	return &Budget{limits: limits}
+}
+
+// Member accounts a new member and returns an error if members limit is exceeded
+func (b *Budget) Member() error {
+	b.members++
+	if b.members > b.limits.MaxMembers {
+		return &LimitError{fmt.Sprintf("archive contains more than %d members", b.limits.MaxMembers)}
+	}
+	return nil
+}
+
+// Read reads member content fully, accounting its size
+// and returning an error if total size limit is exceeded
+func (b *Budget) Read(r io.Reader) ([]byte, error) {
+	data, err := ReadAll(r, b.limits.MaxSize-b.size)
+	if _, ok := err.(*LimitError); ok {
+		return nil, &LimitError{fmt.Sprintf("archive expanded size exceeds %d bytes", b.limits.MaxSize)}
+	}
+	if err != nil {
+		return nil, err
+	}
+	b.size += int64(len(data))
+	return data, nil
+}
+
+// Spool spools member content like Read, keeping up to memLimit bytes in memory
+func (b *Budget) Spool(r io.Reader, memLimit int64) (*spool.File, error) {
+	f, err := Spool(r, b.limits.MaxSize-b.size, memLimit)
+	if _, ok := err.(*LimitError); ok {
+		return nil, &LimitError{fmt.Sprintf("archive expanded size exceeds %d bytes", b.limits.MaxSize)}
+	}
+	if err != nil {
+		return nil, err
+	}
+	b.size += f.Size()
+	return f, nil
+}
@@ -0,0 +1,311 @@
+package archive_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/netcracker/qubership-av-scan-service/pkg/archive"
+)
+
+func zipArchive(files map[string][]byte) []byte {
+	buffer := &bytes.Buffer{}
+	zw := zip.NewWriter(buffer)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			panic(err)
+		}
+		if _, err := w.Write(content); err != nil {
+			panic(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		panic(err)
+	}
+	return buffer.Bytes()
+}
+
+// nestedZipArchive builds zip archive nested depth times, the innermost one contains a single text file
+func nestedZipArchive(depth int) []byte {
+	content := zipArchive(map[string][]byte{"a.txt": []byte("safe content")})
+	for range depth - 1 {
+		content = zipArchive(map[string][]byte{"inner.zip": content})
+	}
+	return content
+}
+
+func isLimitError(err error) bool {
+	_, ok := err.(*archive.LimitError)
+	return ok
+}
+
+func TestReadAll(t *testing.T) {
+	tests := []struct {
+		name        string
+		size        int
+		limit       int64
+		expectLimit bool
+	}{
+		{name: "below limit", size: 9, limit: 10},
+		{name: "exactly at limit", size: 10, limit: 10},
+		{name: "one byte over limit", size: 11, limit: 10, expectLimit: true},
+		{name: "empty with zero limit", size: 0, limit: 0},
+		{name: "one byte with zero limit", size: 1, limit: 0, expectLimit: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := archive.ReadAll(strings.NewReader(strings.Repeat("x", test.size)), test.limit)
+			if test.expectLimit {
+				if !isLimitError(err) {
+					t.Fatalf("expected limit error, but got: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, but got: %s", err)
+			}
+			if len(data) != test.size {
+				t.Fatalf("expected %d bytes to be read, but got: %d", test.size, len(data))
+			}
+		})
+	}
+}
+
+func TestSpool(t *testing.T) {
+	tests := []struct {
+		name        string
+		size        int
+		limit       int64
+		memLimit    int64
+		expectLimit bool
+	}{
+		{name: "exactly at limit in memory", size: 10, limit: 10, memLimit: 10},
+		{name: "one byte over limit in memory", size: 11, limit: 10, memLimit: 20, expectLimit: true},
+		{name: "exactly at limit on disk", size: 10, limit: 10, memLimit: 4},
+		{name: "one byte over limit on disk", size: 11, limit: 10, memLimit: 4, expectLimit: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			content := strings.Repeat("x", test.size)
+			f, err := archive.Spool(strings.NewReader(content), test.limit, test.memLimit)
+			if test.expectLimit {
+				if !isLimitError(err) {
+					t.Fatalf("expected limit error, but got: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, but got: %s", err)
+			}
+			defer f.Close()
+			data, err := io.ReadAll(f.Reader())
+			if err != nil {
+				t.Fatalf("expected to read spooled content, but failed: %s", err)
+			}
+			if f.Size() != int64(test.size) || string(data) != content {
+				t.Fatalf("expected spooled content to be %d bytes, but got: %d", test.size, len(data))
+			}
+		})
+	}
+}
+
+func TestBudgetMembers(t *testing.T) {
+	budget := archive.NewBudget(archive.Limits{MaxMembers: 3, MaxSize: 100})
+	for i := range 3 {
+		if err := budget.Member(); err != nil {
+			t.Fatalf("expected member %d to be within limit, but got: %s", i+1, err)
+		}
+	}
+	if err := budget.Member(); !isLimitError(err) {
+		t.Fatalf("expected limit error for member over limit, but got: %v", err)
+	}
+}
+
+func TestBudgetSize(t *testing.T) {
+	tests := []struct {
+		name        string
+		sizes       []int
+		expectLimit bool
+	}{
+		{name: "single member exactly at limit", sizes: []int{10}},
+		{name: "single member one byte over limit", sizes: []int{11}, expectLimit: true},
+		{name: "members exactly at limit", sizes: []int{4, 6}},
+		{name: "members one byte over limit", sizes: []int{4, 7}, expectLimit: true},
+		{name: "empty member after limit is reached", sizes: []int{10, 0}},
+		{name: "one byte after limit is reached", sizes: []int{10, 1}, expectLimit: true},
+	}
+
+	read := map[string]func(b *archive.Budget, r io.Reader) (int64, error){
+		"read": func(b *archive.Budget, r io.Reader) (int64, error) {
+			data, err := b.Read(r)
+			return int64(len(data)), err
+		},
+		"spool": func(b *archive.Budget, r io.Reader) (int64, error) {
+			f, err := b.Spool(r, 4)
+			if err != nil {
+				return 0, err
+			}
+			defer f.Close()
+			return f.Size(), nil
+		},
+	}
+
+	for method, fn := range read {
+		for _, test := range tests {
+			t.Run(method+" "+test.name, func(t *testing.T) {
+				budget := archive.NewBudget(archive.Limits{MaxMembers: 10, MaxSize: 10})
+				var err error
+				for _, size := range test.sizes {
+					var n int64
+					if n, err = fn(budget, strings.NewReader(strings.Repeat("x", size))); err != nil {
+						break
+					}
+					if n != int64(size) {
+						t.Fatalf("expected %d bytes to be read, but got: %d", size, n)
+					}
+				}
+				if test.expectLimit != isLimitError(err) {
+					t.Fatalf("expected limit error to be %t, but got: %v", test.expectLimit, err)
+				}
+				if !test.expectLimit && err != nil {
+					t.Fatalf("expected no error, but got: %s", err)
+				}
+			})
+		}
+	}
+}
+
+func TestInspect(t *testing.T) {
+	limits := archive.BombLimits{MaxRatio: 100, MaxEntries: 10, MaxDepth: 3, MaxExpandedSize: 8 << 20}
+	random := make([]byte, 2<<20)
+	for i := range random {
+		random[i] = byte(i*7919 + i/251)
+	}
+
+	tests := []struct {
+		name        string
+		content     []byte
+		expected    archive.BombReason
+		expectDepth int
+	}{
+		{
+			name:        "plain archive",
+			content:     zipArchive(map[string][]byte{"a.txt": []byte("safe content")}),
+			expectDepth: 1,
+		},
+		{
+			name: "small file with high ratio",
+			// ratio is not checked for files smaller than 1MB
+			content:     zipArchive(map[string][]byte{"zeros.txt": make([]byte, 512<<10)}),
+			expectDepth: 1,
+		},
+		{
+			name:     "large file with high ratio",
+			content:  zipArchive(map[string][]byte{"zeros.txt": make([]byte, 2<<20)}),
+			expected: archive.BombReasonRatio,
+		},
+		{
+			name:        "large file with low ratio",
+			content:     zipArchive(map[string][]byte{"random.bin": random}),
+			expectDepth: 1,
+		},
+		{
+			name:        "nesting exactly at limit",
+			content:     nestedZipArchive(3),
+			expectDepth: 3,
+		},
+		{
+			name:     "nesting one level over limit",
+			content:  nestedZipArchive(4),
+			expected: archive.BombReasonDepth,
+		},
+		{
+			name: "entries exactly at limit",
+			content: zipArchive(map[string][]byte{
+				"1": nil, "2": nil, "3": nil, "4": nil, "5": nil,
+				"6": nil, "7": nil, "8": nil, "9": nil, "10": nil,
+			}),
+			expectDepth: 1,
+		},
+		{
+			name: "entries one over limit",
+			content: zipArchive(map[string][]byte{
+				"1": nil, "2": nil, "3": nil, "4": nil, "5": nil, "6": nil,
+				"7": nil, "8": nil, "9": nil, "10": nil, "11": nil,
+			}),
+			expected: archive.BombReasonEntries,
+		},
+		{
+			name: "expanded size over limit",
+			content: zipArchive(map[string][]byte{
+				"a.bin": random, "b.bin": random, "c.bin": random, "d.bin": random, "e.bin": random,
+			}),
+			expected: archive.BombReasonSize,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			inspection, err := archive.Inspect(archive.FormatZip, bytes.NewReader(test.content), int64(len(test.content)), limits)
+			if test.expected != "" {
+				bomb, ok := err.(*archive.BombError)
+				if !ok {
+					t.Fatalf("expected bomb error, but got: %v", err)
+				}
+				if bomb.Reason != test.expected {
+					t.Fatalf("expected bomb reason to be '%s', but got: %s", test.expected, bomb.Reason)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, but got: %s", err)
+			}
+			if inspection.Depth != test.expectDepth {
+				t.Fatalf("expected depth %d, but got: %d", test.expectDepth, inspection.Depth)
+			}
+		})
+	}
+}
+
+func TestWalkGzipName(t *testing.T) {
+	tests := []struct {
+		name       string
+		headerName string
+		expected   string
+	}{
+		{name: "report.txt.gz", expected: "report.txt"},
+		{name: "dir/report.txt.gz", expected: "report.txt"},
+		{name: "backup.tgz", expected: "backup.tar"},
+		{name: "report.bin", expected: "report.bin"},
+		{name: "report.gz", headerName: "original.txt", expected: "original.txt"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buffer := &bytes.Buffer{}
+			gw := gzip.NewWriter(buffer)
+			gw.Name = test.headerName
+			gw.Write([]byte("safe content"))
+			gw.Close()
+
+			var names []string
+			err := archive.Walk(archive.FormatGzip, test.name, bytes.NewReader(buffer.Bytes()), int64(buffer.Len()),
+				func(name string, r io.Reader) error {
+					names = append(names, name)
+					return nil
+				})
+			if err != nil {
+				t.Fatalf("expected no error, but got: %s", err)
+			}
+			if len(names) != 1 || names[0] != test.expected {
+				t.Fatalf("expected single member '%s', but got: %v", test.expected, names)
+			}
+		})
+	}
+}
@@ -0,0 +1,258 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/netcracker/qubership-av-scan-service/pkg/spool"
+)
+
+// ratioMinSize is the minimum expanded size starting from which compression ratio is checked,
+// small files may be compressed with a high ratio legitimately
+const ratioMinSize = 1 << 20
+
+// spoolMemoryLimit is the max size of nested archive kept in memory during inspection,
+// larger nested archives are written to a temporary file
+const spoolMemoryLimit = 10 << 20
+
+// BombReason describes which limit was exceeded by archive bomb
+type BombReason string
+
+const (
+	BombReasonRatio   BombReason = "ratio"
+	BombReasonEntries BombReason = "entries"
+	BombReasonDepth   BombReason = "depth"
+	BombReasonSize    BombReason = "size"
+)
+
+// BombLimits defines thresholds exceeding which archive is considered to be a decompression bomb
+type BombLimits struct {
+	// MaxRatio is the maximum compression ratio of an archive or any of its entries
+	MaxRatio float64
+	// MaxEntries is the maximum total number of entries in archive tree
+	MaxEntries int
+	// MaxDepth is the maximum nesting level of archives
+	MaxDepth int
+	// MaxExpandedSize is the maximum total size of all decompressed entries in bytes
+	MaxExpandedSize int64
+}
+
+// DefaultBombLimits returns bomb limits used when no explicit limits are configured
+func DefaultBombLimits() BombLimits {
+	return BombLimits{
+		MaxRatio:        100,
+		MaxEntries:      10000,
+		MaxDepth:        10,
+		MaxExpandedSize: 1 << 30,
+	}
+}
+
+// BombError is returned when archive is detected as a decompression bomb
+type BombError struct {
+	Reason BombReason
+	msg    string
+}
+
+func (e *BombError) Error() string {
+	return e.msg
+}
+
+// Inspection contains archive characteristics collected during inspection
+type Inspection struct {
+	// Entries is the total number of entries in archive tree
+	Entries int
+	// Depth is the maximum nesting level of archives, 1 for an archive without nested archives
+	Depth int
+	// ExpandedSize is the total size of all decompressed entries in bytes
+	ExpandedSize int64
+}
+
+// Ratio returns overall compression ratio of archive with given compressed size
+func (i *Inspection) Ratio(compressedSize int64) float64 {
+	if compressedSize == 0 {
+		return 0
+	}
+	return float64(i.ExpandedSize) / float64(compressedSize)
+}
+
+// Inspect verifies that archive is not a decompression bomb.
+// Entries are decompressed in streaming manner and discarded,
+// only nested archives are spooled for their own inspection.
+// If any of limits is exceeded, BombError is returned as soon as possible.
+func Inspect(format Format, r io.ReaderAt, size int64, limits BombLimits) (*Inspection, error) {
+	in := &inspector{limits: limits}
+	if err := in.inspect(format, r, size, 1); err != nil {
+		return nil, err
+	}
+	if in.res.ExpandedSize >= ratioMinSize && in.res.Ratio(size) > limits.MaxRatio {
+		return nil, in.bomb(BombReasonRatio, "archive compression ratio %.0f exceeds %.0f",
+			in.res.Ratio(size), limits.MaxRatio)
+	}
+	return &in.res, nil
+}
+
+type inspector struct {
+	limits BombLimits
+	res    Inspection
+}
+
+func (in *inspector) bomb(reason BombReason, format string, args ...any) *BombError {
+	return &BombError{Reason: reason, msg: fmt.Sprintf(format, args...)}
+}
+
+func (in *inspector) inspect(format Format, r io.ReaderAt, size int64, depth int) error {
+	if depth > in.limits.MaxDepth {
+		return in.bomb(BombReasonDepth, "archive nesting depth exceeds %d", in.limits.MaxDepth)
+	}
+	in.res.Depth = max(in.res.Depth, depth)
+
+	switch format {
+	case FormatZip:
+		return in.inspectZip(r, size, depth)
+	case FormatTar:
+		return in.inspectTar(io.NewSectionReader(r, 0, size), depth)
+	case FormatGzip:
+		return in.inspectGzip(io.NewSectionReader(r, 0, size), depth)
+	}
+	return fmt.Errorf("unsupported archive format: %q", format)
+}
+
+func (in *inspector) inspectZip(r io.ReaderAt, size int64, depth int) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return fmt.Errorf("failed to read zip: %w", err)
+	}
+
+	// verify declared sizes first to reject obvious bombs without decompression
+	var declaredSize uint64
+	for _, f := range zr.File {
+		declaredSize += f.UncompressedSize64
+		if err := in.checkRatio(f.Name, f.UncompressedSize64, f.CompressedSize64); err != nil {
+			return err
+		}
+	}
+	if in.res.Entries+len(zr.File) > in.limits.MaxEntries {
+		return in.bomb(BombReasonEntries, "archive contains more than %d entries", in.limits.MaxEntries)
+	}
+	if uint64(in.res.ExpandedSize)+declaredSize > uint64(in.limits.MaxExpandedSize) {
+		return in.bomb(BombReasonSize, "archive expanded size exceeds %d bytes", in.limits.MaxExpandedSize)
+	}
+
+	// declared sizes may be forged, so verify actual sizes too
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() || isEncrypted(f) {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("failed to open zip member %s: %w", f.Name, err)
+		}
+		size, err := in.entry(rc, depth)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+		if err := in.checkRatio(f.Name, uint64(size), f.CompressedSize64); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (in *inspector) inspectTar(r io.Reader, depth int) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read tar: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if _, err := in.entry(tr, depth); err != nil {
+			return err
+		}
+	}
+}
+
+func (in *inspector) inspectGzip(r io.Reader, depth int) error {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("failed to read gzip: %w", err)
+	}
+	defer gr.Close()
+
+	br := bufio.NewReaderSize(gr, HeaderSize)
+	header, err := br.Peek(HeaderSize)
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("failed to read gzip: %w", err)
+	}
+	if Detect(header) == FormatTar {
+		return in.inspectTar(br, depth)
+	}
+	_, err = in.entry(br, depth)
+	return err
+}
+
+// entry accounts single archive entry, discarding its content or inspecting it if it is a nested archive.
+// It returns decompressed size of the entry.
+func (in *inspector) entry(r io.Reader, depth int) (int64, error) {
+	in.res.Entries++
+	if in.res.Entries > in.limits.MaxEntries {
+		return 0, in.bomb(BombReasonEntries, "archive contains more than %d entries", in.limits.MaxEntries)
+	}
+
+	remaining := in.limits.MaxExpandedSize - in.res.ExpandedSize
+	br := bufio.NewReaderSize(io.LimitReader(r, remaining+1), HeaderSize)
+	header, err := br.Peek(HeaderSize)
+	if err != nil && err != io.EOF {
+		return 0, fmt.Errorf("failed to read archive entry: %w", err)
+	}
+
+	format := Detect(header)
+	var size int64
+	var nested *spool.File
+	if format != FormatNone {
+		nested, err = spool.New(br, spoolMemoryLimit)
+		if err == nil {
+			defer nested.Close()
+			size = nested.Size()
+		}
+	} else {
+		size, err = io.Copy(io.Discard, br)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read archive entry: %w", err)
+	}
+
+	in.res.ExpandedSize += size
+	if size > remaining {
+		return 0, in.bomb(BombReasonSize, "archive expanded size exceeds %d bytes", in.limits.MaxExpandedSize)
+	}
+
+	if nested != nil {
+		if err := in.inspect(format, nested, size, depth+1); err != nil {
+			return 0, err
+		}
+	}
+	return size, nil
+}
+
+// checkRatio verifies compression ratio of a single entry
+func (in *inspector) checkRatio(name string, size uint64, compressedSize uint64) error {
+	if size < ratioMinSize || compressedSize == 0 {
+		return nil
+	}
+	ratio := float64(size) / float64(compressedSize)
+	if ratio > in.limits.MaxRatio {
+		return in.bomb(BombReasonRatio, "compression ratio %.0f of %s exceeds %.0f", ratio, name, in.limits.MaxRatio)
+	}
+	return nil
+}
@@ -0,0 +1,389 @@
+package cdr
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// RemovalKind is a kind of content removed from the document
+type RemovalKind string
+
+const (
+	// RemovalVBAProject is a VBA macros project or its data
+	RemovalVBAProject RemovalKind = "vba_project"
+	// RemovalOLEObject is an embedded OLE object or ActiveX control
+	RemovalOLEObject RemovalKind = "ole_object"
+	// RemovalExternalRelationship is a relationship to external resource, e.g. remote template
+	RemovalExternalRelationship RemovalKind = "external_relationship"
+)
+
+// Removal describes a single piece of content removed from the document
+type Removal struct {
+	// Kind is a kind of removed content
+	Kind RemovalKind `json:"kind"`
+	// Part is a package part which was removed or modified
+	Part string `json:"part"`
+	// Target is a target of removed external relationship
+	Target string `json:"target,omitempty"`
+}
+
+const (
+	hyperlinkRelationship = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/hyperlink"
+	contentTypesPart      = "[Content_Types].xml"
+	// maxPartSize restricts size of XML parts which are rewritten during sanitization
+	maxPartSize = 64 << 20
+)
+
+// removedRelationshipKinds maps last segment of relationship types targeting removed parts to removal kinds
+var removedRelationshipKinds = map[string]RemovalKind{
+	"vbaProject":               RemovalVBAProject,
+	"vbaProjectSignature":      RemovalVBAProject,
+	"vbaProjectSignatureAgile": RemovalVBAProject,
+	"vbaProjectSignatureV3":    RemovalVBAProject,
+	"wordVbaData":              RemovalVBAProject,
+	"oleObject":                RemovalOLEObject,
+	"control":                  RemovalOLEObject,
+	"activeXControlBinary":     RemovalOLEObject,
+}
+
+// removedContentTypes maps content types of removed parts to removal kinds
+var removedContentTypes = map[string]RemovalKind{
+	"application/vnd.ms-office.vbaProject":                    RemovalVBAProject,
+	"application/vnd.ms-office.vbaProjectSignature":           RemovalVBAProject,
+	"application/vnd.ms-office.vbaProjectSignatureAgile":      RemovalVBAProject,
+	"application/vnd.ms-office.vbaProjectSignatureV3":         RemovalVBAProject,
+	"application/vnd.ms-word.vbaData+xml":                     RemovalVBAProject,
+	"application/vnd.ms-office.oleObject":                     RemovalOLEObject,
+	"application/vnd.openxmlformats-officedocument.oleObject": RemovalOLEObject,
+	"application/vnd.ms-office.activeX":                       RemovalOLEObject,
+	"application/vnd.ms-office.activeX+xml":                   RemovalOLEObject,
+}
+
+// macroFreeContentTypes maps content types of macro-enabled main parts to their macro-free equivalents
+var macroFreeContentTypes = map[string]string{
+	"application/vnd.ms-word.document.macroEnabled.main+xml":           "application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml",
+	"application/vnd.ms-word.template.macroEnabledTemplate.main+xml":   "application/vnd.openxmlformats-officedocument.wordprocessingml.template.main+xml",
+	"application/vnd.ms-excel.sheet.macroEnabled.main+xml":             "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml",
+	"application/vnd.ms-excel.template.macroEnabled.main+xml":          "application/vnd.openxmlformats-officedocument.spreadsheetml.template.main+xml",
+	"application/vnd.ms-powerpoint.presentation.macroEnabled.main+xml": "application/vnd.openxmlformats-officedocument.presentationml.presentation.main+xml",
+	"application/vnd.ms-powerpoint.slideshow.macroEnabled.main+xml":    "application/vnd.openxmlformats-officedocument.presentationml.slideshow.main+xml",
+	"application/vnd.ms-powerpoint.template.macroEnabled.main+xml":     "application/vnd.openxmlformats-officedocument.presentationml.template.main+xml",
+}
+
+// macroFreeExtensions maps extensions of macro-enabled documents to their macro-free equivalents
+var macroFreeExtensions = map[string]string{
+	".docm": ".docx",
+	".dotm": ".dotx",
+	".xlsm": ".xlsx",
+	".xltm": ".xltx",
+	".pptm": ".pptx",
+	".ppsm": ".ppsx",
+	".potm": ".potx",
+}
+
+type relationships struct {
+	XMLName      xml.Name       `xml:"http://schemas.openxmlformats.org/package/2006/relationships Relationships"`
+	Relationship []relationship `xml:"Relationship"`
+}
+
+type relationship struct {
+	ID         string `xml:"Id,attr"`
+	Type       string `xml:"Type,attr"`
+	Target     string `xml:"Target,attr"`
+	TargetMode string `xml:"TargetMode,attr,omitempty"`
+}
+
+type contentTypes struct {
+	XMLName  xml.Name      `xml:"http://schemas.openxmlformats.org/package/2006/content-types Types"`
+	Default  []contentType `xml:"Default"`
+	Override []contentType `xml:"Override"`
+}
+
+type contentType struct {
+	Extension   string `xml:"Extension,attr,omitempty"`
+	PartName    string `xml:"PartName,attr,omitempty"`
+	ContentType string `xml:"ContentType,attr"`
+}
+
+// SanitizedName returns a name of sanitized document, macro-enabled extensions are replaced with macro-free ones
+func SanitizedName(filename string) string {
+	ext := path.Ext(filename)
+	if replacement, ok := macroFreeExtensions[strings.ToLower(ext)]; ok {
+		return strings.TrimSuffix(filename, ext) + replacement
+	}
+	return filename
+}
+
+// SanitizeOOXML writes to w a copy of OOXML document with VBA projects, embedded OLE objects,
+// ActiveX controls and external relationships removed. Hyperlinks are kept, since they are not
+// followed without user action. Relationships and content types of removed parts are removed too,
+// and macro-enabled document is converted to macro-free one.
+func SanitizeOOXML(r io.ReaderAt, size int64, w io.Writer) ([]Removal, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read document: %w", err)
+	}
+
+	kinds, err := removedParts(zr)
+	if err != nil {
+		return nil, err
+	}
+	var removals []Removal
+	removed := map[string]bool{}
+	for _, f := range zr.File {
+		if kind, ok := kinds[f.Name]; ok {
+			removed[f.Name] = true
+			removals = append(removals, Removal{Kind: kind, Part: f.Name})
+		}
+	}
+
+	zw := zip.NewWriter(w)
+	for _, f := range zr.File {
+		if removed[f.Name] {
+			continue
+		}
+
+		var content []byte
+		var partRemovals []Removal
+		switch {
+		case f.Name == contentTypesPart:
+			content, err = rewritePart(f, func(data []byte) ([]byte, error) {
+				return sanitizeContentTypes(data, removed)
+			})
+		case strings.HasSuffix(f.Name, ".rels"):
+			content, err = rewritePart(f, func(data []byte) (res []byte, err error) {
+				res, partRemovals, err = sanitizeRelationships(f.Name, data, removed)
+				return res, err
+			})
+		}
+		if err != nil {
+			return nil, err
+		}
+		removals = append(removals, partRemovals...)
+
+		if content == nil {
+			if err := zw.Copy(f); err != nil {
+				return nil, fmt.Errorf("failed to copy part %s: %w", f.Name, err)
+			}
+			continue
+		}
+		pw, err := zw.CreateHeader(&zip.FileHeader{Name: f.Name, Method: zip.Deflate, Modified: f.Modified})
+		if err != nil {
+			return nil, fmt.Errorf("failed to write part %s: %w", f.Name, err)
+		}
+		if _, err := pw.Write(content); err != nil {
+			return nil, fmt.Errorf("failed to write part %s: %w", f.Name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write document: %w", err)
+	}
+	return removals, nil
+}
+
+// removedParts returns kinds of package parts which should be removed. Parts are found by names,
+// by types of relationships targeting them and by their content types, so renamed parts are found too.
+// Relationships of removed parts are removed along with them.
+func removedParts(zr *zip.Reader) (map[string]RemovalKind, error) {
+	kinds := map[string]RemovalKind{}
+	for _, f := range zr.File {
+		if kind, ok := removedPartKind(f.Name); ok {
+			kinds[f.Name] = kind
+		}
+	}
+
+	for _, f := range zr.File {
+		switch {
+		case f.Name == contentTypesPart:
+			data, err := readPart(f)
+			if err != nil {
+				return nil, err
+			}
+			var types contentTypes
+			if err := xml.Unmarshal(data, &types); err != nil {
+				return nil, fmt.Errorf("failed to parse part %s: %w", f.Name, err)
+			}
+			for _, part := range zr.File {
+				if kind, ok := removedContentTypes[types.of(part.Name)]; ok {
+					kinds[part.Name] = kind
+				}
+			}
+		case strings.HasSuffix(f.Name, ".rels"):
+			data, err := readPart(f)
+			if err != nil {
+				return nil, err
+			}
+			var rels relationships
+			if xml.Unmarshal(data, &rels) != nil {
+				// kept relationships are parsed again when rewritten, so invalid ones fail there
+				continue
+			}
+			for _, rel := range rels.Relationship {
+				if rel.TargetMode == "External" {
+					continue
+				}
+				if kind, ok := removedRelationshipKinds[path.Base(rel.Type)]; ok {
+					kinds[relationshipTarget(f.Name, rel)] = kind
+				}
+			}
+		}
+	}
+
+	for _, f := range zr.File {
+		if !strings.HasSuffix(f.Name, ".rels") {
+			continue
+		}
+		source := path.Join(path.Dir(path.Dir(f.Name)), strings.TrimSuffix(path.Base(f.Name), ".rels"))
+		if kind, ok := kinds[source]; ok {
+			kinds[f.Name] = kind
+		}
+	}
+	return kinds, nil
+}
+
+// removedPartKind checks if package part should be removed by its name
+func removedPartKind(name string) (RemovalKind, bool) {
+	lower := strings.ToLower(name)
+	base := path.Base(lower)
+	switch {
+	case base == "vbaproject.bin" || base == "vbadata.xml" || base == "vbaprojectsignature.bin":
+		return RemovalVBAProject, true
+	case strings.Contains(lower, "/embeddings/") && strings.HasSuffix(lower, ".bin"):
+		return RemovalOLEObject, true
+	case strings.Contains(lower, "/activex/") && !strings.Contains(lower, "/_rels/"):
+		return RemovalOLEObject, true
+	}
+	return "", false
+}
+
+// of returns content type of the part, overrides take precedence over defaults by extension
+func (t contentTypes) of(name string) string {
+	for _, o := range t.Override {
+		if strings.EqualFold(strings.TrimPrefix(o.PartName, "/"), name) {
+			return o.ContentType
+		}
+	}
+	ext := strings.TrimPrefix(path.Ext(name), ".")
+	for _, d := range t.Default {
+		if strings.EqualFold(d.Extension, ext) {
+			return d.ContentType
+		}
+	}
+	return ""
+}
+
+// relationshipTarget returns name of the part targeted by internal relationship,
+// relationships of part dir/_rels/name.rels are resolved relative to dir
+func relationshipTarget(relsName string, rel relationship) string {
+	if strings.HasPrefix(rel.Target, "/") {
+		return strings.TrimPrefix(rel.Target, "/")
+	}
+	return path.Join(path.Dir(path.Dir(relsName)), rel.Target)
+}
+
+// readPart reads content of XML part restricted by maxPartSize
+func readPart(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open part %s: %w", f.Name, err)
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(io.LimitReader(rc, maxPartSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read part %s: %w", f.Name, err)
+	}
+	if len(data) > maxPartSize {
+		return nil, fmt.Errorf("part %s is too large", f.Name)
+	}
+	return data, nil
+}
+
+// rewritePart reads XML part and applies rewrite function to its content
+func rewritePart(f *zip.File, rewrite func([]byte) ([]byte, error)) ([]byte, error) {
+	data, err := readPart(f)
+	if err != nil {
+		return nil, err
+	}
+	res, err := rewrite(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sanitize part %s: %w", f.Name, err)
+	}
+	return res, nil
+}
+
+// sanitizeRelationships removes external relationships (except hyperlinks) and relationships to removed parts
+func sanitizeRelationships(name string, data []byte, removed map[string]bool) ([]byte, []Removal, error) {
+	var rels relationships
+	if err := xml.Unmarshal(data, &rels); err != nil {
+		return nil, nil, err
+	}
+
+	var kept []relationship
+	var removals []Removal
+	for _, rel := range rels.Relationship {
+		if rel.TargetMode == "External" {
+			if rel.Type == hyperlinkRelationship {
+				kept = append(kept, rel)
+				continue
+			}
+			removals = append(removals, Removal{Kind: RemovalExternalRelationship, Part: name, Target: rel.Target})
+			continue
+		}
+
+		if removed[relationshipTarget(name, rel)] {
+			continue
+		}
+		kept = append(kept, rel)
+	}
+	if len(kept) == len(rels.Relationship) {
+		return data, nil, nil
+	}
+
+	rels.Relationship = kept
+	res, err := marshalXML(rels)
+	return res, removals, err
+}
+
+// sanitizeContentTypes removes content types of removed parts and converts macro-enabled main part types
+func sanitizeContentTypes(data []byte, removed map[string]bool) ([]byte, error) {
+	var types contentTypes
+	if err := xml.Unmarshal(data, &types); err != nil {
+		return nil, err
+	}
+
+	var defaults []contentType
+	for _, d := range types.Default {
+		if d.ContentType != "application/vnd.ms-office.vbaProject" {
+			defaults = append(defaults, d)
+		}
+	}
+	var overrides []contentType
+	for _, o := range types.Override {
+		if removed[strings.TrimPrefix(o.PartName, "/")] {
+			continue
+		}
+		if replacement, ok := macroFreeContentTypes[o.ContentType]; ok {
+			o.ContentType = replacement
+		}
+		overrides = append(overrides, o)
+	}
+
+	types.Default = defaults
+	types.Override = overrides
+	return marshalXML(types)
+}
+
+func marshalXML(v any) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	buf.WriteString(xml.Header)
+	if err := xml.NewEncoder(buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,196 @@
+package cdr_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/netcracker/qubership-av-scan-service/pkg/cdr"
+)
+
+const macroDocumentTypes = `<?xml version="1.0" encoding="UTF-8"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="bin" ContentType="application/vnd.ms-office.vbaProject"/>
+<Override PartName="/word/document.xml" ContentType="application/vnd.ms-word.document.macroEnabled.main+xml"/>
+<Override PartName="/word/embeddings/oleObject1.bin" ContentType="application/vnd.openxmlformats-officedocument.oleObject"/>
+</Types>`
+
+const macroDocumentRels = `<?xml version="1.0" encoding="UTF-8"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.microsoft.com/office/2006/relationships/vbaProject" Target="vbaProject.bin"/>
+<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/oleObject" Target="embeddings/oleObject1.bin"/>
+<Relationship Id="rId3" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/hyperlink" Target="https://example.com" TargetMode="External"/>
+<Relationship Id="rId4" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>
+</Relationships>`
+
+const templateRels = `<?xml version="1.0" encoding="UTF-8"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/attachedTemplate" Target="http://attacker.example/template.dotm" TargetMode="External"/>
+</Relationships>`
+
+func TestSanitizeOOXML(t *testing.T) {
+	doc := zipFile(map[string]string{
+		"[Content_Types].xml":                  macroDocumentTypes,
+		"word/document.xml":                    "<w:document/>",
+		"word/styles.xml":                      "<w:styles/>",
+		"word/vbaProject.bin":                  "vba",
+		"word/_rels/vbaProject.bin.rels":       "<Relationships/>",
+		"word/embeddings/oleObject1.bin":       "ole",
+		"word/_rels/document.xml.rels":         macroDocumentRels,
+		"word/_rels/settings.xml.rels":         templateRels,
+		"word/activeX/activeX1.xml":            "<ax:ocx/>",
+		"word/activeX/_rels/activeX1.xml.rels": "<Relationships/>",
+	})
+
+	out := &bytes.Buffer{}
+	removals, err := cdr.SanitizeOOXML(bytes.NewReader(doc), int64(len(doc)), out)
+	if err != nil {
+		t.Fatalf("expected document to be sanitized, but failed: %s", err)
+	}
+
+	kinds := map[cdr.RemovalKind]int{}
+	for _, r := range removals {
+		kinds[r.Kind]++
+	}
+	if kinds[cdr.RemovalVBAProject] != 2 || kinds[cdr.RemovalOLEObject] != 3 || kinds[cdr.RemovalExternalRelationship] != 1 {
+		t.Fatalf("unexpected removals: %+v", removals)
+	}
+
+	parts := readZip(t, out.Bytes())
+	for _, name := range []string{"word/vbaProject.bin", "word/embeddings/oleObject1.bin", "word/activeX/activeX1.xml", "word/_rels/vbaProject.bin.rels"} {
+		if _, ok := parts[name]; ok {
+			t.Fatalf("expected %s to be removed", name)
+		}
+	}
+	if parts["word/styles.xml"] != "<w:styles/>" {
+		t.Fatalf("expected styles to be kept, but got: %q", parts["word/styles.xml"])
+	}
+
+	rels := parts["word/_rels/document.xml.rels"]
+	if strings.Contains(rels, "vbaProject.bin") || strings.Contains(rels, "oleObject1.bin") {
+		t.Fatalf("expected relationships to removed parts to be removed, but got: %s", rels)
+	}
+	if !strings.Contains(rels, "https://example.com") || !strings.Contains(rels, "styles.xml") {
+		t.Fatalf("expected hyperlink and styles relationships to be kept, but got: %s", rels)
+	}
+	if strings.Contains(parts["word/_rels/settings.xml.rels"], "attacker.example") {
+		t.Fatalf("expected external template to be removed, but got: %s", parts["word/_rels/settings.xml.rels"])
+	}
+
+	types := parts["[Content_Types].xml"]
+	if strings.Contains(types, "macroEnabled") || strings.Contains(types, "vbaProject") || strings.Contains(types, "oleObject1.bin") {
+		t.Fatalf("expected content types to be macro-free, but got: %s", types)
+	}
+	if !strings.Contains(types, "wordprocessingml.document.main+xml") {
+		t.Fatalf("expected main part to be docx document, but got: %s", types)
+	}
+}
+
+func TestSanitizeOOXMLRenamedParts(t *testing.T) {
+	doc := zipFile(map[string]string{
+		"[Content_Types].xml": `<?xml version="1.0" encoding="UTF-8"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Override PartName="/word/document.xml" ContentType="application/vnd.ms-word.document.macroEnabled.main+xml"/>
+<Override PartName="/word/x.dat" ContentType="application/vnd.ms-office.vbaProject"/>
+<Override PartName="/word/media/control.xml" ContentType="application/vnd.ms-office.activeX+xml"/>
+</Types>`,
+		"word/document.xml":      "<w:document/>",
+		"word/x.dat":             "vba",
+		"word/y.dat":             "vba",
+		"word/media/control.xml": "<ax:ocx/>",
+		"word/_rels/document.xml.rels": `<?xml version="1.0" encoding="UTF-8"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.microsoft.com/office/2006/relationships/vbaProject" Target="x.dat"/>
+<Relationship Id="rId2" Type="http://schemas.microsoft.com/office/2006/relationships/vbaProject" Target="/word/y.dat"/>
+</Relationships>`,
+	})
+
+	out := &bytes.Buffer{}
+	removals, err := cdr.SanitizeOOXML(bytes.NewReader(doc), int64(len(doc)), out)
+	if err != nil {
+		t.Fatalf("expected document to be sanitized, but failed: %s", err)
+	}
+	removedParts := map[string]cdr.RemovalKind{}
+	for _, r := range removals {
+		removedParts[r.Part] = r.Kind
+	}
+	if len(removedParts) != 3 || removedParts["word/x.dat"] != cdr.RemovalVBAProject ||
+		removedParts["word/y.dat"] != cdr.RemovalVBAProject || removedParts["word/media/control.xml"] != cdr.RemovalOLEObject {
+		t.Fatalf("unexpected removals: %+v", removals)
+	}
+
+	parts := readZip(t, out.Bytes())
+	for _, name := range []string{"word/x.dat", "word/y.dat", "word/media/control.xml"} {
+		if _, ok := parts[name]; ok {
+			t.Fatalf("expected %s to be removed", name)
+		}
+	}
+	if rels := parts["word/_rels/document.xml.rels"]; strings.Contains(rels, "vbaProject") {
+		t.Fatalf("expected relationships to removed parts to be removed, but got: %s", rels)
+	}
+	if types := parts["[Content_Types].xml"]; strings.Contains(types, "x.dat") || strings.Contains(types, "control.xml") {
+		t.Fatalf("expected content types of removed parts to be removed, but got: %s", types)
+	}
+}
+
+func TestSanitizeOOXMLInvalid(t *testing.T) {
+	content := []byte("not a zip")
+	if _, err := cdr.SanitizeOOXML(bytes.NewReader(content), int64(len(content)), io.Discard); err == nil {
+		t.Fatal("expected error for non-zip content")
+	}
+}
+
+func TestSanitizedName(t *testing.T) {
+	for name, expected := range map[string]string{
+		"report.docm": "report.docx",
+		"BOOK.XLSM":   "BOOK.xlsx",
+		"slides.pptx": "slides.pptx",
+	} {
+		if actual := cdr.SanitizedName(name); actual != expected {
+			t.Fatalf("expected %s to be renamed to %s, but got: %s", name, expected, actual)
+		}
+	}
+}
+
+func zipFile(files map[string]string) []byte {
+	buffer := &bytes.Buffer{}
+	zw := zip.NewWriter(buffer)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			panic(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			panic(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		panic(err)
+	}
+	return buffer.Bytes()
+}
+
+func readZip(t *testing.T, content []byte) map[string]string {
+	zr, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		t.Fatalf("expected sanitized document to be zip, but failed: %s", err)
+	}
+	parts := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("failed to open %s: %s", f.Name, err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("failed to read %s: %s", f.Name, err)
+		}
+		parts[f.Name] = string(data)
+	}
+	return parts
+}
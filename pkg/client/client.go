@@ -0,0 +1,301 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	apierrors "github.com/netcracker/qubership-av-scan-service/pkg/errors"
+	"github.com/netcracker/qubership-av-scan-service/pkg/handlers"
+	"github.com/netcracker/qubership-av-scan-service/pkg/spool"
+)
+
+// DefaultTimeout restricts the whole request including upload of files and scanning if no explicit timeout is configured
+const DefaultTimeout = 5 * time.Minute
+
+// DefaultMaxRetries is the number of retries of requests failed with 5xx status or network error
+const DefaultMaxRetries = 3
+
+// DefaultBackoff is the delay before the first retry, it is doubled for each next retry
+const DefaultBackoff = time.Second
+
+// spoolMemoryLimit is the max size of request body kept in memory for retries, larger bodies are written to disk
+const spoolMemoryLimit = 10 << 20
+
+// Option configures optional client behaviour
+type Option func(*Client)
+
+// WithHTTPClient sets HTTP client used for requests, TLS config set by WithTLSConfig is applied to its transport
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.http = httpClient
+	}
+}
+
+// WithTLSConfig sets TLS config used for connections to https service, e.g. with custom CA or client certificate
+func WithTLSConfig(cfg *tls.Config) Option {
+	return func(c *Client) {
+		c.tls = cfg
+	}
+}
+
+// WithBearerToken authenticates requests with given token in Authorization header
+func WithBearerToken(token string) Option {
+	return func(c *Client) {
+		c.auth = func(req *http.Request) {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+}
+
+// WithBasicAuth authenticates requests with given username and password
+func WithBasicAuth(username string, password string) Option {
+	return func(c *Client) {
+		c.auth = func(req *http.Request) {
+			req.SetBasicAuth(username, password)
+		}
+	}
+}
+
+// WithRetries sets the number of retries of failed requests and the delay before the first retry,
+// the delay is doubled for each next retry. Retries are disabled if maxRetries is 0.
+func WithRetries(maxRetries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.backoff = backoff
+	}
+}
+
+// WithDeepScan requests expansion of archives, so each archive member is verified separately
+func WithDeepScan(deep bool) Option {
+	return func(c *Client) {
+		c.deep = deep
+	}
+}
+
+// File is a named content to scan
+type File struct {
+	// Name is the name of the file reported in scan status
+	Name string
+	// Content is read once, even if request is retried
+	Content io.Reader
+}
+
+// Client calls scan API of Antivirus Scan Service.
+// Requests failed with 5xx status or network error are retried with exponential backoff.
+// If service returns an error, it is wrapped APIError, which could be extracted with errors.As.
+type Client struct {
+	baseURL    *url.URL
+	http       *http.Client
+	tls        *tls.Config
+	auth       func(*http.Request)
+	maxRetries int
+	backoff    time.Duration
+	deep       bool
+}
+
+// New returns Client calling the service at given base URL, e.g. http://av-scan-service:8080
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid service URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid service URL %q, scheme must be http or https", baseURL)
+	}
+	c := &Client{
+		baseURL:    u,
+		maxRetries: DefaultMaxRetries,
+		backoff:    DefaultBackoff,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.http == nil {
+		c.http = &http.Client{Timeout: DefaultTimeout}
+	}
+	if c.tls != nil {
+		transport, ok := c.http.Transport.(*http.Transport)
+		if !ok {
+			transport = http.DefaultTransport.(*http.Transport)
+		}
+		transport = transport.Clone()
+		transport.TLSClientConfig = c.tls
+		httpClient := *c.http
+		httpClient.Transport = transport
+		c.http = &httpClient
+	}
+	return c, nil
+}
+
+// ScanFile scans file at given path, file name is used as name in scan status
+func (c *Client) ScanFile(ctx context.Context, path string) (*handlers.ScanStatus, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return c.ScanReader(ctx, filepath.Base(path), f)
+}
+
+// ScanReader scans content read from r as a file with given name
+func (c *Client) ScanReader(ctx context.Context, name string, r io.Reader) (*handlers.ScanStatus, error) {
+	statuses, err := c.ScanFiles(ctx, File{Name: name, Content: r})
+	if err != nil {
+		return nil, err
+	}
+	if len(statuses) != 1 {
+		return nil, fmt.Errorf("expected 1 scan status, but got %d", len(statuses))
+	}
+	return statuses[0], nil
+}
+
+// ScanFiles scans several files with a single request and returns their statuses in the same order
+func (c *Client) ScanFiles(ctx context.Context, files ...File) ([]*handlers.ScanStatus, error) {
+	body, contentType, err := multipartBody(files)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read files: %w", err)
+	}
+	defer body.Close()
+
+	query := url.Values{}
+	if c.deep {
+		query.Set("deep", strconv.FormatBool(c.deep))
+	}
+	resp, err := c.do(ctx, http.MethodPost, "/api/v1/scan", query, body, contentType)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	statuses, err := handlers.ParseScanStatuses(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse scan statuses: %w", err)
+	}
+	return statuses, nil
+}
+
+// Health checks that the service is able to scan files, error is returned if clamd is not available
+func (c *Client) Health(ctx context.Context) error {
+	resp, err := c.do(ctx, http.MethodGet, "/health", nil, nil, "")
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// HealthDatabaseAge checks health of the service like Health, additionally failing with AV-7102 error
+// if clamd DB is older than maxAge
+func (c *Client) HealthDatabaseAge(ctx context.Context, maxAge time.Duration) error {
+	query := url.Values{"maxDatabaseAge": []string{maxAge.String()}}
+	resp, err := c.do(ctx, http.MethodGet, "/health", query, nil, "")
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// do sends the request and returns response with 2xx status, retrying requests failed with 5xx status or network error.
+// Body is read from the beginning for each attempt.
+func (c *Client) do(
+	ctx context.Context,
+	method string,
+	path string,
+	query url.Values,
+	body *spool.File,
+	contentType string,
+) (*http.Response, error) {
+	u := c.baseURL.JoinPath(path)
+	u.RawQuery = query.Encode()
+
+	backoff := c.backoff
+	for attempt := 0; ; attempt++ {
+		resp, err := c.send(ctx, method, u, body, contentType)
+		if err == nil {
+			if resp.StatusCode < http.StatusMultipleChoices {
+				return resp, nil
+			}
+			err = responseError(method, u, resp)
+			resp.Body.Close()
+			if resp.StatusCode < http.StatusInternalServerError {
+				return nil, err
+			}
+		}
+		if attempt >= c.maxRetries || ctx.Err() != nil {
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (c *Client) send(ctx context.Context, method string, u *url.URL, body *spool.File, contentType string) (*http.Response, error) {
+	var r io.Reader
+	if body != nil {
+		r = body.Reader()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), r)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = body.Size()
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.auth != nil {
+		c.auth(req)
+	}
+	return c.http.Do(req)
+}
+
+// responseError returns APIError from response body wrapped with request description,
+// error with status is returned if body does not contain APIError
+func responseError(method string, u *url.URL, resp *http.Response) error {
+	apiErr, err := apierrors.Parse(resp.Body)
+	if err != nil || apiErr == nil || apiErr.Code == "" {
+		return fmt.Errorf("%s %s: unexpected response status %s", method, u.Redacted(), resp.Status)
+	}
+	return fmt.Errorf("%s %s: %w", method, u.Redacted(), apiErr)
+}
+
+// multipartBody spools multipart/form-data body with given files, so it can be sent several times
+func multipartBody(files []File) (*spool.File, string, error) {
+	r, w := io.Pipe()
+	mw := multipart.NewWriter(w)
+	go func() {
+		for _, f := range files {
+			part, err := mw.CreateFormFile("file", f.Name)
+			if err == nil {
+				_, err = io.Copy(part, f.Content)
+			}
+			if err != nil {
+				w.CloseWithError(err)
+				return
+			}
+		}
+		w.CloseWithError(mw.Close())
+	}()
+
+	body, err := spool.New(r, spoolMemoryLimit)
+	r.Close()
+	if err != nil {
+		return nil, "", err
+	}
+	return body, mw.FormDataContentType(), nil
+}
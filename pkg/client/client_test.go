@@ -0,0 +1,146 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/netcracker/qubership-av-scan-service/pkg/client"
+	apierrors "github.com/netcracker/qubership-av-scan-service/pkg/errors"
+	"github.com/netcracker/qubership-av-scan-service/pkg/handlers"
+	"github.com/netcracker/qubership-av-scan-service/pkg/router"
+	"github.com/netcracker/qubership-av-scan-service/pkg/testutils"
+)
+
+func newClient(t *testing.T, handler http.Handler, opts ...client.Option) *client.Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	c, err := client.New(server.URL, append([]client.Option{client.WithRetries(2, time.Millisecond)}, opts...)...)
+	if err != nil {
+		t.Fatalf("failed to create client: %s", err)
+	}
+	return c
+}
+
+func TestScanFiles(t *testing.T) {
+	c := newClient(t, router.NewRouter(testutils.NewClamdMock(), nil))
+
+	statuses, err := c.ScanFiles(context.Background(),
+		client.File{Name: "clean.txt", Content: strings.NewReader("clean content")},
+		client.File{Name: "virus.txt", Content: strings.NewReader(testutils.EICARTest)},
+	)
+	if err != nil {
+		t.Fatalf("failed to scan files: %s", err)
+	}
+	if len(statuses) != 2 || statuses[0].Verdict != handlers.VerdictClean || statuses[1].Verdict != handlers.VerdictInfected {
+		t.Fatalf("unexpected scan statuses: %+v", statuses)
+	}
+}
+
+func TestScanFile(t *testing.T) {
+	c := newClient(t, router.NewRouter(testutils.NewClamdMock(), nil))
+
+	path := filepath.Join(t.TempDir(), "report.txt")
+	if err := os.WriteFile(path, []byte("clean content"), 0o600); err != nil {
+		t.Fatalf("failed to write file: %s", err)
+	}
+	status, err := c.ScanFile(context.Background(), path)
+	if err != nil {
+		t.Fatalf("failed to scan file: %s", err)
+	}
+	if status.Filename != "report.txt" || status.Infected {
+		t.Fatalf("unexpected scan status: %+v", status)
+	}
+}
+
+func TestAPIError(t *testing.T) {
+	c := newClient(t, router.NewRouter(testutils.NewClamdMock().WithUnhealthy("connection refused"), nil))
+
+	_, err := c.ScanReader(context.Background(), "a.txt", strings.NewReader("content"))
+	var apiErr *apierrors.APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != "AV-7101" {
+		t.Fatalf("expected AV-7101 error, but got: %v", err)
+	}
+	if err := c.Health(context.Background()); !errors.As(err, &apiErr) || apiErr.Code != "AV-7100" {
+		t.Fatalf("expected AV-7100 error, but got: %v", err)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	scan := router.NewRouter(testutils.NewClamdMock(), nil)
+	var attempts atomic.Int32
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		scan.ServeHTTP(w, r)
+	})
+	c := newClient(t, handler)
+
+	// body is sent again with each attempt
+	status, err := c.ScanReader(context.Background(), "virus.txt", strings.NewReader(testutils.EICARTest))
+	if err != nil {
+		t.Fatalf("failed to scan file: %s", err)
+	}
+	if !status.Infected || attempts.Load() != 3 {
+		t.Fatalf("unexpected scan status %+v after %d attempts", status, attempts.Load())
+	}
+
+	attempts.Store(-10)
+	if _, err := c.ScanReader(context.Background(), "a.txt", strings.NewReader("content")); err == nil {
+		t.Fatalf("expected error after retries are exhausted")
+	}
+}
+
+func TestClientErrorNotRetried(t *testing.T) {
+	var attempts atomic.Int32
+	c := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusForbidden)
+	}))
+
+	if err := c.Health(context.Background()); err == nil || attempts.Load() != 1 {
+		t.Fatalf("expected a single failed attempt, but got %d attempts and error %v", attempts.Load(), err)
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	var authorization string
+	c := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+	}), client.WithBearerToken("secret"))
+
+	if err := c.Health(context.Background()); err != nil {
+		t.Fatalf("failed to check health: %s", err)
+	}
+	if authorization != "Bearer secret" {
+		t.Fatalf("unexpected Authorization header %q", authorization)
+	}
+}
+
+func TestHealthDatabaseAge(t *testing.T) {
+	c := newClient(t, router.NewRouter(testutils.NewClamdMock().WithDatabaseAge(3600), nil), client.WithRetries(0, 0))
+
+	if err := c.HealthDatabaseAge(context.Background(), 2*time.Hour); err != nil {
+		t.Fatalf("failed to check health: %s", err)
+	}
+	var apiErr *apierrors.APIError
+	if err := c.HealthDatabaseAge(context.Background(), 30*time.Minute); !errors.As(err, &apiErr) || apiErr.Code != "AV-7102" {
+		t.Fatalf("expected AV-7102 error, but got: %v", err)
+	}
+}
+
+func TestInvalidURL(t *testing.T) {
+	if _, err := client.New("ftp://example.com"); err == nil {
+		t.Fatalf("expected ftp URL to be rejected")
+	}
+}
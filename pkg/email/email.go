@@ -0,0 +1,149 @@
+package email
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// maxNesting is the maximum nesting level of multipart bodies and messages which are walked,
+// deeper entities are reported as is
+const maxNesting = 32
+
+// Part describes a single leaf entity of the MIME tree, i.e. a body part or an attachment
+type Part struct {
+	// Path is a position of the entity in the MIME tree in IMAP notation, e.g. "2.1"
+	Path string
+	// ContentType is a media type of the entity without parameters, e.g. text/plain
+	ContentType string
+	// Filename is a name of the attachment, empty for body parts without name
+	Filename string
+	// Encoding is the Content-Transfer-Encoding of the entity in lower case, empty if not specified
+	Encoding string
+}
+
+// PartFunc is called for each leaf entity with its decoded content.
+// If it returns an error, walking stops and the error is returned.
+type PartFunc func(p Part, r io.Reader) error
+
+// Walk parses RFC 822 message and calls fn for each leaf entity of its MIME tree.
+// Multipart bodies and nested messages are walked recursively,
+// base64 and quoted-printable transfer encodings are decoded.
+func Walk(r io.Reader, fn PartFunc) error {
+	msg, err := mail.ReadMessage(bufio.NewReader(r))
+	if err != nil {
+		return fmt.Errorf("failed to read message: %w", err)
+	}
+	return walkEntity(textproto.MIMEHeader(msg.Header), msg.Body, "", true, 0, fn)
+}
+
+// walkEntity walks entity located at given path. Content of the message which is not multipart
+// is located at path.1, while content of a multipart body part is located at the path itself.
+func walkEntity(
+	header textproto.MIMEHeader,
+	body io.Reader,
+	path string,
+	message bool,
+	depth int,
+	fn PartFunc,
+) error {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		// RFC 2045 defines text/plain as the default type
+		mediaType, params = "text/plain", nil
+	}
+	encoding := strings.ToLower(strings.TrimSpace(header.Get("Content-Transfer-Encoding")))
+
+	if depth < maxNesting {
+		switch {
+		case strings.HasPrefix(mediaType, "multipart/") && params["boundary"] != "":
+			return walkMultipart(body, params["boundary"], path, depth, fn)
+		case mediaType == "message/rfc822":
+			msg, err := mail.ReadMessage(bufio.NewReader(Decode(encoding, body)))
+			if err != nil {
+				return fmt.Errorf("failed to read message %s: %w", path, err)
+			}
+			return walkEntity(textproto.MIMEHeader(msg.Header), msg.Body, path, true, depth+1, fn)
+		}
+	}
+
+	if message {
+		path = childPath(path, 1)
+	}
+	part := Part{
+		Path:        path,
+		ContentType: mediaType,
+		Filename:    filename(header, params),
+		Encoding:    encoding,
+	}
+	return fn(part, Decode(encoding, body))
+}
+
+func walkMultipart(body io.Reader, boundary string, path string, depth int, fn PartFunc) error {
+	mr := multipart.NewReader(body, boundary)
+	for i := 1; ; i++ {
+		// raw parts are used, since NextPart decodes quoted-printable content and drops the header
+		p, err := mr.NextRawPart()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read multipart %s: %w", path, err)
+		}
+		if err := walkEntity(p.Header, p, childPath(path, i), false, depth+1, fn); err != nil {
+			return err
+		}
+	}
+}
+
+func childPath(path string, i int) string {
+	if path == "" {
+		return strconv.Itoa(i)
+	}
+	return path + "." + strconv.Itoa(i)
+}
+
+// filename returns attachment name from Content-Disposition or Content-Type header,
+// decoding RFC 2047 encoded words
+func filename(header textproto.MIMEHeader, contentTypeParams map[string]string) string {
+	name := ""
+	if _, params, err := mime.ParseMediaType(header.Get("Content-Disposition")); err == nil {
+		name = params["filename"]
+	}
+	if name == "" {
+		name = contentTypeParams["name"]
+	}
+	if decoded, err := new(mime.WordDecoder).DecodeHeader(name); err == nil {
+		name = decoded
+	}
+	return name
+}
+
+// Decodable returns true if content with given Content-Transfer-Encoding is decoded by Decode
+func Decodable(encoding string) bool {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64", "quoted-printable":
+		return true
+	}
+	return false
+}
+
+// Decode returns reader which decodes content with given Content-Transfer-Encoding.
+// Identity encodings (7bit, 8bit, binary) and unknown encodings are returned as is.
+func Decode(encoding string, r io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, r)
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	}
+	return r
+}
@@ -0,0 +1,102 @@
+package email_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/netcracker/qubership-av-scan-service/pkg/email"
+)
+
+const nestedMessage = "From: sender@example.com\r\n" +
+	"To: recipient@example.com\r\n" +
+	"Subject: report\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/mixed; boundary=outer\r\n" +
+	"\r\n" +
+	"--outer\r\n" +
+	"Content-Type: text/plain; charset=utf-8\r\n" +
+	"Content-Transfer-Encoding: quoted-printable\r\n" +
+	"\r\n" +
+	"Hello =3D world\r\n" +
+	"--outer\r\n" +
+	"Content-Type: application/octet-stream\r\n" +
+	"Content-Disposition: attachment; filename=\"=?utf-8?q?r=C3=A9port.bin?=\"\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"\r\n" +
+	"YXR0YWNo\r\n" +
+	"bWVudA==\r\n" +
+	"--outer\r\n" +
+	"Content-Type: message/rfc822\r\n" +
+	"\r\n" +
+	"Subject: forwarded\r\n" +
+	"Content-Type: multipart/alternative; boundary=inner\r\n" +
+	"\r\n" +
+	"--inner\r\n" +
+	"Content-Type: text/plain\r\n" +
+	"\r\n" +
+	"plain\r\n" +
+	"--inner\r\n" +
+	"Content-Type: text/html; name=page.html\r\n" +
+	"\r\n" +
+	"<p>html</p>\r\n" +
+	"--inner--\r\n" +
+	"--outer--\r\n"
+
+func TestWalk(t *testing.T) {
+	type part struct {
+		email.Part
+		content string
+	}
+	var parts []part
+	err := email.Walk(strings.NewReader(nestedMessage), func(p email.Part, r io.Reader) error {
+		content, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		parts = append(parts, part{p, string(content)})
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("expected message to be walked, but failed: %s", err)
+	}
+
+	expected := []part{
+		{email.Part{Path: "1", ContentType: "text/plain", Encoding: "quoted-printable"}, "Hello = world"},
+		{email.Part{Path: "2", ContentType: "application/octet-stream", Filename: "réport.bin", Encoding: "base64"}, "attachment"},
+		{email.Part{Path: "3.1", ContentType: "text/plain"}, "plain"},
+		{email.Part{Path: "3.2", ContentType: "text/html", Filename: "page.html"}, "<p>html</p>"},
+	}
+	if len(parts) != len(expected) {
+		t.Fatalf("expected %d parts, but got: %+v", len(expected), parts)
+	}
+	for i := range expected {
+		if parts[i] != expected[i] {
+			t.Fatalf("expected part %+v, but got: %+v", expected[i], parts[i])
+		}
+	}
+}
+
+func TestWalkSinglePart(t *testing.T) {
+	message := "Subject: hello\r\n\r\nbody"
+	var paths []string
+	err := email.Walk(strings.NewReader(message), func(p email.Part, r io.Reader) error {
+		paths = append(paths, p.Path)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("expected message to be walked, but failed: %s", err)
+	}
+	if len(paths) != 1 || paths[0] != "1" {
+		t.Fatalf("expected single part with path 1, but got: %v", paths)
+	}
+}
+
+func TestWalkInvalid(t *testing.T) {
+	err := email.Walk(strings.NewReader("not a message"), func(p email.Part, r io.Reader) error {
+		return nil
+	})
+	if err == nil {
+		t.Fatal("expected error for invalid message")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"time"
 )
 
 // APIError is a type used to return errors to external users
@@ -61,6 +62,216 @@ func FilenameNotSpecifiedError() *APIError {
 	}
 }
 
+func ArchiveLimitExceededError(err error) *APIError {
+	return &APIError{
+		"AV-5002",
+		413,
+		"archive expansion limit exceeded",
+		err.Error(),
+	}
+}
+
+func ArchiveReadError(err error) *APIError {
+	return &APIError{
+		"AV-5003",
+		422,
+		"failed to expand archive",
+		err.Error(),
+	}
+}
+
+func InvalidParameterError(name string, value string) *APIError {
+	return &APIError{
+		"AV-5004",
+		400,
+		"invalid request parameter",
+		fmt.Sprintf("%q is not a valid value for %s", value, name),
+	}
+}
+
+func ArchiveBombError(err error) *APIError {
+	return &APIError{
+		"AV-5005",
+		422,
+		"archive bomb detected",
+		err.Error(),
+	}
+}
+
+func SanitizeUnsupportedError(mime string) *APIError {
+	return &APIError{
+		"AV-5006",
+		415,
+		"file type cannot be sanitized",
+		fmt.Sprintf("%s files are not supported, only OOXML documents can be sanitized", mime),
+	}
+}
+
+func SanitizeError(err error) *APIError {
+	return &APIError{
+		"AV-5007",
+		422,
+		"failed to sanitize file",
+		err.Error(),
+	}
+}
+
+func MultipartNestingError(limit int) *APIError {
+	return &APIError{
+		"AV-5009",
+		422,
+		"multipart nesting limit exceeded",
+		fmt.Sprintf("multipart body is nested deeper than %d levels", limit),
+	}
+}
+
+func ContentEncodingUnsupportedError(encoding string) *APIError {
+	return &APIError{
+		"AV-5010",
+		415,
+		"unsupported content encoding",
+		fmt.Sprintf("%s content-encoding not supported", encoding),
+	}
+}
+
+func DecodedBodyLimitExceededError(limit int64) *APIError {
+	return &APIError{
+		"AV-5011",
+		413,
+		"decompressed body size limit exceeded",
+		fmt.Sprintf("decompressed body exceeds %d bytes", limit),
+	}
+}
+
+func ImageReadError(err error) *APIError {
+	return &APIError{
+		"AV-5012",
+		422,
+		"failed to read image",
+		err.Error(),
+	}
+}
+
+func RemoteURLForbiddenError(err error) *APIError {
+	return &APIError{
+		"AV-5013",
+		403,
+		"remote URL is not allowed",
+		err.Error(),
+	}
+}
+
+func RemoteContentTooLargeError(err error) *APIError {
+	return &APIError{
+		"AV-5014",
+		413,
+		"remote content is too large",
+		err.Error(),
+	}
+}
+
+func RemoteFetchError(err error) *APIError {
+	return &APIError{
+		"AV-5015",
+		502,
+		"failed to fetch remote content",
+		err.Error(),
+	}
+}
+
+func ObjectStorageError(err error) *APIError {
+	return &APIError{
+		"AV-5016",
+		502,
+		"object storage request failed",
+		err.Error(),
+	}
+}
+
+func ObjectLimitExceededError(limit int) *APIError {
+	return &APIError{
+		"AV-5017",
+		413,
+		"objects limit exceeded",
+		fmt.Sprintf("more than %d objects match the request", limit),
+	}
+}
+
+func UploadBlockedError(details string) *APIError {
+	return &APIError{
+		"AV-5018",
+		403,
+		"upload blocked",
+		details,
+	}
+}
+
+func UpstreamError(err error) *APIError {
+	return &APIError{
+		"AV-5019",
+		502,
+		"upstream request failed",
+		err.Error(),
+	}
+}
+
+func UploadLimitExceededError(limit int64) *APIError {
+	return &APIError{
+		"AV-5020",
+		413,
+		"upload size limit exceeded",
+		fmt.Sprintf("file size exceeds %d bytes", limit),
+	}
+}
+
+func WebSocketProtocolError(details string) *APIError {
+	return &APIError{
+		"AV-5021",
+		400,
+		"invalid websocket message",
+		details,
+	}
+}
+
+func UploadNotFoundError(id string) *APIError {
+	return &APIError{
+		"AV-5022",
+		404,
+		"upload not found",
+		fmt.Sprintf("upload %q does not exist or is expired", id),
+	}
+}
+
+func UploadOffsetMismatchError(expected int64, actual string) *APIError {
+	return &APIError{
+		"AV-5023",
+		409,
+		"upload offset mismatch",
+		fmt.Sprintf("upload offset is %d, but content is sent at offset %s", expected, actual),
+	}
+}
+
+func UploadLockedError(id string) *APIError {
+	return &APIError{
+		"AV-5024",
+		423,
+		"upload locked",
+		fmt.Sprintf("upload %q is being modified by another request", id),
+	}
+}
+
+func TusVersionUnsupportedError(version string) *APIError {
+	if version == "" {
+		version = "empty"
+	}
+	return &APIError{
+		"AV-5025",
+		412,
+		"unsupported tus protocol version",
+		fmt.Sprintf("%s Tus-Resumable version not supported", version),
+	}
+}
+
 func ClamdPingError(err error) *APIError {
 	return &APIError{
 		"AV-7100",
@@ -79,6 +290,24 @@ func ClamdScanError(err error) *APIError {
 	}
 }
 
+func DatabaseOutdatedError(age time.Duration, maxAge time.Duration) *APIError {
+	return &APIError{
+		"AV-7102",
+		503,
+		"clamd database is outdated",
+		fmt.Sprintf("database age %s exceeds %s", age, maxAge),
+	}
+}
+
+func DatabaseVersionError(err error) *APIError {
+	return &APIError{
+		"AV-7103",
+		500,
+		"clamd database version error",
+		err.Error(),
+	}
+}
+
 // Parse is used to decode JSON input to APIError
 func Parse(r io.Reader) (*APIError, error) {
 	data, err := io.ReadAll(r)
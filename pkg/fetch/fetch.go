@@ -0,0 +1,273 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Options restricts fetching of remote content
+type Options struct {
+	// MaxSize is the maximum size of fetched content in bytes
+	MaxSize int64
+	// Timeout restricts the whole fetch, including redirects and reading of content
+	Timeout time.Duration
+	// MaxRedirects is the maximum number of followed redirects
+	MaxRedirects int
+	// AllowedHosts lists hosts which content may be fetched from, e.g. example.com or *.example.com.
+	// If empty, any host is allowed.
+	AllowedHosts []string
+	// AllowedNetworks lists private, loopback and other non-public networks which content may be fetched from.
+	// Public addresses are always allowed.
+	AllowedNetworks []netip.Prefix
+}
+
+// DefaultOptions returns options used when no explicit options are configured
+func DefaultOptions() Options {
+	return Options{
+		MaxSize:      100 << 20,
+		Timeout:      30 * time.Second,
+		MaxRedirects: 5,
+	}
+}
+
+// ForbiddenError is returned when URL is not allowed to be fetched
+type ForbiddenError struct {
+	msg string
+}
+
+func (e *ForbiddenError) Error() string {
+	return e.msg
+}
+
+// LimitError is returned when remote content exceeds size limit
+type LimitError struct {
+	msg string
+}
+
+func (e *LimitError) Error() string {
+	return e.msg
+}
+
+// nonPublicNetworks are reserved networks which are not covered by netip.Addr methods
+var nonPublicNetworks = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("2001:db8::/32"),
+}
+
+// Response is a fetched remote content
+type Response struct {
+	// URL is the final URL of the content after redirects
+	URL string
+	// StatusCode is the HTTP status code of the response
+	StatusCode int
+	// ContentType is the Content-Type of the response
+	ContentType string
+	// ContentLength is the Content-Length of the response, -1 if unknown
+	ContentLength int64
+	// ETag is the ETag of the response
+	ETag string
+	// LastModified is the Last-Modified header of the response
+	LastModified string
+	// Filename is the name of the content from Content-Disposition header or URL path
+	Filename string
+	// Body is the content, reading more than MaxSize bytes fails with LimitError
+	Body io.ReadCloser
+}
+
+// Fetcher downloads remote content over HTTP(S) with protection from server-side request forgery:
+// hosts are checked against allowed list, and connections to non-public addresses are refused
+// unless they are explicitly allowed. Addresses are checked at connection time,
+// so DNS rebinding and redirects to internal addresses are prevented too.
+type Fetcher struct {
+	client *http.Client
+	opts   Options
+}
+
+func New(opts Options) *Fetcher {
+	f := &Fetcher{opts: opts}
+	dialer := &net.Dialer{
+		Timeout: opts.Timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			return f.checkAddress(address)
+		},
+	}
+	f.client = &http.Client{
+		Timeout: opts.Timeout,
+		Transport: &http.Transport{
+			// proxy would connect to the target instead of the service, so addresses could not be checked
+			Proxy:               nil,
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: opts.Timeout,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > opts.MaxRedirects {
+				return &ForbiddenError{fmt.Sprintf("more than %d redirects", opts.MaxRedirects)}
+			}
+			return f.checkURL(req.URL)
+		},
+	}
+	return f
+}
+
+// Fetch requests content of given URL. Caller must close the body of returned response.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (*Response, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, &ForbiddenError{fmt.Sprintf("invalid URL: %s", err)}
+	}
+	if err := f.checkURL(u); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		var forbidden *ForbiddenError
+		if errors.As(err, &forbidden) {
+			return nil, forbidden
+		}
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected response status: %s", resp.Status)
+	}
+	if resp.ContentLength > f.opts.MaxSize {
+		resp.Body.Close()
+		return nil, &LimitError{fmt.Sprintf("content length %d exceeds %d bytes", resp.ContentLength, f.opts.MaxSize)}
+	}
+
+	return &Response{
+		URL:           resp.Request.URL.String(),
+		StatusCode:    resp.StatusCode,
+		ContentType:   resp.Header.Get("Content-Type"),
+		ContentLength: resp.ContentLength,
+		ETag:          resp.Header.Get("ETag"),
+		LastModified:  resp.Header.Get("Last-Modified"),
+		Filename:      filename(resp),
+		Body:          &limitedBody{ReadCloser: resp.Body, limit: f.opts.MaxSize, remaining: f.opts.MaxSize},
+	}, nil
+}
+
+// checkURL checks URL scheme and host
+func (f *Fetcher) checkURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return &ForbiddenError{fmt.Sprintf("unsupported URL scheme %q", u.Scheme)}
+	}
+	if u.User != nil {
+		return &ForbiddenError{"URL must not contain credentials"}
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "" {
+		return &ForbiddenError{"URL host is empty"}
+	}
+	if len(f.opts.AllowedHosts) == 0 {
+		return nil
+	}
+	for _, allowed := range f.opts.AllowedHosts {
+		if MatchHost(allowed, host) {
+			return nil
+		}
+	}
+	return &ForbiddenError{fmt.Sprintf("host %s is not allowed", host)}
+}
+
+// checkAddress checks that resolved address is public or belongs to allowed networks
+func (f *Fetcher) checkAddress(address string) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	ip = ip.Unmap()
+	if IsPublic(ip) {
+		return nil
+	}
+	for _, network := range f.opts.AllowedNetworks {
+		if network.Contains(ip) {
+			return nil
+		}
+	}
+	return &ForbiddenError{fmt.Sprintf("address %s is not public", ip)}
+}
+
+// IsPublic returns true if address is a public unicast address,
+// i.e. it is not private, loopback, link-local, multicast or otherwise reserved
+func IsPublic(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return false
+	}
+	for _, network := range nonPublicNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// MatchHost checks if host matches given pattern.
+// Pattern is either exact host name, e.g. example.com, or wildcard, e.g. *.example.com, matching any subdomain.
+func MatchHost(pattern string, host string) bool {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+		return strings.HasSuffix(host, "."+suffix)
+	}
+	return pattern == host
+}
+
+// filename returns name of the content from Content-Disposition header or URL path
+func filename(resp *http.Response) string {
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+		return path.Base(params["filename"])
+	}
+	if name := path.Base(resp.Request.URL.Path); name != "/" && name != "." {
+		return name
+	}
+	return resp.Request.URL.Hostname()
+}
+
+// limitedBody fails with LimitError if more than remaining bytes are read
+type limitedBody struct {
+	io.ReadCloser
+	limit     int64
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		var probe [1]byte
+		if n, err := b.ReadCloser.Read(probe[:]); n == 0 {
+			return 0, err
+		}
+		return 0, &LimitError{fmt.Sprintf("content exceeds %d bytes", b.limit)}
+	}
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	return n, err
+}
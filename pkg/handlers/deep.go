@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/netcracker/qubership-av-scan-service/pkg/archive"
+	"github.com/netcracker/qubership-av-scan-service/pkg/errors"
+	"github.com/netcracker/qubership-av-scan-service/pkg/log"
+	"github.com/netcracker/qubership-av-scan-service/pkg/spool"
+)
+
+// scanDeep scans given file and, if it is a supported archive,
+// expands it and scans each member, building a tree of scan statuses
+func (s *ScanHandler) scanDeep(ctx context.Context, filename string, f *spool.File) (*ScanStatus, error) {
+	limits := s.opts.ArchiveLimits
+	header, err := f.Header(archive.HeaderSize)
+	if err != nil {
+		return nil, errors.RequestBodyReadError(err)
+	}
+
+	format := archive.Detect(header)
+	if format == archive.FormatNone || limits.MaxDepth < 1 {
+		return s.scanFile(ctx, filename, f)
+	}
+	if f.Size() > limits.MaxSize {
+		return nil, errors.ArchiveLimitExceededError(
+			fmt.Errorf("content size exceeds %d bytes", limits.MaxSize),
+		)
+	}
+
+	return s.scanArchive(ctx, filename, format, f, 1, archive.NewBudget(limits))
+}
+
+// scanArchive scans archive as a whole and then each of its members.
+// Archive is considered infected if it is infected itself or any of its members.
+// Each archive, including nested ones and message attachments, is inspected for bombs before expansion.
+func (s *ScanHandler) scanArchive(
+	ctx context.Context,
+	name string,
+	format archive.Format,
+	f *spool.File,
+	depth int,
+	budget *archive.Budget,
+) (*ScanStatus, error) {
+	if bomb, err := s.inspectArchive(ctx, name, format, f); bomb != nil || err != nil {
+		return bomb, err
+	}
+	status, err := s.scanFile(ctx, name, f)
+	if err != nil {
+		return nil, err
+	}
+
+	err = archive.Walk(format, name, f, f.Size(), func(member string, r io.Reader) error {
+		if err := budget.Member(); err != nil {
+			return err
+		}
+		content, err := budget.Read(r)
+		if err != nil {
+			return err
+		}
+
+		memberStatus, err := s.scanMember(ctx, member, spool.FromBytes(content), depth+1, budget)
+		if err != nil {
+			return err
+		}
+		status.merge(memberStatus)
+		status.Members = append(status.Members, memberStatus)
+		return nil
+	})
+	if err != nil {
+		return nil, archiveError(err)
+	}
+	return status, nil
+}
+
+// inspectArchive checks archive for bombs. Detected bomb is rejected with error or,
+// if BombPolicy is flag, reported with archive bomb verdict and is not expanded.
+func (s *ScanHandler) inspectArchive(ctx context.Context, name string, format archive.Format, f *spool.File) (*ScanStatus, error) {
+	_, err := archive.Inspect(format, f, f.Size(), s.opts.BombLimits)
+	if err == nil {
+		return nil, nil
+	}
+	bomb, ok := err.(*archive.BombError)
+	if !ok {
+		return nil, errors.ArchiveReadError(err)
+	}
+	log.FromContext(ctx).Warn("archive bomb detected", "filename", name, "reason", bomb)
+	s.bombsCount.WithLabelValues(string(bomb.Reason)).Inc()
+	if s.opts.BombPolicy != BombPolicyFlag {
+		return nil, errors.ArchiveBombError(bomb)
+	}
+	return &ScanStatus{Filename: name, Verdict: VerdictArchiveBomb, Reason: bomb.Error()}, nil
+}
+
+// scanMember scans archive member, expanding it if it is a nested archive within depth limit
+func (s *ScanHandler) scanMember(
+	ctx context.Context,
+	name string,
+	f *spool.File,
+	depth int,
+	budget *archive.Budget,
+) (*ScanStatus, error) {
+	if depth <= s.opts.ArchiveLimits.MaxDepth {
+		header, err := f.Header(archive.HeaderSize)
+		if err != nil {
+			return nil, err
+		}
+		if format := archive.Detect(header); format != archive.FormatNone {
+			return s.scanArchive(ctx, name, format, f, depth, budget)
+		}
+	}
+	return s.scanFile(ctx, name, f)
+}
+
+// archiveError converts error happened during archive expansion to APIError
+func archiveError(err error) error {
+	switch e := err.(type) {
+	case *errors.APIError:
+		return e
+	case *archive.LimitError:
+		return errors.ArchiveLimitExceededError(e)
+	}
+	return errors.ArchiveReadError(err)
+}
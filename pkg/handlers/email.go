@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/netcracker/qubership-av-scan-service/pkg/archive"
+	"github.com/netcracker/qubership-av-scan-service/pkg/email"
+	"github.com/netcracker/qubership-av-scan-service/pkg/errors"
+	"github.com/netcracker/qubership-av-scan-service/pkg/log"
+	"github.com/netcracker/qubership-av-scan-service/pkg/spool"
+)
+
+// messageContentType is the content type of RFC 822 messages
+const messageContentType = "message/rfc822"
+
+// defaultMessageFilename is used for messages uploaded as request body without Content-Disposition filename
+const defaultMessageFilename = "message.eml"
+
+// messageFilename returns name of the message uploaded as request body
+func messageFilename(req *http.Request) string {
+	if _, params, err := mime.ParseMediaType(req.Header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+		return params["filename"]
+	}
+	return defaultMessageFilename
+}
+
+// isMessage checks if uploaded file is RFC 822 message by its content type or extension
+func isMessage(filename string, contentType string) bool {
+	return strings.Contains(contentType, messageContentType) || strings.EqualFold(path.Ext(filename), ".eml")
+}
+
+// scanMessage scans message as a whole and then each of its body parts and attachments.
+// Message is considered infected if it is infected itself or any of its parts.
+// Parts count and total decoded size are restricted by archive limits.
+// Files which can not be parsed as RFC 822 message, e.g. other files with .eml extension,
+// are scanned as opaque files, the same way as files of other types.
+func (s *ScanHandler) scanMessage(ctx context.Context, filename string, f *spool.File, deep bool) (*ScanStatus, error) {
+	status, err := s.scanFile(ctx, filename, f)
+	if err != nil {
+		return nil, err
+	}
+
+	budget := archive.NewBudget(s.opts.ArchiveLimits)
+	err = email.Walk(f.Reader(), func(p email.Part, r io.Reader) error {
+		if err := budget.Member(); err != nil {
+			return err
+		}
+		content, err := budget.Read(r)
+		if err != nil {
+			return err
+		}
+
+		name := p.Filename
+		if name == "" {
+			name = "part " + p.Path
+		}
+		var partStatus *ScanStatus
+		if deep {
+			partStatus, err = s.scanMember(ctx, name, spool.FromBytes(content), 1, budget)
+		} else {
+			partStatus, err = s.scanFile(ctx, name, spool.FromBytes(content))
+		}
+		if err != nil {
+			return err
+		}
+		partStatus.MimePath = p.Path
+		partStatus.TransferEncoding = p.Encoding
+		partStatus.Decoded = email.Decodable(p.Encoding)
+		status.merge(partStatus)
+		status.Members = append(status.Members, partStatus)
+		return nil
+	})
+	switch e := err.(type) {
+	case nil:
+		return status, nil
+	case *errors.APIError:
+		return nil, e
+	case *archive.LimitError:
+		return nil, errors.ArchiveLimitExceededError(e)
+	}
+
+	log.FromContext(ctx).Warn("failed to parse message, scanning it as opaque file", "filename", filename, "error", err)
+	if deep {
+		return s.scanDeep(ctx, filename, f)
+	}
+	return s.scanFile(ctx, filename, f)
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"github.com/netcracker/qubership-av-scan-service/pkg/errors"
+)
+
+// DefaultMaxDecodedBodySize is the max size of decompressed request body used if no explicit limit is configured
+const DefaultMaxDecodedBodySize = 1 << 30
+
+// zstdMaxWindow restricts memory used by zstd decoder
+const zstdMaxWindow = 64 << 20
+
+// decodedBody is a request body decompressed according to Content-Encoding header.
+// Reading more than limit bytes fails, and exceeded flag is set.
+type decodedBody struct {
+	r        io.Reader
+	closers  []func()
+	limit    int64
+	read     int64
+	exceeded bool
+}
+
+func (b *decodedBody) Read(p []byte) (int, error) {
+	if b.read >= b.limit {
+		// check if there is any content beyond the limit
+		var probe [1]byte
+		if n, err := b.r.Read(probe[:]); n == 0 {
+			return 0, err
+		}
+		b.exceeded = true
+		return 0, fmt.Errorf("decompressed body exceeds %d bytes", b.limit)
+	}
+	if int64(len(p)) > b.limit-b.read {
+		p = p[:b.limit-b.read]
+	}
+	n, err := b.r.Read(p)
+	b.read += int64(n)
+	return n, err
+}
+
+func (b *decodedBody) Close() error {
+	for _, c := range b.closers {
+		c()
+	}
+	return nil
+}
+
+// decodeBody replaces request body with reader decompressing it according to Content-Encoding header.
+// Multiple encodings are decoded in reverse order of their application.
+// It returns nil if body is not encoded.
+func decodeBody(req *http.Request, limit int64) (*decodedBody, error) {
+	header := req.Header.Get("Content-Encoding")
+	if header == "" {
+		return nil, nil
+	}
+
+	if limit <= 0 {
+		limit = DefaultMaxDecodedBodySize
+	}
+	body := &decodedBody{r: req.Body, limit: limit}
+	encodings := strings.Split(header, ",")
+	for i := len(encodings) - 1; i >= 0; i-- {
+		encoding := strings.ToLower(strings.TrimSpace(encodings[i]))
+		var err error
+		switch encoding {
+		case "identity", "":
+		case "gzip", "x-gzip":
+			var zr *gzip.Reader
+			if zr, err = gzip.NewReader(body.r); err == nil {
+				body.r = zr
+			}
+		case "deflate":
+			body.r, err = deflateReader(body.r)
+		case "br":
+			body.r = brotli.NewReader(body.r)
+		case "zstd":
+			var zr *zstd.Decoder
+			if zr, err = zstd.NewReader(body.r, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxWindow(zstdMaxWindow)); err == nil {
+				body.r = zr
+				body.closers = append(body.closers, zr.Close)
+			}
+		default:
+			body.Close()
+			return nil, errors.ContentEncodingUnsupportedError(encoding)
+		}
+		if err != nil {
+			body.Close()
+			return nil, errors.RequestBodyReadError(err)
+		}
+	}
+
+	req.Body = body
+	req.ContentLength = -1
+	req.Header.Del("Content-Encoding")
+	return body, nil
+}
+
+// deflateReader returns reader for deflate content encoding. According to RFC 9110 it is zlib format,
+// but some clients send raw deflate stream, so zlib header is checked to distinguish them.
+func deflateReader(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(2)
+	if err != nil {
+		return nil, err
+	}
+	if header[0]&0x0F == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
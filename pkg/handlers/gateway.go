@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/netcracker/qubership-av-scan-service/pkg/errors"
+	"github.com/netcracker/qubership-av-scan-service/pkg/spool"
+)
+
+// defaultUploadFilename is used for raw uploads when name can not be taken from request
+const defaultUploadFilename = "upload"
+
+// DefaultMaxGatewayBodySize is the max size of request body scanned by the gateway if no explicit limit is configured
+const DefaultMaxGatewayBodySize = 1 << 30
+
+// GatewayHandler scans files uploaded in requests passing through scanning gateway to upstream backend.
+// Files of multipart/form-data bodies are scanned one by one the same way as by scan endpoint,
+// form fields without filename are skipped. Bodies of other content types are scanned as a single raw upload.
+type GatewayHandler struct {
+	scanner     *ScanHandler
+	deep        bool
+	maxBodySize int64
+}
+
+// NewGatewayHandler returns GatewayHandler which scans uploads with given scanner,
+// archives are expanded if deep is true. Requests with body larger than maxBodySize are rejected.
+func NewGatewayHandler(scanner *ScanHandler, deep bool, maxBodySize int64) *GatewayHandler {
+	if maxBodySize <= 0 {
+		maxBodySize = DefaultMaxGatewayBodySize
+	}
+	return &GatewayHandler{scanner: scanner, deep: deep, maxBodySize: maxBodySize}
+}
+
+// Scan spools request body and scans uploaded files.
+// If all files are clean, request body is replaced with spooled content, so request can be forwarded upstream,
+// and returned file must be closed after that. If any file is not clean, UploadBlockedError is returned.
+func (g *GatewayHandler) Scan(req *http.Request) (*spool.File, error) {
+	if req.ContentLength > g.maxBodySize {
+		req.Body.Close()
+		return nil, errors.UploadLimitExceededError(g.maxBodySize)
+	}
+	body, err := spool.New(io.LimitReader(req.Body, g.maxBodySize+1), spoolMemoryLimit)
+	req.Body.Close()
+	if err != nil {
+		return nil, errors.RequestBodyReadError(err)
+	}
+	if body.Size() > g.maxBodySize {
+		body.Close()
+		return nil, errors.UploadLimitExceededError(g.maxBodySize)
+	}
+
+	statuses, err := g.scan(req, body)
+	if err != nil {
+		body.Close()
+		return nil, err
+	}
+	var blocked []string
+	for _, status := range statuses {
+		if status.Verdict != VerdictClean {
+			blocked = append(blocked, describeVerdict(status))
+		}
+	}
+	if len(blocked) > 0 {
+		body.Close()
+		return nil, errors.UploadBlockedError(strings.Join(blocked, "; "))
+	}
+
+	req.Body = io.NopCloser(body.Reader())
+	req.ContentLength = body.Size()
+	req.TransferEncoding = nil
+	return body, nil
+}
+
+// scan scans spooled body of the request, body is decompressed according to Content-Encoding for scanning only
+func (g *GatewayHandler) scan(req *http.Request, body *spool.File) ([]*ScanStatus, error) {
+	if body.Size() == 0 {
+		return nil, nil
+	}
+
+	scanReq := req.Clone(req.Context())
+	scanReq.Body = io.NopCloser(body.Reader())
+	decoded, err := decodeBody(scanReq, g.scanner.opts.MaxDecodedBodySize)
+	if err != nil {
+		return nil, err
+	}
+	if decoded == nil {
+		return g.scanUploads(scanReq)
+	}
+	defer decoded.Close()
+
+	statuses, err := g.scanUploads(scanReq)
+	if err != nil && decoded.exceeded {
+		return nil, errors.DecodedBodyLimitExceededError(decoded.limit)
+	}
+	return statuses, err
+}
+
+// scanUploads scans files of multipart/form-data body or the whole body of raw upload
+func (g *GatewayHandler) scanUploads(req *http.Request) ([]*ScanStatus, error) {
+	contentType := req.Header.Get("Content-Type")
+	if !strings.Contains(contentType, "multipart/form-data") {
+		status, err := g.scanner.scanPart(req.Context(), uploadFilename(req), contentType, req.Body, g.deep)
+		if err != nil {
+			return nil, err
+		}
+		g.scanner.reportViruses(req, status)
+		return []*ScanStatus{status}, nil
+	}
+
+	reader, err := req.MultipartReader()
+	if err != nil {
+		return nil, errors.RequestBodyReadError(err)
+	}
+	return g.scanner.scanMultipart(req, reader, g.deep, 0, true)
+}
+
+// uploadFilename returns name of raw upload from Content-Disposition header or request path
+func uploadFilename(req *http.Request) string {
+	if _, params, err := mime.ParseMediaType(req.Header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+		return params["filename"]
+	}
+	if name := path.Base(req.URL.Path); name != "/" && name != "." {
+		return name
+	}
+	return defaultUploadFilename
+}
+
+// describeVerdict returns short description of not clean scan status for error details
+func describeVerdict(status *ScanStatus) string {
+	switch {
+	case status.Infected:
+		return fmt.Sprintf("%s: %s (%s)", status.Filename, status.Verdict, status.Virus)
+	case status.Reason != "":
+		return fmt.Sprintf("%s: %s (%s)", status.Filename, status.Verdict, status.Reason)
+	}
+	return fmt.Sprintf("%s: %s", status.Filename, status.Verdict)
+}
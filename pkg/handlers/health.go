@@ -2,13 +2,14 @@ package handlers
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/netcracker/qubership-av-scan-service/pkg/clamav"
 	"github.com/netcracker/qubership-av-scan-service/pkg/errors"
 )
 
 // HealthHandler handles health requests.
-// It verifies that clamd is ready to be used.
+// It verifies that clamd is ready to be used and, if maxDatabaseAge parameter is set, that its DB is not outdated.
 type HealthHandler struct {
 	clamd clamav.Clamd
 }
@@ -17,10 +18,26 @@ func NewHealthHandler(clamd clamav.Clamd) *HealthHandler {
 	return &HealthHandler{clamd: clamd}
 }
 
-func (h *HealthHandler) Handle(_ *http.Request) (any, error) {
+func (h *HealthHandler) Handle(req *http.Request) (any, error) {
 	err := h.clamd.Ping()
 	if err != nil {
 		return nil, errors.ClamdPingError(err)
 	}
+
+	v := req.URL.Query().Get("maxDatabaseAge")
+	if v == "" {
+		return nil, nil
+	}
+	maxAge, err := time.ParseDuration(v)
+	if err != nil || maxAge <= 0 {
+		return nil, errors.InvalidParameterError("maxDatabaseAge", v)
+	}
+	ageSeconds, err := h.clamd.DatabaseAge()
+	if err != nil {
+		return nil, errors.DatabaseVersionError(err)
+	}
+	if age := time.Duration(ageSeconds * float64(time.Second)).Round(time.Second); age > maxAge {
+		return nil, errors.DatabaseOutdatedError(age, maxAge)
+	}
 	return nil, nil
 }
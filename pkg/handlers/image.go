@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/netcracker/qubership-av-scan-service/pkg/archive"
+	apierrors "github.com/netcracker/qubership-av-scan-service/pkg/errors"
+	"github.com/netcracker/qubership-av-scan-service/pkg/image"
+	"github.com/netcracker/qubership-av-scan-service/pkg/log"
+)
+
+// ImageScanResult is a struct representing a result of a single container image scan.
+type ImageScanResult struct {
+	// Filename is the name of the image tarball which was scanned
+	Filename string `json:"filename"`
+	// Infected is true if virus was found in any layer
+	Infected bool `json:"infected"`
+	// Verdict is a final decision made about the image
+	Verdict Verdict `json:"verdict"`
+	// Reason describes why verdict was made, set only for verdicts not related to viruses
+	Reason string `json:"reason,omitempty"`
+	// Detections lists files which are not clean
+	Detections []*ImageDetection `json:"detections"`
+	// Layers contains statistics of each layer
+	Layers []*LayerStats `json:"layers"`
+	// Stats contains aggregate statistics of the image
+	Stats ImageStats `json:"stats"`
+}
+
+// ImageDetection is a file of image layer which is not clean
+type ImageDetection struct {
+	// Layer is the digest of the layer containing the file
+	Layer string `json:"layer"`
+	// Path is the path of the file in the image filesystem
+	Path string `json:"path"`
+	// Hidden is true if file is removed or replaced in upper layers,
+	// so it is not visible in container filesystem, but it is still distributed with the image
+	Hidden bool `json:"hidden"`
+	// Status is the scan status of the file
+	Status *ScanStatus `json:"status"`
+}
+
+// LayerStats contains statistics of a single image layer scan
+type LayerStats struct {
+	// Digest identifies the layer
+	Digest string `json:"digest"`
+	// Files is the number of scanned regular files
+	Files int `json:"files"`
+	// Size is the total size of scanned files in bytes
+	Size int64 `json:"size"`
+	// Detections is the number of files which are not clean
+	Detections int `json:"detections"`
+}
+
+// ImageStats contains aggregate statistics of an image scan
+type ImageStats struct {
+	// Layers is the number of scanned layers
+	Layers int `json:"layers"`
+	// Files is the number of scanned regular files
+	Files int `json:"files"`
+	// Size is the total size of scanned files in bytes
+	Size int64 `json:"size"`
+	// Infected is the number of infected files
+	Infected int `json:"infected"`
+	// Blocked is the number of files which are not clean for reasons other than viruses
+	Blocked int `json:"blocked"`
+}
+
+// ImageHandler handles container image scan requests.
+// It parses multipart/form-data to `docker save` or OCI layout tarballs, optionally gzip-compressed,
+// and verifies each regular file of each layer.
+// Image is restricted by image limits of the scanner: tarball size, number of layer files and their total size.
+type ImageHandler struct {
+	scanner *ScanHandler
+}
+
+// DefaultImageLimits returns image limits used when no explicit limits are configured.
+// Images are much larger than regular archives, so limits are sized for real images with several layers.
+// MaxDepth is not used, as layer files are not expanded.
+func DefaultImageLimits() archive.Limits {
+	return archive.Limits{
+		MaxMembers: 200000,
+		MaxSize:    10 << 30,
+	}
+}
+
+// NewImageHandler returns ImageHandler which scans image files with given scanner
+func NewImageHandler(scanner *ScanHandler) *ImageHandler {
+	return &ImageHandler{scanner: scanner}
+}
+
+func (h *ImageHandler) Handle(req *http.Request) (any, error) {
+	contentType := req.Header.Get("Content-Type")
+	if !strings.Contains(contentType, "multipart/form-data") {
+		return nil, apierrors.ContentTypeUnsupportedError(contentType)
+	}
+
+	results := make([]*ImageScanResult, 0)
+	reader, err := req.MultipartReader()
+	if err != nil {
+		return nil, apierrors.RequestBodyReadError(err)
+	}
+
+	part, partErr := reader.NextPart()
+	for partErr != io.EOF {
+		if partErr != nil {
+			return nil, apierrors.RequestBodyReadError(partErr)
+		}
+
+		filename := part.FileName()
+		if filename == "" {
+			return nil, apierrors.FilenameNotSpecifiedError()
+		}
+
+		f, err := archive.Spool(part, h.scanner.opts.ImageLimits.MaxSize, spoolMemoryLimit)
+		if err != nil {
+			return nil, imageError(err, apierrors.RequestBodyReadError)
+		}
+		result, err := h.ScanImage(req.Context(), filename, f, f.Size())
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+
+		part, partErr = reader.NextPart()
+	}
+
+	return results, nil
+}
+
+// ScanImage scans each regular file of each layer of the image tarball.
+// Tarball compressed with gzip is decompressed before scanning.
+func (h *ImageHandler) ScanImage(ctx context.Context, filename string, r io.ReaderAt, size int64) (*ImageScanResult, error) {
+	limits := h.scanner.opts.ImageLimits
+	if size > limits.MaxSize {
+		return nil, apierrors.ArchiveLimitExceededError(
+			fmt.Errorf("content size exceeds %d bytes", limits.MaxSize),
+		)
+	}
+	header := make([]byte, 2)
+	if _, err := r.ReadAt(header, 0); err == nil && bytes.Equal(header, []byte{0x1F, 0x8B}) {
+		zr, err := gzip.NewReader(io.NewSectionReader(r, 0, size))
+		if err != nil {
+			return nil, apierrors.ImageReadError(err)
+		}
+		f, err := archive.Spool(zr, limits.MaxSize, spoolMemoryLimit)
+		if err != nil {
+			return nil, imageError(err, apierrors.ImageReadError)
+		}
+		defer f.Close()
+		r, size = f, f.Size()
+	}
+
+	img, err := image.Open(r, size)
+	if err != nil {
+		return nil, apierrors.ImageReadError(err)
+	}
+
+	result := &ImageScanResult{Filename: filename, Detections: []*ImageDetection{}}
+	for _, l := range img.Layers {
+		result.Layers = append(result.Layers, &LayerStats{Digest: l.Digest})
+	}
+	aggregate := &ScanStatus{Filename: filename, Verdict: VerdictClean}
+	var detected []image.File
+	budget := archive.NewBudget(limits)
+	err = img.Walk(func(file image.File, r io.Reader) error {
+		if err := budget.Member(); err != nil {
+			return err
+		}
+		f, err := budget.Spool(r, spoolMemoryLimit)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		status, err := h.scanner.scanFile(ctx, file.Path, f)
+		if err != nil {
+			return err
+		}
+
+		layer := result.Layers[file.Layer]
+		layer.Files++
+		layer.Size += f.Size()
+		if status.Verdict == VerdictClean {
+			return nil
+		}
+		layer.Detections++
+		if status.Infected {
+			result.Stats.Infected++
+			log.FromContext(ctx).Warn(
+				"virus detected",
+				"virus", status.Virus,
+				"filename", filename,
+				"layer", layer.Digest,
+				"path", file.Path,
+			)
+			h.scanner.virusesCount.Inc()
+		} else {
+			result.Stats.Blocked++
+		}
+		aggregate.merge(status)
+		detected = append(detected, file)
+		result.Detections = append(result.Detections, &ImageDetection{
+			Layer:  layer.Digest,
+			Path:   file.Path,
+			Status: status,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, imageError(err, apierrors.ImageReadError)
+	}
+
+	for i, file := range detected {
+		result.Detections[i].Hidden = img.Hidden(file)
+	}
+	for _, l := range result.Layers {
+		result.Stats.Layers++
+		result.Stats.Files += l.Files
+		result.Stats.Size += l.Size
+	}
+	result.Infected = aggregate.Infected
+	result.Verdict = aggregate.Verdict
+	result.Reason = aggregate.Reason
+	return result, nil
+}
+
+// imageError converts error happened during image scan to APIError, using fallback for errors of reading image.
+// Errors of walking layers are wrapped, so they are unwrapped to find APIError or exceeded limit.
+func imageError(err error, fallback func(error) *apierrors.APIError) error {
+	var apiErr *apierrors.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	var limitErr *archive.LimitError
+	if errors.As(err, &limitErr) {
+		return apierrors.ArchiveLimitExceededError(err)
+	}
+	return fallback(err)
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/netcracker/qubership-av-scan-service/pkg/errors"
+	"github.com/netcracker/qubership-av-scan-service/pkg/log"
+	"github.com/netcracker/qubership-av-scan-service/pkg/s3"
+	"github.com/netcracker/qubership-av-scan-service/pkg/spool"
+)
+
+// DefaultMaxObjects is the default maximum number of objects scanned by a single prefix request
+const DefaultMaxObjects = 1000
+
+// Tags set on scanned objects when tagging is requested
+const (
+	VerdictTag = "av-verdict"
+	VirusTag   = "av-virus"
+)
+
+// ObjectScanRequest is a body of object storage scan request
+type ObjectScanRequest struct {
+	// Bucket is the name of the bucket containing objects
+	Bucket string `json:"bucket"`
+	// Key is the key of a single object to scan, mutually exclusive with Prefix
+	Key string `json:"key,omitempty"`
+	// Prefix selects all objects with keys starting with it, the whole bucket is scanned if both Key and Prefix are empty
+	Prefix string `json:"prefix,omitempty"`
+	// Tag requests to set verdict tags on scanned objects
+	Tag bool `json:"tag,omitempty"`
+}
+
+// ObjectScanResult is a result of a single object scan
+type ObjectScanResult struct {
+	// Bucket is the name of the bucket containing the object
+	Bucket string `json:"bucket"`
+	// Key is the key of the object
+	Key string `json:"key"`
+	// Size is the size of the object in bytes
+	Size int64 `json:"size"`
+	// ETag is the entity tag of the scanned object
+	ETag string `json:"etag,omitempty"`
+	// Tagged is true if verdict tags were set on the object,
+	// object which is overwritten during scan in not versioned bucket is not tagged
+	Tagged bool `json:"tagged"`
+	// Status is the scan status of the object content, it is not set if the object was not scanned
+	Status *ScanStatus `json:"status,omitempty"`
+	// Error describes why the object was not scanned, e.g. it is larger than MaxFileSize
+	Error *errors.APIError `json:"error,omitempty"`
+}
+
+// ObjectScanHandler handles scan requests for objects of S3-compatible storage.
+// It streams objects selected by key or prefix from the storage, verifies them the same way as uploaded files,
+// and optionally tags them with the verdict.
+type ObjectScanHandler struct {
+	scanner    *ScanHandler
+	client     *s3.Client
+	maxObjects int
+}
+
+// NewObjectScanHandler returns ObjectScanHandler which reads objects with given client and scans them with given scanner.
+// Prefix requests matching more than maxObjects objects are rejected.
+func NewObjectScanHandler(scanner *ScanHandler, client *s3.Client, maxObjects int) *ObjectScanHandler {
+	return &ObjectScanHandler{scanner: scanner, client: client, maxObjects: maxObjects}
+}
+
+func (h *ObjectScanHandler) Handle(req *http.Request) (any, error) {
+	contentType := req.Header.Get("Content-Type")
+	if !strings.Contains(contentType, "application/json") {
+		return nil, errors.ContentTypeUnsupportedError(contentType)
+	}
+
+	var scanReq ObjectScanRequest
+	if err := json.NewDecoder(http.MaxBytesReader(nil, req.Body, maxJSONRequestSize)).Decode(&scanReq); err != nil {
+		return nil, errors.InvalidParameterError("body", err.Error())
+	}
+	return h.ScanObjects(req.Context(), scanReq)
+}
+
+// ScanObjects scans the object selected by key or all objects selected by prefix and returns results in key order
+func (h *ObjectScanHandler) ScanObjects(ctx context.Context, scanReq ObjectScanRequest) ([]*ObjectScanResult, error) {
+	if scanReq.Bucket == "" {
+		return nil, errors.InvalidParameterError("bucket", scanReq.Bucket)
+	}
+	if scanReq.Key != "" && scanReq.Prefix != "" {
+		return nil, errors.InvalidParameterError("prefix", scanReq.Prefix)
+	}
+
+	keys := []string{scanReq.Key}
+	if scanReq.Key == "" {
+		objects, err := h.client.List(ctx, scanReq.Bucket, scanReq.Prefix, h.maxObjects)
+		if err != nil {
+			if limitErr, ok := err.(*s3.LimitError); ok {
+				return nil, errors.ObjectLimitExceededError(limitErr.Limit)
+			}
+			return nil, errors.ObjectStorageError(err)
+		}
+		keys = keys[:0]
+		for _, obj := range objects {
+			keys = append(keys, obj.Key)
+		}
+	}
+
+	results := make([]*ObjectScanResult, 0, len(keys))
+	for _, key := range keys {
+		result, err := h.scanObject(ctx, scanReq.Bucket, key, scanReq.Tag)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// scanObject streams a single object from the storage, scans it and tags it if requested
+func (h *ObjectScanHandler) scanObject(ctx context.Context, bucket string, key string, tag bool) (*ObjectScanResult, error) {
+	r, obj, err := h.client.Get(ctx, bucket, key)
+	if err != nil {
+		return nil, errors.ObjectStorageError(err)
+	}
+	defer r.Close()
+
+	// large objects are not spooled, since clamd rejects them anyway
+	limit := h.scanner.maxFileSize()
+	if obj.Size > limit {
+		return h.notScanned(ctx, bucket, obj, errors.UploadLimitExceededError(limit)), nil
+	}
+	f, err := spool.New(io.LimitReader(r, limit+1), spoolMemoryLimit)
+	if err != nil {
+		return nil, errors.ObjectStorageError(err)
+	}
+	defer f.Close()
+	if f.Size() > limit {
+		return h.notScanned(ctx, bucket, obj, errors.UploadLimitExceededError(limit)), nil
+	}
+
+	status, err := h.scanner.scanFile(ctx, key, f)
+	if err != nil {
+		return nil, err
+	}
+	if status.Infected {
+		log.FromContext(ctx).Warn(
+			"virus detected",
+			"virus", status.Virus,
+			"bucket", bucket,
+			"key", key,
+		)
+		h.scanner.virusesCount.Inc()
+	}
+
+	result := &ObjectScanResult{Bucket: bucket, Key: key, Size: f.Size(), ETag: obj.ETag, Status: status}
+	if tag {
+		tags := map[string]string{VerdictTag: string(status.Verdict)}
+		if status.Infected {
+			tags[VirusTag] = status.Virus
+		}
+		err := h.client.Tag(ctx, bucket, obj, tags)
+		switch {
+		case err == s3.ErrObjectChanged:
+			// verdict of scanned content must not be assigned to the new content
+			log.FromContext(ctx).Warn("object changed during scan, it is not tagged", "bucket", bucket, "key", key)
+		case err != nil:
+			return nil, errors.ObjectStorageError(err)
+		default:
+			result.Tagged = true
+		}
+	}
+	return result, nil
+}
+
+// notScanned returns result of the object which was not scanned because of given error
+func (h *ObjectScanHandler) notScanned(ctx context.Context, bucket string, obj s3.Object, err *errors.APIError) *ObjectScanResult {
+	log.FromContext(ctx).Warn("object is not scanned", "bucket", bucket, "key", obj.Key, "error", err)
+	return &ObjectScanResult{Bucket: bucket, Key: obj.Key, Size: obj.Size, ETag: obj.ETag, Error: err}
+}
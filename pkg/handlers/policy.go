@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/netcracker/qubership-av-scan-service/pkg/inspect"
+)
+
+// TypePolicy restricts types of files which are accepted by the service.
+// Types are specified as MIME patterns, e.g. application/pdf or image/*.
+type TypePolicy struct {
+	// Allowed lists accepted types, if empty any type not denied is accepted
+	Allowed []string
+	// Denied lists rejected types, it has precedence over Allowed
+	Denied []string
+}
+
+// Check returns a reason why file of given type is blocked by policy, or empty string if it is accepted
+func (p TypePolicy) Check(mime string) string {
+	for _, pattern := range p.Denied {
+		if inspect.MatchMIME(pattern, mime) {
+			return fmt.Sprintf("file type %s is denied", mime)
+		}
+	}
+	if len(p.Allowed) == 0 {
+		return ""
+	}
+	for _, pattern := range p.Allowed {
+		if inspect.MatchMIME(pattern, mime) {
+			return ""
+		}
+	}
+	return fmt.Sprintf("file type %s is not allowed", mime)
+}
+
+// FindingPolicy defines which types of findings make the file blocked
+type FindingPolicy struct {
+	// Blocked lists finding types which block the file
+	Blocked []inspect.FindingType
+}
+
+// Check returns a reason why file with given findings is blocked by policy, or empty string if it is accepted
+func (p FindingPolicy) Check(findings []inspect.Finding) string {
+	for _, f := range findings {
+		if slices.Contains(p.Blocked, f.Type) {
+			return fmt.Sprintf("%s found: %s", f.Type, f.Description)
+		}
+	}
+	return ""
+}
+
+// block sets given verdict and reason to status, unless more severe verdict is already set
+func (s *ScanStatus) block(verdict Verdict, reason string) {
+	if s.Verdict == VerdictClean {
+		s.Verdict = verdict
+		s.Reason = reason
+	}
+}
+
+// merge propagates verdict of archive member to the archive status
+func (s *ScanStatus) merge(member *ScanStatus) {
+	if member.Infected && !s.Infected {
+		s.Infected = true
+		s.Virus = member.Virus
+		s.Verdict = VerdictInfected
+		s.Reason = ""
+	}
+	if member.Verdict != VerdictClean && member.Verdict != VerdictInfected {
+		s.block(member.Verdict, fmt.Sprintf("%s: %s", member.Filename, member.Reason))
+	}
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/netcracker/qubership-av-scan-service/pkg/cdr"
+	"github.com/netcracker/qubership-av-scan-service/pkg/errors"
+	"github.com/netcracker/qubership-av-scan-service/pkg/inspect"
+	"github.com/netcracker/qubership-av-scan-service/pkg/log"
+	"github.com/netcracker/qubership-av-scan-service/pkg/spool"
+)
+
+// SanitizeResult is a struct representing a result of a single file sanitization.
+type SanitizeResult struct {
+	// Filename is the name of the sanitized file,
+	// macro-enabled extension is replaced with macro-free one, e.g. .docm with .docx
+	Filename string `json:"filename"`
+	// Content is the sanitized file, it is omitted if sanitized file is still not clean
+	Content []byte `json:"content,omitempty"`
+	// Removed lists content removed from the file
+	Removed []cdr.Removal `json:"removed"`
+	// Scan is the scan status of the sanitized file
+	Scan *ScanStatus `json:"scan"`
+}
+
+// SanitizeHandler handles content disarm and reconstruction requests.
+// It parses multipart/form-data to OOXML documents, removes active content from each one,
+// and rescans the result.
+type SanitizeHandler struct {
+	scanner *ScanHandler
+}
+
+// NewSanitizeHandler returns SanitizeHandler which rescans sanitized files with given scanner
+func NewSanitizeHandler(scanner *ScanHandler) *SanitizeHandler {
+	return &SanitizeHandler{scanner: scanner}
+}
+
+func (s *SanitizeHandler) Handle(req *http.Request) (any, error) {
+	contentType := req.Header.Get("Content-Type")
+	if !strings.Contains(contentType, "multipart/form-data") {
+		return nil, errors.ContentTypeUnsupportedError(contentType)
+	}
+
+	results := make([]*SanitizeResult, 0)
+	reader, err := req.MultipartReader()
+	if err != nil {
+		return nil, errors.RequestBodyReadError(err)
+	}
+
+	part, partErr := reader.NextPart()
+	for partErr != io.EOF {
+		if partErr != nil {
+			return nil, errors.RequestBodyReadError(partErr)
+		}
+
+		filename := part.FileName()
+		if filename == "" {
+			return nil, errors.FilenameNotSpecifiedError()
+		}
+
+		result, err := s.sanitizePart(req.Context(), filename, part)
+		if err != nil {
+			return nil, err
+		}
+		if len(result.Removed) > 0 {
+			log.From(req).Info(
+				"active content removed",
+				"filename", filename,
+				"removed", len(result.Removed),
+			)
+		}
+		results = append(results, result)
+
+		part, partErr = reader.NextPart()
+	}
+
+	return results, nil
+}
+
+// sanitizePart spools content of a single uploaded file, sanitizes it and scans the result,
+// UploadLimitExceededError is returned if file is larger than MaxFileSize of the scanner
+func (s *SanitizeHandler) sanitizePart(ctx context.Context, filename string, r io.Reader) (*SanitizeResult, error) {
+	limit := s.scanner.maxFileSize()
+	f, err := spool.New(io.LimitReader(r, limit+1), spoolMemoryLimit)
+	if err != nil {
+		return nil, errors.RequestBodyReadError(err)
+	}
+	defer f.Close()
+	if f.Size() > limit {
+		return nil, errors.UploadLimitExceededError(limit)
+	}
+
+	fileType, err := inspect.DetectFileType(f, f.Size())
+	if err != nil {
+		return nil, errors.RequestBodyReadError(err)
+	}
+	if !isOOXML(fileType.MIME) {
+		return nil, errors.SanitizeUnsupportedError(fileType.MIME)
+	}
+
+	buf := &bytes.Buffer{}
+	removed, err := cdr.SanitizeOOXML(f, f.Size(), buf)
+	if err != nil {
+		return nil, errors.SanitizeError(err)
+	}
+
+	result := &SanitizeResult{
+		Filename: cdr.SanitizedName(filename),
+		Removed:  removed,
+	}
+	if result.Removed == nil {
+		result.Removed = []cdr.Removal{}
+	}
+	result.Scan, err = s.scanner.scanFile(ctx, result.Filename, spool.FromBytes(buf.Bytes()))
+	if err != nil {
+		return nil, err
+	}
+	if result.Scan.Infected {
+		log.FromContext(ctx).Warn(
+			"virus detected in sanitized file",
+			"virus", result.Scan.Virus,
+			"filename", filename,
+		)
+		s.scanner.virusesCount.Inc()
+	}
+	if result.Scan.Verdict == VerdictClean {
+		result.Content = buf.Bytes()
+	}
+	return result, nil
+}
+
+// isOOXML checks if MIME type is an Office Open XML document type
+func isOOXML(mime string) bool {
+	return strings.HasPrefix(mime, "application/vnd.openxmlformats-officedocument.") ||
+		strings.HasPrefix(mime, "application/vnd.ms-word.") ||
+		strings.HasPrefix(mime, "application/vnd.ms-excel.") ||
+		strings.HasPrefix(mime, "application/vnd.ms-powerpoint.")
+}
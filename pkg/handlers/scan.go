@@ -1,92 +1,458 @@
 package handlers
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"io"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"strconv"
 	"strings"
 
+	"github.com/netcracker/qubership-av-scan-service/pkg/archive"
 	"github.com/netcracker/qubership-av-scan-service/pkg/clamav"
+	"github.com/netcracker/qubership-av-scan-service/pkg/email"
 	"github.com/netcracker/qubership-av-scan-service/pkg/errors"
+	"github.com/netcracker/qubership-av-scan-service/pkg/inspect"
 	"github.com/netcracker/qubership-av-scan-service/pkg/log"
+	"github.com/netcracker/qubership-av-scan-service/pkg/spool"
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Verdict is a final decision made about scanned file, it replaces Infected flag of ScanStatus.
+// Verdicts other than clean and infected are opt-in: they are reported only if corresponding policy is configured,
+// since clients checking only Infected flag would accept such files.
+type Verdict string
+
+const (
+	// VerdictClean means that no threats were found
+	VerdictClean Verdict = "clean"
+	// VerdictInfected means that virus was found
+	VerdictInfected Verdict = "infected"
+	// VerdictArchiveBomb means that file is an archive detected as decompression bomb, reported only with BombPolicyFlag
+	VerdictArchiveBomb Verdict = "archive_bomb"
+	// VerdictPolicyBlocked means that file is rejected by configured TypePolicy or FindingPolicy
+	VerdictPolicyBlocked Verdict = "policy_blocked"
+)
+
+// EncryptedPolicy defines how encrypted archives and documents are handled
+type EncryptedPolicy string
+
+const (
+	// EncryptedPolicyFlag only reports encrypted flag in scan status
+	EncryptedPolicyFlag EncryptedPolicy = "flag"
+	// EncryptedPolicyInfected reports encrypted files as infected
+	EncryptedPolicyInfected EncryptedPolicy = "infected"
+)
+
+// BombPolicy defines how archive bombs are handled in deep scan mode
+type BombPolicy string
+
+const (
+	// BombPolicyReject fails the whole request with APIError
+	BombPolicyReject BombPolicy = "reject"
+	// BombPolicyFlag reports archive bomb with dedicated verdict without scanning it
+	BombPolicyFlag BombPolicy = "flag"
 )
 
 // ScanStatus is a struct representing a single file scan status.
 type ScanStatus struct {
 	// Filename is the name of the file which was scanned.
 	Filename string `json:"filename"`
-	// Infected is true if virus was found
+	// TransferEncoding is the Content-Transfer-Encoding of the uploaded part or message part
+	TransferEncoding string `json:"transferEncoding,omitempty"`
+	// Decoded is true if content was decoded from TransferEncoding before scanning,
+	// transfer-encoded content with unknown encoding is scanned as is
+	Decoded bool `json:"decoded,omitempty"`
+	// MimePath is a position of the part in the message MIME tree, e.g. "2.1", set only for message parts
+	MimePath string `json:"mimePath,omitempty"`
+	// Remote describes fetched remote content, set only for URL scans
+	Remote *RemoteSource `json:"remote,omitempty"`
+	// Infected is true if virus was found, it is false for files blocked for other reasons, see Verdict
 	Infected bool `json:"infected"`
 	// Virus is a string representing found virus, set only if Infected
 	Virus string `json:"virus,omitempty"`
+	// Encrypted is true if file is an encrypted archive or document
+	Encrypted bool `json:"encrypted"`
+	// SHA256 is a hex-encoded SHA-256 hash of the file content
+	SHA256 string `json:"sha256,omitempty"`
+	// MimeType is a file type detected from the file content
+	MimeType string `json:"mimeType,omitempty"`
+	// ExtensionMismatch is true if file extension does not correspond to detected file type
+	ExtensionMismatch bool `json:"extensionMismatch"`
+	// Findings contains potentially dangerous content found by Go-side inspection, e.g. macros
+	Findings []inspect.Finding `json:"findings,omitempty"`
+	// Verdict is a final decision made about the file
+	Verdict Verdict `json:"verdict"`
+	// Reason describes why verdict was made, set only for verdicts not related to viruses
+	Reason string `json:"reason,omitempty"`
+	// Members contains scan statuses of archive members, set only in deep scan mode
+	Members []*ScanStatus `json:"members,omitempty"`
+}
+
+// ScanOptions configures optional behaviour of ScanHandler
+type ScanOptions struct {
+	// ArchiveLimits restricts expansion of archives in deep scan mode
+	ArchiveLimits archive.Limits
+	// ImageLimits restricts size and number of files of scanned container images
+	ImageLimits archive.Limits
+	// BombLimits defines thresholds for archive bomb detection in deep scan mode
+	BombLimits archive.BombLimits
+	// BombPolicy defines how detected archive bombs are handled
+	BombPolicy BombPolicy
+	// EncryptedPolicy defines how encrypted archives and documents are handled
+	EncryptedPolicy EncryptedPolicy
+	// TypePolicy restricts accepted file types
+	TypePolicy TypePolicy
+	// FindingPolicy defines which findings block the file
+	FindingPolicy FindingPolicy
+	// MaxDecodedBodySize restricts size of request body decompressed according to Content-Encoding
+	MaxDecodedBodySize int64
+	// MaxFileSize restricts size of a single uploaded file, larger files are rejected before scanning
+	MaxFileSize int64
+}
+
+// DefaultScanOptions returns options with default archive limits and policies
+func DefaultScanOptions() ScanOptions {
+	return ScanOptions{
+		ArchiveLimits:      archive.DefaultLimits(),
+		ImageLimits:        DefaultImageLimits(),
+		BombLimits:         archive.DefaultBombLimits(),
+		BombPolicy:         BombPolicyReject,
+		EncryptedPolicy:    EncryptedPolicyFlag,
+		MaxDecodedBodySize: DefaultMaxDecodedBodySize,
+		MaxFileSize:        DefaultMaxFileSize,
+	}
 }
 
 // VirusesFoundMetric is the name of the metric which tracks
 // total number of found viruses
 const VirusesFoundMetric = "av_viruses_found_total"
 
+// findingDetectors are Go-side inspectors which look for potentially dangerous content
+var findingDetectors = []func(r io.ReaderAt, size int64) ([]inspect.Finding, error){
+	inspect.DetectMacros,
+	inspect.DetectPDFActiveContent,
+}
+
+// maxMultipartNesting is the maximum nesting level of multipart bodies in scan request
+const maxMultipartNesting = 8
+
+// DefaultMaxFileSize is the max size of a single uploaded file if no explicit limit is configured,
+// it matches default StreamMaxLength of clamd, which rejects larger streams anyway
+const DefaultMaxFileSize = 100 << 20
+
+// spoolMemoryLimit is the max size of uploaded file kept in memory during scanning,
+// larger files are spooled to temporary files
+const spoolMemoryLimit = 10 << 20
+
+// ArchiveBombsMetric is the name of the metric which tracks
+// total number of detected archive bombs by reason
+const ArchiveBombsMetric = "av_archive_bombs_total"
+
 // ScanHandler handles scan requests.
 // It parses multipart/form-data to files and verifies each file on the fly.
+// RFC 822 messages, uploaded as message/rfc822 body or as a part, are scanned as a whole
+// and each of their body parts and attachments is verified separately.
+// If deep query parameter is set, archives are expanded and each member is verified separately.
+// Request body compressed with gzip, deflate, br or zstd Content-Encoding is decompressed on the fly.
 type ScanHandler struct {
 	clamd        clamav.Clamd
+	opts         ScanOptions
 	virusesCount prometheus.Counter
+	bombsCount   *prometheus.CounterVec
+}
+
+func NewScanHandler(clamd clamav.Clamd, reg *prometheus.Registry, opts ScanOptions) *ScanHandler {
+	virusesCount := VirusesCounter(reg)
+	bombsCount := register(reg, prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: ArchiveBombsMetric},
+		[]string{"reason"},
+	))
+	return &ScanHandler{
+		clamd:        clamd,
+		opts:         opts,
+		virusesCount: virusesCount,
+		bombsCount:   bombsCount,
+	}
 }
 
-func NewScanHandler(clamd clamav.Clamd, reg *prometheus.Registry) *ScanHandler {
-	virusesCount := promauto.With(reg).NewCounter(
-		prometheus.CounterOpts{Name: VirusesFoundMetric},
-	)
-	return &ScanHandler{clamd: clamd, virusesCount: virusesCount}
+// VirusesCounter returns counter of found viruses registered in the registry.
+// Counter already registered by another scanner, e.g. ICAP server, is shared.
+func VirusesCounter(reg prometheus.Registerer) prometheus.Counter {
+	return register(reg, prometheus.NewCounter(prometheus.CounterOpts{Name: VirusesFoundMetric}))
+}
+
+// register registers the collector or returns already registered one,
+// so scanners serving different APIs share metrics of the same registry
+func register[T prometheus.Collector](reg prometheus.Registerer, c T) T {
+	if err := reg.Register(c); err != nil {
+		registered, ok := err.(prometheus.AlreadyRegisteredError)
+		if !ok {
+			panic(err)
+		}
+		return registered.ExistingCollector.(T)
+	}
+	return c
 }
 
 func (s *ScanHandler) Handle(req *http.Request) (any, error) {
+	body, err := decodeBody(req, s.opts.MaxDecodedBodySize)
+	if err != nil {
+		return nil, err
+	}
+	if body == nil {
+		return s.handle(req)
+	}
+	defer body.Close()
+
+	res, err := s.handle(req)
+	if err != nil && body.exceeded {
+		return nil, errors.DecodedBodyLimitExceededError(body.limit)
+	}
+	return res, err
+}
+
+// handle scans request body, which is already decompressed
+func (s *ScanHandler) handle(req *http.Request) (any, error) {
 	contentType := req.Header.Get("Content-Type")
-	if !strings.Contains(contentType, "multipart/form-data") {
+	isMessage := strings.Contains(contentType, messageContentType)
+	if !strings.Contains(contentType, "multipart/form-data") && !isMessage {
 		return nil, errors.ContentTypeUnsupportedError(contentType)
 	}
 
-	scans := make([]*ScanStatus, 0)
+	deep := false
+	if v := req.URL.Query().Get("deep"); v != "" {
+		var err error
+		if deep, err = strconv.ParseBool(v); err != nil {
+			return nil, errors.InvalidParameterError("deep", v)
+		}
+	}
+
+	if isMessage {
+		status, err := s.scanPart(req.Context(), messageFilename(req), messageContentType, req.Body, deep)
+		if err != nil {
+			return nil, err
+		}
+		s.reportViruses(req, status)
+		return []*ScanStatus{status}, nil
+	}
+
 	reader, err := req.MultipartReader()
 	if err != nil {
 		return nil, errors.RequestBodyReadError(err)
 	}
+	return s.scanMultipart(req, reader, deep, 0, false)
+}
+
+// scanMultipart scans each file of multipart body. Transfer-encoded parts are decoded before scanning,
+// nested multipart bodies are scanned recursively and their files are added to the same list.
+// Parts without filename are skipped if skipFields is true, otherwise FilenameNotSpecifiedError is returned.
+func (s *ScanHandler) scanMultipart(
+	req *http.Request,
+	reader *multipart.Reader,
+	deep bool,
+	depth int,
+	skipFields bool,
+) ([]*ScanStatus, error) {
+	scans := make([]*ScanStatus, 0)
 
-	part, partErr := reader.NextPart()
+	// raw parts are used, since NextPart decodes quoted-printable content and drops the header
+	part, partErr := reader.NextRawPart()
 	for partErr != io.EOF {
 		if partErr != nil {
 			return nil, errors.RequestBodyReadError(partErr)
 		}
 
+		contentType := part.Header.Get("Content-Type")
+		if mediaType, params, err := mime.ParseMediaType(contentType); err == nil &&
+			strings.HasPrefix(mediaType, "multipart/") && params["boundary"] != "" {
+			if depth >= maxMultipartNesting {
+				return nil, errors.MultipartNestingError(maxMultipartNesting)
+			}
+			nested, err := s.scanMultipart(req, multipart.NewReader(part, params["boundary"]), deep, depth+1, skipFields)
+			if err != nil {
+				return nil, err
+			}
+			scans = append(scans, nested...)
+			part, partErr = reader.NextRawPart()
+			continue
+		}
+
 		filename := part.FileName()
 		if filename == "" {
-			return nil, errors.FilenameNotSpecifiedError()
+			if !skipFields {
+				return nil, errors.FilenameNotSpecifiedError()
+			}
+			part, partErr = reader.NextRawPart()
+			continue
 		}
 
-		res, err := s.clamd.ScanStream(req.Context(), part)
+		encoding := strings.ToLower(strings.TrimSpace(part.Header.Get("Content-Transfer-Encoding")))
+		status, err := s.scanPart(req.Context(), filename, contentType, email.Decode(encoding, part), deep)
 		if err != nil {
-			return nil, errors.ClamdScanError(err)
+			return nil, err
 		}
+		status.TransferEncoding = encoding
+		status.Decoded = email.Decodable(encoding)
+		s.reportViruses(req, status)
+		scans = append(scans, status)
 
-		if res.Infected {
-			log.From(req).Warn(
-				"virus detected",
-				"virus", res.VirusDescription,
-				"filename", filename,
-			)
-			s.virusesCount.Inc()
+		part, partErr = reader.NextRawPart()
+	}
+
+	return scans, nil
+}
+
+// reportViruses logs and counts virus found in the uploaded file
+func (s *ScanHandler) reportViruses(req *http.Request, status *ScanStatus) {
+	if status.Infected {
+		log.From(req).Warn(
+			"virus detected",
+			"virus", status.Virus,
+			"filename", status.Filename,
+		)
+		s.virusesCount.Inc()
+	}
+}
+
+// ScanFile scans a single file received outside of multipart request, e.g. through gRPC API.
+// Message files are scanned part by part, archives are expanded if deep is true.
+func (s *ScanHandler) ScanFile(
+	ctx context.Context,
+	filename string,
+	contentType string,
+	r io.Reader,
+	deep bool,
+) (*ScanStatus, error) {
+	status, err := s.scanPart(ctx, filename, contentType, r, deep)
+	if err != nil {
+		return nil, err
+	}
+	if status.Infected {
+		log.FromContext(ctx).Warn(
+			"virus detected",
+			"virus", status.Virus,
+			"filename", status.Filename,
+		)
+		s.virusesCount.Inc()
+	}
+	return status, nil
+}
+
+// maxFileSize returns max size of a single file accepted for scanning
+func (s *ScanHandler) maxFileSize() int64 {
+	if s.opts.MaxFileSize <= 0 {
+		return DefaultMaxFileSize
+	}
+	return s.opts.MaxFileSize
+}
+
+// scanPart spools content of a single uploaded file and scans it,
+// UploadLimitExceededError is returned if file is larger than MaxFileSize
+func (s *ScanHandler) scanPart(
+	ctx context.Context,
+	filename string,
+	contentType string,
+	r io.Reader,
+	deep bool,
+) (*ScanStatus, error) {
+	limit := s.maxFileSize()
+	f, err := spool.New(io.LimitReader(r, limit+1), spoolMemoryLimit)
+	if err != nil {
+		return nil, errors.RequestBodyReadError(err)
+	}
+	defer f.Close()
+	if f.Size() > limit {
+		return nil, errors.UploadLimitExceededError(limit)
+	}
+	return s.scanSpooled(ctx, filename, contentType, f, deep)
+}
+
+// scanSpooled scans content of a single file, message files are scanned part by part
+func (s *ScanHandler) scanSpooled(
+	ctx context.Context,
+	filename string,
+	contentType string,
+	f *spool.File,
+	deep bool,
+) (*ScanStatus, error) {
+	if isMessage(filename, contentType) {
+		return s.scanMessage(ctx, filename, f, deep)
+	}
+	if deep {
+		return s.scanDeep(ctx, filename, f)
+	}
+	return s.scanFile(ctx, filename, f)
+}
+
+// scanFile scans spooled file with clamd and inspects its content
+func (s *ScanHandler) scanFile(ctx context.Context, filename string, f *spool.File) (*ScanStatus, error) {
+	status, err := s.scanStream(ctx, filename, f.Reader())
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f.Reader()); err != nil {
+		return nil, errors.RequestBodyReadError(err)
+	}
+	status.SHA256 = hex.EncodeToString(hash.Sum(nil))
+
+	encryption, err := inspect.DetectEncryption(f, f.Size())
+	if err != nil {
+		log.FromContext(ctx).Debug("failed to detect encryption", "filename", filename, "error", err)
+	}
+	if encryption != inspect.EncryptionNone {
+		status.Encrypted = true
+		if s.opts.EncryptedPolicy == EncryptedPolicyInfected && !status.Infected {
+			status.Infected = true
+			status.Virus = encryption.Signature()
+			status.Verdict = VerdictInfected
 		}
-		scans = append(scans, &ScanStatus{
-			Filename: filename,
-			Infected: res.Infected,
-			Virus:    res.VirusDescription,
-		})
+	}
+
+	fileType, err := inspect.DetectFileType(f, f.Size())
+	if err != nil {
+		return nil, errors.RequestBodyReadError(err)
+	}
+	status.MimeType = fileType.MIME
+	status.ExtensionMismatch = fileType.Mismatch(filename)
+	if reason := s.opts.TypePolicy.Check(fileType.MIME); reason != "" {
+		status.block(VerdictPolicyBlocked, reason)
+	}
 
-		part, partErr = reader.NextPart()
+	for _, detect := range findingDetectors {
+		findings, err := detect(f, f.Size())
+		if err != nil {
+			log.FromContext(ctx).Debug("failed to inspect content", "filename", filename, "error", err)
+		}
+		status.Findings = append(status.Findings, findings...)
 	}
+	if reason := s.opts.FindingPolicy.Check(status.Findings); reason != "" {
+		status.block(VerdictPolicyBlocked, reason)
+	}
+	return status, nil
+}
 
-	return scans, nil
+// scanStream scans given stream as a single file
+func (s *ScanHandler) scanStream(ctx context.Context, filename string, r io.Reader) (*ScanStatus, error) {
+	res, err := s.clamd.ScanStream(ctx, r)
+	if err != nil {
+		return nil, errors.ClamdScanError(err)
+	}
+	verdict := VerdictClean
+	if res.Infected {
+		verdict = VerdictInfected
+	}
+	return &ScanStatus{
+		Filename: filename,
+		Infected: res.Infected,
+		Virus:    res.VirusDescription,
+		Verdict:  verdict,
+	}, nil
 }
 
 // ParseScanStatuses is used to decode JSON input to list of scan statuses
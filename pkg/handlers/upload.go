@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/netcracker/qubership-av-scan-service/pkg/errors"
+	"github.com/netcracker/qubership-av-scan-service/pkg/log"
+	"github.com/netcracker/qubership-av-scan-service/pkg/spool"
+	"github.com/netcracker/qubership-av-scan-service/pkg/upload"
+)
+
+// DefaultMaxUploadSize is the max size of a resumable upload if no explicit limit is configured
+const DefaultMaxUploadSize = 4 << 30
+
+// ReasonTag is set in upload metadata together with VerdictTag for verdicts not related to viruses
+const ReasonTag = "av-reason"
+
+// Metadata keys set by upload client, names follow conventions of tus clients
+const (
+	filenameMetadata = "filename"
+	filetypeMetadata = "filetype"
+	deepMetadata     = "deep"
+)
+
+// UploadHandler handles resumable uploads of tus protocol.
+// Content of the upload is received by one or several requests and appended to the file of the upload store.
+// Once the whole content is received, it is scanned and the verdict is set in upload metadata
+// with VerdictTag, VirusTag and ReasonTag keys, so client learns it by requesting upload info.
+type UploadHandler struct {
+	scanner *ScanHandler
+	store   *upload.Store
+	maxSize int64
+}
+
+// NewUploadHandler returns UploadHandler which keeps uploads in given store and scans them with given scanner.
+// Uploads larger than maxSize are rejected. maxSize is capped by MaxFileSize of the scanner,
+// so uploads which can never be scanned are not created.
+func NewUploadHandler(scanner *ScanHandler, store *upload.Store, maxSize int64) *UploadHandler {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxUploadSize
+	}
+	maxSize = min(maxSize, scanner.maxFileSize())
+	return &UploadHandler{scanner: scanner, store: store, maxSize: maxSize}
+}
+
+// MaxSize returns max size of a single upload
+func (h *UploadHandler) MaxSize() int64 {
+	return h.maxSize
+}
+
+// Create creates an upload with length from Upload-Length header and metadata from Upload-Metadata header.
+// Empty upload is scanned immediately.
+func (h *UploadHandler) Create(req *http.Request) (*upload.Info, error) {
+	lengthHeader := req.Header.Get("Upload-Length")
+	length, err := strconv.ParseInt(lengthHeader, 10, 64)
+	if err != nil || length < 0 {
+		return nil, errors.InvalidParameterError("Upload-Length", lengthHeader)
+	}
+	if length > h.maxSize {
+		return nil, errors.UploadLimitExceededError(h.maxSize)
+	}
+	metadata, err := ParseUploadMetadata(req.Header.Get("Upload-Metadata"))
+	if err != nil {
+		return nil, errors.InvalidParameterError("Upload-Metadata", err.Error())
+	}
+	// verdict can be set only by the service
+	delete(metadata, VerdictTag)
+	delete(metadata, VirusTag)
+	delete(metadata, ReasonTag)
+
+	info, err := h.store.Create(length, metadata)
+	if err != nil {
+		return nil, errors.UnexpectedError(err)
+	}
+	if !info.Complete() {
+		return info, nil
+	}
+	unlock, err := h.lock(info.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return h.scan(req.Context(), info)
+}
+
+// Get returns info of the upload identified by request path
+func (h *UploadHandler) Get(req *http.Request) (*upload.Info, error) {
+	id := req.PathValue("id")
+	info, err := h.store.Get(id)
+	if err != nil {
+		return nil, storeError(id, err)
+	}
+	return info, nil
+}
+
+// Write appends request body to the upload identified by request path at offset from Upload-Offset header.
+// Once the whole content is received, it is scanned. Request with empty body at the end of complete upload
+// retries the scan, e.g. if clamd was not available when the last content was received.
+func (h *UploadHandler) Write(req *http.Request) (*upload.Info, error) {
+	contentType := req.Header.Get("Content-Type")
+	if contentType != "application/offset+octet-stream" {
+		return nil, errors.ContentTypeUnsupportedError(contentType)
+	}
+	offsetHeader := req.Header.Get("Upload-Offset")
+	offset, err := strconv.ParseInt(offsetHeader, 10, 64)
+	if err != nil || offset < 0 {
+		return nil, errors.InvalidParameterError("Upload-Offset", offsetHeader)
+	}
+
+	id := req.PathValue("id")
+	unlock, err := h.lock(id)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	info, err := h.store.Write(id, offset, req.Body)
+	if err != nil {
+		if offsetErr, ok := err.(*upload.OffsetError); ok {
+			return nil, errors.UploadOffsetMismatchError(offsetErr.Offset, offsetHeader)
+		}
+		if lengthErr, ok := err.(*upload.LengthError); ok {
+			return nil, errors.UploadLimitExceededError(lengthErr.Length)
+		}
+		if info != nil {
+			// received content is kept, so client may resume from the new offset
+			log.From(req).Warn("upload interrupted", "id", id, "offset", info.Offset, "error", err)
+			return nil, errors.RequestBodyReadError(err)
+		}
+		return nil, storeError(id, err)
+	}
+	if !info.Complete() || info.Metadata[VerdictTag] != "" {
+		return info, nil
+	}
+	return h.scan(req.Context(), info)
+}
+
+// Delete removes the upload identified by request path
+func (h *UploadHandler) Delete(req *http.Request) error {
+	id := req.PathValue("id")
+	unlock, err := h.lock(id)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := h.store.Delete(id); err != nil {
+		return storeError(id, err)
+	}
+	return nil
+}
+
+func (h *UploadHandler) lock(id string) (func(), error) {
+	unlock, err := h.store.Lock(id)
+	if err != nil {
+		return nil, errors.UploadLockedError(id)
+	}
+	return unlock, nil
+}
+
+// scan scans content of complete upload with clamd and saves the verdict in upload metadata
+func (h *UploadHandler) scan(ctx context.Context, info *upload.Info) (*upload.Info, error) {
+	filename := info.Metadata[filenameMetadata]
+	if filename == "" {
+		filename = defaultUploadFilename
+	}
+	deep, _ := strconv.ParseBool(info.Metadata[deepMetadata])
+
+	file, err := h.store.Open(info.ID)
+	if err != nil {
+		return nil, storeError(info.ID, err)
+	}
+	defer file.Close()
+
+	status, err := h.scanner.scanSpooled(ctx, filename, info.Metadata[filetypeMetadata], spool.FromReaderAt(file, info.Length), deep)
+	if err != nil {
+		return nil, err
+	}
+	if status.Infected {
+		log.FromContext(ctx).Warn(
+			"virus detected",
+			"virus", status.Virus,
+			"upload", info.ID,
+			"filename", filename,
+		)
+		h.scanner.virusesCount.Inc()
+	}
+
+	metadata := make(map[string]string, len(info.Metadata)+3)
+	for k, v := range info.Metadata {
+		metadata[k] = v
+	}
+	metadata[VerdictTag] = string(status.Verdict)
+	if status.Infected {
+		metadata[VirusTag] = status.Virus
+	}
+	if status.Reason != "" {
+		metadata[ReasonTag] = status.Reason
+	}
+	updated, err := h.store.SetMetadata(info.ID, metadata)
+	if err != nil {
+		return nil, storeError(info.ID, err)
+	}
+	return updated, nil
+}
+
+// storeError converts error of upload store to APIError
+func storeError(id string, err error) error {
+	switch err {
+	case upload.ErrNotFound:
+		return errors.UploadNotFoundError(id)
+	case upload.ErrLocked:
+		return errors.UploadLockedError(id)
+	}
+	return errors.UnexpectedError(err)
+}
+
+// ParseUploadMetadata parses value of Upload-Metadata header,
+// which is a comma-separated list of keys with optional base64-encoded values separated by space
+func ParseUploadMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+	for _, pair := range strings.Split(header, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, fmt.Errorf("empty key")
+		}
+		if _, ok := metadata[key]; ok {
+			return nil, fmt.Errorf("duplicate key %q", key)
+		}
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("malformed value of key %q: %s", key, err)
+		}
+		metadata[key] = string(value)
+	}
+	return metadata, nil
+}
+
+// FormatUploadMetadata formats metadata as value of Upload-Metadata header, keys are sorted
+func FormatUploadMetadata(metadata map[string]string) string {
+	keys := make([]string, 0, len(metadata))
+	for k := range metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+" "+base64.StdEncoding.EncodeToString([]byte(metadata[k])))
+	}
+	return strings.Join(pairs, ",")
+}
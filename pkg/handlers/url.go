@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/netcracker/qubership-av-scan-service/pkg/errors"
+	"github.com/netcracker/qubership-av-scan-service/pkg/fetch"
+	"github.com/netcracker/qubership-av-scan-service/pkg/spool"
+)
+
+// maxJSONRequestSize restricts size of JSON request bodies
+const maxJSONRequestSize = 64 << 10
+
+// ScanURLRequest is a body of URL scan request
+type ScanURLRequest struct {
+	// URL is the address of content to scan
+	URL string `json:"url"`
+	// Filename overrides the name of the content, by default it is taken from response or URL path
+	Filename string `json:"filename,omitempty"`
+}
+
+// RemoteSource describes remote content which was fetched for scanning
+type RemoteSource struct {
+	// URL is the requested URL
+	URL string `json:"url"`
+	// ResolvedURL is the final URL of the content after redirects
+	ResolvedURL string `json:"resolvedUrl"`
+	// StatusCode is the HTTP status code of the response
+	StatusCode int `json:"statusCode"`
+	// ContentType is the Content-Type of the response
+	ContentType string `json:"contentType,omitempty"`
+	// ContentLength is the number of bytes fetched
+	ContentLength int64 `json:"contentLength"`
+	// ETag is the ETag of the response
+	ETag string `json:"etag,omitempty"`
+	// LastModified is the Last-Modified header of the response
+	LastModified string `json:"lastModified,omitempty"`
+}
+
+// URLScanHandler handles scan requests for remote content.
+// It fetches content of the URL given in JSON body with size and time limits and SSRF protection,
+// and verifies it the same way as uploaded files.
+type URLScanHandler struct {
+	scanner *ScanHandler
+	fetcher *fetch.Fetcher
+}
+
+// NewURLScanHandler returns URLScanHandler which fetches content with given fetcher and scans it with given scanner
+func NewURLScanHandler(scanner *ScanHandler, fetcher *fetch.Fetcher) *URLScanHandler {
+	return &URLScanHandler{scanner: scanner, fetcher: fetcher}
+}
+
+func (h *URLScanHandler) Handle(req *http.Request) (any, error) {
+	contentType := req.Header.Get("Content-Type")
+	if !strings.Contains(contentType, "application/json") {
+		return nil, errors.ContentTypeUnsupportedError(contentType)
+	}
+
+	var scanReq ScanURLRequest
+	if err := json.NewDecoder(http.MaxBytesReader(nil, req.Body, maxJSONRequestSize)).Decode(&scanReq); err != nil {
+		return nil, errors.InvalidParameterError("body", err.Error())
+	}
+	if scanReq.URL == "" {
+		return nil, errors.InvalidParameterError("url", scanReq.URL)
+	}
+
+	resp, err := h.fetcher.Fetch(req.Context(), scanReq.URL)
+	if err != nil {
+		return nil, fetchError(err)
+	}
+	defer resp.Body.Close()
+
+	f, err := spool.New(resp.Body, spoolMemoryLimit)
+	if err != nil {
+		return nil, fetchError(err)
+	}
+	defer f.Close()
+
+	filename := scanReq.Filename
+	if filename == "" {
+		filename = resp.Filename
+	}
+	status, err := h.scanner.scanFile(req.Context(), filename, f)
+	if err != nil {
+		return nil, err
+	}
+	status.Remote = &RemoteSource{
+		URL:           scanReq.URL,
+		ResolvedURL:   resp.URL,
+		StatusCode:    resp.StatusCode,
+		ContentType:   resp.ContentType,
+		ContentLength: f.Size(),
+		ETag:          resp.ETag,
+		LastModified:  resp.LastModified,
+	}
+	h.scanner.reportViruses(req, status)
+	return status, nil
+}
+
+// fetchError converts error happened during fetching of remote content to APIError
+func fetchError(err error) error {
+	switch e := err.(type) {
+	case *fetch.ForbiddenError:
+		return errors.RemoteURLForbiddenError(e)
+	case *fetch.LimitError:
+		return errors.RemoteContentTooLargeError(e)
+	}
+	return errors.RemoteFetchError(err)
+}
@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/netcracker/qubership-av-scan-service/pkg/errors"
+	"github.com/netcracker/qubership-av-scan-service/pkg/log"
+)
+
+// DefaultMaxWebSocketFileSize is the max size of a file uploaded through WebSocket if no explicit limit is configured
+const DefaultMaxWebSocketFileSize = 1 << 30
+
+// maxWebSocketFrameSize restricts size of a single message received from client
+const maxWebSocketFrameSize = 1 << 20
+
+// webSocketIdleTimeout closes connections of clients which do not send messages
+const webSocketIdleTimeout = time.Minute
+
+// webSocketWriteTimeout restricts time of sending a single message to client
+const webSocketWriteTimeout = 10 * time.Second
+
+// Types of messages sent to WebSocket client
+const (
+	// WebSocketProgress reports number of received bytes of the file after each binary message
+	WebSocketProgress = "progress"
+	// WebSocketResult contains scan status of the file
+	WebSocketResult = "result"
+	// WebSocketError contains APIError, connection is closed after it
+	WebSocketError = "error"
+)
+
+// WebSocketFile is sent by client as text message before binary messages with file content
+type WebSocketFile struct {
+	// Filename is the name of the file, required
+	Filename string `json:"filename"`
+	// Size is the size of the file in bytes, file content ends when this number of bytes is received
+	Size int64 `json:"size"`
+	// ContentType is the MIME type of the file, message/rfc822 files are scanned part by part
+	ContentType string `json:"contentType,omitempty"`
+	// Deep enables expansion of archives
+	Deep bool `json:"deep,omitempty"`
+}
+
+// WebSocketMessage is sent to client to report upload progress, scan result or error
+type WebSocketMessage struct {
+	// Type is one of WebSocketProgress, WebSocketResult or WebSocketError
+	Type string `json:"type"`
+	// Filename is the name of the file being uploaded
+	Filename string `json:"filename,omitempty"`
+	// Received is the number of received bytes of the file
+	Received int64 `json:"received"`
+	// Size is the declared size of the file
+	Size int64 `json:"size"`
+	// Status is the scan status of the file, set only in WebSocketResult message
+	Status *ScanStatus `json:"status,omitempty"`
+	// Error is set only in WebSocketError message
+	Error *errors.APIError `json:"error,omitempty"`
+}
+
+// WebSocketScanHandler scans files uploaded through WebSocket connection.
+// For each file client sends WebSocketFile as text message followed by binary messages with file content.
+// Content is streamed to the scanner as it arrives, and progress message is sent back after each binary message,
+// so client may wait for it before sending the next one. Once declared size is received, result message is sent
+// and client may upload the next file over the same connection. On any error, error message is sent
+// and connection is closed.
+type WebSocketScanHandler struct {
+	scanner     *ScanHandler
+	maxFileSize int64
+	upgrader    websocket.Upgrader
+}
+
+// NewWebSocketScanHandler returns WebSocketScanHandler which scans uploaded files with given scanner.
+// Files larger than maxFileSize are rejected by declared size. maxFileSize is capped by MaxFileSize of the scanner,
+// so files which can never be scanned are rejected before any content is accepted.
+func NewWebSocketScanHandler(scanner *ScanHandler, maxFileSize int64) *WebSocketScanHandler {
+	if maxFileSize <= 0 {
+		maxFileSize = DefaultMaxWebSocketFileSize
+	}
+	maxFileSize = min(maxFileSize, scanner.maxFileSize())
+	return &WebSocketScanHandler{scanner: scanner, maxFileSize: maxFileSize}
+}
+
+func (h *WebSocketScanHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// upgrader writes error response itself
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	conn.SetReadLimit(maxWebSocketFrameSize)
+
+	logger := log.From(r)
+	for {
+		file, err := h.readFile(conn)
+		if err == io.EOF {
+			return
+		}
+		var status *ScanStatus
+		if err == nil {
+			status, err = h.scan(r.Context(), conn, file)
+		}
+		if err == nil {
+			err = h.write(conn, &WebSocketMessage{
+				Type:     WebSocketResult,
+				Filename: file.Filename,
+				Received: file.Size,
+				Size:     file.Size,
+				Status:   status,
+			})
+		}
+		if err != nil {
+			logger.Error("websocket scan failed", "error", err)
+			h.closeWithError(conn, err)
+			return
+		}
+	}
+}
+
+// readFile reads metadata of the next file, io.EOF is returned if client closed connection
+func (h *WebSocketScanHandler) readFile(conn *websocket.Conn) (*WebSocketFile, error) {
+	conn.SetReadDeadline(time.Now().Add(webSocketIdleTimeout))
+	msgType, data, err := conn.ReadMessage()
+	if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, errors.RequestBodyReadError(err)
+	}
+	if msgType != websocket.TextMessage {
+		return nil, errors.WebSocketProtocolError("file metadata must be sent as text message before file content")
+	}
+
+	file := &WebSocketFile{}
+	if err := json.Unmarshal(data, file); err != nil {
+		return nil, errors.WebSocketProtocolError(fmt.Sprintf("malformed file metadata: %s", err))
+	}
+	if file.Filename == "" {
+		return nil, errors.FilenameNotSpecifiedError()
+	}
+	if file.Size < 0 {
+		return nil, errors.InvalidParameterError("size", fmt.Sprint(file.Size))
+	}
+	if file.Size > h.maxFileSize {
+		return nil, errors.UploadLimitExceededError(h.maxFileSize)
+	}
+	return file, nil
+}
+
+// scan streams binary messages with file content to the scanner until declared size is received
+func (h *WebSocketScanHandler) scan(ctx context.Context, conn *websocket.Conn, file *WebSocketFile) (*ScanStatus, error) {
+	r, w := io.Pipe()
+	type scanResult struct {
+		status *ScanStatus
+		err    error
+	}
+	result := make(chan scanResult, 1)
+	go func() {
+		// scanning is out of the handler goroutine, so it is not covered by panic recovery middleware
+		defer func() {
+			if p := recover(); p != nil {
+				log.FromContext(ctx).Error("websocket scan panicked", "stacktrace", debug.Stack())
+				r.CloseWithError(io.ErrClosedPipe)
+				result <- scanResult{err: errors.UnexpectedError(fmt.Errorf("%v", p))}
+			}
+		}()
+		status, err := h.scanner.ScanFile(ctx, file.Filename, file.ContentType, r, file.Deep)
+		// unblock writes if scanner stopped reading
+		r.CloseWithError(io.ErrClosedPipe)
+		result <- scanResult{status: status, err: err}
+	}()
+
+	if err := h.receive(conn, file, w); err != nil {
+		w.CloseWithError(err)
+		<-result
+		return nil, err
+	}
+	w.Close()
+	res := <-result
+	return res.status, res.err
+}
+
+// receive writes content of the file to w, reporting progress after each binary message
+func (h *WebSocketScanHandler) receive(conn *websocket.Conn, file *WebSocketFile, w io.Writer) error {
+	var received int64
+	for received < file.Size {
+		conn.SetReadDeadline(time.Now().Add(webSocketIdleTimeout))
+		msgType, data, err := conn.ReadMessage()
+		if err != nil {
+			return errors.RequestBodyReadError(err)
+		}
+		if msgType != websocket.BinaryMessage {
+			return errors.WebSocketProtocolError("file content must be sent as binary messages")
+		}
+		if received+int64(len(data)) > file.Size {
+			return errors.WebSocketProtocolError(fmt.Sprintf("file content exceeds declared size %d", file.Size))
+		}
+		if _, err := w.Write(data); err != nil {
+			// scanner failed, its error is returned with the result
+			return nil
+		}
+		received += int64(len(data))
+
+		err = h.write(conn, &WebSocketMessage{
+			Type:     WebSocketProgress,
+			Filename: file.Filename,
+			Received: received,
+			Size:     file.Size,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *WebSocketScanHandler) write(conn *websocket.Conn, msg *WebSocketMessage) error {
+	conn.SetWriteDeadline(time.Now().Add(webSocketWriteTimeout))
+	return conn.WriteJSON(msg)
+}
+
+// closeWithError sends error message to client and closes connection with matching close code
+func (h *WebSocketScanHandler) closeWithError(conn *websocket.Conn, err error) {
+	apiErr, ok := err.(*errors.APIError)
+	if !ok {
+		apiErr = errors.UnexpectedError(err)
+	}
+	if h.write(conn, &WebSocketMessage{Type: WebSocketError, Error: apiErr}) != nil {
+		return
+	}
+
+	code := websocket.CloseInternalServerErr
+	switch {
+	case apiErr.Status == http.StatusRequestEntityTooLarge:
+		code = websocket.CloseMessageTooBig
+	case apiErr.Status < http.StatusInternalServerError:
+		code = websocket.ClosePolicyViolation
+	}
+	conn.WriteControl(
+		websocket.CloseMessage,
+		websocket.FormatCloseMessage(code, apiErr.Code),
+		time.Now().Add(webSocketWriteTimeout),
+	)
+}
@@ -0,0 +1,332 @@
+package icap
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Encapsulated entity names, see RFC 3507 section 4.4.1
+const (
+	entityReqHdr  = "req-hdr"
+	entityResHdr  = "res-hdr"
+	entityReqBody = "req-body"
+	entityResBody = "res-body"
+	entityNull    = "null-body"
+	entityOpt     = "opt-body"
+)
+
+// maxEncapsulatedHeaderSize restricts size of encapsulated HTTP headers
+const maxEncapsulatedHeaderSize = 64 << 10
+
+// maxChunkLineSize restricts size of chunk size line including extensions
+const maxChunkLineSize = 4096
+
+// request is a parsed ICAP request. Encapsulated HTTP headers are kept as is,
+// body is left in the connection reader.
+type request struct {
+	Method string
+	URI    string
+	Header textproto.MIMEHeader
+	// ReqHdr and ResHdr are raw encapsulated HTTP request and response headers, including final empty line
+	ReqHdr []byte
+	ResHdr []byte
+	// HasBody is true if encapsulated HTTP message has body
+	HasBody bool
+	// Preview is the size of preview sent by client, -1 if preview is not used
+	Preview int
+}
+
+// AllowsNoContent returns true if client accepts 204 response outside of preview
+func (r *request) AllowsNoContent() bool {
+	for _, v := range strings.Split(r.Header.Get("Allow"), ",") {
+		if strings.TrimSpace(v) == "204" {
+			return true
+		}
+	}
+	return false
+}
+
+// protocolError is a malformed request which is answered with 400 status
+type protocolError struct {
+	msg string
+}
+
+func (e *protocolError) Error() string {
+	return e.msg
+}
+
+// readRequest reads ICAP request line, headers and encapsulated HTTP headers.
+// Body is left in the reader and must be read with chunkedReader.
+func readRequest(br *bufio.Reader) (*request, error) {
+	tp := textproto.NewReader(br)
+	line, err := tp.ReadLine()
+	if err != nil {
+		return nil, err
+	}
+	parts := strings.SplitN(line, " ", 3)
+	if len(parts) != 3 || !strings.HasPrefix(parts[2], "ICAP/") {
+		return nil, &protocolError{fmt.Sprintf("malformed request line %q", line)}
+	}
+	req := &request{Method: parts[0], URI: parts[1], Preview: -1}
+	if req.Header, err = tp.ReadMIMEHeader(); err != nil {
+		return nil, &protocolError{fmt.Sprintf("malformed headers: %s", err)}
+	}
+	if v := req.Header.Get("Preview"); v != "" {
+		if req.Preview, err = strconv.Atoi(v); err != nil || req.Preview < 0 {
+			return nil, &protocolError{fmt.Sprintf("invalid preview %q", v)}
+		}
+	}
+
+	entities, err := parseEncapsulated(req.Header.Get("Encapsulated"))
+	if err != nil {
+		return nil, err
+	}
+	offset := 0
+	for i, e := range entities {
+		if e.offset != offset {
+			return nil, &protocolError{fmt.Sprintf("unexpected offset of %s", e.name)}
+		}
+		switch e.name {
+		case entityReqHdr, entityResHdr:
+			if i == len(entities)-1 {
+				return nil, &protocolError{"encapsulated headers must be followed by body entity"}
+			}
+			size := entities[i+1].offset - e.offset
+			if size > maxEncapsulatedHeaderSize {
+				return nil, &protocolError{fmt.Sprintf("%s exceeds %d bytes", e.name, maxEncapsulatedHeaderSize)}
+			}
+			hdr := make([]byte, size)
+			if _, err := io.ReadFull(br, hdr); err != nil {
+				return nil, err
+			}
+			if e.name == entityReqHdr {
+				req.ReqHdr = hdr
+			} else {
+				req.ResHdr = hdr
+			}
+			offset += size
+		case entityReqBody, entityResBody, entityOpt:
+			req.HasBody = true
+		}
+	}
+	return req, nil
+}
+
+type entity struct {
+	name   string
+	offset int
+}
+
+// parseEncapsulated parses Encapsulated header, e.g. "req-hdr=0, res-hdr=137, res-body=296"
+func parseEncapsulated(header string) ([]entity, error) {
+	if header == "" {
+		return nil, nil
+	}
+	var entities []entity
+	for _, field := range strings.Split(header, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+		if !ok {
+			return nil, &protocolError{fmt.Sprintf("malformed encapsulated header %q", header)}
+		}
+		offset, err := strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			return nil, &protocolError{fmt.Sprintf("malformed encapsulated header %q", header)}
+		}
+		entities = append(entities, entity{name: name, offset: offset})
+	}
+	sort.SliceStable(entities, func(i, j int) bool { return entities[i].offset < entities[j].offset })
+	return entities, nil
+}
+
+// chunkedReader reads chunked encapsulated body. Reading stops at zero-length chunk,
+// ieof is set if the chunk has ieof extension, meaning that preview contains the whole body.
+type chunkedReader struct {
+	br        *bufio.Reader
+	remaining int64
+	done      bool
+	ieof      bool
+}
+
+func (c *chunkedReader) Read(p []byte) (int, error) {
+	if c.done {
+		return 0, io.EOF
+	}
+	if c.remaining == 0 {
+		if err := c.nextChunk(); err != nil {
+			return 0, err
+		}
+		if c.done {
+			return 0, io.EOF
+		}
+	}
+	if int64(len(p)) > c.remaining {
+		p = p[:c.remaining]
+	}
+	n, err := c.br.Read(p)
+	c.remaining -= int64(n)
+	if c.remaining == 0 && err == nil {
+		err = c.readCRLF()
+	}
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// nextChunk reads chunk size line, and trailer after the last chunk
+func (c *chunkedReader) nextChunk() error {
+	line, err := c.readLine()
+	if err != nil {
+		return err
+	}
+	sizeField, ext, _ := strings.Cut(line, ";")
+	size, err := strconv.ParseInt(strings.TrimSpace(sizeField), 16, 64)
+	if err != nil || size < 0 {
+		return &protocolError{fmt.Sprintf("malformed chunk size %q", line)}
+	}
+	if size > 0 {
+		c.remaining = size
+		return nil
+	}
+
+	c.done = true
+	c.ieof = strings.TrimSpace(ext) == "ieof"
+	// skip trailer
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return err
+		}
+		if line == "" {
+			return nil
+		}
+	}
+}
+
+func (c *chunkedReader) readLine() (string, error) {
+	line, err := c.br.ReadSlice('\n')
+	if err == bufio.ErrBufferFull || len(line) > maxChunkLineSize {
+		return "", &protocolError{"chunk line too long"}
+	}
+	if err == io.EOF {
+		return "", io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(line), "\r\n"), nil
+}
+
+func (c *chunkedReader) readCRLF() error {
+	line, err := c.readLine()
+	if err != nil {
+		return err
+	}
+	if line != "" {
+		return &protocolError{"missing chunk terminator"}
+	}
+	return nil
+}
+
+// response is an ICAP response, Encapsulated header is computed from ReqHdr, ResHdr and Body
+type response struct {
+	StatusCode int
+	Header     textproto.MIMEHeader
+	ReqHdr     []byte
+	ResHdr     []byte
+	// Body is written chunked if not nil
+	Body io.Reader
+}
+
+var statusText = map[int]string{
+	100: "Continue",
+	200: "OK",
+	204: "No Content",
+	400: "Bad request",
+	404: "ICAP Service Not Found",
+	405: "Method Not Allowed",
+	500: "Server Error",
+	501: "Method Not Implemented",
+	505: "ICAP Version Not Supported",
+}
+
+// write writes the response with chunked body and flushes it
+func (r *response) write(w *bufio.Writer) error {
+	fmt.Fprintf(w, "ICAP/1.0 %d %s\r\n", r.StatusCode, statusText[r.StatusCode])
+	keys := make([]string, 0, len(r.Header))
+	for k := range r.Header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range r.Header[k] {
+			fmt.Fprintf(w, "%s: %s\r\n", k, v)
+		}
+	}
+	if r.StatusCode != 100 && r.StatusCode != 204 {
+		fmt.Fprintf(w, "Encapsulated: %s\r\n", r.encapsulated())
+	}
+	w.WriteString("\r\n")
+	w.Write(r.ReqHdr)
+	w.Write(r.ResHdr)
+
+	if r.Body != nil {
+		buf := make([]byte, 32<<10)
+		for {
+			n, err := r.Body.Read(buf)
+			if n > 0 {
+				fmt.Fprintf(w, "%x\r\n", n)
+				w.Write(buf[:n])
+				w.WriteString("\r\n")
+			}
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+		}
+		w.WriteString("0\r\n\r\n")
+	}
+	return w.Flush()
+}
+
+// encapsulated returns value of Encapsulated header describing the response
+func (r *response) encapsulated() string {
+	var fields []string
+	offset := 0
+	if r.ReqHdr != nil {
+		fields = append(fields, fmt.Sprintf("%s=%d", entityReqHdr, offset))
+		offset += len(r.ReqHdr)
+	}
+	if r.ResHdr != nil {
+		fields = append(fields, fmt.Sprintf("%s=%d", entityResHdr, offset))
+		offset += len(r.ResHdr)
+	}
+	switch {
+	case r.Body == nil:
+		fields = append(fields, fmt.Sprintf("%s=%d", entityNull, offset))
+	case r.ResHdr != nil:
+		fields = append(fields, fmt.Sprintf("%s=%d", entityResBody, offset))
+	default:
+		fields = append(fields, fmt.Sprintf("%s=%d", entityReqBody, offset))
+	}
+	return strings.Join(fields, ", ")
+}
+
+// httpResponseHeader builds HTTP response header for a generated response, e.g. block page
+func httpResponseHeader(status int, statusText string, contentType string, contentLength int) []byte {
+	b := &bytes.Buffer{}
+	fmt.Fprintf(b, "HTTP/1.1 %d %s\r\n", status, statusText)
+	fmt.Fprintf(b, "Content-Type: %s\r\n", contentType)
+	fmt.Fprintf(b, "Content-Length: %d\r\n", contentLength)
+	b.WriteString("Cache-Control: no-store\r\n")
+	b.WriteString("\r\n")
+	return b.Bytes()
+}
@@ -0,0 +1,477 @@
+package icap
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/netcracker/qubership-av-scan-service/pkg/clamav"
+	"github.com/netcracker/qubership-av-scan-service/pkg/handlers"
+	"github.com/netcracker/qubership-av-scan-service/pkg/spool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/xid"
+)
+
+// DefaultPreviewSize is the preview size advertised in OPTIONS response
+const DefaultPreviewSize = 1024
+
+// idleTimeout closes persistent connections which do not send requests
+const idleTimeout = 5 * time.Minute
+
+// spoolMemoryLimit is the max size of encapsulated body kept in memory during scanning
+const spoolMemoryLimit = 10 << 20
+
+// optionsTTL is the time in seconds OPTIONS response may be cached by clients
+const optionsTTL = 3600
+
+const serviceName = "qubership-av-scan-service ICAP"
+
+// ErrServerClosed is returned by Serve after Close
+var ErrServerClosed = errors.New("icap: server closed")
+
+var blockPage = template.Must(template.New("block").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Access blocked</title></head>
+<body>
+<h1>Access blocked</h1>
+{{if .Virus}}<p>The content was blocked because virus was found in it.</p>
+<p>Virus: {{.Virus}}</p>
+{{else}}<p>The content was blocked because it is too large to be scanned.</p>
+{{end}}<p>URL: {{.URL}}</p>
+</body>
+</html>
+`))
+
+// OversizePolicy defines how bodies larger than max file size of the scanner are handled
+type OversizePolicy string
+
+const (
+	// OversizePolicyBlock replaces message with block page
+	OversizePolicyBlock OversizePolicy = "block"
+	// OversizePolicyPass returns message unmodified without scanning
+	OversizePolicyPass OversizePolicy = "pass"
+)
+
+// ParseOversizePolicy parses oversize policy name
+func ParseOversizePolicy(s string) (OversizePolicy, error) {
+	switch p := OversizePolicy(s); p {
+	case OversizePolicyBlock, OversizePolicyPass:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown icap oversize policy %q, must be block or pass", s)
+}
+
+// Options configures optional behaviour of Server
+type Options struct {
+	// OversizePolicy defines how bodies larger than max file size of the scanner are handled
+	OversizePolicy OversizePolicy
+}
+
+// DefaultOptions returns options blocking bodies which are too large to be scanned
+func DefaultOptions() Options {
+	return Options{OversizePolicy: OversizePolicyBlock}
+}
+
+// Server is an ICAP (RFC 3507) server which scans bodies of encapsulated HTTP messages with clamd.
+// It supports OPTIONS, REQMOD and RESPMOD methods on any service path, preview and 204 responses.
+// Clean messages are answered with 204 if client allows it, or returned unmodified otherwise.
+// Infected messages are replaced with 403 block page, and virus name is returned in X-Infection-Found header.
+// Bodies larger than MaxFileSize of scan options are not scanned and handled according to OversizePolicy.
+type Server struct {
+	clamd        clamav.Clamd
+	logger       *slog.Logger
+	maxBodySize  int64
+	opts         Options
+	istag        string
+	requests     *prometheus.CounterVec
+	duration     *prometheus.HistogramVec
+	virusesCount prometheus.Counter
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// NewServer returns Server which scans with given clamd. Metrics are registered in the registry,
+// so they could be exposed together with HTTP API metrics.
+func NewServer(
+	clamd clamav.Clamd,
+	registry *prometheus.Registry,
+	logger *slog.Logger,
+	scanOpts handlers.ScanOptions,
+	opts Options,
+) *Server {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	maxBodySize := scanOpts.MaxFileSize
+	if maxBodySize <= 0 {
+		maxBodySize = handlers.DefaultMaxFileSize
+	}
+	return &Server{
+		clamd:       clamd,
+		logger:      logger,
+		maxBodySize: maxBodySize,
+		opts:        opts,
+		// ISTag changes on restart, so clients do not reuse cached verdicts made with old virus databases
+		istag: strconv.Quote("AV-" + strconv.FormatInt(time.Now().Unix(), 36)),
+		requests: promauto.With(registry).NewCounterVec(
+			prometheus.CounterOpts{Name: "icap_requests_total"},
+			[]string{"method", "code"},
+		),
+		duration: promauto.With(registry).NewHistogramVec(
+			prometheus.HistogramOpts{Name: "icap_request_duration_seconds"},
+			[]string{"method", "code"},
+		),
+		virusesCount: handlers.VirusesCounter(registry),
+		conns:        map[net.Conn]struct{}{},
+	}
+}
+
+// ListenAndServe listens on given TCP address and serves ICAP connections until Close is called
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts connections on the listener until Close is called
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		l.Close()
+		return ErrServerClosed
+	}
+	s.listener = l
+	s.mu.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			return err
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return ErrServerClosed
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+		go s.serveConn(conn)
+	}
+}
+
+// Close stops accepting connections, closes active connections and waits for their handlers to finish
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+// serveConn serves requests of a persistent connection
+func (s *Server) serveConn(conn net.Conn) {
+	defer func() {
+		conn.Close()
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		s.wg.Done()
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	br := bufio.NewReader(conn)
+	bw := bufio.NewWriter(conn)
+	for {
+		conn.SetReadDeadline(time.Now().Add(idleTimeout))
+		req, err := readRequest(br)
+		if err != nil {
+			var perr *protocolError
+			if errors.As(err, &perr) {
+				s.logger.Error("malformed icap request", "error", err, "remote", conn.RemoteAddr())
+				resp := &response{StatusCode: 400, Header: s.header()}
+				resp.Header.Set("Connection", "close")
+				resp.write(bw)
+			}
+			return
+		}
+		conn.SetReadDeadline(time.Time{})
+
+		if !s.serveRequest(ctx, req, br, bw) || req.Header.Get("Connection") == "close" {
+			return
+		}
+	}
+}
+
+// serveRequest handles a single request and returns false if connection can't be reused
+func (s *Server) serveRequest(ctx context.Context, req *request, br *bufio.Reader, bw *bufio.Writer) bool {
+	start := time.Now()
+	logger := s.logger.With("reqId", xid.New(), "method", req.Method, "url", req.URI)
+	logger.Debug("icap request received")
+
+	resp, body, err := s.handle(ctx, logger, req, br, bw)
+	if body != nil {
+		// unmodified body is streamed from the spool with the response
+		defer body.Close()
+	}
+	if err != nil {
+		var perr *protocolError
+		if !errors.As(err, &perr) {
+			// connection failed, response can't be written
+			logger.Error("icap request failed", "error", err, "duration", time.Since(start))
+			return false
+		}
+		resp = &response{StatusCode: 400, Header: s.header()}
+		resp.Header.Set("Connection", "close")
+		logger.Error("malformed icap request", "error", err)
+	}
+	if err := resp.write(bw); err != nil {
+		logger.Error("failed to write icap response", "error", err)
+		return false
+	}
+
+	code := strconv.Itoa(resp.StatusCode)
+	s.requests.WithLabelValues(req.Method, code).Inc()
+	s.duration.WithLabelValues(req.Method, code).Observe(time.Since(start).Seconds())
+	if resp.StatusCode >= 400 {
+		logger.Error("icap request completed with error", "status", resp.StatusCode, "duration", time.Since(start))
+	} else {
+		logger.Info("icap request completed successfully", "status", resp.StatusCode, "duration", time.Since(start))
+	}
+	return err == nil && resp.Header.Get("Connection") != "close"
+}
+
+// handle reads body of the request if any and returns response to it along with the spooled body,
+// which must be closed by the caller after the response is written.
+// Error is returned if body can't be read.
+func (s *Server) handle(
+	ctx context.Context,
+	logger *slog.Logger,
+	req *request,
+	br *bufio.Reader,
+	bw *bufio.Writer,
+) (*response, *spool.File, error) {
+	switch req.Method {
+	case "OPTIONS":
+		if req.HasBody {
+			if _, err := io.Copy(io.Discard, &chunkedReader{br: br}); err != nil {
+				return nil, nil, err
+			}
+		}
+		return s.options(), nil, nil
+	case "REQMOD", "RESPMOD":
+	default:
+		// body can't be skipped reliably for unknown methods
+		resp := &response{StatusCode: 501, Header: s.header()}
+		resp.Header.Set("Connection", "close")
+		return resp, nil, nil
+	}
+
+	if !req.HasBody {
+		return s.clean(req, nil, req.AllowsNoContent()), nil, nil
+	}
+
+	body, rest, previewOnly, err := readBody(req, br, bw, s.maxBodySize)
+	if err != nil {
+		return nil, nil, err
+	}
+	if rest != nil {
+		return s.oversize(logger, req, body, rest, previewOnly)
+	}
+
+	result, err := s.clamd.ScanStream(ctx, body.Reader())
+	if err != nil {
+		logger.Error("failed to scan icap body", "error", err)
+		return &response{StatusCode: 500, Header: s.header()}, body, nil
+	}
+	if !result.Infected {
+		// 204 response to preview is allowed regardless of Allow header
+		return s.clean(req, body, previewOnly || req.AllowsNoContent()), body, nil
+	}
+
+	logger.Warn("virus detected", "virus", result.VirusDescription, "target", targetURL(req))
+	s.virusesCount.Inc()
+	resp, err := s.blocked(req, result.VirusDescription)
+	return resp, body, err
+}
+
+// oversize returns response for body which exceeds max body size, so it is not scanned.
+// Unread rest of the body is discarded, unless message is passed without 204 allowed,
+// in which case it is streamed back to client as it is received.
+func (s *Server) oversize(
+	logger *slog.Logger,
+	req *request,
+	body *spool.File,
+	rest io.Reader,
+	previewOnly bool,
+) (*response, *spool.File, error) {
+	logger.Warn("icap body exceeds max size, it is not scanned",
+		"maxSize", s.maxBodySize, "policy", s.opts.OversizePolicy, "target", targetURL(req))
+	if s.opts.OversizePolicy == OversizePolicyPass && !previewOnly && !req.AllowsNoContent() {
+		resp := s.clean(req, nil, false)
+		resp.Body = io.MultiReader(body.Reader(), rest)
+		return resp, body, nil
+	}
+	if _, err := io.Copy(io.Discard, rest); err != nil {
+		return nil, body, err
+	}
+	if s.opts.OversizePolicy == OversizePolicyPass {
+		return s.clean(req, nil, true), body, nil
+	}
+	resp, err := s.blocked(req, "")
+	return resp, body, err
+}
+
+// readBody spools encapsulated body up to limit, requesting the rest of it with 100 Continue after preview.
+// If body exceeds limit, spooled part of it is returned along with the unread rest.
+// previewOnly is true if the whole body was sent in preview, so response is still given to preview.
+func readBody(
+	req *request,
+	br *bufio.Reader,
+	bw *bufio.Writer,
+	limit int64,
+) (body *spool.File, rest io.Reader, previewOnly bool, err error) {
+	chunks := &chunkedReader{br: br}
+	var content io.Reader = chunks
+	if req.Preview >= 0 {
+		// preview is kept in memory, so it is restricted by declared size
+		previewLimit := min(req.Preview, spoolMemoryLimit)
+		preview := &bytes.Buffer{}
+		if _, err := io.Copy(preview, io.LimitReader(chunks, int64(previewLimit)+1)); err != nil {
+			return nil, nil, false, err
+		}
+		if preview.Len() > previewLimit {
+			return nil, nil, false, &protocolError{fmt.Sprintf("preview exceeds %d bytes", previewLimit)}
+		}
+		if chunks.ieof {
+			previewOnly = true
+			content = preview
+		} else {
+			if err := (&response{StatusCode: 100}).write(bw); err != nil {
+				return nil, nil, false, err
+			}
+			content = io.MultiReader(preview, &chunkedReader{br: br})
+		}
+	}
+
+	body, err = spool.New(io.LimitReader(content, limit+1), spoolMemoryLimit)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if body.Size() > limit {
+		return body, content, previewOnly, nil
+	}
+	return body, nil, previewOnly, nil
+}
+
+// options returns response to OPTIONS request
+func (s *Server) options() *response {
+	resp := &response{StatusCode: 200, Header: s.header()}
+	resp.Header.Set("Methods", "REQMOD, RESPMOD")
+	resp.Header.Set("Allow", "204")
+	resp.Header.Set("Preview", strconv.Itoa(DefaultPreviewSize))
+	resp.Header.Set("Transfer-Preview", "*")
+	resp.Header.Set("Options-TTL", strconv.Itoa(optionsTTL))
+	return resp
+}
+
+// clean returns response for clean message, which is 204 if it is allowed, or unmodified message otherwise
+func (s *Server) clean(req *request, body *spool.File, noContent bool) *response {
+	if noContent {
+		return &response{StatusCode: 204, Header: s.header()}
+	}
+	resp := &response{StatusCode: 200, Header: s.header()}
+	if req.Method == "REQMOD" {
+		resp.ReqHdr = req.ReqHdr
+	} else {
+		resp.ResHdr = req.ResHdr
+	}
+	if body != nil {
+		resp.Body = body.Reader()
+	}
+	return resp
+}
+
+// blocked returns response replacing infected message with block page.
+// Virus is empty if message is blocked because it is too large to be scanned.
+func (s *Server) blocked(req *request, virus string) (*response, error) {
+	page := &bytes.Buffer{}
+	err := blockPage.Execute(page, struct{ Virus, URL string }{virus, targetURL(req)})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &response{StatusCode: 200, Header: s.header()}
+	if virus != "" {
+		resp.Header.Set("X-Infection-Found", fmt.Sprintf("Type=0; Resolution=2; Threat=%s;", virus))
+	}
+	resp.ResHdr = httpResponseHeader(403, "Forbidden", "text/html; charset=utf-8", page.Len())
+	resp.Body = page
+	return resp, nil
+}
+
+// header returns headers common for all responses
+func (s *Server) header() textproto.MIMEHeader {
+	h := textproto.MIMEHeader{}
+	h.Set("ISTag", s.istag)
+	h.Set("Service", serviceName)
+	h.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	return h
+}
+
+// targetURL returns URL of encapsulated HTTP request from its request line and Host header
+func targetURL(req *request) string {
+	if req.ReqHdr == nil {
+		return ""
+	}
+	tp := textproto.NewReader(bufio.NewReader(bytes.NewReader(req.ReqHdr)))
+	line, err := tp.ReadLine()
+	if err != nil {
+		return ""
+	}
+	var method, target, proto string
+	if _, err := fmt.Sscanf(line, "%s %s %s", &method, &target, &proto); err != nil {
+		return ""
+	}
+	if target == "" || target[0] != '/' {
+		return target
+	}
+	header, _ := tp.ReadMIMEHeader()
+	return "http://" + header.Get("Host") + target
+}
@@ -0,0 +1,349 @@
+package icap_test
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/netcracker/qubership-av-scan-service/pkg/handlers"
+	"github.com/netcracker/qubership-av-scan-service/pkg/icap"
+	"github.com/netcracker/qubership-av-scan-service/pkg/router"
+	"github.com/netcracker/qubership-av-scan-service/pkg/testutils"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const reqHdr = "POST /upload HTTP/1.1\r\nHost: example.com\r\n\r\n"
+
+const resHdr = "HTTP/1.1 200 OK\r\nContent-Type: text/plain\r\n\r\n"
+
+type icapResponse struct {
+	status int
+	header textproto.MIMEHeader
+	body   string
+}
+
+func startServer(t *testing.T) net.Conn {
+	t.Helper()
+	return startServerOptions(t, handlers.DefaultScanOptions(), icap.DefaultOptions())
+}
+
+func startServerOptions(t *testing.T, scanOpts handlers.ScanOptions, opts icap.Options) net.Conn {
+	t.Helper()
+	srv := icap.NewServer(testutils.NewClamdMock(), prometheus.NewRegistry(), slog.Default(), scanOpts, opts)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err)
+	}
+	done := make(chan error)
+	go func() {
+		done <- srv.Serve(l)
+	}()
+	t.Cleanup(func() {
+		srv.Close()
+		if err := <-done; err != icap.ErrServerClosed {
+			t.Errorf("expected server to be closed, but got: %v", err)
+		}
+	})
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to connect: %s", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func chunk(data string) string {
+	return fmt.Sprintf("%x\r\n%s\r\n", len(data), data)
+}
+
+func readResponse(t *testing.T, br *bufio.Reader) icapResponse {
+	t.Helper()
+	tp := textproto.NewReader(br)
+	line, err := tp.ReadLine()
+	if err != nil {
+		t.Fatalf("failed to read response: %s", err)
+	}
+	status, err := strconv.Atoi(strings.Fields(line)[1])
+	if err != nil {
+		t.Fatalf("malformed status line %q", line)
+	}
+	header, err := tp.ReadMIMEHeader()
+	if err != nil {
+		t.Fatalf("failed to read response headers: %s", err)
+	}
+	resp := icapResponse{status: status, header: header}
+
+	encapsulated := header.Get("Encapsulated")
+	if encapsulated == "" {
+		return resp
+	}
+	var hdrSize int
+	hasBody := false
+	for _, field := range strings.Split(encapsulated, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(field), "=")
+		offset, _ := strconv.Atoi(value)
+		if strings.HasSuffix(name, "-body") {
+			hdrSize = offset
+			hasBody = name != "null-body"
+		}
+	}
+	hdr := make([]byte, hdrSize)
+	if _, err := io.ReadFull(br, hdr); err != nil {
+		t.Fatalf("failed to read encapsulated headers: %s", err)
+	}
+	resp.body = string(hdr)
+	if hasBody {
+		body, err := io.ReadAll(chunkedBody(br))
+		if err != nil {
+			t.Fatalf("failed to read encapsulated body: %s", err)
+		}
+		resp.body += string(body)
+	}
+	return resp
+}
+
+// chunkedBody reads chunked body till the last chunk
+func chunkedBody(br *bufio.Reader) io.Reader {
+	pr, pw := io.Pipe()
+	go func() {
+		for {
+			line, err := br.ReadString('\n')
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			size, err := strconv.ParseInt(strings.TrimSpace(line), 16, 64)
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			if size == 0 {
+				br.ReadString('\n')
+				pw.Close()
+				return
+			}
+			if _, err := io.CopyN(pw, br, size); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			br.ReadString('\n')
+		}
+	}()
+	return pr
+}
+
+func TestOptions(t *testing.T) {
+	conn := startServer(t)
+	fmt.Fprint(conn, "OPTIONS icap://localhost/avscan ICAP/1.0\r\nHost: localhost\r\n\r\n")
+
+	resp := readResponse(t, bufio.NewReader(conn))
+	if resp.status != 200 {
+		t.Fatalf("expected 200 status, but got %d", resp.status)
+	}
+	if resp.header.Get("Methods") != "REQMOD, RESPMOD" || resp.header.Get("ISTag") == "" {
+		t.Fatalf("unexpected OPTIONS headers: %v", resp.header)
+	}
+	if resp.header.Get("Preview") != strconv.Itoa(icap.DefaultPreviewSize) || resp.header.Get("Allow") != "204" {
+		t.Fatalf("expected preview and 204 to be advertised, but got: %v", resp.header)
+	}
+}
+
+func TestReqmodClean(t *testing.T) {
+	conn := startServer(t)
+	br := bufio.NewReader(conn)
+	body := "clean content"
+
+	// persistent connection serves several requests
+	for i := 0; i < 2; i++ {
+		fmt.Fprintf(conn, "REQMOD icap://localhost/avscan ICAP/1.0\r\nHost: localhost\r\nAllow: 204\r\n"+
+			"Encapsulated: req-hdr=0, req-body=%d\r\n\r\n%s%s0\r\n\r\n", len(reqHdr), reqHdr, chunk(body))
+
+		resp := readResponse(t, br)
+		if resp.status != 204 {
+			t.Fatalf("expected 204 status, but got %d", resp.status)
+		}
+	}
+}
+
+func TestReqmodCleanUnmodified(t *testing.T) {
+	conn := startServer(t)
+	body := "clean content"
+	fmt.Fprintf(conn, "REQMOD icap://localhost/avscan ICAP/1.0\r\nHost: localhost\r\n"+
+		"Encapsulated: req-hdr=0, req-body=%d\r\n\r\n%s%s0\r\n\r\n", len(reqHdr), reqHdr, chunk(body))
+
+	resp := readResponse(t, bufio.NewReader(conn))
+	if resp.status != 200 {
+		t.Fatalf("expected 200 status, but got %d", resp.status)
+	}
+	if resp.body != reqHdr+body {
+		t.Fatalf("expected request to be returned unmodified, but got %q", resp.body)
+	}
+}
+
+func TestRespmodInfectedWithPreview(t *testing.T) {
+	conn := startServer(t)
+	br := bufio.NewReader(conn)
+	preview, rest := testutils.EICARTest[:10], testutils.EICARTest[10:]
+	fmt.Fprintf(conn, "RESPMOD icap://localhost/avscan ICAP/1.0\r\nHost: localhost\r\nAllow: 204\r\nPreview: 10\r\n"+
+		"Encapsulated: req-hdr=0, res-hdr=%d, res-body=%d\r\n\r\n%s%s%s0\r\n\r\n",
+		len(reqHdr), len(reqHdr)+len(resHdr), reqHdr, resHdr, chunk(preview))
+
+	if resp := readResponse(t, br); resp.status != 100 {
+		t.Fatalf("expected 100 status after preview, but got %d", resp.status)
+	}
+	fmt.Fprintf(conn, "%s0\r\n\r\n", chunk(rest))
+
+	resp := readResponse(t, br)
+	if resp.status != 200 {
+		t.Fatalf("expected 200 status, but got %d", resp.status)
+	}
+	if infection := resp.header.Get("X-Infection-Found"); !strings.Contains(infection, "Threat="+testutils.EICARTest) {
+		t.Fatalf("expected virus in X-Infection-Found header, but got %q", infection)
+	}
+	if !strings.HasPrefix(resp.body, "HTTP/1.1 403 Forbidden\r\n") || !strings.Contains(resp.body, "http://example.com/upload") {
+		t.Fatalf("expected block page, but got %q", resp.body)
+	}
+}
+
+func TestPreviewWholeBody(t *testing.T) {
+	conn := startServer(t)
+	body := "small"
+	// 204 is allowed in response to preview without Allow header
+	fmt.Fprintf(conn, "RESPMOD icap://localhost/avscan ICAP/1.0\r\nHost: localhost\r\nPreview: 1024\r\n"+
+		"Encapsulated: res-hdr=0, res-body=%d\r\n\r\n%s%s0; ieof\r\n\r\n", len(resHdr), resHdr, chunk(body))
+
+	resp := readResponse(t, bufio.NewReader(conn))
+	if resp.status != 204 {
+		t.Fatalf("expected 204 status without 100 Continue, but got %d", resp.status)
+	}
+}
+
+func TestRespmodCleanLargeBody(t *testing.T) {
+	conn := startServer(t)
+	// body exceeds memory limit, so it is spooled to disk and streamed back from there
+	body := strings.Repeat("clean content\n", 1<<20)
+	go fmt.Fprintf(conn, "RESPMOD icap://localhost/avscan ICAP/1.0\r\nHost: localhost\r\n"+
+		"Encapsulated: res-hdr=0, res-body=%d\r\n\r\n%s%s0\r\n\r\n", len(resHdr), resHdr, chunk(body))
+
+	resp := readResponse(t, bufio.NewReader(conn))
+	if resp.status != 200 {
+		t.Fatalf("expected 200 status, but got %d", resp.status)
+	}
+	if resp.body != resHdr+body {
+		t.Fatalf("expected response to be returned unmodified, but got %d bytes", len(resp.body))
+	}
+}
+
+func TestRespmodOversizeBody(t *testing.T) {
+	scanOpts := handlers.DefaultScanOptions()
+	scanOpts.MaxFileSize = 16
+	body := strings.Repeat("x", 32)
+
+	tests := []struct {
+		name   string
+		policy icap.OversizePolicy
+		allow  string
+		status int
+		check  func(t *testing.T, resp icapResponse)
+	}{
+		{
+			name:   "block",
+			policy: icap.OversizePolicyBlock,
+			status: 200,
+			check: func(t *testing.T, resp icapResponse) {
+				if !strings.Contains(resp.body, "403 Forbidden") || !strings.Contains(resp.body, "too large") {
+					t.Fatalf("expected block page, but got %q", resp.body)
+				}
+				if resp.header.Get("X-Infection-Found") != "" {
+					t.Fatalf("expected no infection to be reported, but got %q", resp.header.Get("X-Infection-Found"))
+				}
+			},
+		},
+		{
+			name:   "pass with 204",
+			policy: icap.OversizePolicyPass,
+			allow:  "Allow: 204\r\n",
+			status: 204,
+		},
+		{
+			name:   "pass unmodified",
+			policy: icap.OversizePolicyPass,
+			status: 200,
+			check: func(t *testing.T, resp icapResponse) {
+				if resp.body != resHdr+body {
+					t.Fatalf("expected response to be returned unmodified, but got %q", resp.body)
+				}
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn := startServerOptions(t, scanOpts, icap.Options{OversizePolicy: test.policy})
+			br := bufio.NewReader(conn)
+			// body is sent in several chunks, so the rest of it is left unread when limit is exceeded
+			fmt.Fprintf(conn, "RESPMOD icap://localhost/avscan ICAP/1.0\r\nHost: localhost\r\n%s"+
+				"Encapsulated: res-hdr=0, res-body=%d\r\n\r\n%s%s%s%s0\r\n\r\n",
+				test.allow, len(resHdr), resHdr, chunk(body[:8]), chunk(body[8:24]), chunk(body[24:]))
+
+			resp := readResponse(t, br)
+			if resp.status != test.status {
+				t.Fatalf("expected %d status, but got %d", test.status, resp.status)
+			}
+			if test.check != nil {
+				test.check(t, resp)
+			}
+
+			// connection is still usable after oversize body
+			fmt.Fprintf(conn, "REQMOD icap://localhost/avscan ICAP/1.0\r\nHost: localhost\r\nAllow: 204\r\n"+
+				"Encapsulated: req-hdr=0, req-body=%d\r\n\r\n%s%s0\r\n\r\n", len(reqHdr), reqHdr, chunk("small"))
+			if resp := readResponse(t, br); resp.status != 204 {
+				t.Fatalf("expected 204 status for the next request, but got %d", resp.status)
+			}
+		})
+	}
+}
+
+func TestPreviewExceedsDeclaredSize(t *testing.T) {
+	conn := startServer(t)
+	fmt.Fprintf(conn, "RESPMOD icap://localhost/avscan ICAP/1.0\r\nHost: localhost\r\nPreview: 4\r\n"+
+		"Encapsulated: res-hdr=0, res-body=%d\r\n\r\n%s%s0\r\n\r\n", len(resHdr), resHdr, chunk("longer preview"))
+
+	resp := readResponse(t, bufio.NewReader(conn))
+	if resp.status != 400 {
+		t.Fatalf("expected 400 status, but got %d", resp.status)
+	}
+}
+
+func TestMalformedRequest(t *testing.T) {
+	conn := startServer(t)
+	fmt.Fprint(conn, "REQMOD icap://localhost/avscan ICAP/1.0\r\nHost: localhost\r\nEncapsulated: req-hdr=x\r\n\r\n")
+
+	resp := readResponse(t, bufio.NewReader(conn))
+	if resp.status != 400 {
+		t.Fatalf("expected 400 status, but got %d", resp.status)
+	}
+}
+
+func TestSharedRegistry(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	clamd := testutils.NewClamdMock()
+	// ICAP server and router count viruses with the same metric
+	icap.NewServer(clamd, registry, slog.Default(), handlers.DefaultScanOptions(), icap.DefaultOptions())
+	r := router.NewRouter(clamd, slog.Default(), router.WithRegistry(registry))
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.Contains(rec.Body.String(), handlers.VirusesFoundMetric) {
+		t.Fatalf("expected shared metrics to be exposed, but got:\n%s", rec.Body.String())
+	}
+}
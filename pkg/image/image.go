@@ -0,0 +1,344 @@
+package image
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	dockerManifest = "manifest.json"
+	ociIndex       = "index.json"
+	// maxMetadataSize restricts size of manifests and configs read into memory
+	maxMetadataSize = 4 << 20
+	// maxIndexNesting restricts nesting of OCI image indexes
+	maxIndexNesting = 4
+	whiteoutPrefix  = ".wh."
+	opaqueWhiteout  = ".wh..wh..opq"
+)
+
+// Layer is a filesystem layer of the image
+type Layer struct {
+	// Digest identifies the layer, e.g. sha256:abc...
+	Digest string
+	// blob is the path of the layer blob inside the image tarball
+	blob string
+	// paths contains regular files of the layer, filled during walk
+	paths map[string]bool
+	// whiteouts contains paths removed from lower layers, filled during walk
+	whiteouts map[string]bool
+	// opaque contains directories which hide content of lower layers, filled during walk
+	opaque map[string]bool
+}
+
+// File describes a regular file of an image layer
+type File struct {
+	// Layer is the index of the layer in Image.Layers
+	Layer int
+	// Path is the path of the file in the image filesystem without leading slash
+	Path string
+	// Size is the size of the file in bytes
+	Size int64
+}
+
+// FileFunc is called for each regular file of each layer.
+// If it returns an error, walking stops and the error is returned.
+type FileFunc func(f File, r io.Reader) error
+
+// Image is a container image stored in `docker save` or OCI layout tarball
+type Image struct {
+	// Layers lists layers of all images in the tarball, each image layers go from the base layer to the top one
+	Layers []*Layer
+	// images contains indexes of layers in Layers for each image in the tarball
+	images [][]int
+	// entries contains content of tarball entries by name
+	entries map[string]*io.SectionReader
+}
+
+// Open reads index of the image tarball and locates its layers.
+// Both `docker save` (manifest.json) and OCI image layout (index.json) tarballs are supported,
+// all images contained in the tarball are walked, layers shared by images are walked once.
+func Open(r io.ReaderAt, size int64) (*Image, error) {
+	entries, err := readEntries(r, size)
+	if err != nil {
+		return nil, err
+	}
+	img := &Image{entries: entries}
+
+	switch {
+	case entries[dockerManifest] != nil:
+		err = img.readDockerManifest()
+	case entries[ociIndex] != nil:
+		err = img.readOCIIndex(ociIndex, 0)
+	default:
+		return nil, fmt.Errorf("neither %s nor %s found, content is not an image tarball", dockerManifest, ociIndex)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(img.Layers) == 0 {
+		return nil, fmt.Errorf("image tarball contains no layers")
+	}
+	return img, nil
+}
+
+// readEntries indexes tarball entries, recording position of their content
+func readEntries(r io.ReaderAt, size int64) (map[string]*io.SectionReader, error) {
+	// section reader is seekable, so content of entries is skipped and current offset is known
+	sr := io.NewSectionReader(r, 0, size)
+	tr := tar.NewReader(sr)
+	entries := map[string]*io.SectionReader{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read image tarball: %w", err)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			offset, err := sr.Seek(0, io.SeekCurrent)
+			if err != nil {
+				return nil, err
+			}
+			entries[cleanPath(hdr.Name)] = io.NewSectionReader(r, offset, hdr.Size)
+		}
+	}
+}
+
+type dockerManifestEntry struct {
+	Config string   `json:"Config"`
+	Layers []string `json:"Layers"`
+}
+
+type dockerConfig struct {
+	RootFS struct {
+		DiffIDs []string `json:"diff_ids"`
+	} `json:"rootfs"`
+}
+
+func (img *Image) readDockerManifest() error {
+	var manifests []dockerManifestEntry
+	if err := img.readJSON(dockerManifest, &manifests); err != nil {
+		return err
+	}
+	for _, m := range manifests {
+		var layers []int
+		var config dockerConfig
+		if m.Config != "" {
+			// config is used only to get layer digests, so failure to read it is not fatal
+			_ = img.readJSON(m.Config, &config)
+		}
+		for i, blob := range m.Layers {
+			digest := blobDigest(blob)
+			if digest == "" && i < len(config.RootFS.DiffIDs) {
+				digest = config.RootFS.DiffIDs[i]
+			}
+			if digest == "" {
+				digest = blob
+			}
+			layers = append(layers, img.addLayer(digest, cleanPath(blob)))
+		}
+		img.images = append(img.images, layers)
+	}
+	return nil
+}
+
+type ociDescriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+}
+
+type ociManifest struct {
+	MediaType string          `json:"mediaType"`
+	Manifests []ociDescriptor `json:"manifests"`
+	Layers    []ociDescriptor `json:"layers"`
+}
+
+// readOCIIndex reads OCI index or manifest, indexes are walked recursively
+func (img *Image) readOCIIndex(name string, depth int) error {
+	if depth > maxIndexNesting {
+		return fmt.Errorf("image index nesting exceeds %d levels", maxIndexNesting)
+	}
+	var m ociManifest
+	if err := img.readJSON(name, &m); err != nil {
+		return err
+	}
+	if len(m.Layers) > 0 {
+		var layers []int
+		for _, l := range m.Layers {
+			layers = append(layers, img.addLayer(l.Digest, digestPath(l.Digest)))
+		}
+		img.images = append(img.images, layers)
+	}
+	for _, child := range m.Manifests {
+		if err := img.readOCIIndex(digestPath(child.Digest), depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addLayer adds layer if it is not added yet and returns its index
+func (img *Image) addLayer(digest string, blob string) int {
+	for i, l := range img.Layers {
+		if l.blob == blob {
+			return i
+		}
+	}
+	img.Layers = append(img.Layers, &Layer{Digest: digest, blob: blob})
+	return len(img.Layers) - 1
+}
+
+func (img *Image) readJSON(name string, v any) error {
+	entry := img.entries[cleanPath(name)]
+	if entry == nil {
+		return fmt.Errorf("%s not found in image tarball", name)
+	}
+	if entry.Size() > maxMetadataSize {
+		return fmt.Errorf("%s is too large", name)
+	}
+	data, err := io.ReadAll(entry)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	return nil
+}
+
+// Walk calls fn for each regular file of each layer, from the base layer to the top one.
+// Whiteout files are not reported, they are recorded to be used by Hidden.
+func (img *Image) Walk(fn FileFunc) error {
+	for i, l := range img.Layers {
+		if err := img.walkLayer(i, l, fn); err != nil {
+			return fmt.Errorf("layer %s: %w", l.Digest, err)
+		}
+	}
+	return nil
+}
+
+func (img *Image) walkLayer(index int, l *Layer, fn FileFunc) error {
+	blob := img.entries[l.blob]
+	if blob == nil {
+		return fmt.Errorf("blob %s not found in image tarball", l.blob)
+	}
+	r, closeFn, err := decompress(blob)
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	l.paths, l.whiteouts, l.opaque = map[string]bool{}, map[string]bool{}, map[string]bool{}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read layer: %w", err)
+		}
+
+		name := cleanPath(hdr.Name)
+		dir, base := path.Split(name)
+		dir = strings.TrimSuffix(dir, "/")
+		switch {
+		case base == opaqueWhiteout:
+			l.opaque[dir] = true
+			continue
+		case strings.HasPrefix(base, whiteoutPrefix):
+			l.whiteouts[path.Join(dir, strings.TrimPrefix(base, whiteoutPrefix))] = true
+			continue
+		case hdr.Typeflag != tar.TypeReg:
+			continue
+		}
+
+		l.paths[name] = true
+		if err := fn(File{Layer: index, Path: name, Size: hdr.Size}, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// Hidden returns true if file of given layer is not visible in filesystem of any image containing the layer,
+// since it is removed by whiteout or replaced in one of upper layers. It must be called after Walk.
+func (img *Image) Hidden(f File) bool {
+	for _, layers := range img.images {
+		position := slices.Index(layers, f.Layer)
+		if position < 0 {
+			continue
+		}
+		if !img.hiddenBy(f, layers[position+1:]) {
+			return false
+		}
+	}
+	return true
+}
+
+// hiddenBy checks if file is removed or replaced by any of given upper layers
+func (img *Image) hiddenBy(f File, upperLayers []int) bool {
+	for _, i := range upperLayers {
+		upper := img.Layers[i]
+		if upper.paths[f.Path] || upper.opaque[""] {
+			return true
+		}
+		for p := f.Path; p != "."; p = path.Dir(p) {
+			if upper.whiteouts[p] || (p != f.Path && upper.opaque[p]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// decompress returns reader of layer tar, detecting gzip and zstd compression by magic bytes
+func decompress(r io.Reader) (io.Reader, func(), error) {
+	br := bufio.NewReader(r)
+	header, _ := br.Peek(4)
+	switch {
+	case bytes.HasPrefix(header, []byte{0x1F, 0x8B}):
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to decompress layer: %w", err)
+		}
+		return zr, func() { zr.Close() }, nil
+	case bytes.HasPrefix(header, []byte{0x28, 0xB5, 0x2F, 0xFD}):
+		zr, err := zstd.NewReader(br, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to decompress layer: %w", err)
+		}
+		return zr, zr.Close, nil
+	}
+	return br, func() {}, nil
+}
+
+// blobDigest returns digest of the blob stored in OCI layout, e.g. blobs/sha256/abc..., or empty string
+func blobDigest(blob string) string {
+	parts := strings.Split(cleanPath(blob), "/")
+	if len(parts) == 3 && parts[0] == "blobs" {
+		return parts[1] + ":" + parts[2]
+	}
+	return ""
+}
+
+// digestPath returns path of the blob with given digest in OCI layout
+func digestPath(digest string) string {
+	algorithm, hash, _ := strings.Cut(digest, ":")
+	return path.Join("blobs", algorithm, hash)
+}
+
+// cleanPath returns path without leading slash or ./ prefix
+func cleanPath(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
@@ -0,0 +1,134 @@
+package image_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+
+	"github.com/netcracker/qubership-av-scan-service/pkg/image"
+)
+
+type entry struct {
+	name    string
+	content string
+}
+
+func TestWalkDockerSave(t *testing.T) {
+	base := tarFile([]entry{{"bin/sh", "shell"}, {"etc/secret", "secret"}, {"opt/app/old.conf", "old"}})
+	top := gzipFile(tarFile([]entry{
+		{"etc/.wh.secret", ""},
+		{"opt/app/.wh..wh..opq", ""},
+		{"bin/sh", "new shell"},
+		{"usr/bin/tool", "tool"},
+	}))
+	tarball := tarFile([]entry{
+		{"manifest.json", `[{"Config":"config.json","Layers":["base/layer.tar","top/layer.tar"]}]`},
+		{"config.json", `{"rootfs":{"diff_ids":["sha256:base","sha256:top"]}}`},
+		{"base/layer.tar", string(base)},
+		{"top/layer.tar", string(top)},
+	})
+
+	img, err := image.Open(bytes.NewReader(tarball), int64(len(tarball)))
+	if err != nil {
+		t.Fatalf("expected image to be opened, but failed: %s", err)
+	}
+	if len(img.Layers) != 2 || img.Layers[0].Digest != "sha256:base" || img.Layers[1].Digest != "sha256:top" {
+		t.Fatalf("unexpected layers: %+v", img.Layers)
+	}
+
+	files := walk(t, img)
+	expected := map[string]bool{
+		"sha256:base bin/sh":           true,
+		"sha256:base etc/secret":       true,
+		"sha256:base opt/app/old.conf": true,
+		"sha256:top bin/sh":            false,
+		"sha256:top usr/bin/tool":      false,
+	}
+	if len(files) != len(expected) {
+		t.Fatalf("expected %d files, but got: %v", len(expected), files)
+	}
+	for name, hidden := range expected {
+		actual, ok := files[name]
+		if !ok {
+			t.Fatalf("expected %s to be walked, but got: %v", name, files)
+		}
+		if actual != hidden {
+			t.Fatalf("expected %s hidden to be %v, but got: %v", name, hidden, actual)
+		}
+	}
+}
+
+func TestWalkOCILayout(t *testing.T) {
+	layer := tarFile([]entry{{"app/run.sh", "#!/bin/sh"}})
+	tarball := tarFile([]entry{
+		{"oci-layout", `{"imageLayoutVersion":"1.0.0"}`},
+		{"index.json", `{"manifests":[{"mediaType":"application/vnd.oci.image.manifest.v1+json","digest":"sha256:manifest"}]}`},
+		{"blobs/sha256/manifest", `{"layers":[{"mediaType":"application/vnd.oci.image.layer.v1.tar","digest":"sha256:layer"}]}`},
+		{"blobs/sha256/layer", string(layer)},
+	})
+
+	img, err := image.Open(bytes.NewReader(tarball), int64(len(tarball)))
+	if err != nil {
+		t.Fatalf("expected image to be opened, but failed: %s", err)
+	}
+	files := walk(t, img)
+	if _, ok := files["sha256:layer app/run.sh"]; !ok || len(files) != 1 {
+		t.Fatalf("expected app/run.sh to be walked, but got: %v", files)
+	}
+}
+
+func TestOpenNotImage(t *testing.T) {
+	tarball := tarFile([]entry{{"file.txt", "text"}})
+	if _, err := image.Open(bytes.NewReader(tarball), int64(len(tarball))); err == nil {
+		t.Fatal("expected error for tarball without manifest")
+	}
+}
+
+// walk returns hidden flag of each walked file by "<layer digest> <path>"
+func walk(t *testing.T, img *image.Image) map[string]bool {
+	var walked []image.File
+	err := img.Walk(func(f image.File, r io.Reader) error {
+		walked = append(walked, f)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("expected image to be walked, but failed: %s", err)
+	}
+	files := map[string]bool{}
+	for _, f := range walked {
+		files[img.Layers[f.Layer].Digest+" "+f.Path] = img.Hidden(f)
+	}
+	return files
+}
+
+func tarFile(entries []entry) []byte {
+	buffer := &bytes.Buffer{}
+	tw := tar.NewWriter(buffer)
+	for _, e := range entries {
+		err := tw.WriteHeader(&tar.Header{Name: e.name, Mode: 0600, Size: int64(len(e.content))})
+		if err != nil {
+			panic(err)
+		}
+		if _, err := tw.Write([]byte(e.content)); err != nil {
+			panic(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		panic(err)
+	}
+	return buffer.Bytes()
+}
+
+func gzipFile(content []byte) []byte {
+	buffer := &bytes.Buffer{}
+	gw := gzip.NewWriter(buffer)
+	if _, err := gw.Write(content); err != nil {
+		panic(err)
+	}
+	if err := gw.Close(); err != nil {
+		panic(err)
+	}
+	return buffer.Bytes()
+}
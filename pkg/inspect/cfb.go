@@ -0,0 +1,243 @@
+package inspect
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"unicode/utf16"
+)
+
+// cfbSignature is a signature of Compound File Binary format (OLE2),
+// used by legacy Office documents and encrypted OOXML documents
+var cfbSignature = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}
+
+const (
+	cfbHeaderSize   = 512
+	cfbDirEntrySize = 128
+	cfbMaxRegSect   = 0xFFFFFFFA
+	cfbEndOfChain   = 0xFFFFFFFE
+	// cfbMaxSectors restricts number of sectors walked in any chain to protect from loops
+	cfbMaxSectors = 1 << 20
+)
+
+// cfb entry object types
+const (
+	cfbTypeStorage = 1
+	cfbTypeStream  = 2
+	cfbTypeRoot    = 5
+)
+
+// cfbEntry is a single directory entry of compound file
+type cfbEntry struct {
+	Name  string
+	Type  byte
+	Start uint32
+	Size  uint64
+}
+
+// cfbFile is a minimal read-only parser of Compound File Binary format.
+// It is able to list directory entries and read streams.
+type cfbFile struct {
+	r              io.ReaderAt
+	sectorSize     int64
+	fat            []uint32
+	firstDir       uint32
+	miniCutoff     uint64
+	firstMiniFat   uint32
+	miniFatCount   uint32
+	miniSectorSize int64
+}
+
+// isCFB returns true if given header starts with compound file signature
+func isCFB(header []byte) bool {
+	return bytes.HasPrefix(header, cfbSignature)
+}
+
+// openCFB reads compound file header and sectors allocation table
+func openCFB(r io.ReaderAt, size int64) (*cfbFile, error) {
+	header := make([]byte, cfbHeaderSize)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return nil, fmt.Errorf("failed to read compound file header: %w", err)
+	}
+	if !isCFB(header) {
+		return nil, fmt.Errorf("not a compound file")
+	}
+
+	shift := binary.LittleEndian.Uint16(header[0x1E:])
+	if shift != 9 && shift != 12 {
+		return nil, fmt.Errorf("unsupported compound file sector shift: %d", shift)
+	}
+	// mini sector shift is fixed by the format, any other value is not trusted to be used as a shift
+	miniShift := binary.LittleEndian.Uint16(header[0x20:])
+	if miniShift != 6 {
+		return nil, fmt.Errorf("unsupported compound file mini sector shift: %d", miniShift)
+	}
+	c := &cfbFile{
+		r:              r,
+		sectorSize:     1 << shift,
+		miniCutoff:     uint64(binary.LittleEndian.Uint32(header[0x38:])),
+		firstMiniFat:   binary.LittleEndian.Uint32(header[0x3C:]),
+		miniFatCount:   binary.LittleEndian.Uint32(header[0x40:]),
+		miniSectorSize: 1 << miniShift,
+	}
+
+	// collect FAT sector locations from header DIFAT and DIFAT chain
+	fatCount := binary.LittleEndian.Uint32(header[0x2C:])
+	if int64(fatCount) > size/c.sectorSize+1 {
+		return nil, fmt.Errorf("invalid compound file FAT sectors count: %d", fatCount)
+	}
+	var fatSectors []uint32
+	for i := 0; i < 109 && uint32(len(fatSectors)) < fatCount; i++ {
+		fatSectors = append(fatSectors, binary.LittleEndian.Uint32(header[0x4C+i*4:]))
+	}
+	difat := binary.LittleEndian.Uint32(header[0x44:])
+	for walked := 0; difat <= cfbMaxRegSect && uint32(len(fatSectors)) < fatCount; walked++ {
+		if walked > cfbMaxSectors {
+			return nil, fmt.Errorf("compound file DIFAT chain is too long")
+		}
+		sector, err := c.sector(difat)
+		if err != nil {
+			return nil, err
+		}
+		entries := len(sector)/4 - 1
+		for i := 0; i < entries && uint32(len(fatSectors)) < fatCount; i++ {
+			fatSectors = append(fatSectors, binary.LittleEndian.Uint32(sector[i*4:]))
+		}
+		difat = binary.LittleEndian.Uint32(sector[entries*4:])
+	}
+
+	for _, s := range fatSectors {
+		sector, err := c.sector(s)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < len(sector); i += 4 {
+			c.fat = append(c.fat, binary.LittleEndian.Uint32(sector[i:]))
+		}
+	}
+
+	c.firstDir = binary.LittleEndian.Uint32(header[0x30:])
+	if c.firstDir > cfbMaxRegSect {
+		return nil, fmt.Errorf("compound file has no directory")
+	}
+	return c, nil
+}
+
+// sector reads sector with given number
+func (c *cfbFile) sector(n uint32) ([]byte, error) {
+	if n > cfbMaxRegSect {
+		return nil, fmt.Errorf("invalid compound file sector: %x", n)
+	}
+	buf := make([]byte, c.sectorSize)
+	if _, err := c.r.ReadAt(buf, (int64(n)+1)*c.sectorSize); err != nil {
+		return nil, fmt.Errorf("failed to read compound file sector %d: %w", n, err)
+	}
+	return buf, nil
+}
+
+// chain reads sectors of the chain starting from given sector until the end of chain or until size bytes are read.
+// Each sector is read at most once, so looped chains are rejected before reading more data than the file contains.
+func (c *cfbFile) chain(start uint32, size uint64) ([]byte, error) {
+	var data []byte
+	visited := map[uint32]bool{}
+	for n := start; n != cfbEndOfChain && uint64(len(data)) < size; n = c.fat[n] {
+		if int(n) >= len(c.fat) || visited[n] {
+			return nil, fmt.Errorf("invalid compound file sectors chain")
+		}
+		visited[n] = true
+		sector, err := c.sector(n)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, sector...)
+	}
+	return data, nil
+}
+
+// entries returns all directory entries of compound file
+func (c *cfbFile) entries() ([]cfbEntry, error) {
+	// size of directory is not stored in version 3 files, it is restricted by the file size only
+	dir, err := c.chain(c.firstDir, math.MaxUint64)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []cfbEntry
+	for off := 0; off+cfbDirEntrySize <= len(dir); off += cfbDirEntrySize {
+		raw := dir[off : off+cfbDirEntrySize]
+		entryType := raw[66]
+		if entryType != cfbTypeStorage && entryType != cfbTypeStream && entryType != cfbTypeRoot {
+			continue
+		}
+		nameLen := int(binary.LittleEndian.Uint16(raw[64:]))
+		if nameLen < 2 || nameLen > 64 {
+			continue
+		}
+		name := make([]uint16, nameLen/2-1)
+		for i := range name {
+			name[i] = binary.LittleEndian.Uint16(raw[i*2:])
+		}
+		size := binary.LittleEndian.Uint64(raw[120:])
+		if c.sectorSize == 512 {
+			// version 3 files have only 32-bit sizes, high part may contain garbage
+			size &= 0xFFFFFFFF
+		}
+		entries = append(entries, cfbEntry{
+			Name:  string(utf16.Decode(name)),
+			Type:  entryType,
+			Start: binary.LittleEndian.Uint32(raw[116:]),
+			Size:  size,
+		})
+	}
+	return entries, nil
+}
+
+// stream reads content of the stream entry, failing if stream is larger than limit.
+// Streams smaller than mini stream cutoff are read from the mini stream of the root entry.
+func (c *cfbFile) stream(e cfbEntry, root cfbEntry, limit int64) ([]byte, error) {
+	if e.Size > uint64(limit) {
+		return nil, fmt.Errorf("compound file stream %s is too large: %d", e.Name, e.Size)
+	}
+	if e.Size == 0 {
+		return nil, nil
+	}
+	if e.Size >= c.miniCutoff {
+		data, err := c.chain(e.Start, e.Size)
+		if err != nil {
+			return nil, err
+		}
+		if uint64(len(data)) < e.Size {
+			return nil, fmt.Errorf("compound file stream %s is truncated", e.Name)
+		}
+		return data[:e.Size], nil
+	}
+
+	miniFatData, err := c.chain(c.firstMiniFat, uint64(c.miniFatCount)*uint64(c.sectorSize))
+	if err != nil {
+		return nil, err
+	}
+	miniFat := make([]uint32, len(miniFatData)/4)
+	for i := range miniFat {
+		miniFat[i] = binary.LittleEndian.Uint32(miniFatData[i*4:])
+	}
+	ministream, err := c.chain(root.Start, root.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, 0, e.Size)
+	for n, walked := e.Start, 0; n != cfbEndOfChain && uint64(len(data)) < e.Size; walked++ {
+		off := int64(n) * c.miniSectorSize
+		if walked > len(miniFat) || int(n) >= len(miniFat) || off+c.miniSectorSize > int64(len(ministream)) {
+			return nil, fmt.Errorf("invalid compound file mini sectors chain")
+		}
+		data = append(data, ministream[off:off+c.miniSectorSize]...)
+		n = miniFat[n]
+	}
+	if uint64(len(data)) < e.Size {
+		return nil, fmt.Errorf("compound file stream %s is truncated", e.Name)
+	}
+	return data[:e.Size], nil
+}
@@ -0,0 +1,210 @@
+package inspect
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Encryption is a kind of encrypted content
+type Encryption string
+
+const (
+	EncryptionNone   Encryption = ""
+	EncryptionZip    Encryption = "Zip"
+	Encryption7Zip   Encryption = "7Zip"
+	EncryptionRAR    Encryption = "RAR"
+	EncryptionOffice Encryption = "Office"
+	EncryptionPDF    Encryption = "PDF"
+)
+
+// Signature returns a virus-like name used to report encrypted content as infected,
+// it is aligned with names ClamAV uses for encrypted content heuristics
+func (e Encryption) Signature() string {
+	return "Heuristics.Encrypted." + string(e)
+}
+
+var (
+	zipSignature      = []byte("PK\x03\x04")
+	sevenZipSignature = []byte{'7', 'z', 0xBC, 0xAF, 0x27, 0x1C}
+	rar4Signature     = []byte("Rar!\x1a\x07\x00")
+	rar5Signature     = []byte("Rar!\x1a\x07\x01\x00")
+	pdfSignature      = []byte("%PDF-")
+)
+
+// sevenZipAESMethod is the identifier of 7z AES-256 + SHA-256 coder
+var sevenZipAESMethod = []byte{0x06, 0xF1, 0x07, 0x01}
+
+// maxHeaderSize restricts size of archive headers read during detection
+const maxHeaderSize = 1 << 20
+
+// DetectEncryption detects if content is an encrypted archive or document.
+// Zip with any encrypted entry, 7z and RAR with encrypted headers,
+// encrypted OOXML documents and encrypted PDF documents are detected.
+func DetectEncryption(r io.ReaderAt, size int64) (Encryption, error) {
+	header := make([]byte, min(size, 512))
+	if _, err := r.ReadAt(header, 0); err != nil && err != io.EOF {
+		return EncryptionNone, err
+	}
+
+	var encrypted bool
+	var kind Encryption
+	var err error
+	switch {
+	case bytes.HasPrefix(header, zipSignature):
+		kind = EncryptionZip
+		encrypted, err = zipEncrypted(r, size)
+	case bytes.HasPrefix(header, sevenZipSignature):
+		kind = Encryption7Zip
+		encrypted, err = sevenZipEncrypted(r, size)
+	case bytes.HasPrefix(header, rar5Signature):
+		kind = EncryptionRAR
+		encrypted, err = rar5Encrypted(r)
+	case bytes.HasPrefix(header, rar4Signature):
+		kind = EncryptionRAR
+		encrypted, err = rar4Encrypted(r)
+	case isCFB(header):
+		kind = EncryptionOffice
+		encrypted, err = officeEncrypted(r, size)
+	case bytes.HasPrefix(header, pdfSignature):
+		kind = EncryptionPDF
+		encrypted, err = pdfEncrypted(r, size)
+	}
+	if err != nil || !encrypted {
+		return EncryptionNone, err
+	}
+	return kind, nil
+}
+
+// zipEncrypted checks encryption bit of general purpose flags of each entry
+func zipEncrypted(r io.ReaderAt, size int64) (bool, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return false, fmt.Errorf("failed to read zip: %w", err)
+	}
+	for _, f := range zr.File {
+		if f.Flags&0x1 != 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// sevenZipEncrypted checks if 7z header is encoded with AES coder
+func sevenZipEncrypted(r io.ReaderAt, size int64) (bool, error) {
+	start := make([]byte, 32)
+	if _, err := r.ReadAt(start, 0); err != nil {
+		return false, fmt.Errorf("failed to read 7z header: %w", err)
+	}
+	offset := binary.LittleEndian.Uint64(start[12:])
+	length := binary.LittleEndian.Uint64(start[20:])
+	if offset > uint64(size) || length > maxHeaderSize || 32+offset+length > uint64(size) {
+		return false, fmt.Errorf("invalid 7z next header location")
+	}
+
+	header := make([]byte, length)
+	if _, err := r.ReadAt(header, int64(32+offset)); err != nil {
+		return false, fmt.Errorf("failed to read 7z next header: %w", err)
+	}
+	return bytes.Contains(header, sevenZipAESMethod), nil
+}
+
+// rar4Encrypted checks password flag of RAR 4.x main header and encryption flag of file headers
+func rar4Encrypted(r io.ReaderAt) (bool, error) {
+	const (
+		mainHeaderType   = 0x73
+		fileHeaderType   = 0x74
+		mainHeadPassword = 0x0080
+		fileEncrypted    = 0x0004
+		longBlock        = 0x8000
+	)
+
+	offset := int64(len(rar4Signature))
+	block := make([]byte, 11)
+	for i := 0; i < 16; i++ {
+		n, err := r.ReadAt(block, offset)
+		if n < 7 {
+			if err == io.EOF {
+				return false, nil
+			}
+			return false, fmt.Errorf("failed to read RAR block: %w", err)
+		}
+		blockType := block[2]
+		flags := binary.LittleEndian.Uint16(block[3:])
+		blockSize := int64(binary.LittleEndian.Uint16(block[5:]))
+		if blockType == mainHeaderType && flags&mainHeadPassword != 0 {
+			return true, nil
+		}
+		if blockType == fileHeaderType && flags&fileEncrypted != 0 {
+			return true, nil
+		}
+		if flags&longBlock != 0 || blockType == fileHeaderType {
+			if n < 11 {
+				return false, nil
+			}
+			blockSize += int64(binary.LittleEndian.Uint32(block[7:]))
+		}
+		if blockSize < 7 {
+			return false, fmt.Errorf("invalid RAR block size: %d", blockSize)
+		}
+		offset += blockSize
+	}
+	return false, nil
+}
+
+// rar5Encrypted checks if the first RAR 5.x header is archive encryption header
+func rar5Encrypted(r io.ReaderAt) (bool, error) {
+	const encryptionHeaderType = 4
+
+	buf := make([]byte, 24)
+	n, err := r.ReadAt(buf, int64(len(rar5Signature)))
+	if n < 6 {
+		return false, fmt.Errorf("failed to read RAR header: %w", err)
+	}
+	// header starts with CRC32 followed by header size and header type as vints
+	rest := buf[4:n]
+	_, sizeLen := binary.Uvarint(rest)
+	if sizeLen <= 0 {
+		return false, fmt.Errorf("invalid RAR header size")
+	}
+	headerType, typeLen := binary.Uvarint(rest[sizeLen:])
+	if typeLen <= 0 {
+		return false, fmt.Errorf("invalid RAR header type")
+	}
+	return headerType == encryptionHeaderType, nil
+}
+
+// officeEncrypted checks if compound file contains EncryptionInfo stream,
+// which is used to store encrypted OOXML documents
+func officeEncrypted(r io.ReaderAt, size int64) (bool, error) {
+	c, err := openCFB(r, size)
+	if err != nil {
+		return false, err
+	}
+	entries, err := c.entries()
+	if err != nil {
+		return false, err
+	}
+	for _, e := range entries {
+		if e.Type == cfbTypeStream && e.Name == "EncryptionInfo" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// pdfEncrypted checks if PDF trailer or cross-reference stream dictionary contains Encrypt key
+func pdfEncrypted(r io.ReaderAt, size int64) (bool, error) {
+	found := false
+	err := scanTokens(io.NewSectionReader(r, 0, size), [][]byte{[]byte("/Encrypt")}, func(token []byte, next byte) bool {
+		// skip keys like /EncryptMetadata which only start with /Encrypt
+		if isPDFNameChar(next) {
+			return true
+		}
+		found = true
+		return false
+	})
+	return found, err
+}
@@ -0,0 +1,109 @@
+package inspect_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"runtime"
+	"testing"
+
+	"github.com/netcracker/qubership-av-scan-service/pkg/inspect"
+	"github.com/netcracker/qubership-av-scan-service/pkg/testutils"
+)
+
+func TestDetectEncryption(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  []byte
+		expected inspect.Encryption
+	}{
+		{
+			name:     "plain text",
+			content:  []byte("safe content"),
+			expected: inspect.EncryptionNone,
+		},
+		{
+			name:     "encrypted office",
+			content:  testutils.CompoundFile(map[string][]byte{"EncryptionInfo": {1, 2, 3}, "EncryptedPackage": {4, 5, 6}}),
+			expected: inspect.EncryptionOffice,
+		},
+		{
+			name:     "legacy office",
+			content:  testutils.CompoundFile(map[string][]byte{"WordDocument": {1, 2, 3}}),
+			expected: inspect.EncryptionNone,
+		},
+		{
+			name:     "encrypted pdf",
+			content:  []byte("%PDF-1.7\n1 0 obj\n<<>>\nendobj\ntrailer\n<< /Root 1 0 R /Encrypt 2 0 R >>\n%%EOF"),
+			expected: inspect.EncryptionPDF,
+		},
+		{
+			name:     "pdf with encrypt metadata key only",
+			content:  []byte("%PDF-1.7\n1 0 obj\n<< /EncryptMetadata false >>\nendobj\n%%EOF"),
+			expected: inspect.EncryptionNone,
+		},
+		{
+			name:     "rar5 with encrypted headers",
+			content:  append([]byte("Rar!\x1a\x07\x01\x00"), 0, 0, 0, 0, 0x0C, 0x04, 0x00, 0x00),
+			expected: inspect.EncryptionRAR,
+		},
+		{
+			name:     "rar4 with encrypted headers",
+			content:  append([]byte("Rar!\x1a\x07\x00"), 0, 0, 0x73, 0x80, 0x00, 0x0D, 0x00, 0, 0, 0, 0, 0, 0),
+			expected: inspect.EncryptionRAR,
+		},
+		{
+			name:     "7z with encrypted header",
+			content:  sevenZip([]byte{0x17, 0x06, 0xF1, 0x07, 0x01}),
+			expected: inspect.Encryption7Zip,
+		},
+		{
+			name:     "7z without encryption",
+			content:  sevenZip([]byte{0x01, 0x04, 0x06, 0x00}),
+			expected: inspect.EncryptionNone,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			encryption, err := inspect.DetectEncryption(bytes.NewReader(test.content), int64(len(test.content)))
+			if err != nil {
+				t.Fatalf("expected no error, but got: %s", err)
+			}
+			if encryption != test.expected {
+				t.Fatalf("expected encryption to be '%s', but got: '%s'", test.expected, encryption)
+			}
+		})
+	}
+}
+
+// sevenZip builds 7z archive skeleton with given next header and no packed streams
+func sevenZip(nextHeader []byte) []byte {
+	start := make([]byte, 32)
+	copy(start, []byte{'7', 'z', 0xBC, 0xAF, 0x27, 0x1C, 0, 4})
+	binary.LittleEndian.PutUint64(start[12:], 0)
+	binary.LittleEndian.PutUint64(start[20:], uint64(len(nextHeader)))
+	return append(start, nextHeader...)
+}
+
+func TestDetectEncryptionLoopedChain(t *testing.T) {
+	// zero-filled FAT makes every sector point to sector 0, so directory chain loops over it
+	content := make([]byte, 4<<20)
+	header := testutils.CompoundFile(map[string][]byte{"WordDocument": {1, 2, 3}})[:512]
+	copy(content, header)
+	binary.LittleEndian.PutUint32(content[0x2C:], 109)
+	binary.LittleEndian.PutUint32(content[0x30:], 0)
+	for i := 0; i < 109; i++ {
+		binary.LittleEndian.PutUint32(content[0x4C+i*4:], uint32(i))
+	}
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err := inspect.DetectEncryption(bytes.NewReader(content), int64(len(content)))
+	runtime.ReadMemStats(&after)
+	if err == nil {
+		t.Fatal("expected error for looped sectors chain")
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+		t.Fatalf("expected looped chain to be rejected early, but %d bytes were allocated", allocated)
+	}
+}
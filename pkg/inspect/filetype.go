@@ -0,0 +1,153 @@
+package inspect
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+)
+
+// sniffSize is the number of leading bytes used to detect file type
+const sniffSize = 512
+
+// FileType is a file type detected from the file content
+type FileType struct {
+	// MIME is a detected media type without parameters, e.g. application/pdf
+	MIME string
+	// Extensions lists extensions which are expected for the type,
+	// it is empty if any extension is acceptable
+	Extensions []string
+}
+
+// Mismatch returns true if extension of given file name is not expected for the file type.
+// Files without extension are never considered mismatched.
+func (t FileType) Mismatch(filename string) bool {
+	ext := strings.ToLower(path.Ext(filename))
+	if ext == "" || len(t.Extensions) == 0 {
+		return false
+	}
+	return !slices.Contains(t.Extensions, ext)
+}
+
+// magic describes file type signature located at the given offset
+type magic struct {
+	offset    int
+	signature []byte
+	fileType  FileType
+}
+
+var magics = []magic{
+	{0, []byte("%PDF-"), FileType{"application/pdf", []string{".pdf"}}},
+	{0, []byte("\x89PNG\r\n\x1a\n"), FileType{"image/png", []string{".png"}}},
+	{0, []byte{0xFF, 0xD8, 0xFF}, FileType{"image/jpeg", []string{".jpg", ".jpeg", ".jpe", ".jfif"}}},
+	{0, []byte("GIF87a"), FileType{"image/gif", []string{".gif"}}},
+	{0, []byte("GIF89a"), FileType{"image/gif", []string{".gif"}}},
+	{0, []byte("II*\x00"), FileType{"image/tiff", []string{".tif", ".tiff"}}},
+	{0, []byte("MM\x00*"), FileType{"image/tiff", []string{".tif", ".tiff"}}},
+	{8, []byte("WEBP"), FileType{"image/webp", []string{".webp"}}},
+	{0, []byte{0x00, 0x00, 0x01, 0x00}, FileType{"image/x-icon", []string{".ico"}}},
+	{0, []byte("MZ"), FileType{"application/vnd.microsoft.portable-executable", []string{".exe", ".dll", ".sys", ".scr", ".com", ".cpl", ".ocx", ".efi"}}},
+	{0, []byte("\x7fELF"), FileType{"application/x-elf", []string{".so", ".o", ".elf", ".bin", ".run"}}},
+	{0, []byte{0xCF, 0xFA, 0xED, 0xFE}, FileType{"application/x-mach-binary", []string{".dylib", ".bundle"}}},
+	{0, []byte{0xCA, 0xFE, 0xBA, 0xBE}, FileType{"application/java-vm", []string{".class"}}},
+	{0, []byte("#!"), FileType{"text/x-shellscript", []string{".sh", ".bash", ".py", ".pl", ".rb", ".run"}}},
+	{0, cfbSignature, FileType{"application/x-ole-storage", []string{".doc", ".dot", ".xls", ".xlt", ".ppt", ".pot", ".msg", ".msi", ".docx", ".docm", ".xlsx", ".xlsm", ".pptx", ".pptm", ".vsd", ".pub"}}},
+	{0, []byte("PK\x03\x04"), FileType{"application/zip", []string{".zip"}}},
+	{0, []byte("PK\x05\x06"), FileType{"application/zip", []string{".zip"}}},
+	{0, []byte{0x1F, 0x8B}, FileType{"application/gzip", []string{".gz", ".tgz"}}},
+	{0, sevenZipSignature, FileType{"application/x-7z-compressed", []string{".7z"}}},
+	{0, []byte("Rar!\x1a\x07"), FileType{"application/vnd.rar", []string{".rar"}}},
+	{0, []byte("BZh"), FileType{"application/x-bzip2", []string{".bz2", ".tbz2"}}},
+	{0, []byte{0xFD, '7', 'z', 'X', 'Z', 0x00}, FileType{"application/x-xz", []string{".xz", ".txz"}}},
+	{0, []byte{0x28, 0xB5, 0x2F, 0xFD}, FileType{"application/zstd", []string{".zst"}}},
+	{257, []byte("ustar"), FileType{"application/x-tar", []string{".tar"}}},
+	{0, []byte("{\\rtf"), FileType{"application/rtf", []string{".rtf", ".doc"}}},
+}
+
+// sniffedExtensions defines expected extensions for types detected by net/http
+var sniffedExtensions = map[string][]string{
+	"image/bmp":       {".bmp", ".dib"},
+	"image/webp":      {".webp"},
+	"text/html":       {".html", ".htm", ".xhtml", ".shtml"},
+	"audio/mpeg":      {".mp3"},
+	"audio/wave":      {".wav"},
+	"video/mp4":       {".mp4", ".m4v", ".m4a"},
+	"video/webm":      {".webm"},
+	"application/ogg": {".ogg", ".oga", ".ogv"},
+	"font/woff":       {".woff"},
+	"font/woff2":      {".woff2"},
+}
+
+// zipTypes defines zip-based formats detected by the presence of a specific member
+var zipTypes = []struct {
+	member   string
+	fileType FileType
+}{
+	{"word/vbaProject.bin", FileType{"application/vnd.ms-word.document.macroEnabled.12", []string{".docm", ".dotm"}}},
+	{"word/document.xml", FileType{"application/vnd.openxmlformats-officedocument.wordprocessingml.document", []string{".docx", ".dotx"}}},
+	{"xl/vbaProject.bin", FileType{"application/vnd.ms-excel.sheet.macroEnabled.12", []string{".xlsm", ".xltm", ".xlam"}}},
+	{"xl/workbook.xml", FileType{"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", []string{".xlsx", ".xltx"}}},
+	{"xl/workbook.bin", FileType{"application/vnd.ms-excel.sheet.binary.macroEnabled.12", []string{".xlsb"}}},
+	{"ppt/vbaProject.bin", FileType{"application/vnd.ms-powerpoint.presentation.macroEnabled.12", []string{".pptm", ".potm", ".ppsm"}}},
+	{"ppt/presentation.xml", FileType{"application/vnd.openxmlformats-officedocument.presentationml.presentation", []string{".pptx", ".potx", ".ppsx"}}},
+	{"AndroidManifest.xml", FileType{"application/vnd.android.package-archive", []string{".apk"}}},
+	{"META-INF/MANIFEST.MF", FileType{"application/java-archive", []string{".jar", ".war", ".ear"}}},
+}
+
+// DetectFileType detects type of the content using magic bytes.
+// Zip-based formats (Office documents, Java archives) are recognized by their members.
+// If type is not recognized by known signatures, detection algorithm of net/http is used.
+func DetectFileType(r io.ReaderAt, size int64) (FileType, error) {
+	header := make([]byte, min(size, sniffSize))
+	if _, err := r.ReadAt(header, 0); err != nil && err != io.EOF {
+		return FileType{}, err
+	}
+
+	for _, m := range magics {
+		if len(header) < m.offset || !bytes.HasPrefix(header[m.offset:], m.signature) {
+			continue
+		}
+		if m.fileType.MIME == "application/zip" {
+			return detectZipType(r, size, m.fileType), nil
+		}
+		return m.fileType, nil
+	}
+
+	mime := http.DetectContentType(header)
+	if i := strings.IndexByte(mime, ';'); i >= 0 {
+		mime = mime[:i]
+	}
+	return FileType{MIME: mime, Extensions: sniffedExtensions[mime]}, nil
+}
+
+// detectZipType refines zip type by looking for members specific to zip-based formats
+func detectZipType(r io.ReaderAt, size int64, zipType FileType) FileType {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return zipType
+	}
+	names := make(map[string]bool, len(zr.File))
+	for _, f := range zr.File {
+		names[f.Name] = true
+	}
+	for _, t := range zipTypes {
+		if names[t.member] {
+			return t.fileType
+		}
+	}
+	return zipType
+}
+
+// MatchMIME checks if MIME type matches given pattern.
+// Pattern is either exact type, e.g. application/pdf, or type with wildcard subtype, e.g. image/*.
+func MatchMIME(pattern string, mime string) bool {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	mime = strings.ToLower(mime)
+	if prefix, ok := strings.CutSuffix(pattern, "/*"); ok {
+		return strings.HasPrefix(mime, prefix+"/")
+	}
+	return pattern == "*" || pattern == mime
+}
@@ -0,0 +1,26 @@
+package inspect
+
+// FindingType identifies a kind of potentially dangerous content found in a file
+type FindingType string
+
+// FindingTypes lists all types of findings reported by detectors
+var FindingTypes = []FindingType{
+	FindingVBAProject,
+	FindingXLMMacro,
+	FindingDDE,
+	FindingPDFJavaScript,
+	FindingPDFLaunch,
+	FindingPDFOpenAction,
+	FindingPDFEmbeddedFile,
+	FindingPDFEmbeddedExecutable,
+}
+
+// Finding is a potentially dangerous content found in a file by Go-side inspection
+type Finding struct {
+	// Type identifies the kind of finding
+	Type FindingType `json:"type"`
+	// Location is a path of the finding inside the file, e.g. archive member or stream name
+	Location string `json:"location,omitempty"`
+	// Description is a human-readable description of the finding
+	Description string `json:"description"`
+}
@@ -0,0 +1,239 @@
+package inspect
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+const (
+	// FindingVBAProject means that document contains VBA macros project
+	FindingVBAProject FindingType = "vba_project"
+	// FindingXLMMacro means that workbook contains Excel 4.0 (XLM) macro sheets
+	FindingXLMMacro FindingType = "xlm_macro"
+	// FindingDDE means that document contains Dynamic Data Exchange links or fields
+	FindingDDE FindingType = "dde"
+)
+
+// maxOfficePartSize restricts size of document parts which are read during inspection
+const maxOfficePartSize = 64 << 20
+
+// biff record types used to detect XLM macro sheets in legacy workbooks
+const (
+	biffBoundSheet = 0x0085
+	biffMacroSheet = 0x01
+)
+
+// ddeTokens are lower-cased markers of DDE fields and links in document parts
+var ddeTokens = [][]byte{[]byte("ddeauto"), []byte(" dde "), []byte("<ddelink")}
+
+// DetectMacros detects VBA projects, XLM macros and DDE indicators
+// in OOXML (zip-based) and legacy OLE (compound file) Office documents.
+// For other content no findings are returned.
+// If document is malformed, findings collected before the failure are returned along with error.
+func DetectMacros(r io.ReaderAt, size int64) ([]Finding, error) {
+	header := make([]byte, min(size, 8))
+	if _, err := r.ReadAt(header, 0); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(header, zipSignature):
+		return ooxmlMacros(r, size)
+	case isCFB(header):
+		return oleMacros(r, size)
+	}
+	return nil, nil
+}
+
+// ooxmlMacros inspects parts of OOXML package
+func ooxmlMacros(r io.ReaderAt, size int64) ([]Finding, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read zip: %w", err)
+	}
+
+	var findings []Finding
+	for _, f := range zr.File {
+		name := f.Name
+		lower := strings.ToLower(name)
+		switch {
+		case path.Base(lower) == "vbaproject.bin":
+			findings = append(findings, Finding{
+				Type:        FindingVBAProject,
+				Location:    name,
+				Description: "document contains VBA project",
+			})
+		case strings.HasPrefix(lower, "xl/macrosheets/"):
+			findings = append(findings, Finding{
+				Type:        FindingXLMMacro,
+				Location:    name,
+				Description: "workbook contains Excel 4.0 macro sheet",
+			})
+		case strings.HasSuffix(lower, ".xml") && (strings.HasPrefix(lower, "word/") || strings.HasPrefix(lower, "xl/externallinks/")):
+			found, err := zipMemberContains(f, ddeTokens)
+			if err != nil {
+				return findings, err
+			}
+			if found {
+				findings = append(findings, Finding{
+					Type:        FindingDDE,
+					Location:    name,
+					Description: "document contains DDE field or link",
+				})
+			}
+		}
+	}
+	return findings, nil
+}
+
+// zipMemberContains checks if zip member contains any of given lower-cased tokens, ignoring case
+func zipMemberContains(f *zip.File, tokens [][]byte) (bool, error) {
+	if f.Flags&0x1 != 0 {
+		return false, nil
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return false, fmt.Errorf("failed to open zip member %s: %w", f.Name, err)
+	}
+	defer rc.Close()
+
+	found := false
+	err = scanTokens(&lowerReader{io.LimitReader(rc, maxOfficePartSize)}, tokens, func([]byte, byte) bool {
+		found = true
+		return false
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to read zip member %s: %w", f.Name, err)
+	}
+	return found, nil
+}
+
+// oleMacros inspects storages and streams of legacy Office document
+func oleMacros(r io.ReaderAt, size int64) ([]Finding, error) {
+	c, err := openCFB(r, size)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := c.entries()
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []Finding
+	var root cfbEntry
+	vbaFound := false
+	for _, e := range entries {
+		switch {
+		case e.Type == cfbTypeRoot:
+			root = e
+		case !vbaFound && (e.Name == "_VBA_PROJECT" || e.Name == "_VBA_PROJECT_CUR" || (e.Type == cfbTypeStorage && e.Name == "VBA")):
+			vbaFound = true
+			findings = append(findings, Finding{
+				Type:        FindingVBAProject,
+				Location:    e.Name,
+				Description: "document contains VBA project",
+			})
+		}
+	}
+
+	for _, e := range entries {
+		if e.Type != cfbTypeStream {
+			continue
+		}
+		switch e.Name {
+		case "Workbook", "Book":
+			data, err := c.stream(e, root, maxOfficePartSize)
+			if err != nil {
+				return findings, err
+			}
+			if sheet := biffMacroSheetName(data); sheet != "" {
+				findings = append(findings, Finding{
+					Type:        FindingXLMMacro,
+					Location:    e.Name,
+					Description: fmt.Sprintf("workbook contains Excel 4.0 macro sheet %q", sheet),
+				})
+			}
+		case "WordDocument":
+			data, err := c.stream(e, root, maxOfficePartSize)
+			if err != nil {
+				return findings, err
+			}
+			if wordContainsDDE(data) {
+				findings = append(findings, Finding{
+					Type:        FindingDDE,
+					Location:    e.Name,
+					Description: "document contains DDE field",
+				})
+			}
+		}
+	}
+	return findings, nil
+}
+
+// biffMacroSheetName walks BIFF records of workbook stream and
+// returns the name of the first macro sheet, or empty string if there are no such sheets
+func biffMacroSheetName(data []byte) string {
+	for off := 0; off+4 <= len(data); {
+		recordType := binary.LittleEndian.Uint16(data[off:])
+		length := int(binary.LittleEndian.Uint16(data[off+2:]))
+		body := data[off+4 : min(off+4+length, len(data))]
+		off += 4 + length
+
+		// BoundSheet8 record: position (4 bytes), visibility (1 byte), sheet type (1 byte), name
+		if recordType != biffBoundSheet || len(body) < 8 || body[5] != biffMacroSheet {
+			continue
+		}
+		nameLen := int(body[6])
+		name := body[8:]
+		if body[7]&0x1 != 0 {
+			// name is stored in UTF-16, keep only low bytes which is enough for reporting
+			var ascii []byte
+			for i := 0; i+1 < len(name) && len(ascii) < nameLen; i += 2 {
+				ascii = append(ascii, name[i])
+			}
+			return string(ascii)
+		}
+		return string(name[:min(nameLen, len(name))])
+	}
+	return ""
+}
+
+// wordContainsDDE checks Word document text for DDE field codes both in 8-bit and UTF-16 encodings
+func wordContainsDDE(data []byte) bool {
+	lower := bytes.ToLower(data)
+	for _, token := range [][]byte{[]byte("ddeauto"), []byte(" dde ")} {
+		if bytes.Contains(lower, token) || bytes.Contains(lower, utf16LE(token)) {
+			return true
+		}
+	}
+	return false
+}
+
+// utf16LE encodes ASCII token in UTF-16LE
+func utf16LE(token []byte) []byte {
+	encoded := make([]byte, 0, len(token)*2)
+	for _, b := range token {
+		encoded = append(encoded, b, 0)
+	}
+	return encoded
+}
+
+// lowerReader converts ASCII letters of the underlying reader to lower case
+type lowerReader struct {
+	r io.Reader
+}
+
+func (l *lowerReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	for i := 0; i < n; i++ {
+		if 'A' <= p[i] && p[i] <= 'Z' {
+			p[i] += 'a' - 'A'
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,142 @@
+package inspect_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/netcracker/qubership-av-scan-service/pkg/inspect"
+	"github.com/netcracker/qubership-av-scan-service/pkg/testutils"
+)
+
+func TestDetectMacros(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  []byte
+		expected []inspect.FindingType
+	}{
+		{
+			name: "plain docx",
+			content: zipFile(map[string]string{
+				"[Content_Types].xml": "<Types/>",
+				"word/document.xml":   "<w:document><w:t>Hello</w:t></w:document>",
+			}),
+		},
+		{
+			name: "docm with vba project",
+			content: zipFile(map[string]string{
+				"word/document.xml":   "<w:document/>",
+				"word/vbaProject.bin": "vba",
+			}),
+			expected: []inspect.FindingType{inspect.FindingVBAProject},
+		},
+		{
+			name: "xlsm with macro sheet",
+			content: zipFile(map[string]string{
+				"xl/workbook.xml":           "<workbook/>",
+				"xl/macrosheets/sheet1.xml": "<xm:macrosheet/>",
+			}),
+			expected: []inspect.FindingType{inspect.FindingXLMMacro},
+		},
+		{
+			name: "docx with dde field",
+			content: zipFile(map[string]string{
+				"word/document.xml": `<w:document><w:fldSimple w:instr=" DDEAUTO c:\\windows\\system32\\cmd.exe "/></w:document>`,
+			}),
+			expected: []inspect.FindingType{inspect.FindingDDE},
+		},
+		{
+			name: "legacy document with vba project",
+			content: testutils.CompoundFile(map[string][]byte{
+				"WordDocument":   []byte("text"),
+				"Macros/VBA/dir": []byte("dir"),
+				"Macros/PROJECT": []byte("project"),
+			}),
+			expected: []inspect.FindingType{inspect.FindingVBAProject},
+		},
+		{
+			name: "legacy document with dde field",
+			content: testutils.CompoundFile(map[string][]byte{
+				"WordDocument": []byte("\x13 DDEAUTO c:\\windows\\system32\\cmd.exe \x14\x15"),
+			}),
+			expected: []inspect.FindingType{inspect.FindingDDE},
+		},
+		{
+			name:     "legacy workbook with macro sheet",
+			content:  testutils.CompoundFile(map[string][]byte{"Workbook": biffWorkbook("Macro1", 0x01)}),
+			expected: []inspect.FindingType{inspect.FindingXLMMacro},
+		},
+		{
+			name:    "legacy workbook with worksheet",
+			content: testutils.CompoundFile(map[string][]byte{"Workbook": biffWorkbook("Sheet1", 0x00)}),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			findings, err := inspect.DetectMacros(bytes.NewReader(test.content), int64(len(test.content)))
+			if err != nil {
+				t.Fatalf("expected no error, but got: %s", err)
+			}
+			if len(findings) != len(test.expected) {
+				t.Fatalf("expected findings %v, but got: %+v", test.expected, findings)
+			}
+			for i, f := range findings {
+				if f.Type != test.expected[i] {
+					t.Fatalf("expected finding '%s', but got: '%s'", test.expected[i], f.Type)
+				}
+			}
+		})
+	}
+}
+
+func TestDetectMacrosInvalidSectorShift(t *testing.T) {
+	for name, offset := range map[string]int{"sector shift": 0x1E, "mini sector shift": 0x20} {
+		t.Run(name, func(t *testing.T) {
+			content := testutils.CompoundFile(map[string][]byte{"WordDocument": []byte("text")})
+			binary.LittleEndian.PutUint16(content[offset:], 63)
+			if _, err := inspect.DetectMacros(bytes.NewReader(content), int64(len(content))); err == nil {
+				t.Fatalf("expected error for invalid %s", name)
+			}
+		})
+	}
+}
+
+func zipFile(files map[string]string) []byte {
+	buffer := &bytes.Buffer{}
+	zw := zip.NewWriter(buffer)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			panic(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			panic(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		panic(err)
+	}
+	return buffer.Bytes()
+}
+
+// biffWorkbook builds workbook stream with a single BoundSheet8 record,
+// stream is padded to be stored out of mini stream
+func biffWorkbook(sheet string, sheetType byte) []byte {
+	record := func(recordType uint16, body []byte) []byte {
+		header := make([]byte, 4)
+		binary.LittleEndian.PutUint16(header, recordType)
+		binary.LittleEndian.PutUint16(header[2:], uint16(len(body)))
+		return append(header, body...)
+	}
+
+	boundSheet := []byte{0, 0, 0, 0, 0, sheetType, byte(len(sheet)), 0}
+	boundSheet = append(boundSheet, sheet...)
+	data := record(0x0809, make([]byte, 16))
+	data = append(data, record(0x0085, boundSheet)...)
+	for len(data) < 4096 {
+		data = append(data, record(0x00FC, make([]byte, 1000))...)
+	}
+	return data
+}
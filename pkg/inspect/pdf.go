@@ -0,0 +1,408 @@
+package inspect
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	// FindingPDFJavaScript means that PDF document contains JavaScript
+	FindingPDFJavaScript FindingType = "pdf_javascript"
+	// FindingPDFLaunch means that PDF document contains launch actions
+	FindingPDFLaunch FindingType = "pdf_launch"
+	// FindingPDFOpenAction means that PDF document performs an action when it is opened
+	FindingPDFOpenAction FindingType = "pdf_open_action"
+	// FindingPDFEmbeddedFile means that PDF document contains embedded files
+	FindingPDFEmbeddedFile FindingType = "pdf_embedded_file"
+	// FindingPDFEmbeddedExecutable means that PDF document contains or refers to executable files
+	FindingPDFEmbeddedExecutable FindingType = "pdf_embedded_executable"
+)
+
+const (
+	// maxPDFStreamSize restricts size of PDF streams which are decoded during inspection
+	maxPDFStreamSize = 16 << 20
+	// maxPDFStringSize restricts size of PDF strings kept during inspection
+	maxPDFStringSize = 4 << 10
+	// pdfHeaderOffset is the max offset of %PDF- header, readers accept some garbage before it
+	pdfHeaderOffset = 1024
+)
+
+// pdfActiveNames maps PDF names to findings they indicate
+var pdfActiveNames = map[string]struct {
+	findingType FindingType
+	description string
+}{
+	"JS":            {FindingPDFJavaScript, "document contains JavaScript"},
+	"JavaScript":    {FindingPDFJavaScript, "document contains JavaScript"},
+	"Launch":        {FindingPDFLaunch, "document contains launch action"},
+	"OpenAction":    {FindingPDFOpenAction, "document performs action when opened"},
+	"EmbeddedFile":  {FindingPDFEmbeddedFile, "document contains embedded file"},
+	"EmbeddedFiles": {FindingPDFEmbeddedFile, "document contains embedded files"},
+}
+
+// executableExtensions are extensions of files which could be executed by operating system
+var executableExtensions = []string{
+	".exe", ".dll", ".scr", ".com", ".cpl", ".msi", ".bat", ".cmd", ".ps1", ".vbs", ".vbe",
+	".js", ".jse", ".wsf", ".wsh", ".hta", ".jar", ".lnk", ".sh", ".app", ".elf",
+}
+
+// executableTypes are MIME types of executable files
+var executableTypes = []string{
+	"application/vnd.microsoft.portable-executable",
+	"application/x-elf",
+	"application/x-mach-binary",
+	"application/java-vm",
+	"application/java-archive",
+	"text/x-shellscript",
+}
+
+// DetectPDFActiveContent inspects PDF document structure in streaming manner and reports
+// JavaScript, launch and open actions, embedded files and embedded or referenced executables.
+// Names are decoded before matching, so obfuscation with #xx escapes is handled.
+// Flate-compressed object streams are inspected too. For non-PDF content no findings are returned.
+func DetectPDFActiveContent(r io.ReaderAt, size int64) ([]Finding, error) {
+	header := make([]byte, min(size, pdfHeaderOffset))
+	if _, err := r.ReadAt(header, 0); err != nil && err != io.EOF {
+		return nil, err
+	}
+	if !bytes.Contains(header, pdfSignature) {
+		return nil, nil
+	}
+
+	p := &pdfInspector{seen: map[FindingType]bool{}}
+	err := p.lex(bufio.NewReader(io.NewSectionReader(r, 0, size)), 0)
+	return p.findings, err
+}
+
+// pdfInspector is a tokenizer of PDF syntax which tracks objects and names relevant for inspection
+type pdfInspector struct {
+	findings []Finding
+	seen     map[FindingType]bool
+	// numbers contains the last two numeric tokens used to get current object number
+	numbers []string
+	// object is a location of the current object, e.g. "object 12 0"
+	object string
+	// names contains names of the current object used before stream keyword
+	names map[string]bool
+	// lastName is the last name token, used to find file names in file specifications
+	lastName string
+}
+
+func (p *pdfInspector) report(findingType FindingType, description string) {
+	if p.seen[findingType] {
+		return
+	}
+	p.seen[findingType] = true
+	p.findings = append(p.findings, Finding{Type: findingType, Location: p.object, Description: description})
+}
+
+func (p *pdfInspector) lex(br *bufio.Reader, depth int) error {
+	p.names = map[string]bool{}
+	for {
+		b, err := br.ReadByte()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case b == '%':
+			if _, err := readUntil(br, func(c byte) bool { return c == '\r' || c == '\n' }, 0); err != nil {
+				return err
+			}
+		case b == '/':
+			name, err := readUntil(br, func(c byte) bool { return !isPDFNameChar(c) }, maxPDFStringSize)
+			if err != nil {
+				return err
+			}
+			p.name(decodePDFName(name))
+		case b == '(':
+			s, err := readPDFLiteral(br)
+			if err != nil {
+				return err
+			}
+			p.str(s)
+		case b == '<':
+			next, err := br.Peek(1)
+			if err == nil && next[0] == '<' {
+				br.ReadByte()
+				continue
+			}
+			s, err := readUntil(br, func(c byte) bool { return c == '>' }, maxPDFStringSize)
+			if err != nil {
+				return err
+			}
+			p.str(decodePDFHex(s))
+		case !isPDFNameChar(b):
+			continue
+		default:
+			rest, err := readUntil(br, func(c byte) bool { return !isPDFNameChar(c) }, maxPDFStringSize)
+			if err != nil {
+				return err
+			}
+			if err := p.keyword(string(append([]byte{b}, rest...)), br, depth); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (p *pdfInspector) name(name string) {
+	p.names[name] = true
+	p.lastName = name
+	if active, ok := pdfActiveNames[name]; ok {
+		p.report(active.findingType, active.description)
+	}
+}
+
+func (p *pdfInspector) str(s []byte) {
+	if p.lastName != "F" && p.lastName != "UF" {
+		return
+	}
+	p.lastName = ""
+	filename := decodePDFText(s)
+	if slices.Contains(executableExtensions, strings.ToLower(path.Ext(filename))) {
+		p.report(FindingPDFEmbeddedExecutable, fmt.Sprintf("document refers to executable file %q", filename))
+	}
+}
+
+func (p *pdfInspector) keyword(keyword string, br *bufio.Reader, depth int) error {
+	if _, err := strconv.Atoi(keyword); err == nil {
+		p.numbers = append(p.numbers[max(len(p.numbers)-1, 0):], keyword)
+		return nil
+	}
+
+	switch keyword {
+	case "obj":
+		if len(p.numbers) == 2 {
+			p.object = fmt.Sprintf("object %s %s", p.numbers[0], p.numbers[1])
+		}
+		p.names = map[string]bool{}
+	case "endobj":
+		p.names = map[string]bool{}
+	case "stream":
+		if err := p.stream(br, depth); err != nil {
+			return err
+		}
+		p.names = map[string]bool{}
+	}
+	p.numbers = p.numbers[:0]
+	return nil
+}
+
+// stream reads stream data up to endstream keyword, decoding it if it is flate-compressed.
+// Embedded files are checked for executable content, object streams are inspected recursively.
+func (p *pdfInspector) stream(br *bufio.Reader, depth int) error {
+	endstream := []byte("endstream")
+	var data []byte
+	truncated := false
+	for {
+		b, err := br.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		data = append(data, b)
+		if bytes.HasSuffix(data, endstream) {
+			data = data[:len(data)-len(endstream)]
+			break
+		}
+		if len(data) > maxPDFStreamSize {
+			// keep only the tail to find the end of stream
+			data = append(data[:0], data[len(data)-len(endstream):]...)
+			truncated = true
+		}
+	}
+	if truncated {
+		return nil
+	}
+
+	data = bytes.TrimLeft(data, "\r\n")
+	if p.names["FlateDecode"] || p.names["Fl"] {
+		decoded, err := io.ReadAll(io.LimitReader(zlibReader(data), maxPDFStreamSize))
+		if err != nil && len(decoded) == 0 {
+			return nil
+		}
+		data = decoded
+	}
+
+	names := p.names
+	if names["EmbeddedFile"] {
+		fileType, err := DetectFileType(bytes.NewReader(data), int64(len(data)))
+		if err == nil && slices.Contains(executableTypes, fileType.MIME) {
+			p.report(FindingPDFEmbeddedExecutable, fmt.Sprintf("document contains embedded executable of type %s", fileType.MIME))
+		}
+	}
+	if names["ObjStm"] && depth == 0 {
+		object := p.object
+		err := p.lex(bufio.NewReader(bytes.NewReader(data)), depth+1)
+		p.object = object
+		return err
+	}
+	return nil
+}
+
+// zlibReader returns reader decompressing zlib data, or empty reader if data is not zlib-compressed
+func zlibReader(data []byte) io.Reader {
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return bytes.NewReader(nil)
+	}
+	return zr
+}
+
+// readUntil reads bytes until stop returns true for the next byte, which is not consumed.
+// Only up to limit bytes are returned, the rest is skipped; if limit is 0, nothing is returned.
+func readUntil(br *bufio.Reader, stop func(byte) bool, limit int) ([]byte, error) {
+	var res []byte
+	for {
+		next, err := br.Peek(1)
+		if err == io.EOF {
+			return res, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if stop(next[0]) {
+			return res, nil
+		}
+		br.ReadByte()
+		if len(res) < limit {
+			res = append(res, next[0])
+		}
+	}
+}
+
+// readPDFLiteral reads literal string after opening parenthesis, handling nested parentheses,
+// escapes, octal character codes and line continuations
+func readPDFLiteral(br *bufio.Reader) ([]byte, error) {
+	var res []byte
+	depth := 1
+	for {
+		b, err := br.ReadByte()
+		if err == io.EOF {
+			return res, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch b {
+		case '\\':
+			if b, err = br.ReadByte(); err != nil {
+				return res, nil
+			}
+			switch b {
+			case 'n':
+				b = '\n'
+			case 'r':
+				b = '\r'
+			case 't':
+				b = '\t'
+			case 'b':
+				b = '\b'
+			case 'f':
+				b = '\f'
+			case '\r':
+				// line continuation, \r\n is a single end-of-line marker
+				if next, err := br.Peek(1); err == nil && next[0] == '\n' {
+					br.ReadByte()
+				}
+				continue
+			case '\n':
+				continue
+			case '0', '1', '2', '3', '4', '5', '6', '7':
+				b = readPDFOctal(br, b)
+			}
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return res, nil
+			}
+		}
+		if len(res) < maxPDFStringSize {
+			res = append(res, b)
+		}
+	}
+}
+
+// readPDFOctal decodes octal character code of up to three digits, first digit is already read.
+// High-order overflow is ignored as required by the specification.
+func readPDFOctal(br *bufio.Reader, first byte) byte {
+	code := first - '0'
+	for range 2 {
+		next, err := br.Peek(1)
+		if err != nil || next[0] < '0' || next[0] > '7' {
+			break
+		}
+		br.ReadByte()
+		code = code<<3 | (next[0] - '0')
+	}
+	return code
+}
+
+// decodePDFName decodes #xx escapes in PDF name
+func decodePDFName(name []byte) string {
+	if !bytes.ContainsRune(name, '#') {
+		return string(name)
+	}
+	var res []byte
+	for i := 0; i < len(name); i++ {
+		if name[i] == '#' && i+2 < len(name) {
+			if v, err := strconv.ParseUint(string(name[i+1:i+3]), 16, 8); err == nil {
+				res = append(res, byte(v))
+				i += 2
+				continue
+			}
+		}
+		res = append(res, name[i])
+	}
+	return string(res)
+}
+
+// decodePDFHex decodes content of PDF hexadecimal string
+func decodePDFHex(s []byte) []byte {
+	var digits []byte
+	for _, c := range s {
+		if ('0' <= c && c <= '9') || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F') {
+			digits = append(digits, c)
+		}
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	res := make([]byte, len(digits)/2)
+	for i := range res {
+		v, _ := strconv.ParseUint(string(digits[i*2:i*2+2]), 16, 8)
+		res[i] = byte(v)
+	}
+	return res
+}
+
+// decodePDFText decodes PDF text string, which is either UTF-16BE with BOM or single-byte encoded
+func decodePDFText(s []byte) string {
+	if !bytes.HasPrefix(s, []byte{0xFE, 0xFF}) {
+		return string(s)
+	}
+	var res []byte
+	for i := 2; i+1 < len(s); i += 2 {
+		if s[i] == 0 {
+			res = append(res, s[i+1])
+		} else {
+			res = append(res, '?')
+		}
+	}
+	return string(res)
+}
@@ -0,0 +1,93 @@
+package inspect_test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"testing"
+
+	"github.com/netcracker/qubership-av-scan-service/pkg/inspect"
+)
+
+func TestDetectPDFActiveContent(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected []inspect.FindingType
+	}{
+		{
+			name:    "plain pdf",
+			content: "%PDF-1.7\n1 0 obj\n<< /Type /Catalog /Pages 2 0 R >>\nendobj\ntrailer\n<< /Root 1 0 R >>\n%%EOF",
+		},
+		{
+			name:     "javascript open action",
+			content:  "%PDF-1.7\n1 0 obj\n<< /Type /Catalog /OpenAction << /S /JavaScript /JS (app.alert\\(1\\)) >> >>\nendobj\n%%EOF",
+			expected: []inspect.FindingType{inspect.FindingPDFOpenAction, inspect.FindingPDFJavaScript},
+		},
+		{
+			name:     "obfuscated javascript name",
+			content:  "%PDF-1.7\n1 0 obj\n<< /S /J#61vaScript /J#53 (x) >>\nendobj\n%%EOF",
+			expected: []inspect.FindingType{inspect.FindingPDFJavaScript},
+		},
+		{
+			name:     "launch action with executable",
+			content:  "%PDF-1.7\n1 0 obj\n<< /S /Launch /Win << /F (cmd.exe) >> >>\nendobj\n%%EOF",
+			expected: []inspect.FindingType{inspect.FindingPDFLaunch, inspect.FindingPDFEmbeddedExecutable},
+		},
+		{
+			name:     "launch action with octal escaped executable",
+			content:  "%PDF-1.7\n1 0 obj\n<< /S /Launch /Win << /F (evil\\056\\145xe) >> >>\nendobj\n%%EOF",
+			expected: []inspect.FindingType{inspect.FindingPDFLaunch, inspect.FindingPDFEmbeddedExecutable},
+		},
+		{
+			name:     "launch action with line continuation in executable",
+			content:  "%PDF-1.7\n1 0 obj\n<< /S /Launch /Win << /F (evil.\\\r\nex\\\ne) >> >>\nendobj\n%%EOF",
+			expected: []inspect.FindingType{inspect.FindingPDFLaunch, inspect.FindingPDFEmbeddedExecutable},
+		},
+		{
+			name: "embedded executable",
+			content: "%PDF-1.7\n1 0 obj\n<< /Type /EmbeddedFile /Filter /FlateDecode >>\nstream\n" +
+				deflate("MZ\x90\x00\x03\x00\x00\x00") + "\nendstream\nendobj\n%%EOF",
+			expected: []inspect.FindingType{inspect.FindingPDFEmbeddedFile, inspect.FindingPDFEmbeddedExecutable},
+		},
+		{
+			name: "javascript in object stream",
+			content: "%PDF-1.7\n5 0 obj\n<< /Type /ObjStm /N 1 /First 4 /Filter /FlateDecode >>\nstream\n" +
+				deflate("1 0 << /S /JavaScript /JS (x) >>") + "\nendstream\nendobj\n%%EOF",
+			expected: []inspect.FindingType{inspect.FindingPDFJavaScript},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			content := []byte(test.content)
+			findings, err := inspect.DetectPDFActiveContent(bytes.NewReader(content), int64(len(content)))
+			if err != nil {
+				t.Fatalf("expected no error, but got: %s", err)
+			}
+			if len(findings) != len(test.expected) {
+				t.Fatalf("expected findings %v, but got: %+v", test.expected, findings)
+			}
+			for i, f := range findings {
+				if f.Type != test.expected[i] {
+					t.Fatalf("expected finding '%s', but got: '%s'", test.expected[i], f.Type)
+				}
+				if f.Location == "" {
+					t.Fatalf("expected finding location to be set")
+				}
+			}
+		})
+	}
+}
+
+func deflate(content string) string {
+	buffer := &bytes.Buffer{}
+	zw := zlib.NewWriter(buffer)
+	if _, err := fmt.Fprint(zw, content); err != nil {
+		panic(err)
+	}
+	if err := zw.Close(); err != nil {
+		panic(err)
+	}
+	return buffer.String()
+}
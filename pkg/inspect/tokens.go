@@ -0,0 +1,72 @@
+package inspect
+
+import (
+	"bytes"
+	"io"
+)
+
+// scanChunkSize is the size of chunks in which content is searched for tokens
+const scanChunkSize = 64 << 10
+
+// tokenFunc is called for each found token with the byte following it (0 at the end of content).
+// If it returns false, scanning stops.
+type tokenFunc func(token []byte, next byte) bool
+
+// scanTokens searches content of r for given tokens in streaming manner,
+// keeping in memory only a single chunk of content
+func scanTokens(r io.Reader, tokens [][]byte, fn tokenFunc) error {
+	maxLen := 0
+	for _, t := range tokens {
+		maxLen = max(maxLen, len(t))
+	}
+
+	buf := make([]byte, 0, scanChunkSize+maxLen+1)
+	chunk := make([]byte, scanChunkSize)
+	for {
+		n, err := io.ReadFull(r, chunk)
+		buf = append(buf, chunk[:n]...)
+		eof := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !eof {
+			return err
+		}
+
+		// tokens which end in the last maxLen bytes are handled with the next chunk
+		// so that the following byte is always known
+		limit := len(buf) - maxLen
+		if eof {
+			limit = len(buf)
+		}
+		for _, t := range tokens {
+			for from := 0; from < limit; {
+				i := bytes.Index(buf[from:], t)
+				if i < 0 || from+i >= limit {
+					break
+				}
+				end := from + i + len(t)
+				var next byte
+				if end < len(buf) {
+					next = buf[end]
+				}
+				if !fn(t, next) {
+					return nil
+				}
+				from = end
+			}
+		}
+
+		if eof {
+			return nil
+		}
+		buf = append(buf[:0], buf[max(limit, 0):]...)
+	}
+}
+
+// isPDFNameChar returns true if byte may be a part of PDF name object,
+// i.e. it is neither whitespace nor delimiter
+func isPDFNameChar(b byte) bool {
+	switch b {
+	case 0, '\t', '\n', '\f', '\r', ' ', '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return false
+	}
+	return true
+}
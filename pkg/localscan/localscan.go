@@ -0,0 +1,212 @@
+package localscan
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime/debug"
+	"sync"
+
+	"github.com/netcracker/qubership-av-scan-service/pkg/handlers"
+	"github.com/netcracker/qubership-av-scan-service/pkg/log"
+	"github.com/netcracker/qubership-av-scan-service/pkg/report"
+)
+
+// DefaultConcurrency is the number of files scanned in parallel if no explicit concurrency is configured,
+// it is below default MaxThreads of clamd, so clamd is able to serve other clients
+const DefaultConcurrency = 4
+
+// Filter selects files by glob patterns of path.Match syntax.
+// Pattern matches the file if it matches either base name or path relative to the walked root, e.g. "*.jar"
+// or "vendor/*". Directories matching exclude patterns are skipped together with their content.
+type Filter struct {
+	// Include selects files to scan, all files are selected if empty
+	Include []string
+	// Exclude skips files and directories, it takes precedence over Include
+	Exclude []string
+}
+
+// Options configures Scanner
+type Options struct {
+	// Filter selects files to scan
+	Filter Filter
+	// Concurrency is the number of files scanned in parallel
+	Concurrency int
+	// Deep enables expansion of archives
+	Deep bool
+}
+
+// Scanner scans local files and directories with clamd, verifying each file the same way as uploaded files
+type Scanner struct {
+	scanner *handlers.ScanHandler
+	opts    Options
+}
+
+func New(scanner *handlers.ScanHandler, opts Options) *Scanner {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = DefaultConcurrency
+	}
+	return &Scanner{scanner: scanner, opts: opts}
+}
+
+// Scan scans files of given paths, directories are walked recursively.
+// Results are returned in walk order, files which could not be read or scanned are reported with error.
+// Error is returned only if scanning is interrupted by cancelled context.
+func (s *Scanner) Scan(ctx context.Context, paths []string) ([]*report.ScanResult, error) {
+	var results []*report.ScanResult
+	pending := make(chan *report.ScanResult)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < s.opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for result := range pending {
+				s.scanFile(ctx, result)
+			}
+		}()
+	}
+
+	err := Walk(ctx, paths, s.opts.Filter, func(path string, err error) {
+		result := &report.ScanResult{Path: path}
+		results = append(results, result)
+		if err != nil {
+			result.Error = err.Error()
+			return
+		}
+		pending <- result
+	})
+	close(pending)
+	wg.Wait()
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// scanFile sets status or error of the result, panic of the scanner is reported as error of the file
+// instead of crashing the whole scan
+func (s *Scanner) scanFile(ctx context.Context, result *report.ScanResult) {
+	defer func() {
+		if p := recover(); p != nil {
+			log.FromContext(ctx).Error("file scan panicked", "path", result.Path, "stacktrace", debug.Stack())
+			result.Status = nil
+			result.Error = fmt.Sprintf("unexpected error: %v", p)
+		}
+	}()
+
+	f, err := os.Open(result.Path)
+	if err != nil {
+		result.Error = err.Error()
+		return
+	}
+	defer f.Close()
+
+	result.Status, err = s.scanner.ScanFile(ctx, filepath.Base(result.Path), "", f, s.opts.Deep)
+	if err != nil {
+		result.Error = err.Error()
+	}
+}
+
+// Walk calls fn for each regular file of given paths selected by filter, directories are walked recursively.
+// Files given explicitly are not filtered. Symbolic links given explicitly are followed,
+// while links found inside walked directories are not.
+// Errors of accessing files are passed to fn, error is returned only if walking is interrupted by cancelled context.
+func Walk(ctx context.Context, paths []string, filter Filter, fn func(path string, err error)) error {
+	for _, root := range paths {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		info, err := os.Stat(root)
+		if err != nil {
+			fn(root, err)
+			continue
+		}
+		if !info.IsDir() {
+			if info.Mode().IsRegular() {
+				fn(root, nil)
+			} else {
+				fn(root, fmt.Errorf("%s is not a regular file", root))
+			}
+			continue
+		}
+		if err := walkDir(ctx, root, filter, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkDir walks directory root, which may be a symbolic link. Its content is reported by paths under root.
+func walkDir(ctx context.Context, root string, filter Filter, fn func(path string, err error)) error {
+	dir, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		fn(root, err)
+		return nil
+	}
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		rel, relErr := filepath.Rel(dir, path)
+		if relErr != nil {
+			fn(path, relErr)
+			return nil
+		}
+		path = filepath.Join(root, rel)
+		if err != nil {
+			fn(path, err)
+			return nil
+		}
+		if rel == "." {
+			return nil
+		}
+
+		if filter.excluded(rel) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type().IsRegular() && filter.included(rel) {
+			fn(path, nil)
+		}
+		return nil
+	})
+}
+
+// Validate returns an error if any pattern is malformed, since malformed pattern silently matches nothing
+func (f Filter) Validate() error {
+	for _, patterns := range [][]string{f.Include, f.Exclude} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("malformed pattern %q: %w", pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (f Filter) included(rel string) bool {
+	return len(f.Include) == 0 || matchAny(f.Include, rel)
+}
+
+func (f Filter) excluded(rel string) bool {
+	return matchAny(f.Exclude, rel)
+}
+
+// matchAny returns true if any pattern matches base name or relative path
+func matchAny(patterns []string, rel string) bool {
+	rel = filepath.ToSlash(rel)
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, path.Base(rel)); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, rel); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,190 @@
+package localscan_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+
+	"github.com/netcracker/qubership-av-scan-service/pkg/handlers"
+	"github.com/netcracker/qubership-av-scan-service/pkg/localscan"
+	"github.com/netcracker/qubership-av-scan-service/pkg/report"
+	"github.com/netcracker/qubership-av-scan-service/pkg/testutils"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// writeTree creates files with given content under a temporary directory and returns its path
+func writeTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatalf("failed to create directory: %s", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("failed to write file: %s", err)
+		}
+	}
+	return root
+}
+
+func relPaths(t *testing.T, root string, results []*report.ScanResult) []string {
+	t.Helper()
+	var paths []string
+	for _, result := range results {
+		rel, err := filepath.Rel(root, result.Path)
+		if err != nil {
+			t.Fatalf("unexpected result path %s", result.Path)
+		}
+		paths = append(paths, filepath.ToSlash(rel))
+	}
+	return paths
+}
+
+func TestScan(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"a.txt":         "clean content",
+		"lib/virus.com": testutils.EICARTest,
+		"lib/b.txt":     "clean content",
+	})
+	scanner := handlers.NewScanHandler(testutils.NewClamdMock(), prometheus.NewRegistry(), handlers.DefaultScanOptions())
+
+	results, err := localscan.New(scanner, localscan.Options{Concurrency: 2}).Scan(context.Background(), []string{root})
+	if err != nil {
+		t.Fatalf("failed to scan: %s", err)
+	}
+	// results are in walk order regardless of concurrency
+	if paths := relPaths(t, root, results); len(paths) != 3 || paths[0] != "a.txt" || paths[1] != "lib/b.txt" || paths[2] != "lib/virus.com" {
+		t.Fatalf("unexpected scanned files: %v", paths)
+	}
+	if summary := report.Summarize(results); summary.Clean != 2 || summary.Detected != 1 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+	if !results[2].Status.Infected || results[2].Status.Filename != "virus.com" {
+		t.Fatalf("expected infected status, but got %+v", results[2].Status)
+	}
+}
+
+func TestScanUnavailableClamd(t *testing.T) {
+	root := writeTree(t, map[string]string{"a.txt": "clean content"})
+	scanner := handlers.NewScanHandler(testutils.NewClamdMock().WithUnhealthy("connection refused"), prometheus.NewRegistry(), handlers.DefaultScanOptions())
+
+	results, err := localscan.New(scanner, localscan.Options{}).Scan(context.Background(), []string{root, filepath.Join(root, "missing")})
+	if err != nil {
+		t.Fatalf("failed to scan: %s", err)
+	}
+	if summary := report.Summarize(results); summary.Errors != 2 {
+		t.Fatalf("expected both files to fail, but got %+v", summary)
+	}
+}
+
+func TestScanPanic(t *testing.T) {
+	root := writeTree(t, map[string]string{"a.txt": "clean content", "b.txt": "clean content"})
+	scanner := handlers.NewScanHandler(testutils.NewClamdMock().WithPanic("scanner bug"), prometheus.NewRegistry(), handlers.DefaultScanOptions())
+
+	results, err := localscan.New(scanner, localscan.Options{}).Scan(context.Background(), []string{root})
+	if err != nil {
+		t.Fatalf("failed to scan: %s", err)
+	}
+	if summary := report.Summarize(results); summary.Errors != 2 {
+		t.Fatalf("expected both files to fail, but got %+v", summary)
+	}
+}
+
+func TestWalkSymlinkRoots(t *testing.T) {
+	root := writeTree(t, map[string]string{"dir/a.txt": "", "b.txt": ""})
+	links := t.TempDir()
+	dirLink, fileLink, brokenLink := filepath.Join(links, "dir"), filepath.Join(links, "b.txt"), filepath.Join(links, "broken")
+	for link, target := range map[string]string{
+		dirLink:    filepath.Join(root, "dir"),
+		fileLink:   filepath.Join(root, "b.txt"),
+		brokenLink: filepath.Join(root, "missing"),
+	} {
+		if err := os.Symlink(target, link); err != nil {
+			t.Skipf("symlinks are not supported: %s", err)
+		}
+	}
+
+	var paths, failed []string
+	err := localscan.Walk(context.Background(), []string{dirLink, fileLink, brokenLink}, localscan.Filter{}, func(path string, err error) {
+		if err != nil {
+			failed = append(failed, path)
+			return
+		}
+		paths = append(paths, path)
+	})
+	if err != nil {
+		t.Fatalf("failed to walk: %s", err)
+	}
+	// files of linked directory are reported under the link
+	if len(paths) != 2 || paths[0] != filepath.Join(dirLink, "a.txt") || paths[1] != fileLink {
+		t.Fatalf("unexpected walked files: %v", paths)
+	}
+	if len(failed) != 1 || failed[0] != brokenLink {
+		t.Fatalf("expected broken link to be reported, but got: %v", failed)
+	}
+}
+
+func TestWalkFilter(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"app.jar":              "",
+		"README.md":            "",
+		"lib/dep.jar":          "",
+		"lib/test/fixture.jar": "",
+		"node_modules/x.jar":   "",
+	})
+	filter := localscan.Filter{Include: []string{"*.jar"}, Exclude: []string{"node_modules", "lib/test"}}
+
+	var paths []string
+	err := localscan.Walk(context.Background(), []string{root, filepath.Join(root, "README.md")}, filter, func(path string, err error) {
+		if err != nil {
+			t.Fatalf("failed to walk %s: %s", path, err)
+		}
+		rel, _ := filepath.Rel(root, path)
+		paths = append(paths, filepath.ToSlash(rel))
+	})
+	if err != nil {
+		t.Fatalf("failed to walk: %s", err)
+	}
+	// explicitly given file is not filtered
+	if len(paths) != 3 || paths[0] != "app.jar" || paths[1] != "lib/dep.jar" || paths[2] != "README.md" {
+		t.Fatalf("unexpected walked files: %v", paths)
+	}
+}
+
+func TestFilterValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		filter    localscan.Filter
+		expectErr bool
+	}{
+		{name: "empty", filter: localscan.Filter{}},
+		{name: "valid patterns", filter: localscan.Filter{Include: []string{"*.jar", "lib/[a-z]*"}, Exclude: []string{".git"}}},
+		{name: "malformed include", filter: localscan.Filter{Include: []string{"*.jar", "[.git"}}, expectErr: true},
+		{name: "malformed exclude", filter: localscan.Filter{Exclude: []string{"lib\\"}}, expectErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.filter.Validate()
+			if test.expectErr && !errors.Is(err, path.ErrBadPattern) {
+				t.Fatalf("expected bad pattern error, but got: %v", err)
+			}
+			if !test.expectErr && err != nil {
+				t.Fatalf("expected no error, but got: %s", err)
+			}
+		})
+	}
+}
+
+func TestWalkCancelled(t *testing.T) {
+	root := writeTree(t, map[string]string{"a.txt": ""})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := localscan.Walk(ctx, []string{root}, localscan.Filter{}, func(string, error) {}); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, but got: %v", err)
+	}
+}
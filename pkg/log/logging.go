@@ -12,13 +12,25 @@ type logKey struct{}
 // With sets given logger in the given request context,
 // so that it could be retrieved in future using From (and only this way).
 func With(r *http.Request, logger *slog.Logger) *http.Request {
-	return r.WithContext(context.WithValue(r.Context(), logKey{}, logger))
+	return r.WithContext(WithContext(r.Context(), logger))
+}
+
+// WithContext sets given logger in the given context,
+// so that it could be retrieved in future using FromContext.
+func WithContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, logKey{}, logger)
 }
 
 // From retrieves saved logger from given request context.
 // If logger is not found, a default logger is returned.
 func From(r *http.Request) *slog.Logger {
-	v := r.Context().Value(logKey{})
+	return FromContext(r.Context())
+}
+
+// FromContext retrieves logger saved by With or WithContext from given context.
+// If logger is not found, a default logger is returned.
+func FromContext(ctx context.Context) *slog.Logger {
+	v := ctx.Value(logKey{})
 	if logger, ok := v.(*slog.Logger); ok {
 		return logger
 	}
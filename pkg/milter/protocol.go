@@ -0,0 +1,110 @@
+package milter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Commands sent by MTA, see libmilter/mfdef.h of Sendmail
+const (
+	cmdAbort   = 'A'
+	cmdBody    = 'B'
+	cmdConnect = 'C'
+	cmdMacro   = 'D'
+	cmdEOB     = 'E'
+	cmdHelo    = 'H'
+	cmdQuitNC  = 'K'
+	cmdHeader  = 'L'
+	cmdMail    = 'M'
+	cmdEOH     = 'N'
+	cmdOptNeg  = 'O'
+	cmdQuit    = 'Q'
+	cmdRcpt    = 'R'
+	cmdData    = 'T'
+	cmdUnknown = 'U'
+)
+
+// Responses sent to MTA
+const (
+	respAccept     = 'a'
+	respContinue   = 'c'
+	respOptNeg     = 'O'
+	respQuarantine = 'q'
+	respReplyCode  = 'y'
+	respTempFail   = 't'
+)
+
+// Actions which milter may perform at the end of message, negotiated with MTA
+const (
+	actionQuarantine uint32 = 0x20
+)
+
+// Protocol flags telling MTA which steps should not be sent to milter, negotiated with MTA
+const (
+	protoNoConnect uint32 = 0x01
+	protoNoHelo    uint32 = 0x02
+	protoNoMail    uint32 = 0x04
+	protoNoRcpt    uint32 = 0x08
+	protoNoUnknown uint32 = 0x100
+	protoNoData    uint32 = 0x200
+)
+
+// protocolVersion is the newest milter protocol version supported
+const protocolVersion = 6
+
+// minProtocolVersion is the oldest milter protocol version supported
+const minProtocolVersion = 2
+
+// maxPacketSize restricts size of a single packet, MTAs send body in chunks of up to 64KB
+const maxPacketSize = 1 << 20
+
+// packet is a single milter protocol message
+type packet struct {
+	cmd  byte
+	data []byte
+}
+
+// readPacket reads packet prefixed with its length in network byte order
+func readPacket(r io.Reader) (*packet, error) {
+	var size uint32
+	if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+		return nil, err
+	}
+	if size == 0 || size > maxPacketSize {
+		return nil, fmt.Errorf("invalid packet size %d", size)
+	}
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	return &packet{cmd: buf[0], data: buf[1:]}, nil
+}
+
+// writePacket writes packet prefixed with its length
+func writePacket(w io.Writer, cmd byte, data []byte) error {
+	buf := make([]byte, 5, 5+len(data))
+	binary.BigEndian.PutUint32(buf, uint32(len(data)+1))
+	buf[4] = cmd
+	_, err := w.Write(append(buf, data...))
+	return err
+}
+
+// cstrings splits data into NUL-terminated strings
+func cstrings(data []byte) []string {
+	data = bytes.TrimSuffix(data, []byte{0})
+	if len(data) == 0 {
+		return nil
+	}
+	var res []string
+	for _, s := range bytes.Split(data, []byte{0}) {
+		res = append(res, string(s))
+	}
+	return res
+}
+
+// cstring returns string terminated with NUL
+func cstring(s string) []byte {
+	return append([]byte(s), 0)
+}
@@ -0,0 +1,464 @@
+package milter
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/netcracker/qubership-av-scan-service/pkg/clamav"
+	apierrors "github.com/netcracker/qubership-av-scan-service/pkg/errors"
+	"github.com/netcracker/qubership-av-scan-service/pkg/handlers"
+	"github.com/netcracker/qubership-av-scan-service/pkg/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/xid"
+)
+
+// DefaultReply is the SMTP reply rejecting infected messages
+const DefaultReply = "550 5.7.1 Message rejected, virus found"
+
+// idleTimeout closes connections of MTA which do not send commands
+const idleTimeout = 5 * time.Minute
+
+// messageFilename is the name of scanned message in logs
+const messageFilename = "message.eml"
+
+// messageContentType makes scanner scan message as a whole and each of its parts
+const messageContentType = "message/rfc822"
+
+// ErrServerClosed is returned by Serve after Close
+var ErrServerClosed = errors.New("milter: server closed")
+
+// errAborted stops scanning of message aborted by MTA
+var errAborted = errors.New("message aborted")
+
+// Action is what is done with infected message
+type Action string
+
+const (
+	// ActionReject rejects infected message with configured SMTP reply
+	ActionReject Action = "reject"
+	// ActionQuarantine accepts infected message into quarantine of MTA, e.g. hold queue of Postfix
+	ActionQuarantine Action = "quarantine"
+	// ActionAccept accepts infected message, virus is only logged and counted
+	ActionAccept Action = "accept"
+)
+
+// ParseAction parses action name
+func ParseAction(s string) (Action, error) {
+	switch a := Action(s); a {
+	case ActionReject, ActionQuarantine, ActionAccept:
+		return a, nil
+	}
+	return "", fmt.Errorf("unknown milter action %q, must be reject, quarantine or accept", s)
+}
+
+// Options configures handling of infected messages
+type Options struct {
+	// Action is what is done with infected message
+	Action Action
+	// Reply is the SMTP reply rejecting infected message, e.g. "550 5.7.1 Message rejected".
+	// Virus name is appended to the reply text.
+	Reply string
+	// Deep enables expansion of archives attached to messages
+	Deep bool
+}
+
+// DefaultOptions returns options rejecting infected messages with DefaultReply
+func DefaultOptions() Options {
+	return Options{Action: ActionReject, Reply: DefaultReply}
+}
+
+// Server is a milter which scans messages received by MTA, e.g. Postfix or Sendmail.
+// Message is scanned as a whole and each of its body parts and attachments separately.
+// Clean messages are accepted, infected ones are handled according to Action.
+// Messages which can't be scanned because of clamd failure are temporarily rejected.
+type Server struct {
+	scanner  *handlers.ScanHandler
+	opts     Options
+	logger   *slog.Logger
+	messages *prometheus.CounterVec
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// NewServer returns milter Server which scans messages with given clamd. Metrics are registered in the registry,
+// so they could be exposed together with HTTP API metrics.
+func NewServer(
+	clamd clamav.Clamd,
+	registry *prometheus.Registry,
+	logger *slog.Logger,
+	scanOpts handlers.ScanOptions,
+	opts Options,
+) (*Server, error) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if _, err := ParseAction(string(opts.Action)); err != nil {
+		return nil, err
+	}
+	if err := checkReply(opts.Reply); err != nil {
+		return nil, err
+	}
+	return &Server{
+		scanner: handlers.NewScanHandler(clamd, registry, scanOpts),
+		opts:    opts,
+		logger:  logger,
+		messages: promauto.With(registry).NewCounterVec(
+			prometheus.CounterOpts{Name: "milter_messages_total"},
+			[]string{"action"},
+		),
+		conns: map[net.Conn]struct{}{},
+	}, nil
+}
+
+// checkReply verifies that reply starts with 4xx or 5xx SMTP code
+func checkReply(reply string) error {
+	code, _, _ := strings.Cut(reply, " ")
+	if len(code) != 3 || (code[0] != '4' && code[0] != '5') ||
+		code[1] < '0' || code[1] > '9' || code[2] < '0' || code[2] > '9' {
+		return fmt.Errorf("invalid milter reply %q, must start with 4xx or 5xx SMTP code", reply)
+	}
+	if strings.ContainsAny(reply, "\r\n") {
+		return fmt.Errorf("invalid milter reply %q, must be a single line", reply)
+	}
+	return nil
+}
+
+// ListenAndServe listens on given address and serves MTA connections until Close is called.
+// Address is either TCP address, e.g. 0.0.0.0:7357, or path of unix socket prefixed with "unix:".
+func (s *Server) ListenAndServe(addr string) error {
+	network := "tcp"
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		network, addr = "unix", path
+		// socket left after unclean shutdown would prevent listening
+		if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+			os.Remove(path)
+		}
+	}
+	l, err := net.Listen(network, addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts connections on the listener until Close is called
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		l.Close()
+		return ErrServerClosed
+	}
+	s.listener = l
+	s.mu.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			return err
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return ErrServerClosed
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+		go s.serveConn(conn)
+	}
+}
+
+// Close stops accepting connections, closes active connections and waits for their handlers to finish
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+// serveConn serves commands of a single MTA connection, which may carry several messages
+func (s *Server) serveConn(conn net.Conn) {
+	sess := &session{srv: s, w: conn, macros: map[string]string{}}
+	defer func() {
+		sess.abort()
+		conn.Close()
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		s.wg.Done()
+	}()
+
+	br := bufio.NewReader(conn)
+	for {
+		conn.SetReadDeadline(time.Now().Add(idleTimeout))
+		p, err := readPacket(br)
+		if err != nil {
+			if err != io.EOF && !errors.Is(err, net.ErrClosed) {
+				s.logger.Error("failed to read milter command", "error", err, "remote", conn.RemoteAddr())
+			}
+			return
+		}
+		quit, err := sess.handle(p)
+		if err != nil {
+			s.logger.Error("failed to handle milter command", "command", string(p.cmd), "error", err)
+			return
+		}
+		if quit {
+			return
+		}
+	}
+}
+
+// session is a state of MTA connection
+type session struct {
+	srv *Server
+	w   io.Writer
+	// actions are negotiated actions milter may perform
+	actions uint32
+	macros  map[string]string
+	msg     *message
+}
+
+// message is a message being received, its content is streamed to the scanner
+type message struct {
+	w          *io.PipeWriter
+	result     chan scanResult
+	logger     *slog.Logger
+	headerDone bool
+}
+
+type scanResult struct {
+	status *handlers.ScanStatus
+	err    error
+}
+
+// handle handles a single command and returns true if connection must be closed
+func (s *session) handle(p *packet) (bool, error) {
+	switch p.cmd {
+	case cmdOptNeg:
+		return false, s.negotiate(p.data)
+	case cmdMacro:
+		s.macro(p.data)
+		return false, nil
+	case cmdMail:
+		// new message starts, state of previous one is dropped
+		s.abort()
+	case cmdHeader:
+		fields := cstrings(p.data)
+		if len(fields) != 2 {
+			return false, fmt.Errorf("malformed header")
+		}
+		s.write(fields[0] + ": " + fields[1] + "\r\n")
+	case cmdEOH:
+		s.endHeader()
+	case cmdBody:
+		s.endHeader()
+		s.write(string(p.data))
+	case cmdEOB:
+		s.endHeader()
+		s.write(string(p.data))
+		return false, s.endBody()
+	case cmdAbort:
+		s.abort()
+		return false, nil
+	case cmdQuit:
+		return true, nil
+	case cmdQuitNC:
+		// connection is reused for a new SMTP session
+		s.abort()
+		s.macros = map[string]string{}
+		return false, nil
+	case cmdConnect, cmdHelo, cmdRcpt, cmdData, cmdUnknown:
+	default:
+		return false, fmt.Errorf("unknown command")
+	}
+	return false, writePacket(s.w, respContinue, nil)
+}
+
+// negotiate agrees protocol version, actions and steps of the session with MTA
+func (s *session) negotiate(data []byte) error {
+	if len(data) < 12 {
+		return fmt.Errorf("malformed option negotiation")
+	}
+	version := binary.BigEndian.Uint32(data)
+	if version < minProtocolVersion {
+		return fmt.Errorf("unsupported protocol version %d", version)
+	}
+	version = min(version, protocolVersion)
+	offeredActions := binary.BigEndian.Uint32(data[4:])
+	offeredProtocol := binary.BigEndian.Uint32(data[8:])
+
+	if s.srv.opts.Action == ActionQuarantine {
+		s.actions = offeredActions & actionQuarantine
+	}
+	// only headers and body are needed to scan the message
+	protocol := offeredProtocol & (protoNoConnect | protoNoHelo | protoNoMail | protoNoRcpt | protoNoUnknown | protoNoData)
+
+	resp := make([]byte, 12)
+	binary.BigEndian.PutUint32(resp, version)
+	binary.BigEndian.PutUint32(resp[4:], s.actions)
+	binary.BigEndian.PutUint32(resp[8:], protocol)
+	return writePacket(s.w, respOptNeg, resp)
+}
+
+// macro saves macros sent by MTA, e.g. queue id, which are used in logs
+func (s *session) macro(data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	fields := cstrings(data[1:])
+	for i := 0; i+1 < len(fields); i += 2 {
+		s.macros[strings.Trim(fields[i], "{}")] = fields[i+1]
+	}
+}
+
+// write streams content of the message to the scanner, starting the scanning on first write
+func (s *session) write(content string) {
+	if s.msg == nil {
+		s.start()
+	}
+	// error means that scanning has already failed, it is reported at the end of message
+	io.WriteString(s.msg.w, content)
+}
+
+// endHeader writes empty line separating message header from body
+func (s *session) endHeader() {
+	if s.msg == nil || !s.msg.headerDone {
+		s.write("\r\n")
+		s.msg.headerDone = true
+	}
+}
+
+// start starts scanning of a new message, which content is written to the pipe
+func (s *session) start() {
+	r, w := io.Pipe()
+	msg := &message{
+		w:      w,
+		result: make(chan scanResult, 1),
+		logger: s.srv.logger.With("reqId", xid.New()),
+	}
+	ctx := log.WithContext(context.Background(), msg.logger)
+	go func() {
+		// panic must not stop the whole service, the message is temporarily rejected instead
+		defer func() {
+			if p := recover(); p != nil {
+				msg.logger.Error("message scan panicked", "stacktrace", debug.Stack())
+				r.CloseWithError(io.ErrClosedPipe)
+				msg.result <- scanResult{err: apierrors.UnexpectedError(fmt.Errorf("%v", p))}
+			}
+		}()
+		status, err := s.srv.scanner.ScanFile(ctx, messageFilename, messageContentType, r, s.srv.opts.Deep)
+		// unblock writes if scanner stopped reading
+		r.CloseWithError(io.ErrClosedPipe)
+		msg.result <- scanResult{status: status, err: err}
+	}()
+	s.msg = msg
+	msg.logger.Debug("message received")
+}
+
+// abort stops scanning of the current message if any
+func (s *session) abort() {
+	if s.msg == nil {
+		return
+	}
+	s.msg.w.CloseWithError(errAborted)
+	<-s.msg.result
+	s.msg = nil
+}
+
+// endBody waits for scan result of the message and replies with final decision
+func (s *session) endBody() error {
+	msg := s.msg
+	s.msg = nil
+	msg.w.Close()
+	result := <-msg.result
+	logger := msg.logger.With("queueId", s.macros["i"])
+
+	if result.err != nil {
+		apiErr, ok := result.err.(*apierrors.APIError)
+		if !ok || apiErr.Status < 400 || apiErr.Status >= 500 {
+			logger.Error("failed to scan message", "error", result.err)
+			s.srv.messages.WithLabelValues("tempfail").Inc()
+			return writePacket(s.w, respTempFail, nil)
+		}
+		// message which can't be scanned safely, e.g. archive bomb, is blocked
+		return s.block(logger, apiErr.Reason)
+	}
+
+	status := result.status
+	if status.Verdict == handlers.VerdictClean {
+		logger.Info("message is clean")
+		s.srv.messages.WithLabelValues(string(ActionAccept)).Inc()
+		return writePacket(s.w, respAccept, nil)
+	}
+	reason := status.Virus
+	if reason == "" {
+		reason = status.Reason
+	}
+	if reason == "" {
+		reason = string(status.Verdict)
+	}
+	return s.block(logger, reason)
+}
+
+// block handles message which is not clean according to configured action
+func (s *session) block(logger *slog.Logger, reason string) error {
+	reason = strings.Map(func(r rune) rune {
+		if r < ' ' {
+			return ' '
+		}
+		return r
+	}, reason)
+
+	action := s.srv.opts.Action
+	if action == ActionQuarantine && s.actions&actionQuarantine == 0 {
+		logger.Warn("quarantine is not allowed by MTA, message is rejected")
+		action = ActionReject
+	}
+	logger.Warn("message blocked", "reason", reason, "action", action)
+	s.srv.messages.WithLabelValues(string(action)).Inc()
+
+	switch action {
+	case ActionQuarantine:
+		if err := writePacket(s.w, respQuarantine, cstring(reason)); err != nil {
+			return err
+		}
+		return writePacket(s.w, respAccept, nil)
+	case ActionAccept:
+		return writePacket(s.w, respAccept, nil)
+	}
+	return writePacket(s.w, respReplyCode, cstring(s.srv.opts.Reply+": "+reason))
+}
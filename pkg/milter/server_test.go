@@ -0,0 +1,234 @@
+package milter_test
+
+import (
+	"encoding/binary"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/netcracker/qubership-av-scan-service/pkg/handlers"
+	"github.com/netcracker/qubership-av-scan-service/pkg/milter"
+	"github.com/netcracker/qubership-av-scan-service/pkg/testutils"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const cleanBody = "Hello,\r\nplease find the report attached.\r\n"
+
+// client is a minimal MTA side of milter protocol
+type client struct {
+	t    *testing.T
+	conn net.Conn
+}
+
+func startServer(t *testing.T, clamd *testutils.ClamdMock, opts milter.Options) *client {
+	t.Helper()
+	srv, err := milter.NewServer(clamd, prometheus.NewRegistry(), slog.Default(), handlers.DefaultScanOptions(), opts)
+	if err != nil {
+		t.Fatalf("failed to create server: %s", err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err)
+	}
+	done := make(chan error)
+	go func() {
+		done <- srv.Serve(l)
+	}()
+	t.Cleanup(func() {
+		srv.Close()
+		if err := <-done; err != milter.ErrServerClosed {
+			t.Errorf("expected server to be closed, but got: %v", err)
+		}
+	})
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to connect: %s", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &client{t: t, conn: conn}
+}
+
+func (c *client) send(cmd byte, data ...string) {
+	c.t.Helper()
+	payload := []byte{cmd}
+	for _, d := range data {
+		payload = append(payload, d...)
+	}
+	size := make([]byte, 4)
+	binary.BigEndian.PutUint32(size, uint32(len(payload)))
+	if _, err := c.conn.Write(append(size, payload...)); err != nil {
+		c.t.Fatalf("failed to send command: %s", err)
+	}
+}
+
+func (c *client) recv() (byte, string) {
+	c.t.Helper()
+	size := make([]byte, 4)
+	if _, err := io.ReadFull(c.conn, size); err != nil {
+		c.t.Fatalf("failed to read response: %s", err)
+	}
+	payload := make([]byte, binary.BigEndian.Uint32(size))
+	if _, err := io.ReadFull(c.conn, payload); err != nil {
+		c.t.Fatalf("failed to read response: %s", err)
+	}
+	return payload[0], string(payload[1:])
+}
+
+func (c *client) expect(cmd byte) string {
+	c.t.Helper()
+	got, data := c.recv()
+	if got != cmd {
+		c.t.Fatalf("expected %q response, but got %q %q", cmd, got, data)
+	}
+	return data
+}
+
+// negotiate offers quarantine action and all protocol steps, returns negotiated actions and protocol
+func (c *client) negotiate() (uint32, uint32) {
+	c.t.Helper()
+	data := make([]byte, 12)
+	binary.BigEndian.PutUint32(data, 6)
+	binary.BigEndian.PutUint32(data[4:], 0x3f)
+	binary.BigEndian.PutUint32(data[8:], 0x1fffff)
+	c.send('O', string(data))
+	resp := c.expect('O')
+	return binary.BigEndian.Uint32([]byte(resp[4:])), binary.BigEndian.Uint32([]byte(resp[8:]))
+}
+
+// message sends message headers and body in chunks, and returns final response
+func (c *client) message(contentType string, body ...string) (byte, string) {
+	c.t.Helper()
+	c.send('D', "M", "{mail_addr}\x00sender@example.com\x00")
+	c.send('M', "<sender@example.com>\x00")
+	c.expect('c')
+	c.send('R', "<rcpt@example.com>\x00")
+	c.expect('c')
+	c.send('D', "L", "i\x004ABCDEF\x00")
+	c.send('L', "From\x00sender@example.com\x00")
+	c.expect('c')
+	c.send('L', "Subject\x00Report\x00")
+	c.expect('c')
+	c.send('L', "Content-Type\x00"+contentType+"\x00")
+	c.expect('c')
+	c.send('N')
+	c.expect('c')
+	for _, chunk := range body {
+		c.send('B', chunk)
+		c.expect('c')
+	}
+	c.send('E')
+	return c.recv()
+}
+
+func TestNegotiate(t *testing.T) {
+	c := startServer(t, testutils.NewClamdMock(), milter.Options{Action: milter.ActionQuarantine, Reply: milter.DefaultReply})
+	actions, protocol := c.negotiate()
+	if actions != 0x20 {
+		t.Fatalf("expected only quarantine action to be requested, but got %#x", actions)
+	}
+	// connect, helo, mail, rcpt, unknown and data steps are not needed
+	if protocol != 0x30f {
+		t.Fatalf("unexpected negotiated protocol %#x", protocol)
+	}
+}
+
+func TestCleanMessage(t *testing.T) {
+	c := startServer(t, testutils.NewClamdMock(), milter.DefaultOptions())
+	c.negotiate()
+
+	// connection is reused for several messages
+	for i := 0; i < 2; i++ {
+		if resp, data := c.message("text/plain", cleanBody[:10], cleanBody[10:]); resp != 'a' {
+			t.Fatalf("expected message to be accepted, but got %q %q", resp, data)
+		}
+	}
+}
+
+func TestInfectedMessage(t *testing.T) {
+	eicar := testutils.EICARTest
+	multipartType := "multipart/mixed; boundary=b"
+	multipart := "--b\r\nContent-Type: text/plain\r\n\r\n" + cleanBody +
+		"--b\r\nContent-Type: application/octet-stream\r\nContent-Disposition: attachment; filename=report.com\r\n\r\n" +
+		eicar + "\r\n--b--\r\n"
+
+	t.Run("reject", func(t *testing.T) {
+		c := startServer(t, testutils.NewClamdMock(), milter.Options{Action: milter.ActionReject, Reply: "554 5.7.1 Infected"})
+		c.negotiate()
+		resp, data := c.message(multipartType, multipart[:50], multipart[50:])
+		if resp != 'y' || !strings.HasPrefix(data, "554 5.7.1 Infected: ") || !strings.Contains(data, eicar) {
+			t.Fatalf("expected message to be rejected with reply, but got %q %q", resp, data)
+		}
+	})
+
+	t.Run("quarantine", func(t *testing.T) {
+		c := startServer(t, testutils.NewClamdMock(), milter.Options{Action: milter.ActionQuarantine, Reply: milter.DefaultReply})
+		c.negotiate()
+		if resp, data := c.message(multipartType, multipart); resp != 'q' || !strings.Contains(data, eicar) {
+			t.Fatalf("expected message to be quarantined, but got %q %q", resp, data)
+		}
+		c.expect('a')
+	})
+
+	t.Run("accept", func(t *testing.T) {
+		c := startServer(t, testutils.NewClamdMock(), milter.Options{Action: milter.ActionAccept, Reply: milter.DefaultReply})
+		c.negotiate()
+		if resp, data := c.message(multipartType, multipart); resp != 'a' {
+			t.Fatalf("expected message to be accepted, but got %q %q", resp, data)
+		}
+	})
+}
+
+func TestAbortedMessage(t *testing.T) {
+	c := startServer(t, testutils.NewClamdMock(), milter.DefaultOptions())
+	c.negotiate()
+
+	c.send('L', "Subject\x00Aborted\x00")
+	c.expect('c')
+	c.send('N')
+	c.expect('c')
+	c.send('B', testutils.EICARTest)
+	c.expect('c')
+	c.send('A')
+
+	// aborted content does not affect the next message
+	if resp, data := c.message("text/plain", cleanBody); resp != 'a' {
+		t.Fatalf("expected message to be accepted, but got %q %q", resp, data)
+	}
+}
+
+func TestUnavailableClamd(t *testing.T) {
+	c := startServer(t, testutils.NewClamdMock().WithUnhealthy("connection refused"), milter.DefaultOptions())
+	c.negotiate()
+	if resp, data := c.message("text/plain", cleanBody); resp != 't' {
+		t.Fatalf("expected message to be temporarily rejected, but got %q %q", resp, data)
+	}
+}
+
+func TestScanPanic(t *testing.T) {
+	c := startServer(t, testutils.NewClamdMock().WithPanic("scanner bug"), milter.DefaultOptions())
+	c.negotiate()
+	if resp, data := c.message("text/plain", cleanBody); resp != 't' {
+		t.Fatalf("expected message to be temporarily rejected, but got %q %q", resp, data)
+	}
+	// connection is still served
+	if resp, data := c.message("text/plain", cleanBody); resp != 't' {
+		t.Fatalf("expected message to be temporarily rejected, but got %q %q", resp, data)
+	}
+}
+
+func TestInvalidOptions(t *testing.T) {
+	clamd := testutils.NewClamdMock()
+	for _, opts := range []milter.Options{
+		{Action: "drop", Reply: milter.DefaultReply},
+		{Action: milter.ActionReject, Reply: "250 OK"},
+		{Action: milter.ActionReject, Reply: "55x 5.7.1 Infected"},
+		{Action: milter.ActionReject, Reply: "550 5.7.1 Infected\r\n250 OK"},
+	} {
+		if _, err := milter.NewServer(clamd, prometheus.NewRegistry(), slog.Default(), handlers.DefaultScanOptions(), opts); err == nil {
+			t.Fatalf("expected options %+v to be rejected", opts)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package report
+
+import (
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+
+	"github.com/netcracker/qubership-av-scan-service/pkg/handlers"
+)
+
+// Format renders scan results in particular format
+type Format struct {
+	// Name selects the format in command line tools
+	Name string
+	// MediaType is the Content-Type of rendered results
+	MediaType string
+	// Write renders results to w
+	Write func(w io.Writer, results []*ScanResult) error
+}
+
+// Supported formats of scan results
+var (
+	Text  = Format{Name: "text", MediaType: "text/plain", Write: WriteText}
+	JSON  = Format{Name: "json", MediaType: "application/json", Write: WriteJSON}
+	SARIF = Format{Name: "sarif", MediaType: "application/sarif+json", Write: WriteSARIF}
+	JUnit = Format{Name: "junit", MediaType: "application/junit+xml", Write: WriteJUnit}
+)
+
+// Formats lists all supported formats
+var Formats = []Format{Text, JSON, SARIF, JUnit}
+
+// FormatByName returns format with given name
+func FormatByName(name string) (Format, bool) {
+	for _, f := range Formats {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return Format{}, false
+}
+
+// NegotiateFormat returns CI report format requested by Accept header, i.e. SARIF or JUnit XML.
+// Media range with the highest q-value wins, ranges with equal q-values are preferred in order.
+// JSON is the default response, so it is also selected by wildcards, false is returned if JSON
+// or no report format is requested.
+func NegotiateFormat(accept string) (Format, bool) {
+	var best Format
+	bestQ := 0.0
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q <= bestQ {
+			continue
+		}
+
+		switch mediaType {
+		case JSON.MediaType, "application/*", "*/*":
+			best, bestQ = JSON, q
+		case SARIF.MediaType:
+			best, bestQ = SARIF, q
+		case JUnit.MediaType:
+			best, bestQ = JUnit, q
+		}
+	}
+	if best.Name == "" || best.Name == JSON.Name {
+		return Format{}, false
+	}
+	return best, true
+}
+
+// FromStatuses converts scan statuses returned by scan API to results, file names are used as paths
+func FromStatuses(statuses []*handlers.ScanStatus) []*ScanResult {
+	results := make([]*ScanResult, 0, len(statuses))
+	for _, status := range statuses {
+		results = append(results, &ScanResult{Path: status.Filename, Status: status})
+	}
+	return results
+}
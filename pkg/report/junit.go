@@ -0,0 +1,93 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitProblem `xml:"failure,omitempty"`
+	Error     *junitProblem `xml:"error,omitempty"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes results as JUnit XML with a test case per file. Detected files are failed test cases
+// with signature name or verdict as failure type, and failure text lists all detections with SHA-256 hashes.
+// Files which could not be scanned are test cases with error.
+func WriteJUnit(w io.Writer, results []*ScanResult) error {
+	summary := Summarize(results)
+	suite := junitTestSuite{
+		Name:      toolName,
+		Tests:     summary.Files,
+		Failures:  summary.Detected,
+		Errors:    summary.Errors,
+		TestCases: make([]junitTestCase, 0, len(results)),
+	}
+	for _, result := range results {
+		testCase := junitTestCase{Name: result.Path, ClassName: toolName}
+		switch {
+		case result.Status == nil:
+			testCase.Error = &junitProblem{Message: result.Error, Type: "error"}
+		case result.Detected():
+			found := detections(result.Status)
+			lines := make([]string, 0, len(found))
+			for _, status := range found {
+				line := detectionMessage(result, status)
+				if status.SHA256 != "" {
+					line += fmt.Sprintf(" (sha256: %s)", status.SHA256)
+				}
+				lines = append(lines, line)
+			}
+			testCase.Failure = &junitProblem{
+				Message: detectionMessage(result, found[0]),
+				Type:    ruleID(found[0]),
+				Text:    strings.Join(lines, "\n"),
+			}
+		}
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	err := encoder.Encode(junitTestSuites{
+		Name:     toolName,
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Errors:   suite.Errors,
+		Suites:   []junitTestSuite{suite},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,135 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/netcracker/qubership-av-scan-service/pkg/handlers"
+)
+
+// ScanResult is a result of scanning a single local file by command line tools
+type ScanResult struct {
+	// Path is the path of the scanned file
+	Path string `json:"path"`
+	// Status is the scan status of the file, not set if scanning failed
+	Status *handlers.ScanStatus `json:"status,omitempty"`
+	// Error describes why the file could not be scanned
+	Error string `json:"error,omitempty"`
+}
+
+// Detected returns true if the file was scanned and its verdict is not clean
+func (r *ScanResult) Detected() bool {
+	return r.Status != nil && r.Status.Verdict != handlers.VerdictClean
+}
+
+// Summary contains number of scanned files by outcome
+type Summary struct {
+	// Files is the total number of files
+	Files int `json:"files"`
+	// Clean is the number of files with clean verdict
+	Clean int `json:"clean"`
+	// Detected is the number of infected or otherwise blocked files
+	Detected int `json:"detected"`
+	// Errors is the number of files which could not be scanned
+	Errors int `json:"errors"`
+}
+
+// Summarize counts results by outcome
+func Summarize(results []*ScanResult) Summary {
+	summary := Summary{Files: len(results)}
+	for _, result := range results {
+		switch {
+		case result.Status == nil:
+			summary.Errors++
+		case result.Detected():
+			summary.Detected++
+		default:
+			summary.Clean++
+		}
+	}
+	return summary
+}
+
+// Report is written by JSON formatter
+type Report struct {
+	Results []*ScanResult `json:"results"`
+	Summary Summary       `json:"summary"`
+}
+
+// WriteJSON writes results with their summary as indented JSON
+func WriteJSON(w io.Writer, results []*ScanResult) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(Report{Results: results, Summary: Summarize(results)})
+}
+
+// WriteText writes a line per file followed by summary, in the manner of clamscan
+func WriteText(w io.Writer, results []*ScanResult) error {
+	for _, result := range results {
+		if _, err := fmt.Fprintf(w, "%s: %s\n", result.Path, describe(result)); err != nil {
+			return err
+		}
+	}
+	summary := Summarize(results)
+	_, err := fmt.Fprintf(w,
+		"\n----------- SCAN SUMMARY -----------\nScanned files: %d\nClean files: %d\nDetected files: %d\nErrors: %d\n",
+		summary.Files, summary.Clean, summary.Detected, summary.Errors,
+	)
+	return err
+}
+
+// describe returns short description of the result outcome
+func describe(result *ScanResult) string {
+	status := result.Status
+	switch {
+	case status == nil:
+		return "ERROR " + result.Error
+	case status.Infected:
+		return fmt.Sprintf("%s FOUND", status.Virus)
+	case status.Verdict == handlers.VerdictClean:
+		return "OK"
+	case status.Reason != "":
+		return fmt.Sprintf("%s (%s)", status.Verdict, status.Reason)
+	}
+	return string(status.Verdict)
+}
+
+// detections returns statuses of the file and its archive members which caused not clean verdict.
+// Archive is reported itself only if none of its members is detected.
+func detections(status *handlers.ScanStatus) []*handlers.ScanStatus {
+	if status.Verdict == handlers.VerdictClean {
+		return nil
+	}
+	var found []*handlers.ScanStatus
+	for _, member := range status.Members {
+		found = append(found, detections(member)...)
+	}
+	if len(found) == 0 {
+		found = append(found, status)
+	}
+	return found
+}
+
+// ruleID identifies kind of detection by virus signature or verdict
+func ruleID(status *handlers.ScanStatus) string {
+	if status.Infected && status.Virus != "" {
+		return status.Virus
+	}
+	return string(status.Verdict)
+}
+
+// detectionMessage describes detection in the file or its archive member
+func detectionMessage(result *ScanResult, status *handlers.ScanStatus) string {
+	location := result.Path
+	if status != result.Status {
+		location = fmt.Sprintf("%s (member %s)", result.Path, status.Filename)
+	}
+	switch {
+	case status.Infected:
+		return fmt.Sprintf("%s found in %s", status.Virus, location)
+	case status.Reason != "":
+		return fmt.Sprintf("%s is %s: %s", location, status.Verdict, status.Reason)
+	}
+	return fmt.Sprintf("%s is %s", location, status.Verdict)
+}
@@ -0,0 +1,207 @@
+package report_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/netcracker/qubership-av-scan-service/pkg/handlers"
+	"github.com/netcracker/qubership-av-scan-service/pkg/report"
+)
+
+func testResults() []*report.ScanResult {
+	return []*report.ScanResult{
+		{Path: "dist/app.tar", Status: &handlers.ScanStatus{Filename: "app.tar", Verdict: handlers.VerdictClean}},
+		{Path: "dist/virus.com", Status: &handlers.ScanStatus{
+			Filename: "virus.com",
+			Infected: true,
+			Virus:    "Eicar-Signature",
+			Verdict:  handlers.VerdictInfected,
+		}},
+		{Path: "dist/app.elf", Status: &handlers.ScanStatus{
+			Filename: "app.elf",
+			Verdict:  handlers.VerdictPolicyBlocked,
+			Reason:   "application/x-elf type is denied",
+		}},
+		{Path: "dist/locked.bin", Error: "permission denied"},
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	summary := report.Summarize(testResults())
+	if summary != (report.Summary{Files: 4, Clean: 1, Detected: 2, Errors: 1}) {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	out := &bytes.Buffer{}
+	if err := report.WriteText(out, testResults()); err != nil {
+		t.Fatalf("failed to write report: %s", err)
+	}
+	for _, line := range []string{
+		"dist/app.tar: OK\n",
+		"dist/virus.com: Eicar-Signature FOUND\n",
+		"dist/app.elf: policy_blocked (application/x-elf type is denied)\n",
+		"dist/locked.bin: ERROR permission denied\n",
+		"Detected files: 2\n",
+	} {
+		if !strings.Contains(out.String(), line) {
+			t.Fatalf("expected report to contain %q, but got:\n%s", line, out)
+		}
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	out := &bytes.Buffer{}
+	if err := report.WriteJSON(out, testResults()); err != nil {
+		t.Fatalf("failed to write report: %s", err)
+	}
+	var parsed report.Report
+	if err := json.Unmarshal(out.Bytes(), &parsed); err != nil {
+		t.Fatalf("failed to parse report: %s", err)
+	}
+	if len(parsed.Results) != 4 || parsed.Results[1].Status.Virus != "Eicar-Signature" || parsed.Summary.Errors != 1 {
+		t.Fatalf("unexpected report: %+v", parsed)
+	}
+}
+
+func TestWriteSARIF(t *testing.T) {
+	results := testResults()
+	results[1].Status.SHA256 = "275a021bbfb6489e54d471899f7db9d1663fc695ec2fe2a2c4538aabf651fd0f"
+	results = append(results, &report.ScanResult{Path: "dist/bundle.zip", Status: &handlers.ScanStatus{
+		Filename: "bundle.zip",
+		Infected: true,
+		Virus:    "Eicar-Signature",
+		Verdict:  handlers.VerdictInfected,
+		Members: []*handlers.ScanStatus{
+			{Filename: "readme.txt", Verdict: handlers.VerdictClean},
+			{Filename: "bin/virus.com", Infected: true, Virus: "Eicar-Signature", Verdict: handlers.VerdictInfected, SHA256: "abc"},
+		},
+	}})
+
+	out := &bytes.Buffer{}
+	if err := report.WriteSARIF(out, results); err != nil {
+		t.Fatalf("failed to write report: %s", err)
+	}
+	var log struct {
+		Version string `json:"version"`
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Rules []struct {
+						ID string `json:"id"`
+					} `json:"rules"`
+				} `json:"driver"`
+			} `json:"tool"`
+			Invocations []struct {
+				ExecutionSuccessful bool `json:"executionSuccessful"`
+			} `json:"invocations"`
+			Artifacts []struct {
+				Hashes map[string]string `json:"hashes"`
+			} `json:"artifacts"`
+			Results []struct {
+				RuleID    string `json:"ruleId"`
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct {
+							URI string `json:"uri"`
+						} `json:"artifactLocation"`
+					} `json:"physicalLocation"`
+					LogicalLocations []struct {
+						FullyQualifiedName string `json:"fullyQualifiedName"`
+					} `json:"logicalLocations"`
+				} `json:"locations"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &log); err != nil {
+		t.Fatalf("failed to parse report: %s", err)
+	}
+
+	run := log.Runs[0]
+	if log.Version != "2.1.0" || run.Invocations[0].ExecutionSuccessful {
+		t.Fatalf("unexpected version %s or successful execution with errors", log.Version)
+	}
+	// the same signature in several files is a single rule
+	if len(run.Tool.Driver.Rules) != 2 || run.Tool.Driver.Rules[0].ID != "Eicar-Signature" || run.Tool.Driver.Rules[1].ID != "policy_blocked" {
+		t.Fatalf("unexpected rules: %+v", run.Tool.Driver.Rules)
+	}
+	if len(run.Artifacts) != 3 || run.Artifacts[0].Hashes["sha-256"] != results[1].Status.SHA256 {
+		t.Fatalf("unexpected artifacts: %+v", run.Artifacts)
+	}
+	if len(run.Results) != 3 {
+		t.Fatalf("expected 3 results, but got %+v", run.Results)
+	}
+	member := run.Results[2].Locations[0]
+	if member.PhysicalLocation.ArtifactLocation.URI != "dist/bundle.zip" || member.LogicalLocations[0].FullyQualifiedName != "bin/virus.com" {
+		t.Fatalf("unexpected location of detection in archive member: %+v", member)
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	results := testResults()
+	results[1].Status.SHA256 = "275a021bbfb6489e54d471899f7db9d1663fc695ec2fe2a2c4538aabf651fd0f"
+
+	out := &bytes.Buffer{}
+	if err := report.WriteJUnit(out, results); err != nil {
+		t.Fatalf("failed to write report: %s", err)
+	}
+	var suites struct {
+		Tests    int `xml:"tests,attr"`
+		Failures int `xml:"failures,attr"`
+		Errors   int `xml:"errors,attr"`
+		Suite    struct {
+			TestCases []struct {
+				Name    string `xml:"name,attr"`
+				Failure *struct {
+					Type string `xml:"type,attr"`
+					Text string `xml:",chardata"`
+				} `xml:"failure"`
+				Error *struct {
+					Message string `xml:"message,attr"`
+				} `xml:"error"`
+			} `xml:"testcase"`
+		} `xml:"testsuite"`
+	}
+	if err := xml.Unmarshal(out.Bytes(), &suites); err != nil {
+		t.Fatalf("failed to parse report: %s", err)
+	}
+	if suites.Tests != 4 || suites.Failures != 2 || suites.Errors != 1 {
+		t.Fatalf("unexpected counts: %+v", suites)
+	}
+	cases := suites.Suite.TestCases
+	if cases[0].Failure != nil || cases[0].Error != nil {
+		t.Fatalf("expected clean file to pass, but got %+v", cases[0])
+	}
+	if cases[1].Failure == nil || cases[1].Failure.Type != "Eicar-Signature" || !strings.Contains(cases[1].Failure.Text, results[1].Status.SHA256) {
+		t.Fatalf("expected failure with signature and hash, but got %+v", cases[1].Failure)
+	}
+	if cases[3].Error == nil || cases[3].Error.Message != "permission denied" {
+		t.Fatalf("expected error, but got %+v", cases[3])
+	}
+}
+
+func TestNegotiateFormat(t *testing.T) {
+	for accept, expected := range map[string]string{
+		"application/sarif+json":                                "sarif",
+		"text/xml; charset=utf-8":                               "",
+		"application/xml, */*":                                  "",
+		"application/junit+xml;q=0.9, text/plain":               "junit",
+		"application/json, application/sarif+json":              "",
+		"application/sarif+json;q=0, application/json":          "",
+		"application/json;q=0.5, application/sarif+json":        "sarif",
+		"*/*;q=0.1, application/junit+xml":                      "junit",
+		"application/sarif+json, application/junit+xml":         "sarif",
+		"application/sarif+json;q=0, application/junit+xml;q=0": "",
+		"*/*": "",
+		"":    "",
+	} {
+		format, ok := report.NegotiateFormat(accept)
+		if ok != (expected != "") || format.Name != expected {
+			t.Fatalf("expected %q format for %q, but got %q", expected, accept, format.Name)
+		}
+	}
+}
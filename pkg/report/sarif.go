@@ -0,0 +1,176 @@
+package report
+
+import (
+	"encoding/json"
+	"io"
+	"net/url"
+	"path/filepath"
+
+	"github.com/netcracker/qubership-av-scan-service/pkg/handlers"
+)
+
+// Version and schema of SARIF written by WriteSARIF
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+// toolName is the name of the tool reported in SARIF and JUnit reports
+const toolName = "av-scan-service"
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool        sarifTool         `json:"tool"`
+	Invocations []sarifInvocation `json:"invocations"`
+	Artifacts   []sarifArtifact   `json:"artifacts,omitempty"`
+	Results     []sarifResult     `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules,omitempty"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifInvocation struct {
+	ExecutionSuccessful        bool                `json:"executionSuccessful"`
+	ToolExecutionNotifications []sarifNotification `json:"toolExecutionNotifications,omitempty"`
+}
+
+type sarifNotification struct {
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifArtifact struct {
+	Location sarifArtifactLocation `json:"location"`
+	Hashes   map[string]string     `json:"hashes,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI   string `json:"uri"`
+	Index *int   `json:"index,omitempty"`
+}
+
+type sarifResult struct {
+	RuleID     string            `json:"ruleId"`
+	RuleIndex  int               `json:"ruleIndex"`
+	Level      string            `json:"level"`
+	Message    sarifMessage      `json:"message"`
+	Locations  []sarifLocation   `json:"locations"`
+	Properties map[string]string `json:"properties,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation  `json:"physicalLocation"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifLogicalLocation struct {
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+// WriteSARIF writes results as SARIF 2.1.0 log with a single run. Each detection is a result with error level
+// and rule named after virus signature or verdict. Detected files are listed as artifacts with SHA-256 hashes,
+// detections in archive members have logical location with member name and member hash in properties.
+// Files which could not be scanned are reported as tool execution notifications.
+func WriteSARIF(w io.Writer, results []*ScanResult) error {
+	run := sarifRun{
+		Tool:        sarifTool{Driver: sarifDriver{Name: toolName}},
+		Invocations: []sarifInvocation{{ExecutionSuccessful: true}},
+		Results:     []sarifResult{},
+	}
+	rules := make(map[string]int)
+	for _, result := range results {
+		location := sarifArtifactLocation{URI: artifactURI(result.Path)}
+		if result.Status == nil {
+			run.Invocations[0].ExecutionSuccessful = false
+			run.Invocations[0].ToolExecutionNotifications = append(run.Invocations[0].ToolExecutionNotifications, sarifNotification{
+				Level:     "error",
+				Message:   sarifMessage{Text: result.Error},
+				Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: location}}},
+			})
+			continue
+		}
+		found := detections(result.Status)
+		if len(found) == 0 {
+			continue
+		}
+
+		index := len(run.Artifacts)
+		location.Index = &index
+		artifact := sarifArtifact{Location: sarifArtifactLocation{URI: location.URI}}
+		if result.Status.SHA256 != "" {
+			artifact.Hashes = map[string]string{"sha-256": result.Status.SHA256}
+		}
+		run.Artifacts = append(run.Artifacts, artifact)
+
+		for _, status := range found {
+			id := ruleID(status)
+			if _, ok := rules[id]; !ok {
+				rules[id] = len(run.Tool.Driver.Rules)
+				run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+					ID:               id,
+					ShortDescription: sarifMessage{Text: ruleDescription(status)},
+				})
+			}
+			sarifRes := sarifResult{
+				RuleID:    id,
+				RuleIndex: rules[id],
+				Level:     "error",
+				Message:   sarifMessage{Text: detectionMessage(result, status)},
+				Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: location}}},
+				Properties: map[string]string{
+					"verdict": string(status.Verdict),
+				},
+			}
+			if status != result.Status {
+				sarifRes.Locations[0].LogicalLocations = []sarifLogicalLocation{{FullyQualifiedName: status.Filename, Kind: "member"}}
+			}
+			if status.SHA256 != "" {
+				sarifRes.Properties["sha256"] = status.SHA256
+			}
+			run.Results = append(run.Results, sarifRes)
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(sarifLog{Schema: sarifSchema, Version: sarifVersion, Runs: []sarifRun{run}})
+}
+
+// artifactURI converts file path to relative or absolute URI reference
+func artifactURI(path string) string {
+	return (&url.URL{Path: filepath.ToSlash(path)}).String()
+}
+
+// ruleDescription describes rule of detection
+func ruleDescription(status *handlers.ScanStatus) string {
+	if status.Infected {
+		return "Virus signature " + status.Virus
+	}
+	return "File verdict " + string(status.Verdict)
+}
@@ -0,0 +1,91 @@
+package router
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+
+	"github.com/netcracker/qubership-av-scan-service/pkg/clamav"
+	"github.com/netcracker/qubership-av-scan-service/pkg/errors"
+	"github.com/netcracker/qubership-av-scan-service/pkg/handlers"
+	"github.com/netcracker/qubership-av-scan-service/pkg/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Gateway serves its own health and metrics endpoints under this prefix, all other paths belong to upstream
+const gatewayPathPrefix = "/_av"
+
+// DefaultGatewayRoutes intercepts requests of all methods which usually carry uploads
+var DefaultGatewayRoutes = []string{"POST /", "PUT /", "PATCH /"}
+
+// NewGateway returns a http.Handler which forwards requests to upstream backend.
+// Uploads of requests matching given routes are scanned first, and requests are forwarded only if all files are clean,
+// otherwise APIError with verdicts is returned. Routes are http.ServeMux patterns, e.g. "POST /upload"
+// or "PUT /files/{name...}". Health and metrics of the gateway itself are served at /_av/health and /_av/metrics.
+func NewGateway(clamd clamav.Clamd, logger *slog.Logger, upstream *url.URL, routes []string, opts ...Option) (http.Handler, error) {
+	if clamd == nil {
+		panic("Gateway MUST be provided with ClamD instance")
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if upstream.Scheme != "http" && upstream.Scheme != "https" {
+		return nil, fmt.Errorf("invalid upstream URL %q, scheme must be http or https", upstream)
+	}
+
+	cfg := newConfig(opts)
+	registry := newRegistry(cfg, clamd, logger)
+
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(upstream)
+			r.SetXForwarded()
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			handleError(w, log.From(r), errors.UpstreamError(err))
+		},
+	}
+
+	m := http.NewServeMux()
+	gateway := handlers.NewGatewayHandler(handlers.NewScanHandler(clamd, registry, cfg.scan), cfg.deep, cfg.maxGatewayBodySize)
+	scanning := newGatewayHandler(gateway, proxy, registry)
+	for _, route := range routes {
+		if err := handle(m, route, scanning); err != nil {
+			return nil, fmt.Errorf("invalid gateway route %q: %s", route, err)
+		}
+	}
+	if err := handle(m, "/", metricsMiddleware(proxy, registry, "proxy")); err != nil {
+		return nil, err
+	}
+	m.Handle("GET "+gatewayPathPrefix+"/health", newHealthHandler(clamd, registry))
+	m.Handle("GET "+gatewayPathPrefix+"/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	return loggingMiddleware(m, logger), nil
+}
+
+// newGatewayHandler scans uploads of the request and forwards it to upstream if all files are clean
+func newGatewayHandler(gateway *handlers.GatewayHandler, upstream http.Handler, registry *prometheus.Registry) http.Handler {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := gateway.Scan(r)
+		if err != nil {
+			handleError(w, log.From(r), err)
+			return
+		}
+		defer body.Close()
+		upstream.ServeHTTP(w, r)
+	})
+	return metricsMiddleware(panicRecoveryMiddleware(handler), registry, "gateway")
+}
+
+// handle registers handler for the pattern, returning error instead of panic if pattern is invalid or conflicts with others
+func handle(m *http.ServeMux, pattern string, handler http.Handler) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	m.Handle(pattern, handler)
+	return nil
+}
@@ -0,0 +1,257 @@
+package router_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/netcracker/qubership-av-scan-service/pkg/errors"
+	"github.com/netcracker/qubership-av-scan-service/pkg/router"
+	"github.com/netcracker/qubership-av-scan-service/pkg/testutils"
+)
+
+// upstreamMock records bodies of requests it received
+type upstreamMock struct {
+	*httptest.Server
+	bodies map[string]string
+}
+
+func newUpstreamMock() *upstreamMock {
+	u := &upstreamMock{bodies: map[string]string{}}
+	u.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		u.bodies[r.Method+" "+r.URL.Path] = string(body)
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, "stored")
+	}))
+	return u
+}
+
+func newGateway(t *testing.T, upstream *upstreamMock, routes []string, opts ...router.Option) http.Handler {
+	upstreamURL, _ := url.Parse(upstream.URL)
+	gateway, err := router.NewGateway(testutils.NewClamdMock(), slog.Default(), upstreamURL, routes, opts...)
+	if err != nil {
+		t.Fatalf("expected gateway to be created, but failed: %s", err)
+	}
+	return gateway
+}
+
+func TestGatewayForwardsCleanUpload(t *testing.T) {
+	upstream := newUpstreamMock()
+	defer upstream.Close()
+	gateway := newGateway(t, upstream, []string{"POST /upload"})
+
+	buffer := &bytes.Buffer{}
+	multi := multipart.NewWriter(buffer)
+	multi.WriteField("description", "report")
+	writeFile(multi, "a.txt", "clean content")
+	multi.Close()
+	sent := buffer.String()
+
+	respWriter := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/upload", buffer)
+	req.Header.Add("Content-Type", multi.FormDataContentType())
+	gateway.ServeHTTP(respWriter, req)
+
+	resp := respWriter.Result()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected upstream response, but got: %v", resp.Status)
+	}
+	if body, _ := io.ReadAll(resp.Body); string(body) != "stored" {
+		t.Fatalf("expected upstream response body, but got: %q", body)
+	}
+	if upstream.bodies["POST /upload"] != sent {
+		t.Fatalf("expected request body to be forwarded unchanged, but got: %q", upstream.bodies["POST /upload"])
+	}
+}
+
+func TestGatewayBlocksInfectedUpload(t *testing.T) {
+	upstream := newUpstreamMock()
+	defer upstream.Close()
+	gateway := newGateway(t, upstream, router.DefaultGatewayRoutes)
+
+	buffer := &bytes.Buffer{}
+	multi := multipart.NewWriter(buffer)
+	writeFile(multi, "a.txt", "clean content")
+	writeFile(multi, "virus.txt", testutils.EICARTest)
+	multi.Close()
+
+	respWriter := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/upload", buffer)
+	req.Header.Add("Content-Type", multi.FormDataContentType())
+	gateway.ServeHTTP(respWriter, req)
+
+	resp := respWriter.Result()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected forbidden response, but got: %v", resp.Status)
+	}
+	apiErr, err := errors.Parse(resp.Body)
+	if err != nil {
+		t.Fatalf("expected to read apiErr, but failed: %s", err)
+	}
+	if apiErr.Code != "AV-5018" || !strings.Contains(apiErr.Details, "virus.txt: infected") || strings.Contains(apiErr.Details, "a.txt") {
+		t.Fatalf("expected AV-5018 error with verdict of virus.txt, but got: %+v", apiErr)
+	}
+	if len(upstream.bodies) != 0 {
+		t.Fatalf("expected request not to be forwarded, but got: %v", upstream.bodies)
+	}
+}
+
+func TestGatewayRawUpload(t *testing.T) {
+	upstream := newUpstreamMock()
+	defer upstream.Close()
+	gateway := newGateway(t, upstream, []string{"PUT /files/{name...}"})
+
+	cases := map[string]struct {
+		content string
+		status  int
+	}{
+		"clean":    {"clean content", http.StatusCreated},
+		"infected": {testutils.EICARTest, http.StatusForbidden},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			respWriter := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPut, "/files/"+name+".bin", strings.NewReader(c.content))
+			req.Header.Add("Content-Type", "application/octet-stream")
+			gateway.ServeHTTP(respWriter, req)
+
+			resp := respWriter.Result()
+			if resp.StatusCode != c.status {
+				t.Fatalf("expected %d response, but got: %v", c.status, resp.Status)
+			}
+		})
+	}
+	if upstream.bodies["PUT /files/clean.bin"] != "clean content" {
+		t.Fatalf("expected clean upload to be forwarded, but got: %v", upstream.bodies)
+	}
+	if _, ok := upstream.bodies["PUT /files/infected.bin"]; ok {
+		t.Fatal("expected infected upload not to be forwarded")
+	}
+}
+
+func TestGatewayBlocksTransferEncodedAndNestedParts(t *testing.T) {
+	upstream := newUpstreamMock()
+	defer upstream.Close()
+	gateway := newGateway(t, upstream, []string{"POST /upload"})
+
+	cases := map[string]func(multi *multipart.Writer){
+		"base64": func(multi *multipart.Writer) {
+			w, _ := multi.CreatePart(textproto.MIMEHeader{
+				"Content-Disposition":       {`form-data; name="file"; filename="encoded.txt"`},
+				"Content-Transfer-Encoding": {"base64"},
+			})
+			w.Write([]byte(base64.StdEncoding.EncodeToString([]byte(testutils.EICARTest))))
+		},
+		"nested": func(multi *multipart.Writer) {
+			nested := &bytes.Buffer{}
+			nestedMulti := multipart.NewWriter(nested)
+			writeFile(nestedMulti, "nested.txt", testutils.EICARTest)
+			nestedMulti.Close()
+			w, _ := multi.CreatePart(textproto.MIMEHeader{
+				"Content-Disposition": {`form-data; name="files"`},
+				"Content-Type":        {"multipart/mixed; boundary=" + nestedMulti.Boundary()},
+			})
+			w.Write(nested.Bytes())
+		},
+	}
+	for name, write := range cases {
+		t.Run(name, func(t *testing.T) {
+			buffer := &bytes.Buffer{}
+			multi := multipart.NewWriter(buffer)
+			multi.WriteField("description", "report")
+			write(multi)
+			multi.Close()
+
+			respWriter := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/upload", buffer)
+			req.Header.Add("Content-Type", multi.FormDataContentType())
+			gateway.ServeHTTP(respWriter, req)
+
+			resp := respWriter.Result()
+			if resp.StatusCode != http.StatusForbidden {
+				t.Fatalf("expected forbidden response, but got: %v", resp.Status)
+			}
+		})
+	}
+	if len(upstream.bodies) != 0 {
+		t.Fatalf("expected requests not to be forwarded, but got: %v", upstream.bodies)
+	}
+}
+
+func TestGatewayBodySizeLimit(t *testing.T) {
+	upstream := newUpstreamMock()
+	defer upstream.Close()
+	gateway := newGateway(t, upstream, []string{"PUT /files/{name...}"}, router.WithMaxGatewayBodySize(10))
+
+	cases := map[string]struct {
+		content string
+		chunked bool
+		status  int
+	}{
+		"within limit":   {"0123456789", false, http.StatusCreated},
+		"content length": {"0123456789a", false, http.StatusRequestEntityTooLarge},
+		"chunked":        {"0123456789a", true, http.StatusRequestEntityTooLarge},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			respWriter := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPut, "/files/a.bin", strings.NewReader(c.content))
+			if c.chunked {
+				req.ContentLength = -1
+			}
+			req.Header.Add("Content-Type", "application/octet-stream")
+			gateway.ServeHTTP(respWriter, req)
+
+			resp := respWriter.Result()
+			if resp.StatusCode != c.status {
+				t.Fatalf("expected %d response, but got: %v", c.status, resp.Status)
+			}
+			if c.status != http.StatusCreated {
+				apiErr, err := errors.Parse(resp.Body)
+				if err != nil || apiErr.Code != "AV-5020" {
+					t.Fatalf("expected AV-5020 error, but got: %+v, %v", apiErr, err)
+				}
+			}
+		})
+	}
+}
+
+func TestGatewayForwardsNotScannedRoutes(t *testing.T) {
+	upstream := newUpstreamMock()
+	defer upstream.Close()
+	gateway := newGateway(t, upstream, []string{"POST /upload"})
+
+	respWriter := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/comments", strings.NewReader(testutils.EICARTest))
+	gateway.ServeHTTP(respWriter, req)
+
+	resp := respWriter.Result()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected upstream response, but got: %v", resp.Status)
+	}
+	if upstream.bodies["POST /comments"] != testutils.EICARTest {
+		t.Fatalf("expected request to be forwarded, but got: %v", upstream.bodies)
+	}
+
+	respWriter = httptest.NewRecorder()
+	gateway.ServeHTTP(respWriter, httptest.NewRequest(http.MethodGet, "/_av/health", nil))
+	if resp := respWriter.Result(); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected gateway health to be OK, but got: %v", resp.Status)
+	}
+}
+
+func TestGatewayInvalidRoute(t *testing.T) {
+	upstreamURL, _ := url.Parse("http://127.0.0.1:8080")
+	if _, err := router.NewGateway(testutils.NewClamdMock(), slog.Default(), upstreamURL, []string{"POST upload"}); err == nil {
+		t.Fatal("expected error for invalid route")
+	}
+}
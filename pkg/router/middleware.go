@@ -1,9 +1,11 @@
 package router
 
 import (
+	"bufio"
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
 	"runtime/debug"
 	"time"
@@ -11,6 +13,7 @@ import (
 	"github.com/netcracker/qubership-av-scan-service/pkg/errors"
 	"github.com/netcracker/qubership-av-scan-service/pkg/handlers"
 	"github.com/netcracker/qubership-av-scan-service/pkg/log"
+	"github.com/netcracker/qubership-av-scan-service/pkg/report"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -39,6 +42,34 @@ func requestHandlerAdapter(reqHandler handlers.RequestHandler) http.Handler {
 	})
 }
 
+// reportMiddleware renders scan statuses as CI report if SARIF or JUnit XML is requested by Accept header,
+// otherwise request is passed to the next handler writing JSON
+func reportMiddleware(reqHandler handlers.RequestHandler, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		format, ok := report.NegotiateFormat(r.Header.Get("Accept"))
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		logger := log.From(r)
+		res, err := reqHandler.Handle(r)
+		if err != nil {
+			handleError(w, logger, err)
+			return
+		}
+		statuses, ok := res.([]*handlers.ScanStatus)
+		if !ok {
+			handleError(w, logger, fmt.Errorf("unexpected scan result %T", res))
+			return
+		}
+		w.Header().Set("Content-Type", format.MediaType)
+		if err := format.Write(w, report.FromStatuses(statuses)); err != nil {
+			logger.Error("failed to write report", "error", err)
+		}
+	})
+}
+
 // loggingMiddleware logs high-level information about request start/end.
 // It also saves logger in the request context with additional fields for future use
 func loggingMiddleware(next http.Handler, logger *slog.Logger) http.Handler {
@@ -136,6 +167,16 @@ func (lrw *loggingResponseWriter) WriteHeader(code int) {
 	lrw.ResponseWriter.WriteHeader(code)
 }
 
+// Hijack allows handler to take over the connection, e.g. to serve WebSocket
+func (lrw *loggingResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := lrw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	lrw.statusCode = http.StatusSwitchingProtocols
+	return hijacker.Hijack()
+}
+
 // writeResponse marshals given value as JSON and writes it in response body.
 // If any error happens during write, it is returned as is.
 func writeResponse(resp http.ResponseWriter, v any) error {
@@ -4,18 +4,167 @@ import (
 	"log/slog"
 	"net/http"
 
+	"github.com/netcracker/qubership-av-scan-service/pkg/archive"
 	"github.com/netcracker/qubership-av-scan-service/pkg/clamav"
+	"github.com/netcracker/qubership-av-scan-service/pkg/fetch"
 	"github.com/netcracker/qubership-av-scan-service/pkg/handlers"
+	"github.com/netcracker/qubership-av-scan-service/pkg/s3"
+	"github.com/netcracker/qubership-av-scan-service/pkg/upload"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/collectors"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+// Option configures optional router behaviour
+type Option func(*config)
+
+// config contains optional router settings which are passed to handlers
+type config struct {
+	scan                 handlers.ScanOptions
+	fetch                *fetch.Options
+	objects              *s3.Client
+	maxObjects           int
+	deep                 bool
+	maxGatewayBodySize   int64
+	registry             *prometheus.Registry
+	maxWebSocketFileSize int64
+	uploads              *upload.Store
+	maxUploadSize        int64
+}
+
+// newConfig returns default config with given options applied
+func newConfig(opts []Option) *config {
+	cfg := &config{
+		scan:                 handlers.DefaultScanOptions(),
+		maxWebSocketFileSize: handlers.DefaultMaxWebSocketFileSize,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// WithScanOptions sets all options of file scanning at once
+func WithScanOptions(opts handlers.ScanOptions) Option {
+	return func(c *config) {
+		c.scan = opts
+	}
+}
+
+// WithArchiveLimits sets limits used to expand archives in deep scan mode
+func WithArchiveLimits(limits archive.Limits) Option {
+	return func(c *config) {
+		c.scan.ArchiveLimits = limits
+	}
+}
+
+// WithImageLimits sets limits used to scan container images
+func WithImageLimits(limits archive.Limits) Option {
+	return func(c *config) {
+		c.scan.ImageLimits = limits
+	}
+}
+
+// WithBombProtection sets archive bomb detection limits and policy used in deep scan mode
+func WithBombProtection(limits archive.BombLimits, policy handlers.BombPolicy) Option {
+	return func(c *config) {
+		c.scan.BombLimits = limits
+		c.scan.BombPolicy = policy
+	}
+}
+
+// WithEncryptedPolicy sets how encrypted archives and documents are handled
+func WithEncryptedPolicy(policy handlers.EncryptedPolicy) Option {
+	return func(c *config) {
+		c.scan.EncryptedPolicy = policy
+	}
+}
+
+// WithTypePolicy sets allowed and denied file types
+func WithTypePolicy(policy handlers.TypePolicy) Option {
+	return func(c *config) {
+		c.scan.TypePolicy = policy
+	}
+}
+
+// WithFindingPolicy sets types of findings which block the file
+func WithFindingPolicy(policy handlers.FindingPolicy) Option {
+	return func(c *config) {
+		c.scan.FindingPolicy = policy
+	}
+}
+
+// WithMaxDecodedBodySize restricts size of compressed request bodies after decompression
+func WithMaxDecodedBodySize(size int64) Option {
+	return func(c *config) {
+		c.scan.MaxDecodedBodySize = size
+	}
+}
+
+// WithMaxFileSize restricts size of a single uploaded file
+func WithMaxFileSize(size int64) Option {
+	return func(c *config) {
+		c.scan.MaxFileSize = size
+	}
+}
+
+// WithFetchOptions enables URL scans, fetching remote content with given limits and SSRF protection
+func WithFetchOptions(opts fetch.Options) Option {
+	return func(c *config) {
+		c.fetch = &opts
+	}
+}
+
+// WithObjectStorage enables scanning of objects of S3-compatible storage accessed with given client.
+// Prefix requests matching more than maxObjects objects are rejected.
+func WithObjectStorage(client *s3.Client, maxObjects int) Option {
+	return func(c *config) {
+		c.objects = client
+		c.maxObjects = maxObjects
+	}
+}
+
+// WithDeepScan enables expansion of archives uploaded through the gateway
+func WithDeepScan(deep bool) Option {
+	return func(c *config) {
+		c.deep = deep
+	}
+}
+
+// WithMaxGatewayBodySize restricts size of request bodies scanned by the gateway
+func WithMaxGatewayBodySize(size int64) Option {
+	return func(c *config) {
+		c.maxGatewayBodySize = size
+	}
+}
+
+// WithMaxWebSocketFileSize restricts size of files uploaded through WebSocket
+func WithMaxWebSocketFileSize(size int64) Option {
+	return func(c *config) {
+		c.maxWebSocketFileSize = size
+	}
+}
+
+// WithUploadStore enables tus resumable uploads kept in given store, uploads larger than maxSize are rejected
+func WithUploadStore(store *upload.Store, maxSize int64) Option {
+	return func(c *config) {
+		c.uploads = store
+		c.maxUploadSize = maxSize
+	}
+}
+
+// WithRegistry sets metrics registry, so metrics of other listeners, e.g. ICAP server, are exposed together with router ones
+func WithRegistry(registry *prometheus.Registry) Option {
+	return func(c *config) {
+		c.registry = registry
+	}
+}
+
 // NewRouter returns a http.Handler which handles all application endpoints.
 // Under the hood, it uses dedicated handlers and special middleware
 // for metrics, logging, panic recovery, etc
-func NewRouter(clamd clamav.Clamd, logger *slog.Logger) http.Handler {
+func NewRouter(clamd clamav.Clamd, logger *slog.Logger, opts ...Option) http.Handler {
 	if clamd == nil {
 		panic("Server MUST be provided with ClamD instance")
 	}
@@ -23,26 +172,76 @@ func NewRouter(clamd clamav.Clamd, logger *slog.Logger) http.Handler {
 		logger = slog.Default()
 	}
 
-	registry := prometheus.NewRegistry()
-	registry.MustRegister(
-		collectors.NewGoCollector(),
-		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
-	)
-	registry.MustRegister(clamav.NewMetricsCollector(clamd, logger))
+	cfg := newConfig(opts)
+	registry := newRegistry(cfg, clamd, logger)
 
 	m := http.NewServeMux()
-	m.Handle("POST /api/v1/scan", newScanHandler(clamd, registry))
+	scanner := handlers.NewScanHandler(clamd, registry, cfg.scan)
+	m.Handle("POST /api/v1/scan", newScanHandler(scanner, registry))
+	m.Handle("POST /api/v1/sanitize", newSanitizeHandler(scanner, registry))
+	m.Handle("POST /api/v1/scan/image", newImageHandler(scanner, registry))
+	m.Handle("GET /api/v1/scan/ws", newWebSocketScanHandler(scanner, cfg.maxWebSocketFileSize, registry))
+	if cfg.fetch != nil {
+		m.Handle("POST /api/v1/scan/url", newURLScanHandler(scanner, fetch.New(*cfg.fetch), registry))
+	}
+	if cfg.objects != nil {
+		m.Handle("POST /api/v1/scan/s3", newObjectScanHandler(scanner, cfg.objects, cfg.maxObjects, registry))
+	}
+	if cfg.uploads != nil {
+		uploads := newUploadHandler(handlers.NewUploadHandler(scanner, cfg.uploads, cfg.maxUploadSize), registry)
+		m.Handle(uploadsPath, uploads)
+		m.Handle(uploadsPath+"/", uploads)
+	}
 	m.Handle("GET /health", newHealthHandler(clamd, registry))
 	m.Handle("GET /metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
 	return loggingMiddleware(m, logger)
 }
 
+// newRegistry returns metrics registry with runtime and clamd metrics, configured registry is used if set
+func newRegistry(cfg *config, clamd clamav.Clamd, logger *slog.Logger) *prometheus.Registry {
+	registry := cfg.registry
+	if registry == nil {
+		registry = prometheus.NewRegistry()
+	}
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	registry.MustRegister(clamav.NewMetricsCollector(clamd, logger))
+	return registry
+}
+
 func newHealthHandler(clamd clamav.Clamd, registry *prometheus.Registry) http.Handler {
 	handler := requestHandlerAdapter(handlers.NewHealthHandler(clamd))
 	return metricsMiddleware(panicRecoveryMiddleware(handler), registry, "health")
 }
 
-func newScanHandler(clamd clamav.Clamd, registry *prometheus.Registry) http.Handler {
-	handler := requestHandlerAdapter(handlers.NewScanHandler(clamd, registry))
+func newScanHandler(scanner *handlers.ScanHandler, registry *prometheus.Registry) http.Handler {
+	handler := reportMiddleware(scanner, requestHandlerAdapter(scanner))
 	return metricsMiddleware(panicRecoveryMiddleware(handler), registry, "scan")
 }
+
+func newSanitizeHandler(scanner *handlers.ScanHandler, registry *prometheus.Registry) http.Handler {
+	handler := requestHandlerAdapter(handlers.NewSanitizeHandler(scanner))
+	return metricsMiddleware(panicRecoveryMiddleware(handler), registry, "sanitize")
+}
+
+func newWebSocketScanHandler(scanner *handlers.ScanHandler, maxFileSize int64, registry *prometheus.Registry) http.Handler {
+	handler := handlers.NewWebSocketScanHandler(scanner, maxFileSize)
+	return metricsMiddleware(panicRecoveryMiddleware(handler), registry, "websocket")
+}
+
+func newImageHandler(scanner *handlers.ScanHandler, registry *prometheus.Registry) http.Handler {
+	handler := requestHandlerAdapter(handlers.NewImageHandler(scanner))
+	return metricsMiddleware(panicRecoveryMiddleware(handler), registry, "image")
+}
+
+func newURLScanHandler(scanner *handlers.ScanHandler, fetcher *fetch.Fetcher, registry *prometheus.Registry) http.Handler {
+	handler := requestHandlerAdapter(handlers.NewURLScanHandler(scanner, fetcher))
+	return metricsMiddleware(panicRecoveryMiddleware(handler), registry, "url")
+}
+
+func newObjectScanHandler(scanner *handlers.ScanHandler, client *s3.Client, maxObjects int, registry *prometheus.Registry) http.Handler {
+	handler := requestHandlerAdapter(handlers.NewObjectScanHandler(scanner, client, maxObjects))
+	return metricsMiddleware(panicRecoveryMiddleware(handler), registry, "s3")
+}
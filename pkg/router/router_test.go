@@ -1,18 +1,35 @@
 package router_test
 
 import (
+	"archive/tar"
+	"archive/zip"
 	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"io"
 	"log/slog"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
+	"net/textproto"
+	"strconv"
 	"strings"
 	"testing"
 
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"github.com/netcracker/qubership-av-scan-service/pkg/archive"
+	"github.com/netcracker/qubership-av-scan-service/pkg/cdr"
 	"github.com/netcracker/qubership-av-scan-service/pkg/clamav"
 	"github.com/netcracker/qubership-av-scan-service/pkg/errors"
+	"github.com/netcracker/qubership-av-scan-service/pkg/fetch"
 	"github.com/netcracker/qubership-av-scan-service/pkg/handlers"
+	"github.com/netcracker/qubership-av-scan-service/pkg/inspect"
 	"github.com/netcracker/qubership-av-scan-service/pkg/router"
+	"github.com/netcracker/qubership-av-scan-service/pkg/s3"
 	"github.com/netcracker/qubership-av-scan-service/pkg/testutils"
 	"github.com/prometheus/common/expfmt"
 )
@@ -57,6 +74,55 @@ func TestHealthBad(t *testing.T) {
 	}
 }
 
+func TestHealthDatabaseAge(t *testing.T) {
+	// DB is 3 days old
+	r := router.NewRouter(testutils.NewClamdMock().WithDatabaseAge(3*24*3600), nil)
+
+	for query, expected := range map[string]int{
+		"":                     http.StatusOK,
+		"?maxDatabaseAge=96h":  http.StatusOK,
+		"?maxDatabaseAge=48h":  http.StatusServiceUnavailable,
+		"?maxDatabaseAge=2d":   http.StatusBadRequest,
+		"?maxDatabaseAge=-48h": http.StatusBadRequest,
+	} {
+		respWriter := httptest.NewRecorder()
+		r.ServeHTTP(respWriter, httptest.NewRequest(http.MethodGet, "/health"+query, nil))
+
+		resp := respWriter.Result()
+		if resp.StatusCode != expected {
+			t.Fatalf("expected %d status for %q, but got: %v", expected, query, resp.Status)
+		}
+		if expected == http.StatusServiceUnavailable {
+			apiError, err := errors.Parse(resp.Body)
+			if err != nil {
+				t.Fatalf("failed to get error from body: %s", err)
+			}
+			if apiError.Code != "AV-7102" || apiError.Details != "database age 72h0m0s exceeds 48h0m0s" {
+				t.Fatalf("unexpected error: %+v", apiError)
+			}
+		}
+	}
+}
+
+func TestHealthDatabaseAgeError(t *testing.T) {
+	r := router.NewRouter(testutils.NewClamdMock().WithDatabaseError("unexpected version"), slog.Default())
+
+	respWriter := httptest.NewRecorder()
+	r.ServeHTTP(respWriter, httptest.NewRequest(http.MethodGet, "/health?maxDatabaseAge=48h", nil))
+
+	resp := respWriter.Result()
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("expected internal server error, but got: %v", resp.Status)
+	}
+	apiError, err := errors.Parse(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to get error from body: %s", err)
+	}
+	if apiError.Code != "AV-7103" || apiError.Details != "unexpected version" {
+		t.Fatalf("unexpected error: %+v", apiError)
+	}
+}
+
 func TestMetricsPresent(t *testing.T) {
 	// send scan request once for HTTP metrics to appear
 	r := router.NewRouter(testutils.NewClamdMock(), slog.Default())
@@ -325,10 +391,1312 @@ func TestScanFilenameNotSpecified(t *testing.T) {
 	}
 }
 
-func writeFile(w *multipart.Writer, name string, content string) {
-	fileWrite, _ := w.CreateFormFile(name, name)
-	_, err := fileWrite.Write([]byte(content))
+func TestDeepScanZip(t *testing.T) {
+	r := router.NewRouter(testutils.NewClamdMock(), slog.Default())
+	respWriter := httptest.NewRecorder()
+
+	inner := zipArchive(map[string]string{"virus.txt": testutils.EICARTest})
+	outer := zipArchive(map[string]string{
+		"safe.txt":  "safe content",
+		"inner.zip": string(inner),
+	})
+
+	buffer := &bytes.Buffer{}
+	multi := multipart.NewWriter(buffer)
+	writeFile(multi, "file1.zip", string(outer))
+	multi.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/scan?deep=true", buffer)
+	req.Header.Add("Content-Type", multi.FormDataContentType())
+	r.ServeHTTP(respWriter, req)
+
+	resp := respWriter.Result()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected OK response, but got: %v", resp.Status)
+	}
+
+	statuses, err := handlers.ParseScanStatuses(resp.Body)
 	if err != nil {
-		panic(err)
+		t.Fatalf("expected to read scan statuses, but failed: %s", err)
+	}
+
+	if len(statuses) != 1 {
+		t.Fatalf("expected excactly one status, but got: %d", len(statuses))
+	}
+	if !statuses[0].Infected {
+		t.Fatalf("expected archive to be infected, but got non-infected")
+	}
+	if len(statuses[0].Members) != 2 {
+		t.Fatalf("expected excactly two members, but got: %d", len(statuses[0].Members))
+	}
+
+	for _, m := range statuses[0].Members {
+		if m.Filename == "safe.txt" {
+			if m.Infected {
+				t.Fatalf("expected safe.txt to be not infected, but got infected")
+			}
+		} else if m.Filename == "inner.zip" {
+			if !m.Infected || len(m.Members) != 1 || m.Members[0].Filename != "virus.txt" {
+				t.Fatalf("expected inner.zip to contain infected virus.txt, but got: %+v", m.Members)
+			}
+		} else {
+			t.Fatalf("unexpected member name: %s", m.Filename)
+		}
+	}
+}
+
+func TestDeepScanTarGz(t *testing.T) {
+	r := router.NewRouter(testutils.NewClamdMock(), slog.Default())
+	respWriter := httptest.NewRecorder()
+
+	buffer := &bytes.Buffer{}
+	multi := multipart.NewWriter(buffer)
+	writeFile(multi, "file1.tar.gz", string(tarGzArchive(map[string]string{
+		"dir/virus.txt": testutils.EICARTest,
+	})))
+	multi.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/scan?deep=true", buffer)
+	req.Header.Add("Content-Type", multi.FormDataContentType())
+	r.ServeHTTP(respWriter, req)
+
+	resp := respWriter.Result()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected OK response, but got: %v", resp.Status)
+	}
+
+	statuses, err := handlers.ParseScanStatuses(resp.Body)
+	if err != nil {
+		t.Fatalf("expected to read scan statuses, but failed: %s", err)
+	}
+
+	members := statuses[0].Members
+	if len(members) != 1 || members[0].Filename != "dir/virus.txt" || !members[0].Infected {
+		t.Fatalf("expected infected dir/virus.txt member, but got: %+v", members)
+	}
+}
+
+func TestDeepScanMembersLimit(t *testing.T) {
+	r := router.NewRouter(
+		testutils.NewClamdMock(),
+		slog.Default(),
+		router.WithArchiveLimits(archive.Limits{MaxDepth: 1, MaxMembers: 1, MaxSize: 1 << 20}),
+	)
+	respWriter := httptest.NewRecorder()
+
+	buffer := &bytes.Buffer{}
+	multi := multipart.NewWriter(buffer)
+	writeFile(multi, "file1.zip", string(zipArchive(map[string]string{
+		"a.txt": "safe content",
+		"b.txt": "safe content",
+	})))
+	multi.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/scan?deep=true", buffer)
+	req.Header.Add("Content-Type", multi.FormDataContentType())
+	r.ServeHTTP(respWriter, req)
+
+	resp := respWriter.Result()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413 response, but got: %v", resp.Status)
+	}
+
+	apiErr, err := errors.Parse(resp.Body)
+	if err != nil {
+		t.Fatalf("expected to read apiErr, but failed: %s", err)
+	}
+
+	if apiErr.Code != "AV-5002" {
+		t.Fatalf("expected error code to be '%s', but got: %s",
+			"AV-5002", apiErr.Code)
+	}
+}
+
+func TestDeepScanArchiveBombRejected(t *testing.T) {
+	r := router.NewRouter(testutils.NewClamdMock(), slog.Default())
+	respWriter := httptest.NewRecorder()
+
+	buffer := &bytes.Buffer{}
+	multi := multipart.NewWriter(buffer)
+	writeFile(multi, "bomb.zip", string(zipArchive(map[string]string{
+		"zeros.txt": strings.Repeat("0", 10<<20),
+	})))
+	multi.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/scan?deep=true", buffer)
+	req.Header.Add("Content-Type", multi.FormDataContentType())
+	r.ServeHTTP(respWriter, req)
+
+	resp := respWriter.Result()
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 response, but got: %v", resp.Status)
+	}
+
+	apiErr, err := errors.Parse(resp.Body)
+	if err != nil {
+		t.Fatalf("expected to read apiErr, but failed: %s", err)
+	}
+
+	if apiErr.Code != "AV-5005" {
+		t.Fatalf("expected error code to be '%s', but got: %s",
+			"AV-5005", apiErr.Code)
+	}
+
+	// get metrics response
+	respWriter = httptest.NewRecorder()
+	r.ServeHTTP(respWriter, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	var parser expfmt.TextParser
+	mf, err := parser.TextToMetricFamilies(respWriter.Result().Body)
+	if err != nil {
+		t.Fatalf("expected to parse prometheus metrics, but failed: %s", err)
+	}
+
+	v, ok := mf[handlers.ArchiveBombsMetric]
+	if !ok {
+		t.Fatalf("%s metric not found", handlers.ArchiveBombsMetric)
+	}
+	if *v.Metric[0].Counter.Value != 1 || v.Metric[0].Label[0].GetValue() != "ratio" {
+		t.Fatalf("expected ratio bombs counter to increment to 1, but got: %v", v.Metric[0])
+	}
+}
+
+func TestDeepScanArchiveBombFlagged(t *testing.T) {
+	limits := archive.DefaultBombLimits()
+	limits.MaxDepth = 1
+	r := router.NewRouter(
+		testutils.NewClamdMock(),
+		slog.Default(),
+		router.WithBombProtection(limits, handlers.BombPolicyFlag),
+	)
+	respWriter := httptest.NewRecorder()
+
+	inner := zipArchive(map[string]string{"safe.txt": "safe content"})
+	buffer := &bytes.Buffer{}
+	multi := multipart.NewWriter(buffer)
+	writeFile(multi, "nested.zip", string(zipArchive(map[string]string{"inner.zip": string(inner)})))
+	multi.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/scan?deep=true", buffer)
+	req.Header.Add("Content-Type", multi.FormDataContentType())
+	r.ServeHTTP(respWriter, req)
+
+	resp := respWriter.Result()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected OK response, but got: %v", resp.Status)
+	}
+
+	statuses, err := handlers.ParseScanStatuses(resp.Body)
+	if err != nil {
+		t.Fatalf("expected to read scan statuses, but failed: %s", err)
+	}
+
+	if statuses[0].Verdict != handlers.VerdictArchiveBomb {
+		t.Fatalf("expected verdict to be '%s', but got: %s",
+			handlers.VerdictArchiveBomb, statuses[0].Verdict)
+	}
+}
+
+func TestScanEncryptedFlagged(t *testing.T) {
+	r := router.NewRouter(testutils.NewClamdMock(), slog.Default())
+	respWriter := httptest.NewRecorder()
+
+	buffer := &bytes.Buffer{}
+	multi := multipart.NewWriter(buffer)
+	writeFile(multi, "encrypted.zip", string(encryptedZipArchive("secret.txt", "encrypted content")))
+	writeFile(multi, "plain.zip", string(zipArchive(map[string]string{"a.txt": "safe content"})))
+	multi.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/scan", buffer)
+	req.Header.Add("Content-Type", multi.FormDataContentType())
+	r.ServeHTTP(respWriter, req)
+
+	resp := respWriter.Result()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected OK response, but got: %v", resp.Status)
+	}
+
+	statuses, err := handlers.ParseScanStatuses(resp.Body)
+	if err != nil {
+		t.Fatalf("expected to read scan statuses, but failed: %s", err)
+	}
+
+	if len(statuses) != 2 {
+		t.Fatalf("expected excactly two statuses, but got: %d", len(statuses))
+	}
+	if !statuses[0].Encrypted || statuses[0].Infected {
+		t.Fatalf("expected encrypted.zip to be encrypted and not infected, but got: %+v", statuses[0])
+	}
+	if statuses[1].Encrypted {
+		t.Fatalf("expected plain.zip to be not encrypted, but got encrypted")
+	}
+}
+
+func TestScanEncryptedInfectedPolicy(t *testing.T) {
+	r := router.NewRouter(
+		testutils.NewClamdMock(),
+		slog.Default(),
+		router.WithEncryptedPolicy(handlers.EncryptedPolicyInfected),
+	)
+	respWriter := httptest.NewRecorder()
+
+	buffer := &bytes.Buffer{}
+	multi := multipart.NewWriter(buffer)
+	writeFile(multi, "encrypted.pdf", "%PDF-1.7\ntrailer\n<< /Root 1 0 R /Encrypt 2 0 R >>\n%%EOF")
+	multi.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/scan", buffer)
+	req.Header.Add("Content-Type", multi.FormDataContentType())
+	r.ServeHTTP(respWriter, req)
+
+	resp := respWriter.Result()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected OK response, but got: %v", resp.Status)
+	}
+
+	statuses, err := handlers.ParseScanStatuses(resp.Body)
+	if err != nil {
+		t.Fatalf("expected to read scan statuses, but failed: %s", err)
+	}
+
+	if !statuses[0].Encrypted || !statuses[0].Infected || statuses[0].Virus != "Heuristics.Encrypted.PDF" {
+		t.Fatalf("expected encrypted.pdf to be reported as infected, but got: %+v", statuses[0])
+	}
+}
+
+func TestScanTypePolicy(t *testing.T) {
+	r := router.NewRouter(
+		testutils.NewClamdMock(),
+		slog.Default(),
+		router.WithTypePolicy(handlers.TypePolicy{Allowed: []string{"image/*", "application/pdf"}}),
+	)
+	respWriter := httptest.NewRecorder()
+
+	buffer := &bytes.Buffer{}
+	multi := multipart.NewWriter(buffer)
+	writeFile(multi, "image.png", "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	writeFile(multi, "renamed.png", "MZ\x90\x00\x03\x00\x00\x00")
+	multi.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/scan", buffer)
+	req.Header.Add("Content-Type", multi.FormDataContentType())
+	r.ServeHTTP(respWriter, req)
+
+	resp := respWriter.Result()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected OK response, but got: %v", resp.Status)
+	}
+
+	statuses, err := handlers.ParseScanStatuses(resp.Body)
+	if err != nil {
+		t.Fatalf("expected to read scan statuses, but failed: %s", err)
+	}
+
+	if len(statuses) != 2 {
+		t.Fatalf("expected excactly two statuses, but got: %d", len(statuses))
+	}
+	image, renamed := statuses[0], statuses[1]
+	if image.MimeType != "image/png" || image.ExtensionMismatch || image.Verdict != handlers.VerdictClean {
+		t.Fatalf("expected image.png to be clean png, but got: %+v", image)
+	}
+	if renamed.MimeType != "application/vnd.microsoft.portable-executable" {
+		t.Fatalf("expected renamed.png to be detected as executable, but got: %s", renamed.MimeType)
+	}
+	if !renamed.ExtensionMismatch || renamed.Verdict != handlers.VerdictPolicyBlocked {
+		t.Fatalf("expected renamed.png to be mismatched and blocked, but got: %+v", renamed)
+	}
+}
+
+func TestScanBlockedFindings(t *testing.T) {
+	r := router.NewRouter(
+		testutils.NewClamdMock(),
+		slog.Default(),
+		router.WithFindingPolicy(handlers.FindingPolicy{Blocked: []inspect.FindingType{inspect.FindingVBAProject}}),
+	)
+	respWriter := httptest.NewRecorder()
+
+	buffer := &bytes.Buffer{}
+	multi := multipart.NewWriter(buffer)
+	writeFile(multi, "report.docm", string(zipArchive(map[string]string{
+		"word/document.xml":   "<w:document/>",
+		"word/vbaProject.bin": "vba",
+	})))
+	multi.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/scan", buffer)
+	req.Header.Add("Content-Type", multi.FormDataContentType())
+	r.ServeHTTP(respWriter, req)
+
+	resp := respWriter.Result()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected OK response, but got: %v", resp.Status)
+	}
+
+	statuses, err := handlers.ParseScanStatuses(resp.Body)
+	if err != nil {
+		t.Fatalf("expected to read scan statuses, but failed: %s", err)
+	}
+
+	status := statuses[0]
+	if len(status.Findings) != 1 || status.Findings[0].Type != inspect.FindingVBAProject {
+		t.Fatalf("expected vba project finding, but got: %+v", status.Findings)
+	}
+	if status.Findings[0].Location != "word/vbaProject.bin" {
+		t.Fatalf("expected finding location to be word/vbaProject.bin, but got: %s", status.Findings[0].Location)
+	}
+	if status.Verdict != handlers.VerdictPolicyBlocked || status.Infected {
+		t.Fatalf("expected report.docm to be blocked by policy, but got: %+v", status)
+	}
+}
+
+func writeFile(w *multipart.Writer, name string, content string) {
+	fileWrite, _ := w.CreateFormFile(name, name)
+	_, err := fileWrite.Write([]byte(content))
+	if err != nil {
+		panic(err)
+	}
+}
+
+func zipArchive(files map[string]string) []byte {
+	buffer := &bytes.Buffer{}
+	zw := zip.NewWriter(buffer)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			panic(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			panic(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		panic(err)
+	}
+	return buffer.Bytes()
+}
+
+func tarArchive(files map[string]string) []byte {
+	buffer := &bytes.Buffer{}
+	tw := tar.NewWriter(buffer)
+	for name, content := range files {
+		err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(content))})
+		if err != nil {
+			panic(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			panic(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		panic(err)
+	}
+	return buffer.Bytes()
+}
+
+func tarGzArchive(files map[string]string) []byte {
+	buffer := &bytes.Buffer{}
+	gw := gzip.NewWriter(buffer)
+	if _, err := gw.Write(tarArchive(files)); err != nil {
+		panic(err)
+	}
+	if err := gw.Close(); err != nil {
+		panic(err)
+	}
+	return buffer.Bytes()
+}
+
+// encryptedZipArchive builds zip with a single member marked as encrypted,
+// member content is stored as is, since only encryption flag matters for detection
+func encryptedZipArchive(name string, content string) []byte {
+	buffer := &bytes.Buffer{}
+	zw := zip.NewWriter(buffer)
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store, Flags: 0x1})
+	if err != nil {
+		panic(err)
+	}
+	if _, err := w.Write([]byte(content)); err != nil {
+		panic(err)
+	}
+	if err := zw.Close(); err != nil {
+		panic(err)
+	}
+	return buffer.Bytes()
+}
+
+func TestSanitize(t *testing.T) {
+	r := router.NewRouter(testutils.NewClamdMock(), slog.Default())
+	respWriter := httptest.NewRecorder()
+
+	buffer := &bytes.Buffer{}
+	multi := multipart.NewWriter(buffer)
+	writeFile(multi, "report.docm", string(zipArchive(map[string]string{
+		"word/document.xml":   "<w:document/>",
+		"word/vbaProject.bin": "vba",
+	})))
+	multi.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/sanitize", buffer)
+	req.Header.Add("Content-Type", multi.FormDataContentType())
+	r.ServeHTTP(respWriter, req)
+
+	resp := respWriter.Result()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected OK response, but got: %v", resp.Status)
+	}
+
+	var results []*handlers.SanitizeResult
+	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+		t.Fatalf("expected to read sanitize results, but failed: %s", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("expected exactly one result, but got: %d", len(results))
+	}
+
+	result := results[0]
+	if result.Filename != "report.docx" {
+		t.Fatalf("expected sanitized file to be renamed to report.docx, but got: %s", result.Filename)
+	}
+	if len(result.Removed) != 1 || result.Removed[0].Kind != cdr.RemovalVBAProject {
+		t.Fatalf("expected vba project to be removed, but got: %+v", result.Removed)
+	}
+	if result.Scan.Verdict != handlers.VerdictClean || len(result.Scan.Findings) != 0 {
+		t.Fatalf("expected sanitized file to be clean, but got: %+v", result.Scan)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(result.Content), int64(len(result.Content)))
+	if err != nil {
+		t.Fatalf("expected sanitized content to be zip, but failed: %s", err)
+	}
+	for _, f := range zr.File {
+		if f.Name == "word/vbaProject.bin" {
+			t.Fatal("expected vba project to be removed from sanitized content")
+		}
+	}
+}
+
+func TestSanitizeUnsupportedType(t *testing.T) {
+	r := router.NewRouter(testutils.NewClamdMock(), slog.Default())
+	respWriter := httptest.NewRecorder()
+
+	buffer := &bytes.Buffer{}
+	multi := multipart.NewWriter(buffer)
+	writeFile(multi, "report.pdf", "%PDF-1.7\n")
+	multi.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/sanitize", buffer)
+	req.Header.Add("Content-Type", multi.FormDataContentType())
+	r.ServeHTTP(respWriter, req)
+
+	resp := respWriter.Result()
+	if resp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected unsupported media type response, but got: %v", resp.Status)
+	}
+
+	apiErr, err := errors.Parse(resp.Body)
+	if err != nil {
+		t.Fatalf("expected to read API error, but failed: %s", err)
+	}
+	if apiErr.Code != "AV-5006" {
+		t.Fatalf("expected AV-5006 error, but got: %s", apiErr.Code)
+	}
+}
+
+func TestSanitizeFileSizeLimit(t *testing.T) {
+	r := router.NewRouter(testutils.NewClamdMock(), slog.Default(), router.WithMaxFileSize(16))
+	respWriter := httptest.NewRecorder()
+
+	buffer := &bytes.Buffer{}
+	multi := multipart.NewWriter(buffer)
+	writeFile(multi, "report.docx", string(zipArchive(map[string]string{"[Content_Types].xml": "<Types/>"})))
+	multi.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/sanitize", buffer)
+	req.Header.Add("Content-Type", multi.FormDataContentType())
+	r.ServeHTTP(respWriter, req)
+
+	resp := respWriter.Result()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected request entity too large response, but got: %v", resp.Status)
+	}
+	apiErr, err := errors.Parse(resp.Body)
+	if err != nil {
+		t.Fatalf("expected to read API error, but failed: %s", err)
+	}
+	if apiErr.Code != "AV-5020" {
+		t.Fatalf("expected AV-5020 error, but got: %s", apiErr.Code)
+	}
+}
+
+func TestScanMessage(t *testing.T) {
+	r := router.NewRouter(testutils.NewClamdMock(), slog.Default())
+	respWriter := httptest.NewRecorder()
+
+	message := "Subject: invoice\r\n" +
+		"Content-Type: multipart/mixed; boundary=b\r\n" +
+		"\r\n" +
+		"--b\r\n" +
+		"Content-Type: text/plain\r\n" +
+		"\r\n" +
+		"See attached\r\n" +
+		"--b\r\n" +
+		"Content-Type: application/octet-stream\r\n" +
+		"Content-Disposition: attachment; filename=invoice.com\r\n" +
+		"Content-Transfer-Encoding: base64\r\n" +
+		"\r\n" +
+		base64.StdEncoding.EncodeToString([]byte(testutils.EICARTest)) + "\r\n" +
+		"--b--\r\n"
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/scan", strings.NewReader(message))
+	req.Header.Add("Content-Type", "message/rfc822")
+	req.Header.Add("Content-Disposition", `attachment; filename="invoice.eml"`)
+	r.ServeHTTP(respWriter, req)
+
+	resp := respWriter.Result()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected OK response, but got: %v", resp.Status)
+	}
+
+	statuses, err := handlers.ParseScanStatuses(resp.Body)
+	if err != nil {
+		t.Fatalf("expected to read scan statuses, but failed: %s", err)
+	}
+
+	if len(statuses) != 1 {
+		t.Fatalf("expected exactly one status, but got: %d", len(statuses))
+	}
+	status := statuses[0]
+	if status.Filename != "invoice.eml" || !status.Infected {
+		t.Fatalf("expected invoice.eml to be infected, but got: %+v", status)
+	}
+	if len(status.Members) != 2 {
+		t.Fatalf("expected exactly two parts, but got: %d", len(status.Members))
+	}
+	body, attachment := status.Members[0], status.Members[1]
+	if body.MimePath != "1" || body.Infected {
+		t.Fatalf("expected body part to be clean, but got: %+v", body)
+	}
+	if attachment.MimePath != "2" || attachment.Filename != "invoice.com" || !attachment.Infected {
+		t.Fatalf("expected invoice.com attachment to be infected, but got: %+v", attachment)
+	}
+}
+
+func TestDeepScanMessageArchiveBombRejected(t *testing.T) {
+	r := router.NewRouter(testutils.NewClamdMock(), slog.Default())
+	respWriter := httptest.NewRecorder()
+
+	bomb := zipArchive(map[string]string{"zeros.txt": strings.Repeat("0", 10<<20)})
+	message := "Subject: invoice\r\n" +
+		"Content-Type: multipart/mixed; boundary=b\r\n" +
+		"\r\n" +
+		"--b\r\n" +
+		"Content-Type: application/zip\r\n" +
+		"Content-Disposition: attachment; filename=invoice.zip\r\n" +
+		"Content-Transfer-Encoding: base64\r\n" +
+		"\r\n" +
+		base64.StdEncoding.EncodeToString(bomb) + "\r\n" +
+		"--b--\r\n"
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/scan?deep=true", strings.NewReader(message))
+	req.Header.Add("Content-Type", "message/rfc822")
+	req.Header.Add("Content-Disposition", `attachment; filename="invoice.eml"`)
+	r.ServeHTTP(respWriter, req)
+
+	resp := respWriter.Result()
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 response, but got: %v", resp.Status)
+	}
+
+	apiErr, err := errors.Parse(resp.Body)
+	if err != nil {
+		t.Fatalf("expected to read apiErr, but failed: %s", err)
+	}
+
+	if apiErr.Code != "AV-5005" {
+		t.Fatalf("expected error code to be '%s', but got: %s",
+			"AV-5005", apiErr.Code)
+	}
+}
+
+func TestScanMalformedMessage(t *testing.T) {
+	r := router.NewRouter(testutils.NewClamdMock(), slog.Default())
+
+	cases := map[string]struct {
+		content string
+		deep    bool
+		members int
+	}{
+		"opaque":  {testutils.EICARTest, false, 0},
+		"archive": {string(zipArchive(map[string]string{"virus.txt": testutils.EICARTest})), true, 1},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			respWriter := httptest.NewRecorder()
+			buffer := &bytes.Buffer{}
+			multi := multipart.NewWriter(buffer)
+			writeFile(multi, "mail.eml", c.content)
+			multi.Close()
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/scan?deep="+strconv.FormatBool(c.deep), buffer)
+			req.Header.Add("Content-Type", multi.FormDataContentType())
+			r.ServeHTTP(respWriter, req)
+
+			resp := respWriter.Result()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("expected OK response, but got: %v", resp.Status)
+			}
+			statuses, err := handlers.ParseScanStatuses(resp.Body)
+			if err != nil {
+				t.Fatalf("expected to read scan statuses, but failed: %s", err)
+			}
+			if len(statuses) != 1 || !statuses[0].Infected || len(statuses[0].Members) != c.members {
+				t.Fatalf("expected mail.eml to be scanned as infected file with %d members, but got: %+v", c.members, statuses)
+			}
+		})
+	}
+}
+
+func TestScanTransferEncodedAndNestedParts(t *testing.T) {
+	r := router.NewRouter(testutils.NewClamdMock(), slog.Default())
+	respWriter := httptest.NewRecorder()
+
+	nested := &bytes.Buffer{}
+	nestedMulti := multipart.NewWriter(nested)
+	w, _ := nestedMulti.CreatePart(textproto.MIMEHeader{
+		"Content-Disposition":       {`file; filename="nested.txt"`},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	w.Write([]byte("clean =3D content"))
+	nestedMulti.Close()
+
+	buffer := &bytes.Buffer{}
+	multi := multipart.NewWriter(buffer)
+	w, _ = multi.CreatePart(textproto.MIMEHeader{
+		"Content-Disposition":       {`form-data; name="file1"; filename="encoded.txt"`},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	w.Write([]byte(base64.StdEncoding.EncodeToString([]byte(testutils.EICARTest))))
+	w, _ = multi.CreatePart(textproto.MIMEHeader{
+		"Content-Disposition": {`form-data; name="files"`},
+		"Content-Type":        {"multipart/mixed; boundary=" + nestedMulti.Boundary()},
+	})
+	w.Write(nested.Bytes())
+	multi.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/scan", buffer)
+	req.Header.Add("Content-Type", multi.FormDataContentType())
+	r.ServeHTTP(respWriter, req)
+
+	resp := respWriter.Result()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected OK response, but got: %v", resp.Status)
+	}
+
+	statuses, err := handlers.ParseScanStatuses(resp.Body)
+	if err != nil {
+		t.Fatalf("expected to read scan statuses, but failed: %s", err)
+	}
+
+	if len(statuses) != 2 {
+		t.Fatalf("expected exactly two statuses, but got: %d", len(statuses))
+	}
+	encoded, nestedStatus := statuses[0], statuses[1]
+	if !encoded.Infected || !encoded.Decoded || encoded.TransferEncoding != "base64" {
+		t.Fatalf("expected decoded encoded.txt to be infected, but got: %+v", encoded)
+	}
+	if nestedStatus.Filename != "nested.txt" || nestedStatus.Infected {
+		t.Fatalf("expected nested.txt to be clean, but got: %+v", nestedStatus)
+	}
+	if !nestedStatus.Decoded || nestedStatus.TransferEncoding != "quoted-printable" {
+		t.Fatalf("expected nested.txt to be decoded, but got: %+v", nestedStatus)
+	}
+}
+
+func TestScanCompressedBody(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	multi := multipart.NewWriter(buffer)
+	writeFile(multi, "file1", testutils.EICARTest)
+	multi.Close()
+
+	compress := map[string]func(w io.Writer) io.WriteCloser{
+		"gzip": func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) },
+		"deflate": func(w io.Writer) io.WriteCloser {
+			zw, _ := flate.NewWriter(w, flate.DefaultCompression)
+			return zw
+		},
+		"br": func(w io.Writer) io.WriteCloser { return brotli.NewWriter(w) },
+		"zstd": func(w io.Writer) io.WriteCloser {
+			zw, _ := zstd.NewWriter(w)
+			return zw
+		},
+	}
+	for encoding, newWriter := range compress {
+		t.Run(encoding, func(t *testing.T) {
+			r := router.NewRouter(testutils.NewClamdMock(), slog.Default())
+			respWriter := httptest.NewRecorder()
+
+			compressed := &bytes.Buffer{}
+			w := newWriter(compressed)
+			w.Write(buffer.Bytes())
+			w.Close()
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/scan", compressed)
+			req.Header.Add("Content-Type", multi.FormDataContentType())
+			req.Header.Add("Content-Encoding", encoding)
+			r.ServeHTTP(respWriter, req)
+
+			resp := respWriter.Result()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("expected OK response, but got: %v", resp.Status)
+			}
+
+			statuses, err := handlers.ParseScanStatuses(resp.Body)
+			if err != nil {
+				t.Fatalf("expected to read scan statuses, but failed: %s", err)
+			}
+			if len(statuses) != 1 || !statuses[0].Infected {
+				t.Fatalf("expected decompressed file to be infected, but got: %+v", statuses)
+			}
+		})
+	}
+}
+
+func TestScanCompressedBodyLimit(t *testing.T) {
+	r := router.NewRouter(testutils.NewClamdMock(), slog.Default(), router.WithMaxDecodedBodySize(1024))
+	respWriter := httptest.NewRecorder()
+
+	buffer := &bytes.Buffer{}
+	multi := multipart.NewWriter(buffer)
+	writeFile(multi, "zeros.bin", strings.Repeat("\x00", 1<<20))
+	multi.Close()
+
+	compressed := &bytes.Buffer{}
+	gw := gzip.NewWriter(compressed)
+	gw.Write(buffer.Bytes())
+	gw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/scan", compressed)
+	req.Header.Add("Content-Type", multi.FormDataContentType())
+	req.Header.Add("Content-Encoding", "gzip")
+	r.ServeHTTP(respWriter, req)
+
+	resp := respWriter.Result()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected request entity too large response, but got: %v", resp.Status)
+	}
+
+	apiErr, err := errors.Parse(resp.Body)
+	if err != nil {
+		t.Fatalf("expected to read API error, but failed: %s", err)
+	}
+	if apiErr.Code != "AV-5011" {
+		t.Fatalf("expected AV-5011 error, but got: %s", apiErr.Code)
+	}
+}
+
+func TestScanFileSizeLimit(t *testing.T) {
+	r := router.NewRouter(testutils.NewClamdMock(), slog.Default(), router.WithMaxFileSize(16))
+
+	cases := map[string]struct {
+		content string
+		status  int
+	}{
+		"within limit":  {strings.Repeat("a", 16), http.StatusOK},
+		"exceeds limit": {strings.Repeat("a", 17), http.StatusRequestEntityTooLarge},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			respWriter := httptest.NewRecorder()
+			buffer := &bytes.Buffer{}
+			multi := multipart.NewWriter(buffer)
+			writeFile(multi, "a.txt", c.content)
+			multi.Close()
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/scan", buffer)
+			req.Header.Add("Content-Type", multi.FormDataContentType())
+			r.ServeHTTP(respWriter, req)
+
+			resp := respWriter.Result()
+			if resp.StatusCode != c.status {
+				t.Fatalf("expected %d response, but got: %v", c.status, resp.Status)
+			}
+			if c.status == http.StatusOK {
+				return
+			}
+			apiErr, err := errors.Parse(resp.Body)
+			if err != nil {
+				t.Fatalf("expected to read API error, but failed: %s", err)
+			}
+			if apiErr.Code != "AV-5020" {
+				t.Fatalf("expected AV-5020 error, but got: %s", apiErr.Code)
+			}
+		})
+	}
+}
+
+func TestScanUnsupportedContentEncoding(t *testing.T) {
+	r := router.NewRouter(testutils.NewClamdMock(), slog.Default())
+	respWriter := httptest.NewRecorder()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/scan", strings.NewReader("data"))
+	req.Header.Add("Content-Type", "multipart/form-data; boundary=b")
+	req.Header.Add("Content-Encoding", "compress")
+	r.ServeHTTP(respWriter, req)
+
+	resp := respWriter.Result()
+	if resp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected unsupported media type response, but got: %v", resp.Status)
+	}
+}
+
+func TestScanImage(t *testing.T) {
+	r := router.NewRouter(testutils.NewClamdMock(), slog.Default())
+	respWriter := httptest.NewRecorder()
+
+	base := tarArchive(map[string]string{"bin/sh": "shell", "tmp/virus": testutils.EICARTest})
+	top := tarArchive(map[string]string{"tmp/.wh.virus": ""})
+	img := tarGzArchive(map[string]string{
+		"manifest.json":     `[{"Layers":["blobs/sha256/base","blobs/sha256/top"]}]`,
+		"blobs/sha256/base": string(base),
+		"blobs/sha256/top":  string(top),
+	})
+
+	buffer := &bytes.Buffer{}
+	multi := multipart.NewWriter(buffer)
+	writeFile(multi, "image.tar.gz", string(img))
+	multi.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/scan/image", buffer)
+	req.Header.Add("Content-Type", multi.FormDataContentType())
+	r.ServeHTTP(respWriter, req)
+
+	resp := respWriter.Result()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected OK response, but got: %v", resp.Status)
+	}
+
+	var results []*handlers.ImageScanResult
+	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+		t.Fatalf("expected to read image scan results, but failed: %s", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("expected exactly one result, but got: %d", len(results))
+	}
+
+	result := results[0]
+	if !result.Infected || result.Verdict != handlers.VerdictInfected {
+		t.Fatalf("expected image to be infected, but got: %+v", result)
+	}
+	if result.Stats.Layers != 2 || result.Stats.Files != 2 || result.Stats.Infected != 1 {
+		t.Fatalf("unexpected image stats: %+v", result.Stats)
+	}
+	if len(result.Detections) != 1 {
+		t.Fatalf("expected exactly one detection, but got: %d", len(result.Detections))
+	}
+	detection := result.Detections[0]
+	if detection.Layer != "sha256:base" || detection.Path != "tmp/virus" || !detection.Hidden {
+		t.Fatalf("expected hidden tmp/virus in base layer, but got: %+v", detection)
+	}
+}
+
+func TestScanImageLimits(t *testing.T) {
+	// compressed layer expands to files much larger than image tarball
+	layer := tarGzArchive(map[string]string{"bin/sh": "shell", "bin/zeros": strings.Repeat("\x00", 1<<16)})
+	img := tarGzArchive(map[string]string{
+		"manifest.json":     `[{"Layers":["blobs/sha256/base"]}]`,
+		"blobs/sha256/base": string(layer),
+	})
+
+	for name, limits := range map[string]archive.Limits{
+		"members":            {MaxDepth: 1, MaxMembers: 1, MaxSize: 1 << 20},
+		"decompressed image": {MaxDepth: 1, MaxMembers: 10, MaxSize: 1024},
+		"layer files":        {MaxDepth: 1, MaxMembers: 10, MaxSize: 1 << 14},
+	} {
+		t.Run(name, func(t *testing.T) {
+			r := router.NewRouter(testutils.NewClamdMock(), slog.Default(), router.WithImageLimits(limits))
+			respWriter := httptest.NewRecorder()
+
+			buffer := &bytes.Buffer{}
+			multi := multipart.NewWriter(buffer)
+			writeFile(multi, "image.tar.gz", string(img))
+			multi.Close()
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/scan/image", buffer)
+			req.Header.Add("Content-Type", multi.FormDataContentType())
+			r.ServeHTTP(respWriter, req)
+
+			resp := respWriter.Result()
+			if resp.StatusCode != http.StatusRequestEntityTooLarge {
+				t.Fatalf("expected request entity too large response, but got: %v", resp.Status)
+			}
+			apiErr, err := errors.Parse(resp.Body)
+			if err != nil {
+				t.Fatalf("expected to read API error, but failed: %s", err)
+			}
+			if apiErr.Code != "AV-5002" {
+				t.Fatalf("expected AV-5002 error, but got: %s", apiErr.Code)
+			}
+		})
+	}
+}
+
+func TestScanImageOverArchiveLimits(t *testing.T) {
+	r := router.NewRouter(testutils.NewClamdMock(), slog.Default())
+	respWriter := httptest.NewRecorder()
+
+	// layer exceeds default archive limits both in number of files and in their total size,
+	// it is written directly to gzip to keep compressed layer small in memory
+	archiveLimits := archive.DefaultLimits()
+	files := archiveLimits.MaxMembers + 1
+	layer := &bytes.Buffer{}
+	gw := gzip.NewWriter(layer)
+	tw := tar.NewWriter(gw)
+	for i := range files - 1 {
+		content := "file " + strconv.Itoa(i)
+		if err := tw.WriteHeader(&tar.Header{Name: "usr/share/" + strconv.Itoa(i), Mode: 0600, Size: int64(len(content))}); err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(tw, content)
+	}
+	size := archiveLimits.MaxSize + 1
+	if err := tw.WriteHeader(&tar.Header{Name: "usr/lib/data", Mode: 0600, Size: size}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.Copy(tw, io.LimitReader(zeroReader{}, size)); err != nil {
+		t.Fatal(err)
+	}
+	tw.Close()
+	gw.Close()
+
+	img := tarArchive(map[string]string{
+		"manifest.json":     `[{"Layers":["blobs/sha256/base"]}]`,
+		"blobs/sha256/base": layer.String(),
+	})
+
+	buffer := &bytes.Buffer{}
+	multi := multipart.NewWriter(buffer)
+	writeFile(multi, "image.tar", string(img))
+	multi.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/scan/image", buffer)
+	req.Header.Add("Content-Type", multi.FormDataContentType())
+	r.ServeHTTP(respWriter, req)
+
+	resp := respWriter.Result()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected OK response, but got: %v", resp.Status)
+	}
+
+	var results []*handlers.ImageScanResult
+	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+		t.Fatalf("expected to read image scan results, but failed: %s", err)
+	}
+	if len(results) != 1 || results[0].Verdict != handlers.VerdictClean {
+		t.Fatalf("expected image to be clean, but got: %+v", results)
+	}
+	if results[0].Stats.Files != files || results[0].Stats.Size <= archiveLimits.MaxSize {
+		t.Fatalf("expected all %d files to be scanned, but got: %+v", files, results[0].Stats)
+	}
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+func TestScanURL(t *testing.T) {
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/files/virus.txt", http.StatusFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("ETag", `"eicar"`)
+		io.WriteString(w, testutils.EICARTest)
+	}))
+	defer remote.Close()
+
+	opts := fetch.DefaultOptions()
+	opts.AllowedNetworks = []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")}
+	r := router.NewRouter(testutils.NewClamdMock(), slog.Default(), router.WithFetchOptions(opts))
+	respWriter := httptest.NewRecorder()
+
+	body := `{"url":"` + remote.URL + `/redirect"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/scan/url", strings.NewReader(body))
+	req.Header.Add("Content-Type", "application/json")
+	r.ServeHTTP(respWriter, req)
+
+	resp := respWriter.Result()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected OK response, but got: %v", resp.Status)
+	}
+
+	var status handlers.ScanStatus
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		t.Fatalf("expected to read scan status, but failed: %s", err)
+	}
+	if !status.Infected || status.Filename != "virus.txt" {
+		t.Fatalf("expected virus.txt to be infected, but got: %+v", status)
+	}
+	if status.Remote == nil || status.Remote.ResolvedURL != remote.URL+"/files/virus.txt" {
+		t.Fatalf("expected resolved URL to be set, but got: %+v", status.Remote)
+	}
+	if status.Remote.ETag != `"eicar"` || status.Remote.ContentLength != int64(len(testutils.EICARTest)) {
+		t.Fatalf("unexpected remote metadata: %+v", status.Remote)
+	}
+}
+
+func TestScanURLDisabled(t *testing.T) {
+	r := router.NewRouter(testutils.NewClamdMock(), slog.Default())
+	respWriter := httptest.NewRecorder()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/scan/url", strings.NewReader(`{"url":"https://example.com"}`))
+	req.Header.Add("Content-Type", "application/json")
+	r.ServeHTTP(respWriter, req)
+
+	if resp := respWriter.Result(); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected not found response, but got: %v", resp.Status)
+	}
+}
+
+func TestScanURLPrivateAddressForbidden(t *testing.T) {
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("expected private address not to be requested")
+	}))
+	defer remote.Close()
+
+	r := router.NewRouter(testutils.NewClamdMock(), slog.Default(), router.WithFetchOptions(fetch.DefaultOptions()))
+	respWriter := httptest.NewRecorder()
+
+	body := `{"url":"` + remote.URL + `"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/scan/url", strings.NewReader(body))
+	req.Header.Add("Content-Type", "application/json")
+	r.ServeHTTP(respWriter, req)
+
+	resp := respWriter.Result()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected forbidden response, but got: %v", resp.Status)
+	}
+	apiErr, err := errors.Parse(resp.Body)
+	if err != nil {
+		t.Fatalf("expected to read apiErr, but failed: %s", err)
+	}
+	if apiErr.Code != "AV-5013" {
+		t.Fatalf("expected AV-5013 error, but got: %s", apiErr.Code)
+	}
+}
+
+func TestScanURLContentTooLarge(t *testing.T) {
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// chunked response without Content-Length
+		w.(http.Flusher).Flush()
+		io.WriteString(w, strings.Repeat("a", 1024))
+	}))
+	defer remote.Close()
+
+	opts := fetch.DefaultOptions()
+	opts.MaxSize = 100
+	opts.AllowedNetworks = []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")}
+	r := router.NewRouter(testutils.NewClamdMock(), slog.Default(), router.WithFetchOptions(opts))
+	respWriter := httptest.NewRecorder()
+
+	body := `{"url":"` + remote.URL + `/file.bin"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/scan/url", strings.NewReader(body))
+	req.Header.Add("Content-Type", "application/json")
+	r.ServeHTTP(respWriter, req)
+
+	resp := respWriter.Result()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected request entity too large response, but got: %v", resp.Status)
+	}
+}
+
+func TestScanS3Prefix(t *testing.T) {
+	storage := testutils.NewS3Mock().
+		Put("uploads", "incoming/a.txt", "clean content").
+		Put("uploads", "incoming/b.txt", testutils.EICARTest).
+		Put("uploads", "other/c.txt", testutils.EICARTest)
+	defer storage.Close()
+
+	client, err := s3.New(s3.Config{Endpoint: storage.URL})
+	if err != nil {
+		t.Fatalf("expected s3 client to be created, but failed: %s", err)
+	}
+	r := router.NewRouter(testutils.NewClamdMock(), slog.Default(), router.WithObjectStorage(client, 10))
+	respWriter := httptest.NewRecorder()
+
+	body := `{"bucket":"uploads","prefix":"incoming/","tag":true}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/scan/s3", strings.NewReader(body))
+	req.Header.Add("Content-Type", "application/json")
+	r.ServeHTTP(respWriter, req)
+
+	resp := respWriter.Result()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected OK response, but got: %v", resp.Status)
+	}
+
+	var results []*handlers.ObjectScanResult
+	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+		t.Fatalf("expected to read object scan results, but failed: %s", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected exactly two results, but got: %d", len(results))
+	}
+	if results[0].Key != "incoming/a.txt" || results[0].Status.Infected || !results[0].Tagged {
+		t.Fatalf("expected incoming/a.txt to be clean and tagged, but got: %+v", results[0])
+	}
+	if results[1].Key != "incoming/b.txt" || !results[1].Status.Infected || !results[1].Tagged {
+		t.Fatalf("expected incoming/b.txt to be infected and tagged, but got: %+v", results[1])
+	}
+
+	if tags := storage.Tags("uploads", "incoming/a.txt"); tags[handlers.VerdictTag] != "clean" {
+		t.Fatalf("expected clean verdict tag, but got: %v", tags)
+	}
+	tags := storage.Tags("uploads", "incoming/b.txt")
+	if tags[handlers.VerdictTag] != "infected" || tags[handlers.VirusTag] == "" {
+		t.Fatalf("expected infected verdict and virus tags, but got: %v", tags)
+	}
+	if tags := storage.Tags("uploads", "other/c.txt"); len(tags) != 0 {
+		t.Fatalf("expected object outside of prefix not to be tagged, but got: %v", tags)
+	}
+}
+
+func TestScanS3ObjectSizeLimit(t *testing.T) {
+	storage := testutils.NewS3Mock().
+		Put("uploads", "a.txt", "clean").
+		Put("uploads", "b.bin", strings.Repeat("a", 17))
+	defer storage.Close()
+
+	client, err := s3.New(s3.Config{Endpoint: storage.URL})
+	if err != nil {
+		t.Fatalf("expected s3 client to be created, but failed: %s", err)
+	}
+	r := router.NewRouter(testutils.NewClamdMock(), slog.Default(), router.WithObjectStorage(client, 10), router.WithMaxFileSize(16))
+	respWriter := httptest.NewRecorder()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/scan/s3", strings.NewReader(`{"bucket":"uploads","tag":true}`))
+	req.Header.Add("Content-Type", "application/json")
+	r.ServeHTTP(respWriter, req)
+
+	resp := respWriter.Result()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected OK response, but got: %v", resp.Status)
+	}
+	var results []*handlers.ObjectScanResult
+	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+		t.Fatalf("expected to read object scan results, but failed: %s", err)
+	}
+	if len(results) != 2 || results[0].Status == nil || results[0].Error != nil {
+		t.Fatalf("expected a.txt to be scanned, but got: %+v", results)
+	}
+	if results[1].Status != nil || results[1].Tagged || results[1].Error == nil || results[1].Error.Code != "AV-5020" {
+		t.Fatalf("expected b.bin not to be scanned with AV-5020 error, but got: %+v", results[1])
+	}
+	if tags := storage.Tags("uploads", "b.bin"); len(tags) != 0 {
+		t.Fatalf("expected not scanned object not to be tagged, but got: %v", tags)
+	}
+}
+
+func TestScanS3Key(t *testing.T) {
+	storage := testutils.NewS3Mock().Put("uploads", "a.txt", testutils.EICARTest)
+	defer storage.Close()
+
+	client, err := s3.New(s3.Config{Endpoint: storage.URL})
+	if err != nil {
+		t.Fatalf("expected s3 client to be created, but failed: %s", err)
+	}
+	r := router.NewRouter(testutils.NewClamdMock(), slog.Default(), router.WithObjectStorage(client, 10))
+
+	cases := map[string]struct {
+		body   string
+		status int
+		code   string
+	}{
+		"existing":  {`{"bucket":"uploads","key":"a.txt"}`, http.StatusOK, ""},
+		"missing":   {`{"bucket":"uploads","key":"b.txt"}`, http.StatusBadGateway, "AV-5016"},
+		"ambiguous": {`{"bucket":"uploads","key":"a.txt","prefix":"a"}`, http.StatusBadRequest, "AV-5004"},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			respWriter := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/scan/s3", strings.NewReader(c.body))
+			req.Header.Add("Content-Type", "application/json")
+			r.ServeHTTP(respWriter, req)
+
+			resp := respWriter.Result()
+			if resp.StatusCode != c.status {
+				t.Fatalf("expected %d response, but got: %v", c.status, resp.Status)
+			}
+			if c.code == "" {
+				var results []*handlers.ObjectScanResult
+				if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+					t.Fatalf("expected to read object scan results, but failed: %s", err)
+				}
+				if len(results) != 1 || !results[0].Status.Infected || results[0].Tagged {
+					t.Fatalf("expected single infected untagged result, but got: %+v", results)
+				}
+				return
+			}
+			apiErr, err := errors.Parse(resp.Body)
+			if err != nil {
+				t.Fatalf("expected to read apiErr, but failed: %s", err)
+			}
+			if apiErr.Code != c.code {
+				t.Fatalf("expected %s error, but got: %s", c.code, apiErr.Code)
+			}
+		})
+	}
+}
+
+func TestScanS3ObjectsLimit(t *testing.T) {
+	storage := testutils.NewS3Mock().
+		Put("uploads", "a.txt", "a").
+		Put("uploads", "b.txt", "b").
+		Put("uploads", "c.txt", "c")
+	defer storage.Close()
+
+	client, err := s3.New(s3.Config{Endpoint: storage.URL})
+	if err != nil {
+		t.Fatalf("expected s3 client to be created, but failed: %s", err)
+	}
+	r := router.NewRouter(testutils.NewClamdMock(), slog.Default(), router.WithObjectStorage(client, 2))
+	respWriter := httptest.NewRecorder()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/scan/s3", strings.NewReader(`{"bucket":"uploads"}`))
+	req.Header.Add("Content-Type", "application/json")
+	r.ServeHTTP(respWriter, req)
+
+	resp := respWriter.Result()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected request entity too large response, but got: %v", resp.Status)
+	}
+}
+
+func TestScanReportFormats(t *testing.T) {
+	r := router.NewRouter(testutils.NewClamdMock(), slog.Default())
+	for accept, expected := range map[string]string{
+		"application/sarif+json": `"sha-256": "275a021bbfb6489e54d471899f7db9d1663fc695ec2fe2a2c4538aabf651fd0f"`,
+		"application/junit+xml":  `(sha256: 275a021bbfb6489e54d471899f7db9d1663fc695ec2fe2a2c4538aabf651fd0f)</failure>`,
+	} {
+		buffer := &bytes.Buffer{}
+		multi := multipart.NewWriter(buffer)
+		writeFile(multi, "clean.txt", "safe content")
+		writeFile(multi, "virus.txt", testutils.EICARTest)
+		multi.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/scan", buffer)
+		req.Header.Add("Content-Type", multi.FormDataContentType())
+		req.Header.Add("Accept", accept)
+		respWriter := httptest.NewRecorder()
+		r.ServeHTTP(respWriter, req)
+
+		resp := respWriter.Result()
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != accept {
+			t.Fatalf("expected OK response with %s content type, but got: %v %s", accept, resp.Status, resp.Header.Get("Content-Type"))
+		}
+		if body := respWriter.Body.String(); !strings.Contains(body, expected) {
+			t.Fatalf("expected %s report to contain %s, but got:\n%s", accept, expected, body)
+		}
 	}
 }
@@ -0,0 +1,93 @@
+package router
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/netcracker/qubership-av-scan-service/pkg/errors"
+	"github.com/netcracker/qubership-av-scan-service/pkg/handlers"
+	"github.com/netcracker/qubership-av-scan-service/pkg/log"
+	"github.com/netcracker/qubership-av-scan-service/pkg/upload"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// uploadsPath is the path of tus upload creation endpoint, uploads are available under it by their IDs
+const uploadsPath = "/api/v1/uploads"
+
+// Supported version and extensions of tus resumable upload protocol, see https://tus.io/protocols/resumable-upload
+const (
+	tusVersion    = "1.0.0"
+	tusExtensions = "creation,expiration,termination"
+)
+
+// newUploadHandler serves tus resumable uploads with all methods of the protocol under uploadsPath
+func newUploadHandler(uploads *handlers.UploadHandler, registry *prometheus.Registry) http.Handler {
+	m := http.NewServeMux()
+	m.HandleFunc("OPTIONS "+uploadsPath, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Tus-Version", tusVersion)
+		w.Header().Set("Tus-Extension", tusExtensions)
+		w.Header().Set("Tus-Max-Size", strconv.FormatInt(uploads.MaxSize(), 10))
+		w.WriteHeader(http.StatusNoContent)
+	})
+	m.HandleFunc("POST "+uploadsPath, func(w http.ResponseWriter, r *http.Request) {
+		info, err := uploads.Create(r)
+		if err != nil {
+			handleError(w, log.From(r), err)
+			return
+		}
+		w.Header().Set("Location", uploadsPath+"/"+info.ID)
+		writeUploadHeaders(w, info)
+		w.WriteHeader(http.StatusCreated)
+	})
+	m.HandleFunc("HEAD "+uploadsPath+"/{id}", func(w http.ResponseWriter, r *http.Request) {
+		info, err := uploads.Get(r)
+		if err != nil {
+			handleError(w, log.From(r), err)
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Upload-Length", strconv.FormatInt(info.Length, 10))
+		if len(info.Metadata) > 0 {
+			w.Header().Set("Upload-Metadata", handlers.FormatUploadMetadata(info.Metadata))
+		}
+		writeUploadHeaders(w, info)
+		w.WriteHeader(http.StatusOK)
+	})
+	m.HandleFunc("PATCH "+uploadsPath+"/{id}", func(w http.ResponseWriter, r *http.Request) {
+		info, err := uploads.Write(r)
+		if err != nil {
+			handleError(w, log.From(r), err)
+			return
+		}
+		writeUploadHeaders(w, info)
+		w.WriteHeader(http.StatusNoContent)
+	})
+	m.HandleFunc("DELETE "+uploadsPath+"/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if err := uploads.Delete(r); err != nil {
+			handleError(w, log.From(r), err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return metricsMiddleware(panicRecoveryMiddleware(tusMiddleware(m)), registry, "upload")
+}
+
+// tusMiddleware sets protocol version in all responses and rejects requests of unsupported protocol versions.
+// OPTIONS requests are used for protocol discovery, so they are accepted without version.
+func tusMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Tus-Resumable", tusVersion)
+		if version := r.Header.Get("Tus-Resumable"); r.Method != http.MethodOptions && version != tusVersion {
+			w.Header().Set("Tus-Version", tusVersion)
+			handleError(w, log.From(r), errors.TusVersionUnsupportedError(version))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writeUploadHeaders sets current offset and expiration time of the upload
+func writeUploadHeaders(w http.ResponseWriter, info *upload.Info) {
+	w.Header().Set("Upload-Offset", strconv.FormatInt(info.Offset, 10))
+	w.Header().Set("Upload-Expires", info.ExpiresAt.Format(http.TimeFormat))
+}
@@ -0,0 +1,226 @@
+package router_test
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/netcracker/qubership-av-scan-service/pkg/errors"
+	"github.com/netcracker/qubership-av-scan-service/pkg/handlers"
+	"github.com/netcracker/qubership-av-scan-service/pkg/router"
+	"github.com/netcracker/qubership-av-scan-service/pkg/testutils"
+	"github.com/netcracker/qubership-av-scan-service/pkg/upload"
+)
+
+func newUploadRouter(t *testing.T, maxSize int64) http.Handler {
+	t.Helper()
+	store, err := upload.New(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("failed to create upload store: %s", err)
+	}
+	return router.NewRouter(testutils.NewClamdMock(), nil, router.WithUploadStore(store, maxSize))
+}
+
+func tusRequest(method string, target string, body string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Tus-Resumable", "1.0.0")
+	if method == http.MethodPatch {
+		req.Header.Set("Content-Type", "application/offset+octet-stream")
+	}
+	return req
+}
+
+func serve(r http.Handler, req *http.Request) *http.Response {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Result()
+}
+
+// createUpload creates upload of given length with filename metadata and returns its location
+func createUpload(t *testing.T, r http.Handler, filename string, length int) string {
+	t.Helper()
+	req := tusRequest(http.MethodPost, "/api/v1/uploads", "")
+	req.Header.Set("Upload-Length", strconv.Itoa(length))
+	req.Header.Set("Upload-Metadata", "filename "+base64.StdEncoding.EncodeToString([]byte(filename))+",deep")
+	resp := serve(r, req)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201 status, but got: %v", resp.Status)
+	}
+	location := resp.Header.Get("Location")
+	if !strings.HasPrefix(location, "/api/v1/uploads/") || resp.Header.Get("Upload-Expires") == "" {
+		t.Fatalf("unexpected creation response headers: %v", resp.Header)
+	}
+	return location
+}
+
+func patchUpload(t *testing.T, r http.Handler, location string, offset int, chunk string) *http.Response {
+	t.Helper()
+	req := tusRequest(http.MethodPatch, location, chunk)
+	req.Header.Set("Upload-Offset", strconv.Itoa(offset))
+	return serve(r, req)
+}
+
+func headUpload(t *testing.T, r http.Handler, location string) (int, map[string]string) {
+	t.Helper()
+	resp := serve(r, tusRequest(http.MethodHead, location, ""))
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 status, but got: %v", resp.Status)
+	}
+	offset, err := strconv.Atoi(resp.Header.Get("Upload-Offset"))
+	if err != nil {
+		t.Fatalf("invalid Upload-Offset: %s", err)
+	}
+	metadata, err := handlers.ParseUploadMetadata(resp.Header.Get("Upload-Metadata"))
+	if err != nil {
+		t.Fatalf("invalid Upload-Metadata: %s", err)
+	}
+	return offset, metadata
+}
+
+func TestUploadOptions(t *testing.T) {
+	resp := serve(newUploadRouter(t, 100), httptest.NewRequest(http.MethodOptions, "/api/v1/uploads", nil))
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected 204 status, but got: %v", resp.Status)
+	}
+	if resp.Header.Get("Tus-Version") != "1.0.0" || resp.Header.Get("Tus-Max-Size") != "100" ||
+		!strings.Contains(resp.Header.Get("Tus-Extension"), "creation") {
+		t.Fatalf("unexpected discovery headers: %v", resp.Header)
+	}
+}
+
+func TestUploadResumed(t *testing.T) {
+	r := newUploadRouter(t, 0)
+	content := "clean content"
+	location := createUpload(t, r, "clean.txt", len(content))
+
+	if resp := patchUpload(t, r, location, 0, content[:6]); resp.StatusCode != http.StatusNoContent || resp.Header.Get("Upload-Offset") != "6" {
+		t.Fatalf("expected 204 status with new offset, but got: %v %v", resp.Status, resp.Header)
+	}
+	// client lost the response and resumes from the offset reported by server
+	if offset, metadata := headUpload(t, r, location); offset != 6 || metadata[handlers.VerdictTag] != "" {
+		t.Fatalf("unexpected incomplete upload offset %d and metadata %v", offset, metadata)
+	}
+	resp := patchUpload(t, r, location, 0, content)
+	if resp.StatusCode != http.StatusConflict {
+		t.Fatalf("expected 409 status, but got: %v", resp.Status)
+	}
+	if apiErr, _ := errors.Parse(resp.Body); apiErr == nil || apiErr.Code != "AV-5023" {
+		t.Fatalf("expected AV-5023 error, but got: %v", apiErr)
+	}
+	if resp := patchUpload(t, r, location, 6, content[6:]); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected 204 status, but got: %v", resp.Status)
+	}
+
+	offset, metadata := headUpload(t, r, location)
+	if offset != len(content) || metadata[handlers.VerdictTag] != "clean" || metadata["filename"] != "clean.txt" {
+		t.Fatalf("unexpected complete upload offset %d and metadata %v", offset, metadata)
+	}
+}
+
+func TestUploadInfected(t *testing.T) {
+	r := newUploadRouter(t, 0)
+	eicar := testutils.EICARTest
+	location := createUpload(t, r, "virus.com", len(eicar))
+	patchUpload(t, r, location, 0, eicar[:30])
+	patchUpload(t, r, location, 30, eicar[30:])
+
+	_, metadata := headUpload(t, r, location)
+	if metadata[handlers.VerdictTag] != "infected" || metadata[handlers.VirusTag] == "" {
+		t.Fatalf("expected infected verdict in metadata, but got %v", metadata)
+	}
+}
+
+func TestUploadVerdictNotAcceptedFromClient(t *testing.T) {
+	r := newUploadRouter(t, 0)
+	req := tusRequest(http.MethodPost, "/api/v1/uploads", "")
+	req.Header.Set("Upload-Length", "10")
+	req.Header.Set("Upload-Metadata", "av-verdict "+base64.StdEncoding.EncodeToString([]byte("clean")))
+	resp := serve(r, req)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201 status, but got: %v", resp.Status)
+	}
+	if _, metadata := headUpload(t, r, resp.Header.Get("Location")); len(metadata) != 0 {
+		t.Fatalf("expected empty metadata, but got %v", metadata)
+	}
+}
+
+func TestUploadLimits(t *testing.T) {
+	r := newUploadRouter(t, 10)
+
+	req := tusRequest(http.MethodPost, "/api/v1/uploads", "")
+	req.Header.Set("Upload-Length", "11")
+	if resp := serve(r, req); resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413 status, but got: %v", resp.Status)
+	}
+
+	location := createUpload(t, r, "a.txt", 5)
+	if resp := patchUpload(t, r, location, 0, "more than five"); resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413 status, but got: %v", resp.Status)
+	}
+}
+
+func TestUploadLimitedByMaxFileSize(t *testing.T) {
+	store, err := upload.New(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("failed to create upload store: %s", err)
+	}
+	r := router.NewRouter(testutils.NewClamdMock(), nil, router.WithUploadStore(store, 100), router.WithMaxFileSize(10))
+
+	resp := serve(r, httptest.NewRequest(http.MethodOptions, "/api/v1/uploads", nil))
+	if resp.Header.Get("Tus-Max-Size") != "10" {
+		t.Fatalf("expected upload size to be capped by max file size, but got: %s", resp.Header.Get("Tus-Max-Size"))
+	}
+
+	req := tusRequest(http.MethodPost, "/api/v1/uploads", "")
+	req.Header.Set("Upload-Length", "11")
+	resp = serve(r, req)
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413 status, but got: %v", resp.Status)
+	}
+	if apiErr, err := errors.Parse(resp.Body); err != nil || apiErr.Code != "AV-5020" {
+		t.Fatalf("expected AV-5020 error, but got: %+v, %v", apiErr, err)
+	}
+}
+
+func TestUploadProtocolErrors(t *testing.T) {
+	r := newUploadRouter(t, 0)
+	location := createUpload(t, r, "a.txt", 5)
+
+	req := httptest.NewRequest(http.MethodHead, location, nil)
+	if resp := serve(r, req); resp.StatusCode != http.StatusPreconditionFailed || resp.Header.Get("Tus-Version") != "1.0.0" {
+		t.Fatalf("expected 412 status with supported version, but got: %v", resp.Status)
+	}
+
+	req = tusRequest(http.MethodPatch, location, "12345")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Upload-Offset", "0")
+	if resp := serve(r, req); resp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected 415 status, but got: %v", resp.Status)
+	}
+
+	if resp := serve(r, tusRequest(http.MethodHead, "/api/v1/uploads/unknown", "")); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 status, but got: %v", resp.Status)
+	}
+}
+
+func TestUploadTerminated(t *testing.T) {
+	r := newUploadRouter(t, 0)
+	location := createUpload(t, r, "a.txt", 5)
+
+	if resp := serve(r, tusRequest(http.MethodDelete, location, "")); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected 204 status, but got: %v", resp.Status)
+	}
+	if resp := patchUpload(t, r, location, 0, "12345"); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 status, but got: %v", resp.Status)
+	}
+}
+
+func TestUploadsDisabled(t *testing.T) {
+	r := router.NewRouter(testutils.NewClamdMock(), nil)
+	if resp := serve(r, tusRequest(http.MethodPost, "/api/v1/uploads", "")); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 status, but got: %v", resp.Status)
+	}
+}
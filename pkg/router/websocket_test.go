@@ -0,0 +1,145 @@
+package router_test
+
+import (
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/netcracker/qubership-av-scan-service/pkg/handlers"
+	"github.com/netcracker/qubership-av-scan-service/pkg/router"
+	"github.com/netcracker/qubership-av-scan-service/pkg/testutils"
+)
+
+func dialWebSocket(t *testing.T, opts ...router.Option) *websocket.Conn {
+	t.Helper()
+	return dialWebSocketClamd(t, testutils.NewClamdMock(), opts...)
+}
+
+func dialWebSocketClamd(t *testing.T, clamd *testutils.ClamdMock, opts ...router.Option) *websocket.Conn {
+	t.Helper()
+	server := httptest.NewServer(router.NewRouter(clamd, slog.Default(), opts...))
+	t.Cleanup(server.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/api/v1/scan/ws", nil)
+	if err != nil {
+		t.Fatalf("failed to connect: %s", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func readWebSocketMessage(t *testing.T, conn *websocket.Conn) *handlers.WebSocketMessage {
+	t.Helper()
+	msg := &handlers.WebSocketMessage{}
+	if err := conn.ReadJSON(msg); err != nil {
+		t.Fatalf("failed to read message: %s", err)
+	}
+	return msg
+}
+
+// uploadWebSocketFile sends metadata and content in chunks, returning all received progress messages and result
+func uploadWebSocketFile(t *testing.T, conn *websocket.Conn, filename string, chunks ...string) ([]*handlers.WebSocketMessage, *handlers.WebSocketMessage) {
+	t.Helper()
+	size := 0
+	for _, chunk := range chunks {
+		size += len(chunk)
+	}
+	if err := conn.WriteJSON(handlers.WebSocketFile{Filename: filename, Size: int64(size)}); err != nil {
+		t.Fatalf("failed to send metadata: %s", err)
+	}
+
+	var progress []*handlers.WebSocketMessage
+	for _, chunk := range chunks {
+		if err := conn.WriteMessage(websocket.BinaryMessage, []byte(chunk)); err != nil {
+			t.Fatalf("failed to send chunk: %s", err)
+		}
+		msg := readWebSocketMessage(t, conn)
+		if msg.Type != handlers.WebSocketProgress {
+			return progress, msg
+		}
+		progress = append(progress, msg)
+	}
+	return progress, readWebSocketMessage(t, conn)
+}
+
+func TestWebSocketScan(t *testing.T) {
+	conn := dialWebSocket(t)
+
+	progress, result := uploadWebSocketFile(t, conn, "clean.txt", "clean ", "content")
+	if len(progress) != 2 || progress[0].Received != 6 || progress[1].Received != 13 || progress[1].Size != 13 {
+		t.Fatalf("unexpected progress messages: %+v", progress)
+	}
+	if result.Type != handlers.WebSocketResult || result.Status == nil || result.Status.Infected {
+		t.Fatalf("expected clean result, but got: %+v", result)
+	}
+
+	// the next file is uploaded over the same connection
+	eicar := testutils.EICARTest
+	_, result = uploadWebSocketFile(t, conn, "virus.txt", eicar[:30], eicar[30:])
+	if result.Type != handlers.WebSocketResult || !result.Status.Infected || result.Status.Verdict != handlers.VerdictInfected {
+		t.Fatalf("expected infected result, but got: %+v", result.Status)
+	}
+}
+
+func TestWebSocketFileSizeLimit(t *testing.T) {
+	conn := dialWebSocket(t, router.WithMaxWebSocketFileSize(10))
+
+	if err := conn.WriteJSON(handlers.WebSocketFile{Filename: "large.bin", Size: 11}); err != nil {
+		t.Fatalf("failed to send metadata: %s", err)
+	}
+	msg := readWebSocketMessage(t, conn)
+	if msg.Type != handlers.WebSocketError || msg.Error.Code != "AV-5020" {
+		t.Fatalf("expected AV-5020 error, but got: %+v", msg)
+	}
+	if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseMessageTooBig) {
+		t.Fatalf("expected connection to be closed, but got: %v", err)
+	}
+}
+
+func TestWebSocketLimitedByMaxFileSize(t *testing.T) {
+	conn := dialWebSocket(t, router.WithMaxFileSize(10))
+
+	if err := conn.WriteJSON(handlers.WebSocketFile{Filename: "large.bin", Size: 11}); err != nil {
+		t.Fatalf("failed to send metadata: %s", err)
+	}
+	msg := readWebSocketMessage(t, conn)
+	if msg.Type != handlers.WebSocketError || msg.Error.Code != "AV-5020" {
+		t.Fatalf("expected AV-5020 error, but got: %+v", msg)
+	}
+}
+
+func TestWebSocketProtocolErrors(t *testing.T) {
+	for name, send := range map[string]func(conn *websocket.Conn) error{
+		"content before metadata": func(conn *websocket.Conn) error {
+			return conn.WriteMessage(websocket.BinaryMessage, []byte("content"))
+		},
+		"content exceeding declared size": func(conn *websocket.Conn) error {
+			if err := conn.WriteJSON(handlers.WebSocketFile{Filename: "a.txt", Size: 3}); err != nil {
+				return err
+			}
+			return conn.WriteMessage(websocket.BinaryMessage, []byte("content"))
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			conn := dialWebSocket(t)
+			if err := send(conn); err != nil {
+				t.Fatalf("failed to send message: %s", err)
+			}
+			msg := readWebSocketMessage(t, conn)
+			if msg.Type != handlers.WebSocketError || msg.Error.Code != "AV-5021" {
+				t.Fatalf("expected AV-5021 error, but got: %+v", msg)
+			}
+		})
+	}
+}
+
+func TestWebSocketScanPanic(t *testing.T) {
+	conn := dialWebSocketClamd(t, testutils.NewClamdMock().WithPanic("scanner bug"))
+
+	_, result := uploadWebSocketFile(t, conn, "a.txt", "content")
+	if result.Type != handlers.WebSocketError || result.Error.Code != "AV-1900" {
+		t.Fatalf("expected AV-1900 error, but got: %+v", result)
+	}
+}
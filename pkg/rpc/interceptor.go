@@ -0,0 +1,156 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"time"
+
+	"github.com/netcracker/qubership-av-scan-service/pkg/errors"
+	"github.com/netcracker/qubership-av-scan-service/pkg/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/xid"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errorDomain is the domain of ErrorInfo attached to errors of pkg/errors
+const errorDomain = "av-scan-service"
+
+// metrics are collected for each gRPC method like HTTP metrics for each handler
+type metrics struct {
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	inflight *prometheus.GaugeVec
+}
+
+func newMetrics(registry *prometheus.Registry) *metrics {
+	return &metrics{
+		requests: promauto.With(registry).NewCounterVec(
+			prometheus.CounterOpts{Name: "grpc_requests_total"},
+			[]string{"method", "code"},
+		),
+		duration: promauto.With(registry).NewHistogramVec(
+			prometheus.HistogramOpts{Name: "grpc_request_duration_seconds"},
+			[]string{"method", "code"},
+		),
+		inflight: promauto.With(registry).NewGaugeVec(
+			prometheus.GaugeOpts{Name: "grpc_requests_inflight"},
+			[]string{"method"},
+		),
+	}
+}
+
+// unaryInterceptor logs, measures and recovers unary calls, converting returned errors to gRPC statuses
+func unaryInterceptor(logger *slog.Logger, m *metrics) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		ctx, done := observe(ctx, logger, m, info.FullMethod)
+		defer func() { err = done(err, recover()) }()
+		return handler(ctx, req)
+	}
+}
+
+// streamInterceptor logs, measures and recovers streaming calls, converting returned errors to gRPC statuses
+func streamInterceptor(logger *slog.Logger, m *metrics) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		ctx, done := observe(ss.Context(), logger, m, info.FullMethod)
+		defer func() { err = done(err, recover()) }()
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// observe saves logger with request fields in the context and returns function completing the call,
+// which converts error or recovered panic to gRPC status, logs the result and collects metrics
+func observe(ctx context.Context, logger *slog.Logger, m *metrics, method string) (context.Context, func(error, any) error) {
+	start := time.Now()
+	logger = logger.With("reqId", xid.New(), "method", method)
+	logger.Debug("request received")
+	m.inflight.WithLabelValues(method).Inc()
+
+	done := func(err error, recovered any) error {
+		m.inflight.WithLabelValues(method).Dec()
+		if recovered != nil {
+			logger.Error("handler panicked", "stacktrace", debug.Stack())
+			err = fmt.Errorf("%v", recovered)
+		}
+		err = statusError(ctx, err)
+
+		code := status.Code(err)
+		m.requests.WithLabelValues(method, code.String()).Inc()
+		m.duration.WithLabelValues(method, code.String()).Observe(time.Since(start).Seconds())
+		if err != nil {
+			logger.Error("request completed with error",
+				"code", code,
+				"error", err,
+				"duration", time.Since(start))
+		} else {
+			logger.Info("request completed successfully",
+				"code", code,
+				"duration", time.Since(start))
+		}
+		return err
+	}
+	return log.WithContext(ctx, logger), done
+}
+
+// statusError converts error to gRPC status error. Application code of APIError is attached as ErrorInfo reason,
+// errors which are not APIError are returned as UnexpectedError, unless call was cancelled by client.
+func statusError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return status.FromContextError(ctxErr).Err()
+	}
+
+	apiErr, ok := err.(*errors.APIError)
+	if !ok {
+		apiErr = errors.UnexpectedError(err)
+	}
+	msg := apiErr.Reason
+	if apiErr.Details != "" {
+		msg += ": " + apiErr.Details
+	}
+	st := status.New(grpcCode(apiErr), msg)
+	if detailed, err := st.WithDetails(&errdetails.ErrorInfo{Reason: apiErr.Code, Domain: errorDomain}); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// grpcCode maps APIError to gRPC status code by its HTTP status
+func grpcCode(apiErr *errors.APIError) codes.Code {
+	switch apiErr.Code {
+	case "AV-7100", "AV-7101":
+		// clamd is not available
+		return codes.Unavailable
+	}
+	switch apiErr.Status {
+	case 400, 415, 422:
+		return codes.InvalidArgument
+	case 403:
+		return codes.PermissionDenied
+	case 413:
+		return codes.ResourceExhausted
+	case 502, 503:
+		return codes.Unavailable
+	}
+	return codes.Internal
+}
+
+// serverStream overrides context of the stream with the one containing logger
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,208 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: scanpb/scan.proto
+
+package scanpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	ScanService_Scan_FullMethodName      = "/avscan.v1.ScanService/Scan"
+	ScanService_ScanBatch_FullMethodName = "/avscan.v1.ScanService/ScanBatch"
+	ScanService_Health_FullMethodName    = "/avscan.v1.ScanService/Health"
+)
+
+// ScanServiceClient is the client API for ScanService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// ScanService scans files for viruses with clamd.
+// Errors are returned with gRPC status codes, application error code is attached as google.rpc.ErrorInfo
+// with reason set to the code, e.g. AV-5001, and the same codes as in HTTP API.
+type ScanServiceClient interface {
+	// Scan scans a single file. The first message must contain file metadata, the following ones contain file content.
+	Scan(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[ScanRequest, ScanStatus], error)
+	// ScanBatch scans several files and streams back status of each file as soon as it is scanned.
+	ScanBatch(ctx context.Context, in *ScanBatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ScanStatus], error)
+	// Health verifies that clamd is ready to be used.
+	Health(ctx context.Context, in *HealthRequest, opts ...grpc.CallOption) (*HealthResponse, error)
+}
+
+type scanServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewScanServiceClient(cc grpc.ClientConnInterface) ScanServiceClient {
+	return &scanServiceClient{cc}
+}
+
+func (c *scanServiceClient) Scan(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[ScanRequest, ScanStatus], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ScanService_ServiceDesc.Streams[0], ScanService_Scan_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ScanRequest, ScanStatus]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ScanService_ScanClient = grpc.ClientStreamingClient[ScanRequest, ScanStatus]
+
+func (c *scanServiceClient) ScanBatch(ctx context.Context, in *ScanBatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ScanStatus], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ScanService_ServiceDesc.Streams[1], ScanService_ScanBatch_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ScanBatchRequest, ScanStatus]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ScanService_ScanBatchClient = grpc.ServerStreamingClient[ScanStatus]
+
+func (c *scanServiceClient) Health(ctx context.Context, in *HealthRequest, opts ...grpc.CallOption) (*HealthResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(HealthResponse)
+	err := c.cc.Invoke(ctx, ScanService_Health_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ScanServiceServer is the server API for ScanService service.
+// All implementations must embed UnimplementedScanServiceServer
+// for forward compatibility.
+//
+// ScanService scans files for viruses with clamd.
+// Errors are returned with gRPC status codes, application error code is attached as google.rpc.ErrorInfo
+// with reason set to the code, e.g. AV-5001, and the same codes as in HTTP API.
+type ScanServiceServer interface {
+	// Scan scans a single file. The first message must contain file metadata, the following ones contain file content.
+	Scan(grpc.ClientStreamingServer[ScanRequest, ScanStatus]) error
+	// ScanBatch scans several files and streams back status of each file as soon as it is scanned.
+	ScanBatch(*ScanBatchRequest, grpc.ServerStreamingServer[ScanStatus]) error
+	// Health verifies that clamd is ready to be used.
+	Health(context.Context, *HealthRequest) (*HealthResponse, error)
+	mustEmbedUnimplementedScanServiceServer()
+}
+
+// UnimplementedScanServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedScanServiceServer struct{}
+
+func (UnimplementedScanServiceServer) Scan(grpc.ClientStreamingServer[ScanRequest, ScanStatus]) error {
+	return status.Errorf(codes.Unimplemented, "method Scan not implemented")
+}
+func (UnimplementedScanServiceServer) ScanBatch(*ScanBatchRequest, grpc.ServerStreamingServer[ScanStatus]) error {
+	return status.Errorf(codes.Unimplemented, "method ScanBatch not implemented")
+}
+func (UnimplementedScanServiceServer) Health(context.Context, *HealthRequest) (*HealthResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Health not implemented")
+}
+func (UnimplementedScanServiceServer) mustEmbedUnimplementedScanServiceServer() {}
+func (UnimplementedScanServiceServer) testEmbeddedByValue()                     {}
+
+// UnsafeScanServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ScanServiceServer will
+// result in compilation errors.
+type UnsafeScanServiceServer interface {
+	mustEmbedUnimplementedScanServiceServer()
+}
+
+func RegisterScanServiceServer(s grpc.ServiceRegistrar, srv ScanServiceServer) {
+	// If the following call pancis, it indicates UnimplementedScanServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&ScanService_ServiceDesc, srv)
+}
+
+func _ScanService_Scan_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ScanServiceServer).Scan(&grpc.GenericServerStream[ScanRequest, ScanStatus]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ScanService_ScanServer = grpc.ClientStreamingServer[ScanRequest, ScanStatus]
+
+func _ScanService_ScanBatch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ScanBatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ScanServiceServer).ScanBatch(m, &grpc.GenericServerStream[ScanBatchRequest, ScanStatus]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ScanService_ScanBatchServer = grpc.ServerStreamingServer[ScanStatus]
+
+func _ScanService_Health_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HealthRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ScanServiceServer).Health(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ScanService_Health_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ScanServiceServer).Health(ctx, req.(*HealthRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// ScanService_ServiceDesc is the grpc.ServiceDesc for ScanService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ScanService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "avscan.v1.ScanService",
+	HandlerType: (*ScanServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Health",
+			Handler:    _ScanService_Health_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Scan",
+			Handler:       _ScanService_Scan_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "ScanBatch",
+			Handler:       _ScanService_ScanBatch_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "scanpb/scan.proto",
+}
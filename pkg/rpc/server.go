@@ -0,0 +1,167 @@
+package rpc
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative scanpb/scan.proto
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/netcracker/qubership-av-scan-service/pkg/clamav"
+	"github.com/netcracker/qubership-av-scan-service/pkg/errors"
+	"github.com/netcracker/qubership-av-scan-service/pkg/handlers"
+	"github.com/netcracker/qubership-av-scan-service/pkg/rpc/scanpb"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+)
+
+// maxMessageSize restricts size of a single received message, batch request contains content of all its files
+const maxMessageSize = 64 << 20
+
+// service implements ScanService with the same scanner as HTTP API
+type service struct {
+	scanpb.UnimplementedScanServiceServer
+	clamd   clamav.Clamd
+	scanner *handlers.ScanHandler
+}
+
+// NewServer returns gRPC server serving ScanService backed by given clamd.
+// Metrics are registered in the registry, so they could be exposed together with HTTP API metrics.
+// Errors of pkg/errors are returned with gRPC status codes, see statusError.
+func NewServer(clamd clamav.Clamd, registry *prometheus.Registry, logger *slog.Logger, opts handlers.ScanOptions) *grpc.Server {
+	if clamd == nil {
+		panic("gRPC server MUST be provided with ClamD instance")
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	m := newMetrics(registry)
+	srv := grpc.NewServer(
+		grpc.MaxRecvMsgSize(maxMessageSize),
+		grpc.ChainUnaryInterceptor(unaryInterceptor(logger, m)),
+		grpc.ChainStreamInterceptor(streamInterceptor(logger, m)),
+	)
+	scanpb.RegisterScanServiceServer(srv, &service{
+		clamd:   clamd,
+		scanner: handlers.NewScanHandler(clamd, registry, opts),
+	})
+	return srv
+}
+
+func (s *service) Scan(stream scanpb.ScanService_ScanServer) error {
+	first, err := stream.Recv()
+	if err == io.EOF {
+		return errors.FilenameNotSpecifiedError()
+	}
+	if err != nil {
+		return err
+	}
+	metadata := first.GetMetadata()
+	if metadata == nil || metadata.GetFilename() == "" {
+		return errors.FilenameNotSpecifiedError()
+	}
+
+	status, err := s.scanner.ScanFile(
+		stream.Context(),
+		metadata.GetFilename(),
+		metadata.GetContentType(),
+		&chunkReader{stream: stream},
+		metadata.GetDeep(),
+	)
+	if err != nil {
+		return err
+	}
+	return stream.SendAndClose(toProto(status))
+}
+
+func (s *service) ScanBatch(req *scanpb.ScanBatchRequest, stream scanpb.ScanService_ScanBatchServer) error {
+	for _, f := range req.GetFiles() {
+		if f.GetFilename() == "" {
+			return errors.FilenameNotSpecifiedError()
+		}
+	}
+
+	for _, f := range req.GetFiles() {
+		status, err := s.scanner.ScanFile(
+			stream.Context(),
+			f.GetFilename(),
+			f.GetContentType(),
+			bytes.NewReader(f.GetContent()),
+			req.GetDeep(),
+		)
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(toProto(status)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *service) Health(_ context.Context, _ *scanpb.HealthRequest) (*scanpb.HealthResponse, error) {
+	if err := s.clamd.Ping(); err != nil {
+		return nil, errors.ClamdPingError(err)
+	}
+	return &scanpb.HealthResponse{}, nil
+}
+
+// chunkReader reads file content from chunks of Scan stream until client closes sending
+type chunkReader struct {
+	stream scanpb.ScanService_ScanServer
+	chunk  []byte
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for len(r.chunk) == 0 {
+		req, err := r.stream.Recv()
+		if err != nil {
+			return 0, err
+		}
+		if req.GetMetadata() != nil {
+			return 0, fmt.Errorf("metadata must be sent only in the first message")
+		}
+		r.chunk = req.GetChunk()
+	}
+	n := copy(p, r.chunk)
+	r.chunk = r.chunk[n:]
+	return n, nil
+}
+
+var verdicts = map[handlers.Verdict]scanpb.Verdict{
+	handlers.VerdictClean:         scanpb.Verdict_VERDICT_CLEAN,
+	handlers.VerdictInfected:      scanpb.Verdict_VERDICT_INFECTED,
+	handlers.VerdictArchiveBomb:   scanpb.Verdict_VERDICT_ARCHIVE_BOMB,
+	handlers.VerdictPolicyBlocked: scanpb.Verdict_VERDICT_POLICY_BLOCKED,
+}
+
+// toProto converts scan status tree to its protobuf representation
+func toProto(status *handlers.ScanStatus) *scanpb.ScanStatus {
+	res := &scanpb.ScanStatus{
+		Filename:          status.Filename,
+		TransferEncoding:  status.TransferEncoding,
+		Decoded:           status.Decoded,
+		MimePath:          status.MimePath,
+		Infected:          status.Infected,
+		Virus:             status.Virus,
+		Encrypted:         status.Encrypted,
+		MimeType:          status.MimeType,
+		ExtensionMismatch: status.ExtensionMismatch,
+		Verdict:           verdicts[status.Verdict],
+		Reason:            status.Reason,
+	}
+	for _, finding := range status.Findings {
+		res.Findings = append(res.Findings, &scanpb.Finding{
+			Type:        string(finding.Type),
+			Location:    finding.Location,
+			Description: finding.Description,
+		})
+	}
+	for _, member := range status.Members {
+		res.Members = append(res.Members, toProto(member))
+	}
+	return res
+}
@@ -0,0 +1,165 @@
+package rpc_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+
+	"github.com/netcracker/qubership-av-scan-service/pkg/clamav"
+	"github.com/netcracker/qubership-av-scan-service/pkg/handlers"
+	"github.com/netcracker/qubership-av-scan-service/pkg/rpc"
+	"github.com/netcracker/qubership-av-scan-service/pkg/rpc/scanpb"
+	"github.com/netcracker/qubership-av-scan-service/pkg/testutils"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newClient(t *testing.T, clamd clamav.Clamd) scanpb.ScanServiceClient {
+	t.Helper()
+	srv := rpc.NewServer(clamd, prometheus.NewRegistry(), slog.Default(), handlers.ScanOptions{})
+	l := bufconn.Listen(1 << 20)
+	go srv.Serve(l)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient(
+		"passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return l.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client: %s", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return scanpb.NewScanServiceClient(conn)
+}
+
+func scan(t *testing.T, client scanpb.ScanServiceClient, metadata *scanpb.FileMetadata, chunks ...string) (*scanpb.ScanStatus, error) {
+	t.Helper()
+	stream, err := client.Scan(context.Background())
+	if err != nil {
+		t.Fatalf("failed to start scan: %s", err)
+	}
+	if metadata != nil {
+		req := &scanpb.ScanRequest{Payload: &scanpb.ScanRequest_Metadata{Metadata: metadata}}
+		if err := stream.Send(req); err != nil {
+			t.Fatalf("failed to send metadata: %s", err)
+		}
+	}
+	for _, chunk := range chunks {
+		req := &scanpb.ScanRequest{Payload: &scanpb.ScanRequest_Chunk{Chunk: []byte(chunk)}}
+		if err := stream.Send(req); err != nil && err != io.EOF {
+			t.Fatalf("failed to send chunk: %s", err)
+		}
+	}
+	return stream.CloseAndRecv()
+}
+
+func errorCode(err error) string {
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			return info.GetReason()
+		}
+	}
+	return ""
+}
+
+func TestScan(t *testing.T) {
+	client := newClient(t, testutils.NewClamdMock())
+
+	res, err := scan(t, client, &scanpb.FileMetadata{Filename: "clean.txt"}, "clean ", "content")
+	if err != nil {
+		t.Fatalf("expected file to be scanned, but got: %s", err)
+	}
+	if res.GetInfected() || res.GetVerdict() != scanpb.Verdict_VERDICT_CLEAN || res.GetFilename() != "clean.txt" {
+		t.Fatalf("unexpected status of clean file: %v", res)
+	}
+
+	// virus signature is split between chunks
+	eicar := testutils.EICARTest
+	res, err = scan(t, client, &scanpb.FileMetadata{Filename: "virus.txt"}, eicar[:20], eicar[20:])
+	if err != nil {
+		t.Fatalf("expected file to be scanned, but got: %s", err)
+	}
+	if !res.GetInfected() || res.GetVerdict() != scanpb.Verdict_VERDICT_INFECTED || res.GetVirus() == "" {
+		t.Fatalf("unexpected status of infected file: %v", res)
+	}
+}
+
+func TestScanWithoutMetadata(t *testing.T) {
+	client := newClient(t, testutils.NewClamdMock())
+
+	for name, metadata := range map[string]*scanpb.FileMetadata{
+		"no metadata":    nil,
+		"empty filename": {ContentType: "text/plain"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := scan(t, client, metadata, "content")
+			if status.Code(err) != codes.InvalidArgument || errorCode(err) != "AV-5001" {
+				t.Fatalf("expected InvalidArgument with AV-5001 code, but got: %v", err)
+			}
+		})
+	}
+}
+
+func TestScanUnavailableClamd(t *testing.T) {
+	client := newClient(t, testutils.NewClamdMock().WithUnhealthy("connection refused"))
+
+	_, err := scan(t, client, &scanpb.FileMetadata{Filename: "file.txt"}, "content")
+	if status.Code(err) != codes.Unavailable || errorCode(err) != "AV-7101" {
+		t.Fatalf("expected Unavailable with AV-7101 code, but got: %v", err)
+	}
+}
+
+func TestScanBatch(t *testing.T) {
+	client := newClient(t, testutils.NewClamdMock())
+
+	stream, err := client.ScanBatch(context.Background(), &scanpb.ScanBatchRequest{Files: []*scanpb.File{
+		{Filename: "clean.txt", Content: []byte("clean content")},
+		{Filename: "virus.txt", Content: []byte(testutils.EICARTest)},
+	}})
+	if err != nil {
+		t.Fatalf("failed to start batch scan: %s", err)
+	}
+	var statuses []*scanpb.ScanStatus
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("expected batch to be scanned, but got: %s", err)
+		}
+		statuses = append(statuses, res)
+	}
+
+	if len(statuses) != 2 {
+		t.Fatalf("expected 2 statuses, but got %d", len(statuses))
+	}
+	if statuses[0].GetFilename() != "clean.txt" || statuses[0].GetInfected() {
+		t.Fatalf("unexpected status of clean file: %v", statuses[0])
+	}
+	if statuses[1].GetFilename() != "virus.txt" || !statuses[1].GetInfected() {
+		t.Fatalf("unexpected status of infected file: %v", statuses[1])
+	}
+}
+
+func TestHealth(t *testing.T) {
+	if _, err := newClient(t, testutils.NewClamdMock()).Health(context.Background(), &scanpb.HealthRequest{}); err != nil {
+		t.Fatalf("expected service to be healthy, but got: %s", err)
+	}
+
+	client := newClient(t, testutils.NewClamdMock().WithUnhealthy("connection refused"))
+	_, err := client.Health(context.Background(), &scanpb.HealthRequest{})
+	if status.Code(err) != codes.Unavailable || errorCode(err) != "AV-7100" {
+		t.Fatalf("expected Unavailable with AV-7100 code, but got: %v", err)
+	}
+}
@@ -0,0 +1,169 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/tags"
+)
+
+// DefaultRegion is used when region of S3-compatible endpoint is not configured
+const DefaultRegion = "us-east-1"
+
+// maxTagValueLength is the maximum length of object tag value allowed by S3
+const maxTagValueLength = 256
+
+// Config contains settings of S3-compatible object storage connection
+type Config struct {
+	// Endpoint is the URL of S3-compatible storage, e.g. https://s3.amazonaws.com or http://minio:9000
+	Endpoint string
+	// Region is the region of the storage
+	Region string
+	// AccessKey is the access key ID, anonymous access is used if empty
+	AccessKey string
+	// SecretKey is the secret access key
+	SecretKey string
+	// SessionToken is an optional session token of temporary credentials
+	SessionToken string
+}
+
+// Object describes a stored object
+type Object struct {
+	// Key is the key of the object in the bucket
+	Key string
+	// Size is the size of the object in bytes
+	Size int64
+	// ETag is the entity tag of the object
+	ETag string
+	// VersionID identifies version of the object, it is empty if bucket is not versioned
+	VersionID string
+}
+
+// ErrObjectChanged is returned when object of not versioned bucket is overwritten after it was read
+var ErrObjectChanged = errors.New("object was changed after it was read")
+
+// LimitError is returned when listing returns more objects than allowed
+type LimitError struct {
+	Limit int
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("more than %d objects found", e.Limit)
+}
+
+// Client reads and tags objects of S3-compatible object storage
+type Client struct {
+	minio *minio.Client
+}
+
+func New(cfg Config) (*Client, error) {
+	u, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid endpoint: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid endpoint scheme %q, must be http or https", u.Scheme)
+	}
+	region := cfg.Region
+	if region == "" {
+		region = DefaultRegion
+	}
+	creds := credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, cfg.SessionToken)
+	client, err := minio.New(u.Host, &minio.Options{
+		Creds:  creds,
+		Secure: u.Scheme == "https",
+		Region: region,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Client{minio: client}, nil
+}
+
+// List returns objects of the bucket with keys starting with prefix in lexicographical order.
+// LimitError is returned if there are more than limit objects.
+func (c *Client) List(ctx context.Context, bucket string, prefix string, limit int) ([]Object, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	objects := make([]Object, 0)
+	for info := range c.minio.ListObjects(ctx, bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if info.Err != nil {
+			return nil, info.Err
+		}
+		// directory markers have no content
+		if strings.HasSuffix(info.Key, "/") && info.Size == 0 {
+			continue
+		}
+		if len(objects) == limit {
+			return nil, &LimitError{Limit: limit}
+		}
+		objects = append(objects, Object{Key: info.Key, Size: info.Size, ETag: info.ETag})
+	}
+	return objects, nil
+}
+
+// Get returns content of the object. Caller must close returned reader.
+func (c *Client) Get(ctx context.Context, bucket string, key string) (io.ReadCloser, Object, error) {
+	obj, err := c.minio.GetObject(ctx, bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, Object{}, err
+	}
+	info, err := obj.Stat()
+	if err != nil {
+		obj.Close()
+		return nil, Object{}, err
+	}
+	return obj, Object{Key: key, Size: info.Size, ETag: info.ETag, VersionID: info.VersionID}, nil
+}
+
+// Tag sets given tags on the object read by Get, other tags of the object are kept.
+// Characters not allowed in tag values are replaced with underscores.
+// Tags are set on the same version of the object which was read. If bucket is not versioned,
+// ErrObjectChanged is returned when the object was overwritten since it was read.
+func (c *Client) Tag(ctx context.Context, bucket string, obj Object, values map[string]string) error {
+	if obj.VersionID == "" {
+		info, err := c.minio.StatObject(ctx, bucket, obj.Key, minio.StatObjectOptions{})
+		if err != nil {
+			return err
+		}
+		if info.ETag != obj.ETag {
+			return ErrObjectChanged
+		}
+	}
+	current, err := c.minio.GetObjectTagging(ctx, bucket, obj.Key, minio.GetObjectTaggingOptions{VersionID: obj.VersionID})
+	if err != nil {
+		return err
+	}
+	merged := current.ToMap()
+	for k, v := range values {
+		merged[k] = tagValue(v)
+	}
+	t, err := tags.NewTags(merged, true)
+	if err != nil {
+		return err
+	}
+	return c.minio.PutObjectTagging(ctx, bucket, obj.Key, t, minio.PutObjectTaggingOptions{VersionID: obj.VersionID})
+}
+
+// tagValue replaces characters which are not allowed in tag values and truncates too long values
+func tagValue(v string) string {
+	if len(v) > maxTagValueLength {
+		v = v[:maxTagValueLength]
+	}
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case strings.ContainsRune("+-._:/@ =", r):
+			return r
+		}
+		return '_'
+	}, v)
+}
@@ -0,0 +1,123 @@
+package s3_test
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/netcracker/qubership-av-scan-service/pkg/s3"
+	"github.com/netcracker/qubership-av-scan-service/pkg/testutils"
+)
+
+func TestListAndGet(t *testing.T) {
+	storage := testutils.NewS3Mock().
+		Put("bucket", "dir/", "").
+		Put("bucket", "dir/a.txt", "a").
+		Put("bucket", "dir/b.txt", "bb")
+	defer storage.Close()
+
+	client, err := s3.New(s3.Config{Endpoint: storage.URL})
+	if err != nil {
+		t.Fatalf("expected client to be created, but failed: %s", err)
+	}
+	objects, err := client.List(context.Background(), "bucket", "dir/", 10)
+	if err != nil {
+		t.Fatalf("expected objects to be listed, but failed: %s", err)
+	}
+	if len(objects) != 2 || objects[0].Key != "dir/a.txt" || objects[1].Key != "dir/b.txt" || objects[1].Size != 2 {
+		t.Fatalf("unexpected objects: %+v", objects)
+	}
+
+	r, obj, err := client.Get(context.Background(), "bucket", "dir/b.txt")
+	if err != nil {
+		t.Fatalf("expected object to be read, but failed: %s", err)
+	}
+	defer r.Close()
+	content, err := io.ReadAll(r)
+	if err != nil || string(content) != "bb" || obj.Size != 2 || obj.ETag == "" {
+		t.Fatalf("unexpected object %+v with content %q: %v", obj, content, err)
+	}
+
+	if _, err := client.List(context.Background(), "bucket", "", 1); err == nil {
+		t.Fatal("expected limit error")
+	}
+}
+
+func TestTagKeepsExistingTags(t *testing.T) {
+	storage := testutils.NewS3Mock().Put("bucket", "a.txt", "a")
+	defer storage.Close()
+
+	client, err := s3.New(s3.Config{Endpoint: storage.URL})
+	if err != nil {
+		t.Fatalf("expected client to be created, but failed: %s", err)
+	}
+	r, obj, err := client.Get(context.Background(), "bucket", "a.txt")
+	if err != nil {
+		t.Fatalf("expected object to be read, but failed: %s", err)
+	}
+	r.Close()
+	if err := client.Tag(context.Background(), "bucket", obj, map[string]string{"owner": "team"}); err != nil {
+		t.Fatalf("expected object to be tagged, but failed: %s", err)
+	}
+	if err := client.Tag(context.Background(), "bucket", obj, map[string]string{"verdict": "bad (value)"}); err != nil {
+		t.Fatalf("expected object to be tagged, but failed: %s", err)
+	}
+
+	tags := storage.Tags("bucket", "a.txt")
+	if len(tags) != 2 || tags["owner"] != "team" || tags["verdict"] != "bad _value_" {
+		t.Fatalf("unexpected tags: %v", tags)
+	}
+}
+
+func TestTagChangedObject(t *testing.T) {
+	storage := testutils.NewS3Mock().Put("bucket", "a.txt", "a")
+	defer storage.Close()
+
+	client, err := s3.New(s3.Config{Endpoint: storage.URL})
+	if err != nil {
+		t.Fatalf("expected client to be created, but failed: %s", err)
+	}
+	r, obj, err := client.Get(context.Background(), "bucket", "a.txt")
+	if err != nil {
+		t.Fatalf("expected object to be read, but failed: %s", err)
+	}
+	r.Close()
+
+	storage.Put("bucket", "a.txt", "new content")
+	if err := client.Tag(context.Background(), "bucket", obj, map[string]string{"verdict": "clean"}); err != s3.ErrObjectChanged {
+		t.Fatalf("expected ErrObjectChanged, but got: %v", err)
+	}
+	if tags := storage.Tags("bucket", "a.txt"); len(tags) != 0 {
+		t.Fatalf("expected new content not to be tagged, but got: %v", tags)
+	}
+}
+
+func TestTagVersion(t *testing.T) {
+	storage := testutils.NewS3Mock().Versioned("bucket").Put("bucket", "a.txt", "a")
+	defer storage.Close()
+
+	client, err := s3.New(s3.Config{Endpoint: storage.URL})
+	if err != nil {
+		t.Fatalf("expected client to be created, but failed: %s", err)
+	}
+	r, obj, err := client.Get(context.Background(), "bucket", "a.txt")
+	if err != nil {
+		t.Fatalf("expected object to be read, but failed: %s", err)
+	}
+	r.Close()
+	if obj.VersionID == "" {
+		t.Fatalf("expected object version, but got: %+v", obj)
+	}
+
+	// scanned version is tagged even if a new version is put
+	storage.Put("bucket", "a.txt", "new content")
+	if err := client.Tag(context.Background(), "bucket", obj, map[string]string{"verdict": "clean"}); err != nil {
+		t.Fatalf("expected object to be tagged, but failed: %s", err)
+	}
+	if tags := storage.VersionTags(obj.VersionID); tags["verdict"] != "clean" {
+		t.Fatalf("expected scanned version to be tagged, but got: %v", tags)
+	}
+	if tags := storage.Tags("bucket", "a.txt"); len(tags) != 0 {
+		t.Fatalf("expected new version not to be tagged, but got: %v", tags)
+	}
+}
@@ -0,0 +1,87 @@
+package spool
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+)
+
+// File is a spooled content which supports random access.
+// Small content is kept in memory, while large content is written to a temporary file.
+type File struct {
+	r    io.ReaderAt
+	size int64
+	tmp  *os.File
+}
+
+// New reads r fully, keeping up to memLimit bytes in memory
+// and spooling the rest of content to a temporary file.
+// Returned File must be closed to remove the temporary file.
+func New(r io.Reader, memLimit int64) (*File, error) {
+	buf := &bytes.Buffer{}
+	n, err := io.Copy(buf, io.LimitReader(r, memLimit+1))
+	if err != nil {
+		return nil, err
+	}
+	if n <= memLimit {
+		return &File{r: bytes.NewReader(buf.Bytes()), size: n}, nil
+	}
+
+	tmp, err := os.CreateTemp("", "av-spool-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create spool file: %w", err)
+	}
+	f := &File{r: tmp, tmp: tmp}
+	f.size, err = io.Copy(tmp, io.MultiReader(buf, r))
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// FromBytes creates File from content which is already in memory
+func FromBytes(data []byte) *File {
+	return &File{r: bytes.NewReader(data), size: int64(len(data))}
+}
+
+// FromReaderAt creates File reading content of given size from r, e.g. from a file which is already on disk.
+// Closing returned File does not close r.
+func FromReaderAt(r io.ReaderAt, size int64) *File {
+	return &File{r: r, size: size}
+}
+
+// ReadAt implements io.ReaderAt
+func (f *File) ReadAt(p []byte, off int64) (int, error) {
+	return f.r.ReadAt(p, off)
+}
+
+// Size returns total size of spooled content
+func (f *File) Size() int64 {
+	return f.size
+}
+
+// Reader returns a new reader reading content from the beginning
+func (f *File) Reader() io.Reader {
+	return io.NewSectionReader(f.r, 0, f.size)
+}
+
+// Header returns up to n leading bytes of content
+func (f *File) Header(n int) ([]byte, error) {
+	header := make([]byte, min(int64(n), f.size))
+	_, err := f.r.ReadAt(header, 0)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return header, nil
+}
+
+// Close removes temporary file if content was spooled to disk
+func (f *File) Close() error {
+	if f.tmp == nil {
+		return nil
+	}
+	f.tmp.Close()
+	return os.Remove(f.tmp.Name())
+}
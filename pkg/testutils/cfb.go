@@ -0,0 +1,180 @@
+package testutils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+const (
+	cfbSectorSize     = 512
+	cfbDirEntrySize   = 128
+	cfbFreeSect       = 0xFFFFFFFF
+	cfbEndOfChain     = 0xFFFFFFFE
+	cfbFatSect        = 0xFFFFFFFD
+	cfbNoStream       = 0xFFFFFFFF
+	cfbMiniCutoff     = 4096
+	cfbMiniSectorSize = 64
+)
+
+// CompoundFile builds a minimal Compound File Binary (OLE2) document containing given streams.
+// Stream names may contain "/" separated storages, e.g. "Macros/VBA/dir".
+// Directory tree is not balanced, which is enough for testing purposes,
+// but is not a fully valid compound file.
+// Since only a single FAT sector is written, total size of streams is limited to ~60Kb.
+func CompoundFile(streams map[string][]byte) []byte {
+	names := make([]string, 0, len(streams))
+	storages := map[string]bool{}
+	for name := range streams {
+		names = append(names, name)
+		parts := strings.Split(name, "/")
+		for i := 1; i < len(parts); i++ {
+			storages[strings.Join(parts[:i], "/")] = true
+		}
+	}
+	sort.Strings(names)
+
+	type entry struct {
+		name    string
+		objType byte
+		data    []byte
+		start   uint32
+		size    int
+	}
+	entries := []*entry{{name: "Root Entry", objType: 5}}
+	for storage := range storages {
+		entries = append(entries, &entry{name: storage[strings.LastIndex(storage, "/")+1:], objType: 1})
+	}
+	for _, name := range names {
+		entries = append(entries, &entry{name: name[strings.LastIndex(name, "/")+1:], objType: 2, data: streams[name]})
+	}
+
+	// layout: FAT sector, directory sectors, mini FAT sector, mini stream sectors, regular stream sectors
+	fat := []uint32{cfbFatSect}
+	chain := func(count int) uint32 {
+		if count == 0 {
+			return cfbEndOfChain
+		}
+		start := uint32(len(fat))
+		for i := 0; i < count-1; i++ {
+			fat = append(fat, uint32(len(fat)+1))
+		}
+		fat = append(fat, cfbEndOfChain)
+		return start
+	}
+	sectors := func(size int, sectorSize int) int {
+		return (size + sectorSize - 1) / sectorSize
+	}
+	pad := func(b *bytes.Buffer, sectorSize int) {
+		b.Write(make([]byte, sectors(b.Len(), sectorSize)*sectorSize-b.Len()))
+	}
+
+	dirSectors := sectors(len(entries)*cfbDirEntrySize, cfbSectorSize)
+	dirStart := chain(dirSectors)
+
+	// small streams are stored in mini stream
+	var miniFat []uint32
+	ministream := &bytes.Buffer{}
+	for _, e := range entries {
+		if e.objType != 2 || len(e.data) >= cfbMiniCutoff {
+			continue
+		}
+		e.start, e.size = cfbEndOfChain, len(e.data)
+		count := sectors(len(e.data), cfbMiniSectorSize)
+		if count > 0 {
+			e.start = uint32(len(miniFat))
+			for i := 0; i < count-1; i++ {
+				miniFat = append(miniFat, uint32(len(miniFat)+1))
+			}
+			miniFat = append(miniFat, cfbEndOfChain)
+		}
+		ministream.Write(e.data)
+		pad(ministream, cfbMiniSectorSize)
+	}
+	miniFatStart := chain(sectors(len(miniFat)*4, cfbSectorSize))
+	entries[0].start = chain(sectors(ministream.Len(), cfbSectorSize))
+	entries[0].size = ministream.Len()
+	pad(ministream, cfbSectorSize)
+
+	content := &bytes.Buffer{}
+	for _, e := range entries {
+		if e.objType != 2 || len(e.data) < cfbMiniCutoff {
+			continue
+		}
+		e.start, e.size = chain(sectors(len(e.data), cfbSectorSize)), len(e.data)
+		content.Write(e.data)
+		pad(content, cfbSectorSize)
+	}
+
+	dir := &bytes.Buffer{}
+	for i, e := range entries {
+		raw := make([]byte, cfbDirEntrySize)
+		name := utf16.Encode([]rune(e.name))
+		for j, c := range name {
+			binary.LittleEndian.PutUint16(raw[j*2:], c)
+		}
+		binary.LittleEndian.PutUint16(raw[64:], uint16((len(name)+1)*2))
+		raw[66] = e.objType
+		raw[67] = 1 // black
+		binary.LittleEndian.PutUint32(raw[68:], cfbNoStream)
+		binary.LittleEndian.PutUint32(raw[72:], cfbNoStream)
+		binary.LittleEndian.PutUint32(raw[76:], cfbNoStream)
+		if i == 0 && len(entries) > 1 {
+			binary.LittleEndian.PutUint32(raw[76:], 1)
+		} else if i > 0 && i < len(entries)-1 {
+			binary.LittleEndian.PutUint32(raw[72:], uint32(i+1))
+		}
+		if e.objType != 1 {
+			binary.LittleEndian.PutUint32(raw[116:], e.start)
+			binary.LittleEndian.PutUint64(raw[120:], uint64(e.size))
+		}
+		dir.Write(raw)
+	}
+	pad(dir, cfbSectorSize)
+
+	miniFatData := &bytes.Buffer{}
+	for _, v := range miniFat {
+		binary.Write(miniFatData, binary.LittleEndian, v)
+	}
+	for miniFatData.Len()%cfbSectorSize != 0 {
+		binary.Write(miniFatData, binary.LittleEndian, uint32(cfbFreeSect))
+	}
+
+	header := make([]byte, cfbSectorSize)
+	copy(header, []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1})
+	binary.LittleEndian.PutUint16(header[0x18:], 0x3E)
+	binary.LittleEndian.PutUint16(header[0x1A:], 3)
+	binary.LittleEndian.PutUint16(header[0x1C:], 0xFFFE)
+	binary.LittleEndian.PutUint16(header[0x1E:], 9)
+	binary.LittleEndian.PutUint16(header[0x20:], 6)
+	binary.LittleEndian.PutUint32(header[0x2C:], 1)
+	binary.LittleEndian.PutUint32(header[0x30:], dirStart)
+	binary.LittleEndian.PutUint32(header[0x38:], cfbMiniCutoff)
+	binary.LittleEndian.PutUint32(header[0x3C:], miniFatStart)
+	binary.LittleEndian.PutUint32(header[0x40:], uint32(sectors(len(miniFat)*4, cfbSectorSize)))
+	binary.LittleEndian.PutUint32(header[0x44:], cfbEndOfChain)
+	for i := 0; i < 109; i++ {
+		binary.LittleEndian.PutUint32(header[0x4C+i*4:], cfbFreeSect)
+	}
+	binary.LittleEndian.PutUint32(header[0x4C:], 0)
+
+	fatSector := make([]byte, cfbSectorSize)
+	for i := range cfbSectorSize / 4 {
+		v := uint32(cfbFreeSect)
+		if i < len(fat) {
+			v = fat[i]
+		}
+		binary.LittleEndian.PutUint32(fatSector[i*4:], v)
+	}
+
+	out := &bytes.Buffer{}
+	out.Write(header)
+	out.Write(fatSector)
+	out.Write(dir.Bytes())
+	out.Write(miniFatData.Bytes())
+	out.Write(ministream.Bytes())
+	out.Write(content.Bytes())
+	return out.Bytes()
+}
@@ -18,6 +18,9 @@ const EICARTest = "X5O!P%@AP[4\\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FI
 type ClamdMock struct {
 	unhealthyReason string
 	virusSignatures []string
+	databaseAge     float64
+	databaseError   string
+	panicReason     string
 }
 
 func (c *ClamdMock) ScanStream(_ context.Context, r io.Reader) (clamav.ScanResult, error) {
@@ -29,6 +32,9 @@ func (c *ClamdMock) ScanStream(_ context.Context, r io.Reader) (clamav.ScanResul
 	if err != nil {
 		return clamav.ScanResult{}, fmt.Errorf("failed to read content: %s", err)
 	}
+	if c.panicReason != "" {
+		panic(c.panicReason)
+	}
 
 	for _, signature := range c.virusSignatures {
 		if strings.Contains(string(content), signature) {
@@ -47,7 +53,13 @@ func (c *ClamdMock) Ping() error {
 }
 
 func (c *ClamdMock) DatabaseAge() (float64, error) {
-	return 0, nil
+	if c.unhealthyReason != "" {
+		return 0, errors.New(c.unhealthyReason)
+	}
+	if c.databaseError != "" {
+		return 0, errors.New(c.databaseError)
+	}
+	return c.databaseAge, nil
 }
 
 func NewClamdMock() *ClamdMock {
@@ -60,3 +72,21 @@ func (c *ClamdMock) WithUnhealthy(reason string) *ClamdMock {
 	c.unhealthyReason = reason
 	return c
 }
+
+// WithDatabaseAge sets DB age in seconds returned by the mock
+func (c *ClamdMock) WithDatabaseAge(seconds float64) *ClamdMock {
+	c.databaseAge = seconds
+	return c
+}
+
+// WithDatabaseError makes the mock fail to report DB age while ping still succeeds
+func (c *ClamdMock) WithDatabaseError(reason string) *ClamdMock {
+	c.databaseError = reason
+	return c
+}
+
+// WithPanic makes the mock panic on scanning, as a bug in scanning pipeline would
+func (c *ClamdMock) WithPanic(reason string) *ClamdMock {
+	c.panicReason = reason
+	return c
+}
@@ -0,0 +1,226 @@
+package testutils
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// S3Mock is a minimal in-memory S3-compatible server supporting listing (v2), getting and tagging of objects.
+// Objects of versioned buckets keep all their versions, which can be tagged by version id.
+// Request signatures are not verified.
+type S3Mock struct {
+	*httptest.Server
+	mu        sync.Mutex
+	buckets   map[string]map[string]*s3MockObject
+	versioned map[string]bool
+	versions  map[string]*s3MockObject
+}
+
+type s3MockObject struct {
+	content []byte
+	tags    map[string]string
+	version string
+}
+
+type s3MockTagging struct {
+	XMLName xml.Name       `xml:"Tagging"`
+	Tags    []s3MockTagXML `xml:"TagSet>Tag"`
+}
+
+type s3MockTagXML struct {
+	Key   string `xml:"Key"`
+	Value string `xml:"Value"`
+}
+
+type s3MockContents struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int    `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+type s3MockListResult struct {
+	XMLName     xml.Name         `xml:"ListBucketResult"`
+	Name        string           `xml:"Name"`
+	Prefix      string           `xml:"Prefix"`
+	KeyCount    int              `xml:"KeyCount"`
+	MaxKeys     int              `xml:"MaxKeys"`
+	IsTruncated bool             `xml:"IsTruncated"`
+	Contents    []s3MockContents `xml:"Contents"`
+}
+
+type s3MockError struct {
+	XMLName xml.Name `xml:"Error"`
+	Code    string   `xml:"Code"`
+	Message string   `xml:"Message"`
+}
+
+const s3MockLastModified = "Mon, 02 Jan 2006 15:04:05 GMT"
+
+// NewS3Mock starts S3Mock server, it must be closed after use
+func NewS3Mock() *S3Mock {
+	m := &S3Mock{
+		buckets:   map[string]map[string]*s3MockObject{},
+		versioned: map[string]bool{},
+		versions:  map[string]*s3MockObject{},
+	}
+	m.Server = httptest.NewServer(http.HandlerFunc(m.serveHTTP))
+	return m
+}
+
+// Put stores the object in the bucket, bucket is created if it does not exist
+func (m *S3Mock) Put(bucket string, key string, content string) *S3Mock {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.buckets[bucket] == nil {
+		m.buckets[bucket] = map[string]*s3MockObject{}
+	}
+	obj := &s3MockObject{content: []byte(content), tags: map[string]string{}}
+	if m.versioned[bucket] {
+		obj.version = strconv.Itoa(len(m.versions) + 1)
+		m.versions[obj.version] = obj
+	}
+	m.buckets[bucket][key] = obj
+	return m
+}
+
+// Versioned enables versioning of the bucket, objects put after that keep their versions
+func (m *S3Mock) Versioned(bucket string) *S3Mock {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.versioned[bucket] = true
+	return m
+}
+
+// Tags returns tags of the object
+func (m *S3Mock) Tags(bucket string, key string) map[string]string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if obj := m.buckets[bucket][key]; obj != nil {
+		return obj.tags
+	}
+	return nil
+}
+
+// VersionTags returns tags of the object version
+func (m *S3Mock) VersionTags(version string) map[string]string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if obj := m.versions[version]; obj != nil {
+		return obj.tags
+	}
+	return nil
+}
+
+func (m *S3Mock) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	objects, ok := m.buckets[bucket]
+	if !ok {
+		writeS3MockError(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	if key == "" {
+		if r.Method != http.MethodGet || r.URL.Query().Get("list-type") != "2" {
+			writeS3MockError(w, http.StatusNotImplemented, "NotImplemented")
+			return
+		}
+		m.list(w, bucket, objects, r.URL.Query().Get("prefix"))
+		return
+	}
+
+	obj, ok := objects[key]
+	if !ok {
+		writeS3MockError(w, http.StatusNotFound, "NoSuchKey")
+		return
+	}
+	if version := r.URL.Query().Get("versionId"); version != "" {
+		if obj, ok = m.versions[version]; !ok {
+			writeS3MockError(w, http.StatusNotFound, "NoSuchVersion")
+			return
+		}
+	}
+	_, tagging := r.URL.Query()["tagging"]
+	switch {
+	case tagging && r.Method == http.MethodGet:
+		result := s3MockTagging{}
+		for k, v := range obj.tags {
+			result.Tags = append(result.Tags, s3MockTagXML{Key: k, Value: v})
+		}
+		writeS3MockXML(w, result)
+	case tagging && r.Method == http.MethodPut:
+		var request s3MockTagging
+		if err := xml.NewDecoder(r.Body).Decode(&request); err != nil {
+			writeS3MockError(w, http.StatusBadRequest, "MalformedXML")
+			return
+		}
+		obj.tags = map[string]string{}
+		for _, t := range request.Tags {
+			obj.tags[t.Key] = t.Value
+		}
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		w.Header().Set("Content-Length", strconv.Itoa(len(obj.content)))
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("ETag", s3MockETag(obj.content))
+		w.Header().Set("Last-Modified", s3MockLastModified)
+		if obj.version != "" {
+			w.Header().Set("x-amz-version-id", obj.version)
+		}
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			w.Write(obj.content)
+		}
+	default:
+		writeS3MockError(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+func (m *S3Mock) list(w http.ResponseWriter, bucket string, objects map[string]*s3MockObject, prefix string) {
+	keys := make([]string, 0, len(objects))
+	for key := range objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	result := s3MockListResult{Name: bucket, Prefix: prefix, KeyCount: len(keys), MaxKeys: 1000}
+	for _, key := range keys {
+		result.Contents = append(result.Contents, s3MockContents{
+			Key:          key,
+			LastModified: "2006-01-02T15:04:05.000Z",
+			ETag:         s3MockETag(objects[key].content),
+			Size:         len(objects[key].content),
+			StorageClass: "STANDARD",
+		})
+	}
+	writeS3MockXML(w, result)
+}
+
+func s3MockETag(content []byte) string {
+	sum := md5.Sum(content)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func writeS3MockXML(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/xml")
+	io.WriteString(w, xml.Header)
+	xml.NewEncoder(w).Encode(v)
+}
+
+func writeS3MockError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	xml.NewEncoder(w).Encode(s3MockError{Code: code, Message: code})
+}
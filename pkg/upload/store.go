@@ -0,0 +1,285 @@
+package upload
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultExpiration is the time after which uploads are removed if no explicit expiration is configured
+const DefaultExpiration = 24 * time.Hour
+
+// File extensions of upload content and info files in the store directory
+const (
+	dataExt = ".bin"
+	infoExt = ".json"
+)
+
+// idSize is the number of random bytes of upload ID. ID is the only credential of the upload,
+// so it must not be guessable.
+const idSize = 16
+
+// ErrNotFound is returned if upload does not exist or is expired
+var ErrNotFound = errors.New("upload not found")
+
+// ErrLocked is returned if upload is being modified by another request
+var ErrLocked = errors.New("upload is locked by another request")
+
+// OffsetError is returned if content is written at offset different from the current upload offset
+type OffsetError struct {
+	Offset int64
+}
+
+func (e *OffsetError) Error() string {
+	return fmt.Sprintf("upload offset is %d", e.Offset)
+}
+
+// LengthError is returned if written content exceeds upload length
+type LengthError struct {
+	Length int64
+}
+
+func (e *LengthError) Error() string {
+	return fmt.Sprintf("content exceeds upload length %d", e.Length)
+}
+
+// Info describes an upload
+type Info struct {
+	// ID identifies the upload, it is safe to use in file names and URLs
+	ID string `json:"id"`
+	// Length is the total size of the upload in bytes
+	Length int64 `json:"length"`
+	// Offset is the number of bytes received so far, it is taken from the size of content file
+	Offset int64 `json:"-"`
+	// Metadata contains arbitrary key-value pairs attached to the upload
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// ExpiresAt is the time when upload is removed
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// Complete returns true if all content of the upload is received
+func (i *Info) Complete() bool {
+	return i.Offset == i.Length
+}
+
+// Store keeps content of resumable uploads in files of a directory.
+// Content received by several requests is appended to the same file, so upload survives restarts of the service.
+// Expired uploads are removed when new uploads are created.
+type Store struct {
+	dir        string
+	expiration time.Duration
+
+	mu     sync.Mutex
+	locked map[string]bool
+}
+
+// New returns Store keeping uploads in given directory, directory is created if it does not exist
+func New(dir string, expiration time.Duration) (*Store, error) {
+	if expiration <= 0 {
+		expiration = DefaultExpiration
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create upload directory: %w", err)
+	}
+	return &Store{dir: dir, expiration: expiration, locked: make(map[string]bool)}, nil
+}
+
+// Create creates an empty upload of given length with given metadata
+func (s *Store) Create(length int64, metadata map[string]string) (*Info, error) {
+	s.removeExpired()
+
+	info := &Info{
+		ID:        newID(),
+		Length:    length,
+		Metadata:  metadata,
+		ExpiresAt: time.Now().Add(s.expiration).UTC().Truncate(time.Second),
+	}
+	f, err := os.OpenFile(s.path(info.ID, dataExt), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+	if err := s.writeInfo(info); err != nil {
+		os.Remove(s.path(info.ID, dataExt))
+		return nil, err
+	}
+	return info, nil
+}
+
+// Get returns info of the upload, ErrNotFound is returned if upload does not exist or is expired
+func (s *Store) Get(id string) (*Info, error) {
+	if !validID(id) {
+		return nil, ErrNotFound
+	}
+	data, err := os.ReadFile(s.path(id, infoExt))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	info := &Info{}
+	if err := json.Unmarshal(data, info); err != nil {
+		return nil, fmt.Errorf("malformed upload info: %w", err)
+	}
+	if time.Now().After(info.ExpiresAt) {
+		return nil, ErrNotFound
+	}
+
+	stat, err := os.Stat(s.path(id, dataExt))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	info.Offset = stat.Size()
+	return info, nil
+}
+
+// Lock prevents concurrent modification of the upload by several requests.
+// Returned function must be called to release the lock, ErrLocked is returned if upload is already locked.
+func (s *Store) Lock(id string) (func(), error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.locked[id] {
+		return nil, ErrLocked
+	}
+	s.locked[id] = true
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.locked, id)
+	}, nil
+}
+
+// Write appends content read from r to the upload, the upload must be locked by caller.
+// OffsetError is returned if offset does not match the current upload offset.
+// Content received before read error is kept, so returned info is valid even if error is returned.
+func (s *Store) Write(id string, offset int64, r io.Reader) (*Info, error) {
+	info, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if offset != info.Offset {
+		return nil, &OffsetError{Offset: info.Offset}
+	}
+
+	f, err := os.OpenFile(s.path(id, dataExt), os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	n, err := io.Copy(f, io.LimitReader(r, info.Length-info.Offset))
+	info.Offset += n
+	if err != nil {
+		return info, err
+	}
+	// content after declared length is not accepted
+	if extra, _ := r.Read(make([]byte, 1)); extra > 0 {
+		return info, &LengthError{Length: info.Length}
+	}
+	return info, nil
+}
+
+// SetMetadata replaces metadata of the upload, the upload must be locked by caller
+func (s *Store) SetMetadata(id string, metadata map[string]string) (*Info, error) {
+	info, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	info.Metadata = metadata
+	if err := s.writeInfo(info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// Open opens content file of the upload for reading
+func (s *Store) Open(id string) (*os.File, error) {
+	if _, err := s.Get(id); err != nil {
+		return nil, err
+	}
+	return os.Open(s.path(id, dataExt))
+}
+
+// Delete removes the upload, the upload must be locked by caller
+func (s *Store) Delete(id string) error {
+	if _, err := s.Get(id); err != nil {
+		return err
+	}
+	s.remove(id)
+	return nil
+}
+
+// writeInfo saves upload info atomically, so readers never see partially written file
+func (s *Store) writeInfo(info *Info) error {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	tmp := s.path(info.ID, infoExt+".tmp")
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path(info.ID, infoExt))
+}
+
+// removeExpired removes all uploads which are expired and not locked
+func (s *Store) removeExpired() {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), infoExt)
+		if !ok {
+			continue
+		}
+		if _, err := s.Get(id); err != ErrNotFound {
+			continue
+		}
+		if unlock, err := s.Lock(id); err == nil {
+			s.remove(id)
+			unlock()
+		}
+	}
+}
+
+func (s *Store) remove(id string) {
+	os.Remove(s.path(id, dataExt))
+	os.Remove(s.path(id, infoExt))
+}
+
+// newID returns random upload ID encoded as lowercase hex
+func newID() string {
+	id := make([]byte, idSize)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// validID returns true if id has the format of IDs returned by newID, so it is safe to use in file names
+func validID(id string) bool {
+	if len(id) != hex.EncodedLen(idSize) {
+		return false
+	}
+	for _, c := range id {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Store) path(id string, ext string) string {
+	return filepath.Join(s.dir, id+ext)
+}
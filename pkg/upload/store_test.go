@@ -0,0 +1,102 @@
+package upload_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/netcracker/qubership-av-scan-service/pkg/upload"
+)
+
+func TestWriteResumedAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	store, err := upload.New(dir, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create store: %s", err)
+	}
+	info, err := store.Create(10, map[string]string{"filename": "a.txt"})
+	if err != nil {
+		t.Fatalf("failed to create upload: %s", err)
+	}
+	if _, err := store.Write(info.ID, 0, strings.NewReader("01234")); err != nil {
+		t.Fatalf("failed to write content: %s", err)
+	}
+
+	// content and metadata are kept on disk
+	store, err = upload.New(dir, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create store: %s", err)
+	}
+	if _, err := store.Write(info.ID, 0, strings.NewReader("01234")); err == nil {
+		t.Fatalf("expected offset error, but got nil")
+	} else if offsetErr, ok := err.(*upload.OffsetError); !ok || offsetErr.Offset != 5 {
+		t.Fatalf("expected offset error with offset 5, but got: %v", err)
+	}
+	info, err = store.Write(info.ID, 5, strings.NewReader("56789"))
+	if err != nil {
+		t.Fatalf("failed to write content: %s", err)
+	}
+	if !info.Complete() || info.Metadata["filename"] != "a.txt" {
+		t.Fatalf("unexpected upload info: %+v", info)
+	}
+}
+
+func TestLock(t *testing.T) {
+	store, err := upload.New(t.TempDir(), time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create store: %s", err)
+	}
+	unlock, err := store.Lock("id")
+	if err != nil {
+		t.Fatalf("failed to lock upload: %s", err)
+	}
+	if _, err := store.Lock("id"); err != upload.ErrLocked {
+		t.Fatalf("expected ErrLocked, but got: %v", err)
+	}
+	unlock()
+	if _, err := store.Lock("id"); err != nil {
+		t.Fatalf("expected upload to be unlocked, but got: %v", err)
+	}
+}
+
+func TestExpiredUploadRemoved(t *testing.T) {
+	store, err := upload.New(t.TempDir(), time.Second)
+	if err != nil {
+		t.Fatalf("failed to create store: %s", err)
+	}
+	info, err := store.Create(10, nil)
+	if err != nil {
+		t.Fatalf("failed to create upload: %s", err)
+	}
+	time.Sleep(1100 * time.Millisecond)
+	if _, err := store.Get(info.ID); err != upload.ErrNotFound {
+		t.Fatalf("expected ErrNotFound, but got: %v", err)
+	}
+	for _, id := range []string{"../" + info.ID, "unknown"} {
+		if _, err := store.Get(id); err != upload.ErrNotFound {
+			t.Fatalf("expected ErrNotFound for %q, but got: %v", id, err)
+		}
+	}
+}
+
+func TestUploadID(t *testing.T) {
+	store, err := upload.New(t.TempDir(), time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create store: %s", err)
+	}
+	first, err := store.Create(10, nil)
+	if err != nil {
+		t.Fatalf("failed to create upload: %s", err)
+	}
+	second, err := store.Create(10, nil)
+	if err != nil {
+		t.Fatalf("failed to create upload: %s", err)
+	}
+	// 128 random bits
+	if len(first.ID) != 32 || first.ID[:8] == second.ID[:8] {
+		t.Fatalf("expected random IDs, but got %s and %s", first.ID, second.ID)
+	}
+	if _, err := store.Get(strings.ToUpper(first.ID)); err != upload.ErrNotFound {
+		t.Fatalf("expected ErrNotFound for ID in upper case, but got: %v", err)
+	}
+}
@@ -0,0 +1,312 @@
+package watch
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/netcracker/qubership-av-scan-service/pkg/clamav"
+	"github.com/rs/xid"
+)
+
+// DefaultSettleTime is the default time a file must stay unchanged to be considered fully written
+const DefaultSettleTime = 2 * time.Second
+
+// sidecarExt is appended to the name of moved file to get the name of its verdict file
+const sidecarExt = ".json"
+
+// Options configures directories and timings of Watcher
+type Options struct {
+	// Inbox is the directory watched for new files
+	Inbox string
+	// CleanDir is the directory clean files are moved to
+	CleanDir string
+	// QuarantineDir is the directory infected files are moved to
+	QuarantineDir string
+	// SettleTime is the time a file must keep its size and modification time to be considered fully written
+	SettleTime time.Duration
+}
+
+// Verdict is written as JSON sidecar next to each moved file
+type Verdict struct {
+	// Filename is the name of the file in the inbox
+	Filename string `json:"filename"`
+	// Path is the path of the file after it was moved
+	Path string `json:"path"`
+	// Size is the size of the file in bytes
+	Size int64 `json:"size"`
+	// SHA256 is the hex-encoded SHA-256 digest of the file content
+	SHA256 string `json:"sha256"`
+	// Infected is true if virus was found
+	Infected bool `json:"infected"`
+	// Virus is a string representing found virus, set only if Infected
+	Virus string `json:"virus,omitempty"`
+	// ScannedAt is the time the file was scanned
+	ScannedAt time.Time `json:"scannedAt"`
+}
+
+// pendingFile is a file in the inbox which is possibly still being written
+type pendingFile struct {
+	size    int64
+	modTime time.Time
+	// changed is the time the file was last seen changing
+	changed time.Time
+}
+
+// Watcher watches the inbox directory, scans each new file once it is fully written
+// and moves it into clean or quarantine directory with a JSON sidecar verdict.
+// Files are considered fully written when their size and modification time do not change for SettleTime.
+// Hidden files, e.g. temporary files of uploading tools, and directories are ignored.
+type Watcher struct {
+	opts     Options
+	clamd    clamav.Clamd
+	logger   *slog.Logger
+	watcher  *fsnotify.Watcher
+	pending  map[string]*pendingFile
+	watching chan bool
+	stop     sync.Once
+}
+
+func New(clamd clamav.Clamd, opts Options, logger *slog.Logger) (*Watcher, error) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if opts.SettleTime <= 0 {
+		opts.SettleTime = DefaultSettleTime
+	}
+
+	var err error
+	for _, dir := range []*string{&opts.Inbox, &opts.CleanDir, &opts.QuarantineDir} {
+		if *dir, err = filepath.Abs(*dir); err != nil {
+			return nil, err
+		}
+	}
+	info, err := os.Stat(opts.Inbox)
+	if err != nil {
+		return nil, fmt.Errorf("can't access inbox: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("inbox %s is not a directory", opts.Inbox)
+	}
+	for _, dir := range []string{opts.CleanDir, opts.QuarantineDir} {
+		if err := os.MkdirAll(dir, 0o750); err != nil {
+			return nil, fmt.Errorf("can't create output directory: %w", err)
+		}
+	}
+
+	return &Watcher{
+		opts:     opts,
+		clamd:    clamd,
+		logger:   logger,
+		pending:  map[string]*pendingFile{},
+		watching: make(chan bool),
+	}, nil
+}
+
+// Watch processes files already present in the inbox and then watches it for new files until Stop is called
+func (w *Watcher) Watch() error {
+	var err error
+
+	if w.watcher, err = fsnotify.NewWatcher(); err != nil {
+		return fmt.Errorf("can't create watcher for inbox: %w", err)
+	}
+	defer w.watcher.Close()
+
+	if err = w.watcher.Add(w.opts.Inbox); err != nil {
+		return fmt.Errorf("can't watch inbox: %w", err)
+	}
+
+	entries, err := os.ReadDir(w.opts.Inbox)
+	if err != nil {
+		return fmt.Errorf("can't read inbox: %w", err)
+	}
+	for _, entry := range entries {
+		w.touch(filepath.Join(w.opts.Inbox, entry.Name()))
+	}
+
+	w.logger.Info("watching inbox", "inbox", w.opts.Inbox)
+	w.run()
+	return nil
+}
+
+func (w *Watcher) run() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ticker := time.NewTicker(w.opts.SettleTime / 4)
+	defer ticker.Stop()
+
+loop:
+	for {
+		select {
+		case <-w.watching:
+			break loop
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				break loop
+			}
+			if event.Op.Has(fsnotify.Create) || event.Op.Has(fsnotify.Write) || event.Op.Has(fsnotify.Chmod) {
+				w.touch(event.Name)
+			}
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				break loop
+			}
+			w.logger.Error("error watching inbox", "error", err)
+		case <-ticker.C:
+			w.processSettled(ctx)
+		}
+	}
+
+	w.logger.Info("stopped inbox watching")
+}
+
+// Stop stops watching, file being processed is finished first
+func (w *Watcher) Stop() {
+	w.stop.Do(func() {
+		close(w.watching)
+	})
+}
+
+// touch registers a change of the file in the inbox
+func (w *Watcher) touch(path string) {
+	if strings.HasPrefix(filepath.Base(path), ".") {
+		return
+	}
+	if _, ok := w.pending[path]; !ok {
+		w.pending[path] = &pendingFile{size: -1}
+	}
+	w.pending[path].changed = time.Now()
+}
+
+// processSettled scans and moves pending files which have not changed for SettleTime
+func (w *Watcher) processSettled(ctx context.Context) {
+	for path, p := range w.pending {
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			// file was removed or moved away, or it is not a regular file
+			delete(w.pending, path)
+			continue
+		}
+		if info.Size() != p.size || !info.ModTime().Equal(p.modTime) {
+			p.size, p.modTime, p.changed = info.Size(), info.ModTime(), time.Now()
+			continue
+		}
+		if time.Since(p.changed) < w.opts.SettleTime {
+			continue
+		}
+
+		if err := w.process(ctx, path); err != nil {
+			// file is kept in the inbox and retried after SettleTime
+			w.logger.Error("failed to process file", "file", path, "error", err)
+			p.changed = time.Now()
+			continue
+		}
+		delete(w.pending, path)
+	}
+}
+
+// process scans the file and moves it with its verdict into clean or quarantine directory
+func (w *Watcher) process(ctx context.Context, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	size, err := io.Copy(hash, f)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	result, err := w.clamd.ScanStream(ctx, f)
+	if err != nil {
+		return err
+	}
+
+	dir := w.opts.CleanDir
+	if result.Infected {
+		dir = w.opts.QuarantineDir
+	}
+	dest := destination(dir, filepath.Base(path))
+	verdict := Verdict{
+		Filename:  filepath.Base(path),
+		Path:      dest,
+		Size:      size,
+		SHA256:    hex.EncodeToString(hash.Sum(nil)),
+		Infected:  result.Infected,
+		Virus:     result.VirusDescription,
+		ScannedAt: time.Now().UTC(),
+	}
+	sidecar, err := json.MarshalIndent(verdict, "", "  ")
+	if err != nil {
+		return err
+	}
+	// sidecar is written first, so consumers of the output directories always find it next to the file
+	if err := os.WriteFile(dest+sidecarExt, sidecar, 0o640); err != nil {
+		return fmt.Errorf("can't write verdict: %w", err)
+	}
+	if err := move(path, dest); err != nil {
+		os.Remove(dest + sidecarExt)
+		return fmt.Errorf("can't move file: %w", err)
+	}
+
+	if result.Infected {
+		w.logger.Warn("virus detected", "virus", result.VirusDescription, "file", path, "destination", dest)
+	} else {
+		w.logger.Info("file is clean", "file", path, "destination", dest)
+	}
+	return nil
+}
+
+// destination returns path in the directory for the file, which does not overwrite existing files
+func destination(dir string, name string) string {
+	dest := filepath.Join(dir, name)
+	if _, err := os.Lstat(dest); errors.Is(err, os.ErrNotExist) {
+		return dest
+	}
+	ext := filepath.Ext(name)
+	return filepath.Join(dir, strings.TrimSuffix(name, ext)+"-"+xid.New().String()+ext)
+}
+
+// move renames the file, falling back to copying if directories are on different file systems
+func move(src string, dest string) error {
+	err := os.Rename(src, dest)
+	if !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o640)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dest)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dest)
+		return err
+	}
+	return os.Remove(src)
+}
@@ -0,0 +1,127 @@
+package watch_test
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/netcracker/qubership-av-scan-service/pkg/testutils"
+	"github.com/netcracker/qubership-av-scan-service/pkg/watch"
+)
+
+func TestWatch(t *testing.T) {
+	inbox := t.TempDir()
+	opts := watch.Options{
+		Inbox:         inbox,
+		CleanDir:      filepath.Join(inbox, "clean"),
+		QuarantineDir: filepath.Join(inbox, "quarantine"),
+		SettleTime:    100 * time.Millisecond,
+	}
+	// file present before watching is processed too
+	writeFile(t, filepath.Join(inbox, "existing.txt"), "clean content")
+
+	watcher, err := watch.New(testutils.NewClamdMock(), opts, slog.Default())
+	if err != nil {
+		t.Fatalf("expected watcher to be created, but failed: %s", err)
+	}
+	done := make(chan error)
+	go func() {
+		done <- watcher.Watch()
+	}()
+	defer func() {
+		watcher.Stop()
+		if err := <-done; err != nil {
+			t.Errorf("expected watching to stop without error, but got: %s", err)
+		}
+	}()
+
+	writeFile(t, filepath.Join(inbox, "virus.txt"), testutils.EICARTest)
+	writeFile(t, filepath.Join(inbox, ".upload.tmp"), testutils.EICARTest)
+
+	clean := waitVerdict(t, filepath.Join(opts.CleanDir, "existing.txt"))
+	if clean.Infected || clean.Filename != "existing.txt" || clean.Size != int64(len("clean content")) {
+		t.Fatalf("unexpected verdict of clean file: %+v", clean)
+	}
+	infected := waitVerdict(t, filepath.Join(opts.QuarantineDir, "virus.txt"))
+	if !infected.Infected || infected.Virus == "" || infected.SHA256 == "" {
+		t.Fatalf("unexpected verdict of infected file: %+v", infected)
+	}
+
+	for _, name := range []string{"existing.txt", "virus.txt"} {
+		if _, err := os.Stat(filepath.Join(inbox, name)); !os.IsNotExist(err) {
+			t.Fatalf("expected %s to be moved from inbox, but got: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(inbox, ".upload.tmp")); err != nil {
+		t.Fatalf("expected hidden file to be ignored, but got: %s", err)
+	}
+}
+
+func TestWatchKeepsExistingFiles(t *testing.T) {
+	inbox := t.TempDir()
+	opts := watch.Options{
+		Inbox:         inbox,
+		CleanDir:      filepath.Join(inbox, "clean"),
+		QuarantineDir: filepath.Join(inbox, "quarantine"),
+		SettleTime:    100 * time.Millisecond,
+	}
+	if err := os.MkdirAll(opts.CleanDir, 0o750); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(opts.CleanDir, "a.txt"), "previous")
+	writeFile(t, filepath.Join(inbox, "a.txt"), "next")
+
+	watcher, err := watch.New(testutils.NewClamdMock(), opts, slog.Default())
+	if err != nil {
+		t.Fatalf("expected watcher to be created, but failed: %s", err)
+	}
+	go watcher.Watch()
+	defer watcher.Stop()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		matches, _ := filepath.Glob(filepath.Join(opts.CleanDir, "a-*.txt"))
+		if len(matches) == 1 {
+			if content, _ := os.ReadFile(filepath.Join(opts.CleanDir, "a.txt")); string(content) != "previous" {
+				t.Fatalf("expected existing file to be kept, but got: %q", content)
+			}
+			waitVerdict(t, matches[0])
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatal("expected file to be moved under unique name")
+}
+
+func writeFile(t *testing.T, path string, content string) {
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write %s: %s", path, err)
+	}
+}
+
+// waitVerdict waits until the file and its sidecar appear and returns the verdict
+func waitVerdict(t *testing.T, path string) watch.Verdict {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, err := os.Stat(path); err == nil {
+			content, err := os.ReadFile(path + ".json")
+			if err != nil {
+				t.Fatalf("expected verdict of %s, but failed: %s", path, err)
+			}
+			var verdict watch.Verdict
+			if err := json.Unmarshal(content, &verdict); err != nil {
+				t.Fatalf("expected valid verdict of %s, but failed: %s", path, err)
+			}
+			if verdict.Path != path {
+				t.Fatalf("expected verdict path %s, but got: %s", path, verdict.Path)
+			}
+			return verdict
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("expected %s to appear", path)
+	return watch.Verdict{}
+}
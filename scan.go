@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/netcracker/qubership-av-scan-service/pkg/client"
+	"github.com/netcracker/qubership-av-scan-service/pkg/localscan"
+	"github.com/netcracker/qubership-av-scan-service/pkg/report"
+	"github.com/spf13/cobra"
+)
+
+var scanCmd = &cobra.Command{
+	Use:   "scan <paths...>",
+	Short: "Scan local files with running service",
+	Long: "Uploads files and files of directories (recursively) to the service at --server URL " +
+		"and prints results. Bearer token for the service is taken from AV_SCAN_TOKEN environment variable. " +
+		"Exits with 1 if any file is not clean, and with 2 if any file could not be scanned",
+	Args: cobra.MinimumNArgs(1),
+	Run:  RunScan,
+}
+
+func init() {
+	scanCmd.Flags().String("server", "http://localhost:8080", "URL of the service")
+	scanCmd.Flags().String("ca-file", "", "File with CA certificates used to verify https service, system CAs are used if empty")
+	scanCmd.Flags().Bool("insecure", false, "Skip verification of https service certificate")
+	scanCmd.Flags().Duration("timeout", client.DefaultTimeout, "Timeout of scanning a single file")
+	scanCmd.Flags().Int("retries", client.DefaultMaxRetries, "Number of retries of requests failed with 5xx status or network error")
+	scanCmd.Flags().Bool("deep", false, "Expand archives and scan each member separately")
+	addOutputFlag(scanCmd)
+	rootCmd.AddCommand(scanCmd)
+}
+
+// addOutputFlag adds flag selecting format of scanning results
+func addOutputFlag(cmd *cobra.Command) {
+	cmd.Flags().StringP("output", "o", report.Text.Name, "Output format: text, json, sarif or junit")
+}
+
+func RunScan(cmd *cobra.Command, args []string) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	output := ParseOutputFromArgs(cmd, logger)
+	c := ParseClientFromArgs(cmd, logger)
+
+	var results []*report.ScanResult
+	err := localscan.Walk(ctx, args, localscan.Filter{}, func(path string, err error) {
+		result := &report.ScanResult{Path: path}
+		if err == nil {
+			result.Status, err = c.ScanFile(ctx, path)
+		}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	})
+	if err != nil {
+		logger.Error("scan interrupted", "error", err)
+		os.Exit(exitError)
+	}
+
+	if err := output(os.Stdout, results); err != nil {
+		logger.Error("failed to write result", "error", err)
+		os.Exit(exitError)
+	}
+	os.Exit(exitCode(results))
+}
+
+// ParseClientFromArgs creates client of the service configured by cli arguments and environment
+func ParseClientFromArgs(cmd *cobra.Command, logger *slog.Logger) *client.Client {
+	server, err := cmd.Flags().GetString("server")
+	if err != nil {
+		logger.Error("failed to get server", "error", err)
+		os.Exit(exitError)
+	}
+	timeout, err := cmd.Flags().GetDuration("timeout")
+	if err != nil {
+		logger.Error("failed to get timeout", "error", err)
+		os.Exit(exitError)
+	}
+	retries, err := cmd.Flags().GetInt("retries")
+	if err != nil {
+		logger.Error("failed to get retries", "error", err)
+		os.Exit(exitError)
+	}
+	deep, err := cmd.Flags().GetBool("deep")
+	if err != nil {
+		logger.Error("failed to get deep", "error", err)
+		os.Exit(exitError)
+	}
+
+	opts := []client.Option{
+		client.WithHTTPClient(&http.Client{Timeout: timeout}),
+		client.WithTLSConfig(ParseClientTLSConfigFromArgs(cmd, logger)),
+		client.WithRetries(retries, client.DefaultBackoff),
+		client.WithDeepScan(deep),
+	}
+	if token := os.Getenv("AV_SCAN_TOKEN"); token != "" {
+		opts = append(opts, client.WithBearerToken(token))
+	}
+	c, err := client.New(server, opts...)
+	if err != nil {
+		logger.Error("failed to create client", "error", err)
+		os.Exit(exitError)
+	}
+	return c
+}
+
+// ParseClientTLSConfigFromArgs returns TLS config verifying the service certificate with configured CAs
+func ParseClientTLSConfigFromArgs(cmd *cobra.Command, logger *slog.Logger) *tls.Config {
+	caFile, err := cmd.Flags().GetString("ca-file")
+	if err != nil {
+		logger.Error("failed to get ca file", "error", err)
+		os.Exit(exitError)
+	}
+	insecure, err := cmd.Flags().GetBool("insecure")
+	if err != nil {
+		logger.Error("failed to get insecure", "error", err)
+		os.Exit(exitError)
+	}
+
+	cfg := &tls.Config{InsecureSkipVerify: insecure}
+	if caFile != "" {
+		data, err := os.ReadFile(caFile)
+		if err != nil {
+			logger.Error("failed to read ca file", "error", err)
+			os.Exit(exitError)
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(data) {
+			logger.Error("no certificates found in ca file", "file", caFile)
+			os.Exit(exitError)
+		}
+	}
+	return cfg
+}
+
+// ParseOutputFromArgs returns formatter of scanning results selected by cli arguments
+func ParseOutputFromArgs(cmd *cobra.Command, logger *slog.Logger) func(io.Writer, []*report.ScanResult) error {
+	output, err := cmd.Flags().GetString("output")
+	if err != nil {
+		logger.Error("failed to get output", "error", err)
+		os.Exit(exitError)
+	}
+	if format, ok := report.FormatByName(output); ok {
+		return format.Write
+	}
+	logger.Error("invalid output format", "output", output)
+	os.Exit(exitError)
+	return nil
+}
+
+// exitCode returns exitError if any file could not be scanned, exitDetected if any file is not clean, and 0 otherwise
+func exitCode(results []*report.ScanResult) int {
+	summary := report.Summarize(results)
+	switch {
+	case summary.Errors > 0:
+		return exitError
+	case summary.Detected > 0:
+		return exitDetected
+	}
+	return 0
+}
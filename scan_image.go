@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/netcracker/qubership-av-scan-service/pkg/clamav"
+	"github.com/netcracker/qubership-av-scan-service/pkg/handlers"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spf13/cobra"
+)
+
+// Exit codes of scanning commands follow clamscan convention
+const (
+	exitDetected = 1
+	exitError    = 2
+)
+
+var scanImageCmd = &cobra.Command{
+	Use:   "scan-image <tarball>",
+	Short: "Scan container image tarball",
+	Long: "Scans each file of each layer of `docker save` or OCI layout tarball (optionally gzip-compressed) " +
+		"using clamd and prints result as JSON. Size of tarball and layer files and number of layer files " +
+		"are restricted by --image-max-size and --image-max-members. " +
+		"Exits with 1 if image is not clean, and with 2 on error",
+	Args: cobra.ExactArgs(1),
+	Run:  RunScanImage,
+}
+
+func init() {
+	addScanFlags(scanImageCmd)
+	rootCmd.AddCommand(scanImageCmd)
+}
+
+func RunScanImage(cmd *cobra.Command, args []string) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		logger.Error("failed to open image tarball", "error", err)
+		os.Exit(exitError)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		logger.Error("failed to get image tarball info", "error", err)
+		os.Exit(exitError)
+	}
+
+	scanner := handlers.NewScanHandler(clamav.NewClamD(), prometheus.NewRegistry(), ParseScanOptionsFromArgs(cmd, logger))
+	result, err := handlers.NewImageHandler(scanner).ScanImage(ctx, info.Name(), f, info.Size())
+	if err != nil {
+		logger.Error("failed to scan image", "error", err)
+		os.Exit(exitError)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(result); err != nil {
+		logger.Error("failed to write result", "error", err)
+		os.Exit(exitError)
+	}
+	if result.Verdict != handlers.VerdictClean {
+		os.Exit(exitDetected)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/netcracker/qubership-av-scan-service/pkg/clamav"
+	"github.com/netcracker/qubership-av-scan-service/pkg/handlers"
+	"github.com/netcracker/qubership-av-scan-service/pkg/localscan"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spf13/cobra"
+)
+
+var scanLocalCmd = &cobra.Command{
+	Use:   "scan-local <paths...>",
+	Short: "Scan local files directly with clamd",
+	Long: "Scans files and files of directories (recursively) using clamd without running the service " +
+		"and prints results with summary. Files are selected by --include and --exclude glob patterns matching " +
+		"either file name or path relative to the given directory. " +
+		"Exits with 1 if any file is not clean, and with 2 if any file could not be scanned or any pattern is malformed",
+	Args: cobra.MinimumNArgs(1),
+	Run:  RunScanLocal,
+}
+
+func init() {
+	addScanFlags(scanLocalCmd)
+	scanLocalCmd.Flags().StringSlice("include", nil, "Glob patterns of files to scan, e.g. *.jar,*.zip. All files are scanned if empty")
+	scanLocalCmd.Flags().StringSlice("exclude", nil, "Glob patterns of files and directories to skip, e.g. .git,node_modules")
+	scanLocalCmd.Flags().Int("concurrency", localscan.DefaultConcurrency, "Number of files scanned in parallel")
+	scanLocalCmd.Flags().Bool("deep", false, "Expand archives and scan each member separately")
+	addOutputFlag(scanLocalCmd)
+	rootCmd.AddCommand(scanLocalCmd)
+}
+
+func RunScanLocal(cmd *cobra.Command, args []string) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	output := ParseOutputFromArgs(cmd, logger)
+	var opts localscan.Options
+	var err error
+	if opts.Filter.Include, err = cmd.Flags().GetStringSlice("include"); err != nil {
+		logger.Error("failed to get include", "error", err)
+		os.Exit(exitError)
+	}
+	if opts.Filter.Exclude, err = cmd.Flags().GetStringSlice("exclude"); err != nil {
+		logger.Error("failed to get exclude", "error", err)
+		os.Exit(exitError)
+	}
+	if err := opts.Filter.Validate(); err != nil {
+		logger.Error("failed to parse include or exclude", "error", err)
+		os.Exit(exitError)
+	}
+	if opts.Concurrency, err = cmd.Flags().GetInt("concurrency"); err != nil {
+		logger.Error("failed to get concurrency", "error", err)
+		os.Exit(exitError)
+	}
+	if opts.Deep, err = cmd.Flags().GetBool("deep"); err != nil {
+		logger.Error("failed to get deep", "error", err)
+		os.Exit(exitError)
+	}
+
+	scanner := handlers.NewScanHandler(clamav.NewClamD(), prometheus.NewRegistry(), ParseScanOptionsFromArgs(cmd, logger))
+	results, err := localscan.New(scanner, opts).Scan(ctx, args)
+	if err != nil {
+		logger.Error("scan interrupted", "error", err)
+		os.Exit(exitError)
+	}
+
+	if err := output(os.Stdout, results); err != nil {
+		logger.Error("failed to write result", "error", err)
+		os.Exit(exitError)
+	}
+	os.Exit(exitCode(results))
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/netcracker/qubership-av-scan-service/pkg/clamav"
+	"github.com/netcracker/qubership-av-scan-service/pkg/handlers"
+	"github.com/netcracker/qubership-av-scan-service/pkg/s3"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spf13/cobra"
+)
+
+var scanS3Cmd = &cobra.Command{
+	Use:   "scan-s3 <bucket>",
+	Short: "Scan objects of S3-compatible storage",
+	Long: "Scans the object selected by --key or all objects selected by --prefix from S3-compatible storage " +
+		"using clamd and prints results per key as JSON. Credentials are taken from AWS_ACCESS_KEY_ID, " +
+		"AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment variables. " +
+		"Exits with 1 if any object is not clean, and with 2 on error or if any object is not scanned, e.g. it exceeds --max-file-size",
+	Args: cobra.ExactArgs(1),
+	Run:  RunScanS3,
+}
+
+func init() {
+	addS3Flags(scanS3Cmd)
+	addScanFlags(scanS3Cmd)
+	scanS3Cmd.Flags().String("key", "", "Key of a single object to scan")
+	scanS3Cmd.Flags().String("prefix", "", "Prefix of keys of objects to scan, the whole bucket is scanned if both key and prefix are empty")
+	scanS3Cmd.Flags().Bool("tag", false, "Tag scanned objects with verdict")
+	scanS3Cmd.Flags().Int("max-objects", handlers.DefaultMaxObjects, "Max number of objects scanned by prefix")
+	rootCmd.AddCommand(scanS3Cmd)
+}
+
+func RunScanS3(cmd *cobra.Command, args []string) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cfg := ParseS3ConfigFromArgs(cmd, logger)
+	if cfg.Endpoint == "" {
+		logger.Error("s3 endpoint is not specified")
+		os.Exit(exitError)
+	}
+	client, err := s3.New(cfg)
+	if err != nil {
+		logger.Error("failed to create s3 client", "error", err)
+		os.Exit(exitError)
+	}
+
+	scanReq := handlers.ObjectScanRequest{Bucket: args[0]}
+	if scanReq.Key, err = cmd.Flags().GetString("key"); err != nil {
+		logger.Error("failed to get key", "error", err)
+		os.Exit(exitError)
+	}
+	if scanReq.Prefix, err = cmd.Flags().GetString("prefix"); err != nil {
+		logger.Error("failed to get prefix", "error", err)
+		os.Exit(exitError)
+	}
+	if scanReq.Tag, err = cmd.Flags().GetBool("tag"); err != nil {
+		logger.Error("failed to get tag", "error", err)
+		os.Exit(exitError)
+	}
+	maxObjects, err := cmd.Flags().GetInt("max-objects")
+	if err != nil {
+		logger.Error("failed to get max objects", "error", err)
+		os.Exit(exitError)
+	}
+
+	scanner := handlers.NewScanHandler(clamav.NewClamD(), prometheus.NewRegistry(), ParseScanOptionsFromArgs(cmd, logger))
+	results, err := handlers.NewObjectScanHandler(scanner, client, maxObjects).ScanObjects(ctx, scanReq)
+	if err != nil {
+		logger.Error("failed to scan objects", "error", err)
+		os.Exit(exitError)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(results); err != nil {
+		logger.Error("failed to write result", "error", err)
+		os.Exit(exitError)
+	}
+	code := 0
+	for _, result := range results {
+		switch {
+		case result.Error != nil:
+			code = exitError
+		case result.Status.Verdict != handlers.VerdictClean && code == 0:
+			code = exitDetected
+		}
+	}
+	if code != 0 {
+		os.Exit(code)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/netcracker/qubership-av-scan-service/pkg/clamav"
+	"github.com/netcracker/qubership-av-scan-service/pkg/watch"
+	"github.com/oklog/run"
+	"github.com/spf13/cobra"
+)
+
+var watchCmd = &cobra.Command{
+	Use:   "watch <inbox>",
+	Short: "Scan files dropped into directory",
+	Long: "Watches inbox directory, scans each file using clamd once it is fully written, and moves it into " +
+		"clean or quarantine directory together with <name>.json verdict file. " +
+		"Hidden files are ignored, so uploading tools should use them for incomplete files",
+	Args: cobra.ExactArgs(1),
+	Run:  RunWatch,
+}
+
+func init() {
+	watchCmd.Flags().String("clean-dir", "", "Directory clean files are moved to (default <inbox>/clean)")
+	watchCmd.Flags().String("quarantine-dir", "", "Directory infected files are moved to (default <inbox>/quarantine)")
+	watchCmd.Flags().Duration("settle-time", watch.DefaultSettleTime, "Time a file must stay unchanged to be considered fully written")
+	rootCmd.AddCommand(watchCmd)
+}
+
+// ParseWatchOptionsFromArgs parses directories and timings of watch mode from cli arguments
+func ParseWatchOptionsFromArgs(cmd *cobra.Command, args []string, logger *slog.Logger) watch.Options {
+	opts := watch.Options{Inbox: args[0]}
+	var err error
+	if opts.CleanDir, err = cmd.Flags().GetString("clean-dir"); err != nil {
+		logger.Error("failed to get clean dir", "error", err)
+		os.Exit(1)
+	}
+	if opts.CleanDir == "" {
+		opts.CleanDir = filepath.Join(opts.Inbox, "clean")
+	}
+	if opts.QuarantineDir, err = cmd.Flags().GetString("quarantine-dir"); err != nil {
+		logger.Error("failed to get quarantine dir", "error", err)
+		os.Exit(1)
+	}
+	if opts.QuarantineDir == "" {
+		opts.QuarantineDir = filepath.Join(opts.Inbox, "quarantine")
+	}
+	if opts.SettleTime, err = cmd.Flags().GetDuration("settle-time"); err != nil {
+		logger.Error("failed to get settle time", "error", err)
+		os.Exit(1)
+	}
+	return opts
+}
+
+func RunWatch(cmd *cobra.Command, args []string) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	slog.SetDefault(logger)
+	logger.Info("started antivirus watch mode")
+
+	watcher, err := watch.New(clamav.NewClamD(), ParseWatchOptionsFromArgs(cmd, args, logger), logger)
+	if err != nil {
+		logger.Error("error creating inbox watcher", "error", err)
+		os.Exit(1)
+	}
+
+	var gr run.Group
+	gr.Add(run.SignalHandler(context.Background(), os.Interrupt, syscall.SIGINT, syscall.SIGTERM))
+	gr.Add(func() error {
+		return watcher.Watch()
+	}, func(err error) {
+		watcher.Stop()
+	})
+
+	if err := gr.Run(); err != nil {
+		logger.Info("terminating...", "reason", err)
+	}
+}